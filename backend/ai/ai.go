@@ -1,4 +1,4 @@
-// Package ai 实现基于大模型的意图识别与解析逻辑。
+// Package ai 实现基于大模型的意图识别与解析逻辑，模型后端可在 Ark 与 OpenAI 兼容接口间切换。
 package ai
 
 import (
@@ -6,7 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -14,197 +15,361 @@ import (
 	"smartcalendar/config"
 	"smartcalendar/model"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
 	"github.com/cloudwego/eino/schema"
 )
 
 // Proposal 表示经过模型解析后的结构化日程意图。
 type Proposal struct {
-	Action              string     `json:"action"`
-	Title               string     `json:"title"`
-	Type                string     `json:"type"`
-	StartTime           *time.Time `json:"start_time"`
-	EndTime             *time.Time `json:"end_time"`
-	Location            string     `json:"location"`
-	Description         string     `json:"description"`
-	ParticipantKeywords []string   `json:"participant_keywords"`
-	ParticipantIDs      []uint     `json:"participant_ids"`
-	EventID             *uint      `json:"event_id"`
-	TargetTime          *time.Time `json:"target_time"`
-	TargetKeywords      []string   `json:"target_keywords"`
+	Action              string              `json:"action"`
+	Title               string              `json:"title"`
+	Type                string              `json:"type"`
+	StartTime           *time.Time          `json:"start_time"`
+	EndTime             *time.Time          `json:"end_time"`
+	Location            string              `json:"location"`
+	Description         string              `json:"description"`
+	ParticipantKeywords []string            `json:"participant_keywords"`
+	ParticipantIDs      []uint              `json:"participant_ids"`
+	ParticipantChoices  []ParticipantChoice `json:"participant_choices"` // 匹配到多个用户、需在确认时选择的参与人
+	EventID             *uint               `json:"event_id"`
+	TargetTime          *time.Time          `json:"target_time"`
+	TargetKeywords      []string            `json:"target_keywords"`
+	DurationMinutes     int                 `json:"duration_minutes"` // schedule：会议时长
+	Slots               []SlotOption        `json:"slots"`            // schedule：推荐的候选时段，由业务层计算
 }
 
-// ParseResult 表示模型解析后返回给业务层的结果。
+// SlotOption 表示 schedule 提案中可供选择的一个时段。
+type SlotOption struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// ParseResult 表示模型解析后返回给业务层的结果，一条用户消息可解析出多个结果。
 type ParseResult struct {
-	Intent      string
-	Proposal    Proposal
-	NeedConfirm bool
-	Result      string
+	Intent         string
+	Proposal       Proposal
+	NeedConfirm    bool
+	Result         string
+	AmendConfirmID string // 修正待确认提案时为被修正提案的 confirm_id
+	Parser         string // 解析来源：model / rules
+}
+
+// StoredProposal 表示会话中待确认的提案。
+type StoredProposal struct {
+	ConfirmID string
+	Proposal  Proposal
+	ExpiresAt time.Time
 }
 
-// AIService 负责调用 Ark 模型进行意图识别与结构化解析。
+// AIService 负责调用大模型进行意图识别与结构化解析。
 type AIService struct {
 	cfg       config.AppConfig
-	model     *ark.ChatModel
-	modelOnce sync.Once
-	modelErr  error
-	pending   sync.Map
+	proposals ProposalStore
+
+	providerMu       sync.Mutex
+	provider         ChatProvider
+	providerErr      error
+	providerFailures int       // 连续创建失败的次数
+	providerRetryAt  time.Time // 创建失败后下次重试的时间
 }
 
-// NewAIService 初始化 AI 服务并保留模型配置。
+// 模型后端创建失败后的重试间隔，按连续失败次数指数增长。
+const (
+	providerRetryBase = 5 * time.Second
+	providerRetryMax  = 5 * time.Minute
+)
+
+// NewAIService 初始化 AI 服务并保留模型配置，模型后端在首次使用时按 AI_PROVIDER 创建。
 func NewAIService(cfg config.AppConfig) *AIService {
-	return &AIService{cfg: cfg}
+	return &AIService{cfg: cfg, proposals: newProposalStore(cfg)}
+}
+
+// NewAIServiceWithProvider 使用指定的模型后端初始化 AI 服务，如 ScriptedProvider。
+func NewAIServiceWithProvider(cfg config.AppConfig, provider ChatProvider) *AIService {
+	return &AIService{cfg: cfg, provider: provider, proposals: newProposalStore(cfg)}
+}
+
+// SetProposalStore 替换待确认提案的存储，需在处理请求前调用。
+func (a *AIService) SetProposalStore(store ProposalStore) {
+	a.proposals = store
+}
+
+// newProposalStore 按配置创建默认的数据库提案存储。
+func newProposalStore(cfg config.AppConfig) ProposalStore {
+	return NewDBProposalStore(time.Duration(cfg.AIProposalTTLMinutes) * time.Minute)
 }
 
-// ParseMessage 将用户输入发送给大模型并解析意图与字段。
-func (a *AIService) ParseMessage(message string) (ParseResult, error) {
+// ParseMessage 将会话历史与用户输入连同日程工具发送给大模型，按模型的工具调用逐个解析意图与字段。
+// 一条消息可包含多个操作（如“取消3点的会，把5点的改到6点”），每个工具调用对应一个结果；
+// user 提供用户的时区、语言区域、工作时间与近期日历，模型据此换算相对时间，输出的时间统一转换到用户时区；
+// history 超出 token 预算时只保留最近的部分；pending 为会话中待确认的提案，模型可通过 amend_proposal 修正其中之一。
+// 未配置模型时使用规则解析，模型调用失败时记录日志并回退到规则解析，结果的 Parser 标明实际使用的解析方式。
+func (a *AIService) ParseMessage(user UserContext, history []Turn, pending []StoredProposal, message string) ([]ParseResult, error) {
 	ctx := context.Background()
-	chatModel, err := a.getModel(ctx)
+	provider, err := a.getProvider(ctx)
 	if err != nil {
-		return ParseResult{}, err
+		return ParseRules(user, pending, message), nil
+	}
+	messages, err := a.buildMessages(user, history, pending, message)
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now().Format(time.RFC3339)
-	systemPrompt := buildSystemPrompt()
-	userPrompt := fmt.Sprintf("当前时间：%s\n用户输入：%s", now, message)
-
-	resp, err := chatModel.Generate(ctx, []*schema.Message{
-		{Role: schema.System, Content: systemPrompt},
-		{Role: schema.User, Content: userPrompt},
-	})
+	resp, err := provider.Generate(ctx, messages, scheduleTools())
 	if err != nil {
-		return ParseResult{}, err
+		log.Printf("[ai] model call failed, falling back to rules: %v", err)
+		return ParseRules(user, pending, message), nil
 	}
+	return modelResults(user, pending, resp), nil
+}
 
-	intent, err := parseIntent(resp.Content)
+// ParseMessageStream 与 ParseMessage 相同，但通过 Eino 的 Stream 接口调用模型，
+// 模型输出的文本片段到达时依次交给 onDelta，全部片段拼接后再解析工具调用；
+// 后端不支持流式输出时整体作为一个片段。流式调用或读取中途失败时同样回退到规则解析，
+// 若此前已交出文本片段，先调用 onReset 通知调用方作废这些片段。
+func (a *AIService) ParseMessageStream(user UserContext, history []Turn, pending []StoredProposal, message string, onDelta func(string), onReset func()) ([]ParseResult, error) {
+	ctx := context.Background()
+	provider, err := a.getProvider(ctx)
+	if err != nil {
+		return ParseRules(user, pending, message), nil
+	}
+	messages, err := a.buildMessages(user, history, pending, message)
 	if err != nil {
-		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "意图解析失败，请换一种表达"}, nil
+		return nil, err
 	}
 
-	proposal := buildProposal(intent)
-	result := formatResult(proposal)
-	return result, nil
+	streamed := false
+	resp, err := readStream(ctx, provider, messages, func(delta string) {
+		streamed = true
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	})
+	if err != nil {
+		log.Printf("[ai] model stream failed, falling back to rules: %v", err)
+		if streamed && onReset != nil {
+			onReset()
+		}
+		return ParseRules(user, pending, message), nil
+	}
+	return modelResults(user, pending, resp), nil
 }
 
-// StoreProposal 缓存待确认的 Proposal，返回 confirm_id。
-func (a *AIService) StoreProposal(proposal Proposal) string {
-	confirmID := fmt.Sprintf("c_%d", time.Now().UnixNano())
-	a.pending.Store(confirmID, proposal)
-	return confirmID
+// readStream 流式调用模型并读取全部片段，返回拼接后的完整回复。
+func readStream(ctx context.Context, provider ChatProvider, messages []*schema.Message, onDelta func(string)) (*schema.Message, error) {
+	stream, err := streamReply(ctx, provider, messages, scheduleTools())
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	var chunks []*schema.Message
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk == nil {
+			continue
+		}
+		if chunk.Content != "" && onDelta != nil {
+			onDelta(chunk.Content)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		return &schema.Message{Role: schema.Assistant}, nil
+	}
+	return schema.ConcatMessages(chunks)
 }
 
-// ConsumeProposal 取出并删除缓存的 Proposal。
-func (a *AIService) ConsumeProposal(confirmID string) (Proposal, bool) {
-	value, ok := a.pending.Load(confirmID)
-	if !ok {
-		return Proposal{}, false
+// buildMessages 组装发送给模型的消息：系统提示词、预算内的历史消息，以及附带用户上下文与待确认提案的本轮输入。
+func (a *AIService) buildMessages(user UserContext, history []Turn, pending []StoredProposal, message string) ([]*schema.Message, error) {
+	userPrompt := buildGroundingPrompt(user)
+	if len(pending) > 0 {
+		items := make([]pendingPrompt, 0, len(pending))
+		for _, item := range pending {
+			items = append(items, pendingPrompt{ConfirmID: item.ConfirmID, Proposal: item.Proposal})
+		}
+		body, err := json.Marshal(items)
+		if err != nil {
+			return nil, err
+		}
+		userPrompt += fmt.Sprintf("待确认的操作：%s\n", body)
 	}
-	a.pending.Delete(confirmID)
-	return value.(Proposal), true
-}
+	userPrompt += "用户输入：" + message
 
-// getModel 构建或复用 Ark ChatModel。
-func (a *AIService) getModel(ctx context.Context) (*ark.ChatModel, error) {
-	a.modelOnce.Do(func() {
-		if a.cfg.ArkModelID == "" {
-			a.modelErr = errors.New("ARK_MODEL_ID 未配置")
-			return
+	messages := []*schema.Message{{Role: schema.System, Content: buildSystemPrompt()}}
+	for _, turn := range trimHistory(history, a.cfg.AIHistoryTokenBudget) {
+		role := schema.User
+		if turn.Role == model.AIRoleAssistant {
+			role = schema.Assistant
 		}
-		if a.cfg.ArkAPIKey == "" && (a.cfg.ArkAccessKey == "" || a.cfg.ArkSecretKey == "") {
-			a.modelErr = errors.New("ARK_API_KEY 或 ARK_ACCESS_KEY/ARK_SECRET_KEY 未配置")
-			return
+		messages = append(messages, &schema.Message{Role: role, Content: turn.Content})
+	}
+	messages = append(messages, &schema.Message{Role: schema.User, Content: userPrompt})
+	return messages, nil
+}
+
+// modelResults 将模型回复转换为解析结果：无工具调用时以文本作答，否则每个工具调用对应一个结果。
+func modelResults(user UserContext, pending []StoredProposal, resp *schema.Message) []ParseResult {
+	if len(resp.ToolCalls) == 0 {
+		text := strings.TrimSpace(resp.Content)
+		if text == "" {
+			text = "暂仅支持创建、修改、删除和查询日程"
 		}
-		a.model, a.modelErr = ark.NewChatModel(ctx, &ark.ChatModelConfig{
-			APIKey:    a.cfg.ArkAPIKey,
-			AccessKey: a.cfg.ArkAccessKey,
-			SecretKey: a.cfg.ArkSecretKey,
-			Model:     a.cfg.ArkModelID,
-			BaseURL:   a.cfg.ArkBaseURL,
-			Region:    a.cfg.ArkRegion,
-		})
-	})
-	return a.model, a.modelErr
+		return []ParseResult{{Intent: "unknown", NeedConfirm: false, Result: text, Parser: ParserModel}}
+	}
+	now := user.localNow()
+	results := make([]ParseResult, 0, len(resp.ToolCalls))
+	for _, call := range resp.ToolCalls {
+		result := parseToolCall(call, pending, now)
+		result.Parser = ParserModel
+		results = append(results, result)
+	}
+	resolveResultParticipants(user.UserID, results)
+	return results
 }
 
-// intentPayload 为大模型输出的 JSON 结构。
-type intentPayload struct {
-	Action              string   `json:"action"`
-	Title               string   `json:"title"`
-	Type                string   `json:"type"`
-	StartTime           string   `json:"start_time"`
-	EndTime             string   `json:"end_time"`
-	Location            string   `json:"location"`
-	Description         string   `json:"description"`
-	ParticipantKeywords []string `json:"participant_keywords"`
-	EventID             string   `json:"event_id"`
-	TargetTime          string   `json:"target_time"`
-	TargetKeywords      []string `json:"target_keywords"`
+// pendingPrompt 为提示词中待确认提案的结构。
+type pendingPrompt struct {
+	ConfirmID string   `json:"confirm_id"`
+	Proposal  Proposal `json:"proposal"`
 }
 
-// buildSystemPrompt 约束大模型输出为 JSON。
-func buildSystemPrompt() string {
-	return strings.TrimSpace(`你是智能日程助手，请根据用户输入识别意图并输出JSON。
-如果没有结束时间，默认开始时间后1小时；如果没有开始时间，默认结束时间前1小时。
-只输出JSON，不要输出任何解释或markdown。
-JSON字段:
-action: "create" | "update" | "delete" | "unknown"
-title: 日程标题
-type: "work" | "life" | "growth"
-start_time: RFC3339
-end_time: RFC3339
-location: 地点
-description: 描述
-participant_keywords: 参与人关键词数组
-event_id: 如果用户指定了具体ID则填写
-target_time: 需要修改/删除的原日程时间(RFC3339)
-target_keywords: 用于匹配原日程的关键词数组
-如果缺失信息，请留空字符串或空数组。`)
+// StoreProposal 保存用户在会话中待确认的提案，返回 confirm_id。
+func (a *AIService) StoreProposal(userID, sessionID uint, proposal Proposal) (string, error) {
+	return a.proposals.Save(userID, sessionID, proposal, time.Now())
+}
+
+// PendingProposals 按创建顺序返回会话中未过期的待确认提案。
+func (a *AIService) PendingProposals(userID, sessionID uint) ([]StoredProposal, error) {
+	return a.proposals.ListSession(userID, sessionID, time.Now())
 }
 
-// parseIntent 解析模型输出的 JSON 并映射为 intentPayload。
-func parseIntent(content string) (intentPayload, error) {
-	raw := extractJSON(content)
-	if raw == "" {
-		return intentPayload{}, errors.New("empty")
+// LookupProposal 查询用户未过期的提案而不删除，用于确认前校验用户的选择，提案不存在、已过期或属于其他用户时 ok 为 false。
+func (a *AIService) LookupProposal(userID uint, confirmID string) (Proposal, bool, error) {
+	return a.proposals.Get(userID, confirmID, time.Now())
+}
+
+// ConsumeProposal 取出并删除用户未过期的提案，提案不存在、已过期或属于其他用户时 ok 为 false。
+func (a *AIService) ConsumeProposal(userID uint, confirmID string) (Proposal, bool, error) {
+	return a.proposals.Consume(userID, confirmID, time.Now())
+}
+
+// DiscardProposal 作废用户的提案。
+func (a *AIService) DiscardProposal(userID uint, confirmID string) error {
+	return a.proposals.Discard(userID, confirmID)
+}
+
+// DiscardSessionProposals 作废会话中全部待确认的提案。
+func (a *AIService) DiscardSessionProposals(userID, sessionID uint) error {
+	return a.proposals.DiscardSession(userID, sessionID)
+}
+
+// SweepExpiredProposals 清理已过期的提案，返回清理数量。
+func (a *AIService) SweepExpiredProposals(now time.Time) (int64, error) {
+	return a.proposals.Sweep(now)
+}
+
+// getProvider 构建或复用模型后端。只缓存创建成功的后端；创建失败时在退避期内直接返回上次的错误（使用规则解析），
+// 到期后再次尝试，避免一次临时故障使服务在重启前一直无法使用模型。
+func (a *AIService) getProvider(ctx context.Context) (ChatProvider, error) {
+	a.providerMu.Lock()
+	defer a.providerMu.Unlock()
+	if a.provider != nil {
+		return a.provider, nil
+	}
+	now := time.Now()
+	if a.providerErr != nil && now.Before(a.providerRetryAt) {
+		return nil, a.providerErr
+	}
+	provider, err := newProvider(ctx, a.cfg)
+	if err != nil {
+		a.providerFailures++
+		a.providerErr = err
+		a.providerRetryAt = now.Add(providerRetryDelay(a.providerFailures))
+		log.Printf("[ai] model unavailable, using rules (attempt %d, retry after %s): %v", a.providerFailures, a.providerRetryAt.Sub(now), err)
+		return nil, err
 	}
-	var payload intentPayload
-	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
-		return intentPayload{}, err
+	a.provider, a.providerErr, a.providerFailures = provider, nil, 0
+	return provider, nil
+}
+
+// providerRetryDelay 返回第 failures 次创建失败后的重试等待时长。
+func providerRetryDelay(failures int) time.Duration {
+	delay := providerRetryBase
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= providerRetryMax {
+			return providerRetryMax
+		}
 	}
-	payload.Action = strings.ToLower(strings.TrimSpace(payload.Action))
-	return payload, nil
+	return delay
 }
 
-// buildProposal 将 intentPayload 转换为 Proposal。
-func buildProposal(payload intentPayload) Proposal {
-	startTime := parseTime(payload.StartTime)
-	endTime := parseTime(payload.EndTime)
-	targetTime := parseTime(payload.TargetTime)
-	participantKeywords := normalizeKeywords(payload.ParticipantKeywords)
-	targetKeywords := normalizeKeywords(payload.TargetKeywords)
-	var eventID *uint
-	if payload.EventID == "" {
-		eventID = nil
-	} else {
-		eventIDVal, _ := strconv.ParseUint(payload.EventID, 10, 32)
-		eventIDConverted := uint(eventIDVal)
-		eventID = &eventIDConverted
-	}
-	proposal := Proposal{
-		Action:              payload.Action,
-		Title:               strings.TrimSpace(payload.Title),
-		Type:                strings.TrimSpace(payload.Type),
-		StartTime:           startTime,
-		EndTime:             endTime,
-		Location:            strings.TrimSpace(payload.Location),
-		Description:         strings.TrimSpace(payload.Description),
-		ParticipantKeywords: participantKeywords,
-		ParticipantIDs:      resolveParticipants(participantKeywords),
-		EventID:             eventID,
-		TargetTime:          targetTime,
-		TargetKeywords:      targetKeywords,
-	}
-	return proposal
+// buildSystemPrompt 说明日程工具的使用规则。
+func buildSystemPrompt() string {
+	return strings.TrimSpace(`你是智能日程助手，请通过调用工具完成用户的日程操作。
+用户的一条消息可能包含多个操作（如“取消3点的会，把5点的改到6点”），请为每个操作分别调用一次工具，不要合并或遗漏。
+所有时间使用 RFC3339 格式并带用户时区的偏移，相对时间（如“明天下午3点”“下周三”）按当前时间与近期日历换算：“本周”“下周”以日历中的行为准，未说明上午下午的钟点按常理判断。
+修改或删除日程时，用 event_id、target_time（原日程开始时间）或 target_keywords 定位原日程。
+用户询问日程安排时调用 query_events。
+用户请求找一个大家都有空的时间（如“下周找30分钟和张三、李四开会”）而没有给出具体时间时调用 find_time，由系统计算共同空闲时段，不要自行编造时间。
+如果提供了“待确认的操作”且用户是在修正其中某一项（如“改到下午4点”“地点换成502”），调用 amend_proposal 并填写对应的 confirm_id；用户提出新的请求时调用对应的工具。
+与日程操作无关的消息不要调用工具，直接简短回复用户。`)
+}
+
+// amendProposal 以待确认的提案为基础合并用户的修正：保持原动作，用修正中非空的字段覆盖原提案；
+// 只改开始时间或修正后结束时间不晚于开始时间时保持原时长。
+func amendProposal(pending Proposal, amended Proposal) Proposal {
+	merged := pending
+	if amended.Title != "" {
+		merged.Title = amended.Title
+	}
+	if amended.Type != "" {
+		merged.Type = amended.Type
+	}
+	if amended.StartTime != nil {
+		if amended.EndTime == nil && pending.StartTime != nil && pending.EndTime != nil {
+			end := amended.StartTime.Add(pending.EndTime.Sub(*pending.StartTime))
+			merged.EndTime = &end
+		}
+		merged.StartTime = amended.StartTime
+	}
+	if amended.EndTime != nil {
+		merged.EndTime = amended.EndTime
+	}
+	if merged.StartTime != nil && merged.EndTime != nil && !merged.EndTime.After(*merged.StartTime) &&
+		pending.StartTime != nil && pending.EndTime != nil {
+		end := merged.StartTime.Add(pending.EndTime.Sub(*pending.StartTime))
+		merged.EndTime = &end
+	}
+	if amended.Location != "" {
+		merged.Location = amended.Location
+	}
+	if amended.Description != "" {
+		merged.Description = amended.Description
+	}
+	if amended.ParticipantKeywords != nil {
+		merged.ParticipantKeywords = amended.ParticipantKeywords
+		merged.ParticipantIDs = amended.ParticipantIDs
+		merged.ParticipantChoices = amended.ParticipantChoices
+	}
+	if amended.EventID != nil {
+		merged.EventID = amended.EventID
+	}
+	if amended.TargetTime != nil {
+		merged.TargetTime = amended.TargetTime
+	}
+	if amended.TargetKeywords != nil {
+		merged.TargetKeywords = amended.TargetKeywords
+	}
+	if amended.DurationMinutes > 0 {
+		merged.DurationMinutes = amended.DurationMinutes
+	}
+	return merged
 }
 
 // formatResult 将 Proposal 转换为前端可展示的确认提示。
@@ -239,32 +404,36 @@ func formatResult(proposal Proposal) ParseResult {
 			return ParseResult{Intent: "delete", NeedConfirm: false, Result: "删除日程需要提供原日程时间或关键词"}
 		}
 		return ParseResult{Intent: "delete", NeedConfirm: true, Proposal: proposal, Result: "识别到删除日程请求。是否确认删除？"}
+	case "query":
+		return ParseResult{Intent: "query", NeedConfirm: false, Proposal: proposal}
+	case "schedule":
+		if proposal.DurationMinutes <= 0 || proposal.StartTime == nil || proposal.EndTime == nil {
+			return ParseResult{Intent: "schedule", NeedConfirm: false, Result: "安排日程需要会议时长，请补充"}
+		}
+		text := fmt.Sprintf("识别到安排日程：%s，时长 %d 分钟，在 %s 至 %s 之间查找共同空闲时段", proposal.Title, proposal.DurationMinutes,
+			proposal.StartTime.Format("2006-01-02 15:04"), proposal.EndTime.Format("2006-01-02 15:04"))
+		return ParseResult{Intent: "schedule", NeedConfirm: true, Proposal: proposal, Result: text}
 	default:
-		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "暂仅支持创建、修改、删除日程"}
+		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "暂仅支持创建、修改、删除和查询日程"}
 	}
 }
 
-func extractJSON(content string) string {
-	trimmed := strings.TrimSpace(content)
-	if strings.HasPrefix(trimmed, "```") {
-		trimmed = strings.TrimPrefix(trimmed, "```")
-		trimmed = strings.TrimPrefix(trimmed, "json")
-		trimmed = strings.TrimSpace(trimmed)
-		trimmed = strings.TrimSuffix(trimmed, "```")
+// QueryRange 返回查询意图的时间范围：只给出开始时间时查询到当天结束，只给出结束时间时从当天开始，
+// 均未给出或范围无效时查询 now 所在的一整天。
+func QueryRange(proposal Proposal, now time.Time) (time.Time, time.Time) {
+	dayStart := func(value time.Time) time.Time {
+		return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
 	}
-	return strings.TrimSpace(trimmed)
-}
-
-func parseTime(value string) *time.Time {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return nil
+	switch {
+	case proposal.StartTime != nil && proposal.EndTime != nil && proposal.EndTime.After(*proposal.StartTime):
+		return *proposal.StartTime, *proposal.EndTime
+	case proposal.StartTime != nil:
+		return *proposal.StartTime, dayStart(*proposal.StartTime).AddDate(0, 0, 1)
+	case proposal.EndTime != nil && proposal.EndTime.After(dayStart(*proposal.EndTime)):
+		return dayStart(*proposal.EndTime), *proposal.EndTime
 	}
-	parsed, err := time.Parse(time.RFC3339, value)
-	if err != nil {
-		return nil
-	}
-	return &parsed
+	start := dayStart(now)
+	return start, start.AddDate(0, 0, 1)
 }
 
 func normalizeKeywords(input []string) []string {
@@ -282,23 +451,6 @@ func normalizeKeywords(input []string) []string {
 	return output
 }
 
-func resolveParticipants(keywords []string) []uint {
-	if len(keywords) == 0 {
-		return nil
-	}
-	var ids []uint
-	for _, keyword := range keywords {
-		var users []model.User
-		if err := model.DB.Where("nickname LIKE ?", "%"+keyword+"%").Limit(3).Find(&users).Error; err != nil {
-			continue
-		}
-		for _, user := range users {
-			ids = append(ids, user.ID)
-		}
-	}
-	return uniqueUintList(ids)
-}
-
 func uniqueUintList(list []uint) []uint {
 	seen := map[uint]struct{}{}
 	result := make([]uint, 0, len(list))
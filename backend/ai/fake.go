@@ -0,0 +1,165 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrScriptExhausted 表示 ScriptedProvider 的预设回复已用完。
+var ErrScriptExhausted = errors.New("scripted provider has no more replies")
+
+// ErrStreamInterrupted 表示 ScriptedProvider 按预设在流式输出中途中断。
+var ErrStreamInterrupted = errors.New("scripted provider stream interrupted")
+
+// scriptedChunkRunes 为流式输出时每个文本片段的字符数。
+const scriptedChunkRunes = 4
+
+// ScriptedProvider 按顺序返回预设回复的确定性模型后端，用于离线验证对话与确认流程；
+// 每次收到的消息列表会被记录，可通过 Requests 检查发送给模型的上下文。
+// 流式调用时回复按固定长度切分为多个片段，工具调用参数分两片到达，与真实模型的流式输出形态一致。
+type ScriptedProvider struct {
+	mu       sync.Mutex
+	replies  []scriptedReply
+	requests [][]*schema.Message
+}
+
+// scriptedReply 为一条预设回复，interrupted 时流式输出在文本之后以 ErrStreamInterrupted 结束。
+type scriptedReply struct {
+	message     *schema.Message
+	interrupted bool
+}
+
+// NewScriptedProvider 创建按顺序返回文本回复 replies 的模型后端。
+func NewScriptedProvider(replies ...string) *ScriptedProvider {
+	p := &ScriptedProvider{}
+	p.Push(replies...)
+	return p
+}
+
+// Push 追加文本回复。
+func (p *ScriptedProvider) Push(replies ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, reply := range replies {
+		p.replies = append(p.replies, scriptedReply{message: &schema.Message{Role: schema.Assistant, Content: reply}})
+	}
+}
+
+// PushInterrupted 追加一条在流式输出 partial 之后中断的回复，非流式调用时直接返回 ErrStreamInterrupted。
+func (p *ScriptedProvider) PushInterrupted(partial string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = append(p.replies, scriptedReply{
+		message:     &schema.Message{Role: schema.Assistant, Content: partial},
+		interrupted: true,
+	})
+}
+
+// PushToolCalls 追加一条包含若干工具调用的回复。
+func (p *ScriptedProvider) PushToolCalls(calls ...schema.ToolCall) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = append(p.replies, scriptedReply{message: &schema.Message{Role: schema.Assistant, ToolCalls: calls}})
+}
+
+// ToolCall 构造一次工具调用，arguments 为 JSON 字符串。
+func ToolCall(name, arguments string) schema.ToolCall {
+	return schema.ToolCall{Type: "function", Function: schema.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+// Generate 记录请求并返回下一条预设回复，用完后返回 ErrScriptExhausted。
+func (p *ScriptedProvider) Generate(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.Message, error) {
+	reply, err := p.next(messages)
+	if err != nil {
+		return nil, err
+	}
+	if reply.interrupted {
+		return nil, ErrStreamInterrupted
+	}
+	return reply.message, nil
+}
+
+// Stream 记录请求并将下一条预设回复切分为片段依次输出，用完后返回 ErrScriptExhausted。
+func (p *ScriptedProvider) Stream(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.StreamReader[*schema.Message], error) {
+	reply, err := p.next(messages)
+	if err != nil {
+		return nil, err
+	}
+	chunks := splitScriptedReply(reply.message)
+	reader, writer := schema.Pipe[*schema.Message](0)
+	go func() {
+		defer writer.Close()
+		for _, chunk := range chunks {
+			if closed := writer.Send(chunk, nil); closed {
+				return
+			}
+		}
+		if reply.interrupted {
+			writer.Send(nil, ErrStreamInterrupted)
+		}
+	}()
+	return reader, nil
+}
+
+// next 记录请求并取出下一条预设回复，为缺少 ID 的工具调用补齐 ID。
+func (p *ScriptedProvider) next(messages []*schema.Message) (scriptedReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, append([]*schema.Message(nil), messages...))
+	if len(p.replies) == 0 {
+		return scriptedReply{}, ErrScriptExhausted
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	message := *reply.message
+	message.ToolCalls = append([]schema.ToolCall(nil), message.ToolCalls...)
+	for i := range message.ToolCalls {
+		if message.ToolCalls[i].ID == "" {
+			message.ToolCalls[i].ID = "call_" + strconv.Itoa(len(p.requests)) + "_" + strconv.Itoa(i)
+		}
+	}
+	reply.message = &message
+	return reply, nil
+}
+
+// splitScriptedReply 将回复切分为流式片段：文本每 scriptedChunkRunes 个字符一片，
+// 每个工具调用先输出 ID、名称与前半段参数，再输出后半段参数。
+func splitScriptedReply(message *schema.Message) []*schema.Message {
+	var chunks []*schema.Message
+	content := []rune(message.Content)
+	for start := 0; start < len(content); start += scriptedChunkRunes {
+		end := start + scriptedChunkRunes
+		if end > len(content) {
+			end = len(content)
+		}
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant, Content: string(content[start:end])})
+	}
+	for i, call := range message.ToolCalls {
+		index := i
+		arguments := call.Function.Arguments
+		half := len(arguments) / 2
+		head := call
+		head.Index = &index
+		head.Function.Arguments = arguments[:half]
+		tail := schema.ToolCall{Index: &index, Function: schema.FunctionCall{Arguments: arguments[half:]}}
+		chunks = append(chunks,
+			&schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{head}},
+			&schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{tail}},
+		)
+	}
+	if len(chunks) == 0 {
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant})
+	}
+	return chunks
+}
+
+// Requests 返回至今收到的全部请求。
+func (p *ScriptedProvider) Requests() [][]*schema.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]*schema.Message(nil), p.requests...)
+}
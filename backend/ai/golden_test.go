@@ -0,0 +1,259 @@
+package ai
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// goldenCasesJSON 为相对时间表达的标准用例，用于评估在给定用户上下文下换算时间的准确性。
+//
+//go:embed golden/relative_dates.json
+var goldenCasesJSON []byte
+
+// goldenCase 表示一条相对时间标准用例：在 Now、Timezone、Locale 与工作时间下发送 Message，期望得到 Expect。
+type goldenCase struct {
+	Name      string       `json:"name"`
+	Now       string       `json:"now"`        // RFC3339
+	Timezone  string       `json:"timezone"`   // IANA 时区
+	Locale    string       `json:"locale"`     // 语言区域
+	WorkStart string       `json:"work_start"` // 为空时使用默认工作时间
+	WorkEnd   string       `json:"work_end"`
+	Message   string       `json:"message"`
+	Expect    goldenExpect `json:"expect"`
+}
+
+// goldenExpect 为期望的首个解析结果，留空的字段不比较。
+type goldenExpect struct {
+	Action     string `json:"action"`
+	StartTime  string `json:"start_time"`  // RFC3339，须为同一时刻且偏移与用户时区一致
+	EndTime    string `json:"end_time"`    // 同上
+	TargetDate string `json:"target_date"` // YYYY-MM-DD，按用户时区比较原日程日期
+}
+
+// loadGoldenCases 读取内置的相对时间标准用例。
+func loadGoldenCases(t *testing.T) []goldenCase {
+	t.Helper()
+	var cases []goldenCase
+	if err := json.Unmarshal(goldenCasesJSON, &cases); err != nil {
+		t.Fatalf("load golden cases: %v", err)
+	}
+	return cases
+}
+
+// setupGoldenDB 初始化内存数据库；参与人关键词需要查询用户表，不读写业务数据。
+func setupGoldenDB(t *testing.T) config.AppConfig {
+	t.Helper()
+	cfg := config.Load()
+	cfg.DBPath = "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	model.InitDB(cfg)
+	if err := model.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return cfg
+}
+
+// userContext 按用例的时区、语言区域与工作时间构造解析上下文，未设置的偏好与线上一样使用默认值。
+func (g goldenCase) userContext(t *testing.T) UserContext {
+	t.Helper()
+	loc, err := time.LoadLocation(g.Timezone)
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	now, err := time.Parse(time.RFC3339, g.Now)
+	if err != nil {
+		t.Fatalf("parse now: %v", err)
+	}
+	prefs := service.UserAssistantPreferences(model.User{Locale: g.Locale, WorkStart: g.WorkStart, WorkEnd: g.WorkEnd})
+	workDays := make([]time.Weekday, 0, len(prefs.WorkDays))
+	for _, day := range prefs.WorkDays {
+		workDays = append(workDays, time.Weekday(day))
+	}
+	return UserContext{
+		Now:       now.In(loc),
+		Location:  loc,
+		Locale:    prefs.Locale,
+		WeekStart: service.LocaleWeekStart(prefs.Locale),
+		WorkStart: prefs.WorkStart,
+		WorkEnd:   prefs.WorkEnd,
+		WorkDays:  workDays,
+	}
+}
+
+// check 比较解析结果与期望，不一致时返回差异说明。
+func (g goldenCase) check(user UserContext, results []ParseResult) error {
+	if len(results) == 0 {
+		return fmt.Errorf("没有解析结果")
+	}
+	result := results[0]
+	if result.Intent != g.Expect.Action {
+		return fmt.Errorf("action 期望 %s，实际 %s（%s）", g.Expect.Action, result.Intent, result.Result)
+	}
+	proposal := result.Proposal
+	var diffs []string
+	if err := checkGoldenTime("start_time", g.Expect.StartTime, proposal.StartTime); err != nil {
+		diffs = append(diffs, err.Error())
+	}
+	if err := checkGoldenTime("end_time", g.Expect.EndTime, proposal.EndTime); err != nil {
+		diffs = append(diffs, err.Error())
+	}
+	if g.Expect.TargetDate != "" {
+		actual := "空"
+		if proposal.TargetTime != nil {
+			actual = proposal.TargetTime.In(user.Location).Format("2006-01-02")
+		}
+		if actual != g.Expect.TargetDate {
+			diffs = append(diffs, fmt.Sprintf("target_date 期望 %s，实际 %s", g.Expect.TargetDate, actual))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%s", strings.Join(diffs, "；"))
+	}
+	return nil
+}
+
+// checkGoldenTime 要求实际时间与期望为同一时刻，且已转换到期望的时区偏移。
+func checkGoldenTime(field, expected string, actual *time.Time) error {
+	if expected == "" {
+		return nil
+	}
+	want, err := time.Parse(time.RFC3339, expected)
+	if err != nil {
+		return fmt.Errorf("%s 期望值无效：%s", field, expected)
+	}
+	if actual == nil {
+		return fmt.Errorf("%s 期望 %s，实际为空", field, expected)
+	}
+	if !actual.Equal(want) || actual.Format(time.RFC3339) != expected {
+		return fmt.Errorf("%s 期望 %s，实际 %s", field, expected, actual.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// scriptedGoldenCall 构造模型对用例应给出的工具调用，时间一律以 UTC 表示，用于验证服务端转换到用户时区。
+func scriptedGoldenCall(t *testing.T, g goldenCase, user UserContext) schema.ToolCall {
+	t.Helper()
+	utc := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("parse expect: %v", err)
+		}
+		return parsed.UTC().Format(time.RFC3339)
+	}
+	args := map[string]interface{}{}
+	name := ""
+	switch g.Expect.Action {
+	case "create":
+		name = ToolCreateEvent
+		args["title"] = g.Name
+		args["start_time"] = utc(g.Expect.StartTime)
+		if g.Expect.EndTime != "" {
+			args["end_time"] = utc(g.Expect.EndTime)
+		}
+	case "query":
+		name = ToolQueryEvents
+		args["start_time"] = utc(g.Expect.StartTime)
+		args["end_time"] = utc(g.Expect.EndTime)
+	case "delete":
+		name = ToolDeleteEvent
+		day, err := time.ParseInLocation("2006-01-02", g.Expect.TargetDate, user.Location)
+		if err != nil {
+			t.Fatalf("parse target_date: %v", err)
+		}
+		args["target_time"] = day.Add(12 * time.Hour).UTC().Format(time.RFC3339)
+	default:
+		t.Fatalf("unsupported golden action %s", g.Expect.Action)
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("encode arguments: %v", err)
+	}
+	return ToolCall(name, string(body))
+}
+
+// TestGoldenRules 要求规则解析通过全部相对时间标准用例。
+func TestGoldenRules(t *testing.T) {
+	setupGoldenDB(t)
+	for _, g := range loadGoldenCases(t) {
+		t.Run(g.Name, func(t *testing.T) {
+			user := g.userContext(t)
+			if err := g.check(user, ParseRules(user, nil, g.Message)); err != nil {
+				t.Fatalf("%s：%v", g.Message, err)
+			}
+		})
+	}
+}
+
+// TestGoldenScripted 以 ScriptedProvider 模拟模型给出正确的工具调用，验证提示词包含用户的当前时间与时区，
+// 且模型以 UTC 输出的时间被转换到用户时区。
+func TestGoldenScripted(t *testing.T) {
+	cfg := setupGoldenDB(t)
+	for _, g := range loadGoldenCases(t) {
+		t.Run(g.Name, func(t *testing.T) {
+			user := g.userContext(t)
+			provider := NewScriptedProvider()
+			provider.PushToolCalls(scriptedGoldenCall(t, g, user))
+			results, err := NewAIServiceWithProvider(cfg, provider).ParseMessage(user, nil, nil, g.Message)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(results) == 0 || results[0].Parser != ParserModel {
+				t.Fatalf("results = %+v", results)
+			}
+			if err := g.check(user, results); err != nil {
+				t.Fatalf("%s：%v", g.Message, err)
+			}
+
+			requests := provider.Requests()
+			prompt := requests[0][len(requests[0])-1].Content
+			for _, want := range []string{user.Now.Format(time.RFC3339), g.Timezone, g.Message} {
+				if !strings.Contains(prompt, want) {
+					t.Fatalf("prompt missing %q:\n%s", want, prompt)
+				}
+			}
+		})
+	}
+}
+
+// TestGoldenLive 使用当前配置的大模型运行标准用例，调整提示词或更换模型后手动运行：
+// AI_GOLDEN_LIVE=1 go test ./ai -run TestGoldenLive -v
+func TestGoldenLive(t *testing.T) {
+	if os.Getenv("AI_GOLDEN_LIVE") == "" {
+		t.Skip("set AI_GOLDEN_LIVE=1 to evaluate the configured model")
+	}
+	cfg := setupGoldenDB(t)
+	svc := NewAIService(cfg)
+	for _, g := range loadGoldenCases(t) {
+		t.Run(g.Name, func(t *testing.T) {
+			user := g.userContext(t)
+			results, err := svc.ParseMessage(user, nil, nil, g.Message)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(results) > 0 && results[0].Parser != ParserModel {
+				t.Fatalf("model unavailable, results came from %s", results[0].Parser)
+			}
+			if err := g.check(user, results); err != nil {
+				t.Errorf("%s：%v", g.Message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// calendarWeeks 为提示词中迷你日历覆盖的周数（含本周）。
+const calendarWeeks = 4
+
+// calendarWeekLabels 为迷你日历每行的相对周名称。
+var calendarWeekLabels = []string{"本周", "下周", "下下周", "三周后"}
+
+// weekdayNames 为星期的中文简称，下标为 time.Weekday。
+var weekdayNames = []string{"日", "一", "二", "三", "四", "五", "六"}
+
+// UserContext 为解析用户输入所需的个人上下文，用于在提示词中锚定“下周三”“tomorrow at 9”等相对时间。
+type UserContext struct {
+	UserID    uint           // 当前用户，用于匹配参与人时排除本人并按共同参加的日程排序
+	Now       time.Time      // 当前时间，零值表示 time.Now()
+	Location  *time.Location // 用户时区，nil 表示服务器时区
+	Locale    string         // 语言区域，如 zh-CN / en-US
+	WeekStart time.Weekday   // 每周起始日
+	WorkStart string         // 工作开始时间 HH:MM
+	WorkEnd   string         // 工作结束时间 HH:MM
+	WorkDays  []time.Weekday // 工作日
+}
+
+// localNow 返回用户时区的当前时间。
+func (u UserContext) localNow() time.Time {
+	now := u.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return now.In(u.location())
+}
+
+// location 返回用户时区，未设置时使用服务器时区。
+func (u UserContext) location() *time.Location {
+	if u.Location == nil {
+		return time.Local
+	}
+	return u.Location
+}
+
+// buildGroundingPrompt 输出用户的当前时间、时区、语言区域、工作时间与近几周的迷你日历。
+func buildGroundingPrompt(user UserContext) string {
+	now := user.localNow()
+	var b strings.Builder
+	fmt.Fprintf(&b, "当前时间：%s 星期%s\n", now.Format(time.RFC3339), weekdayNames[now.Weekday()])
+	fmt.Fprintf(&b, "用户时区：%s（UTC%s）\n", now.Location().String(), now.Format("-07:00"))
+	if user.Locale != "" {
+		fmt.Fprintf(&b, "用户语言区域：%s（直接回复时使用该语言）\n", user.Locale)
+	}
+	if user.WorkStart != "" && user.WorkEnd != "" {
+		fmt.Fprintf(&b, "工作时间：%s %s-%s（“上班时间”“下班后”等按此理解）\n", formatWorkDays(user.WorkDays), user.WorkStart, user.WorkEnd)
+	}
+	b.WriteString(renderMiniCalendar(now, user.WeekStart))
+	return b.String()
+}
+
+// renderMiniCalendar 从 now 所在周的起始日开始列出 calendarWeeks 周的日期，今天以“今天”标注。
+func renderMiniCalendar(now time.Time, weekStart time.Weekday) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := (int(today.Weekday()) - int(weekStart) + 7) % 7
+	day := today.AddDate(0, 0, -offset)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "近期日历（每周从星期%s开始）：\n", weekdayNames[weekStart])
+	for week := 0; week < calendarWeeks; week++ {
+		cells := make([]string, 0, 7)
+		for i := 0; i < 7; i++ {
+			cell := day.Format("2006-01-02") + "(" + weekdayNames[day.Weekday()] + ")"
+			if day.Equal(today) {
+				cell += "今天"
+			}
+			cells = append(cells, cell)
+			day = day.AddDate(0, 0, 1)
+		}
+		b.WriteString(calendarWeekLabels[week] + "：" + strings.Join(cells, " ") + "\n")
+	}
+	return b.String()
+}
+
+// formatWorkDays 输出工作日，连续的周一至周五显示为“周一至周五”。
+func formatWorkDays(days []time.Weekday) string {
+	if len(days) == 0 {
+		return "每天"
+	}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if len(days) == len(weekdays) {
+		same := true
+		for i := range days {
+			if days[i] != weekdays[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return "周一至周五"
+		}
+	}
+	names := make([]string, 0, len(days))
+	for _, day := range days {
+		names = append(names, "周"+weekdayNames[day])
+	}
+	return strings.Join(names, "、")
+}
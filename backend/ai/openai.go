@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"smartcalendar/config"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// openAIRequestTimeout 为单次请求 OpenAI 兼容接口的超时时间，本地模型首次加载可能较慢。
+const openAIRequestTimeout = 60 * time.Second
+
+// openAIProvider 通过 OpenAI 兼容的 /chat/completions 接口调用模型，适用于 vLLM、Ollama 等自部署服务。
+type openAIProvider struct {
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+// openAIMessage 为 OpenAI 兼容接口的消息结构。
+type openAIMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// openAIToolCall 为模型返回的一次函数调用。
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAITool 为请求中声明的函数。
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIToolFunction `json:"function"`
+}
+
+// openAIToolFunction 为函数名称、说明与参数 JSON Schema。
+type openAIToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+// openAIChatRequest 为 /chat/completions 请求体。
+type openAIChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Tools    []openAITool    `json:"tools,omitempty"`
+}
+
+// openAIChatResponse 为 /chat/completions 响应体中用到的字段。
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newOpenAIProvider 校验 OpenAI 兼容接口配置。
+func newOpenAIProvider(cfg config.AppConfig) (ChatProvider, error) {
+	if cfg.OpenAIBaseURL == "" {
+		return nil, errors.New("OPENAI_BASE_URL 未配置")
+	}
+	if cfg.OpenAIModel == "" {
+		return nil, errors.New("OPENAI_MODEL 未配置")
+	}
+	return openAIProvider{
+		endpoint: strings.TrimRight(cfg.OpenAIBaseURL, "/") + "/chat/completions",
+		apiKey:   cfg.OpenAIAPIKey,
+		model:    cfg.OpenAIModel,
+		client:   &http.Client{Timeout: openAIRequestTimeout},
+	}, nil
+}
+
+// Generate 调用 /chat/completions 并返回首个候选回复，包括其中的函数调用。
+func (p openAIProvider) Generate(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.Message, error) {
+	payload := openAIChatRequest{Model: p.model, Messages: make([]openAIMessage, 0, len(messages))}
+	for _, message := range messages {
+		payload.Messages = append(payload.Messages, openAIMessage{Role: string(message.Role), Content: message.Content})
+	}
+	for _, tool := range tools {
+		function := openAIToolFunction{Name: tool.Name, Description: tool.Desc}
+		if tool.ParamsOneOf != nil {
+			parameters, err := tool.ParamsOneOf.ToJSONSchema()
+			if err != nil {
+				return nil, err
+			}
+			function.Parameters = parameters
+		}
+		payload.Tools = append(payload.Tools, openAITool{Type: "function", Function: function})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var result openAIChatResponse
+	decodeErr := json.Unmarshal(respBody, &result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if decodeErr == nil && result.Error != nil && result.Error.Message != "" {
+			return nil, fmt.Errorf("模型接口返回 %d：%s", resp.StatusCode, result.Error.Message)
+		}
+		return nil, fmt.Errorf("模型接口返回 %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	if len(result.Choices) == 0 {
+		return nil, errors.New("模型接口未返回结果")
+	}
+	choice := result.Choices[0].Message
+	reply := &schema.Message{Role: schema.Assistant, Content: choice.Content}
+	for _, call := range choice.ToolCalls {
+		reply.ToolCalls = append(reply.ToolCalls, schema.ToolCall{
+			ID:       call.ID,
+			Type:     "function",
+			Function: schema.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments},
+		})
+	}
+	return reply, nil
+}
@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"sort"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// participantCandidateLimit 为每个参与人关键词返回的候选用户数量上限。
+const participantCandidateLimit = 5
+
+// participantMatchLimit 为每个参与人关键词参与匹配与排序的用户数量上限，昵称与关键词完全相同的用户优先。
+const participantMatchLimit = 50
+
+// ParticipantChoice 表示匹配到多个用户的参与人关键词，需由用户在确认时选择。
+type ParticipantChoice struct {
+	Keyword    string                 `json:"keyword"`
+	Candidates []ParticipantCandidate `json:"candidates"`
+}
+
+// ParticipantCandidate 为参与人关键词的一个候选用户，按与当前用户共同参加日程的次数降序排列。
+type ParticipantCandidate struct {
+	ID       uint   `json:"id"`
+	Nickname string `json:"nickname"`
+	Email    string `json:"email"`
+	Avatar   string `json:"avatar"`
+	CoEvents int64  `json:"co_events"` // 与当前用户共同参加（创建或受邀且未拒绝）的日程数
+}
+
+// resolveParticipants 按昵称关键词匹配参与人（不含本人与已禁用的用户）：只匹配到一个用户、或恰有一个用户昵称与关键词完全相同时直接采用，
+// 匹配到多个用户时作为待选择的候选列表返回，不自动邀请。
+func resolveParticipants(userID uint, keywords []string) ([]uint, []ParticipantChoice) {
+	var ids []uint
+	var choices []ParticipantChoice
+	for _, keyword := range keywords {
+		var users []model.User
+		query := model.DB.Where("nickname LIKE ? AND status = ?", "%"+keyword+"%", "active")
+		if userID != 0 {
+			query = query.Where("id <> ?", userID)
+		}
+		query = query.Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "CASE WHEN nickname = ? THEN 0 ELSE 1 END, id",
+			Vars: []interface{}{keyword},
+		}})
+		if err := query.Limit(participantMatchLimit).Find(&users).Error; err != nil || len(users) == 0 {
+			continue
+		}
+		if len(users) == 1 {
+			ids = append(ids, users[0].ID)
+			continue
+		}
+		var exact []model.User
+		for _, user := range users {
+			if user.Nickname == keyword {
+				exact = append(exact, user)
+			}
+		}
+		if len(exact) == 1 {
+			ids = append(ids, exact[0].ID)
+			continue
+		}
+		choices = append(choices, ParticipantChoice{Keyword: keyword, Candidates: rankParticipantCandidates(userID, users)})
+	}
+	return uniqueUintList(ids), choices
+}
+
+// rankParticipantCandidates 按与当前用户共同参加日程的次数降序排列候选用户，次数相同时按 ID 升序，最多保留 participantCandidateLimit 个。
+func rankParticipantCandidates(userID uint, users []model.User) []ParticipantCandidate {
+	otherIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		otherIDs = append(otherIDs, user.ID)
+	}
+	counts := countCoEvents(userID, otherIDs)
+	candidates := make([]ParticipantCandidate, 0, len(users))
+	for _, user := range users {
+		candidates = append(candidates, ParticipantCandidate{
+			ID:       user.ID,
+			Nickname: user.Nickname,
+			Email:    user.Email,
+			Avatar:   user.Avatar,
+			CoEvents: counts[user.ID],
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].CoEvents != candidates[j].CoEvents {
+			return candidates[i].CoEvents > candidates[j].CoEvents
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if len(candidates) > participantCandidateLimit {
+		candidates = candidates[:participantCandidateLimit]
+	}
+	return candidates
+}
+
+// countCoEvents 以一次分组查询统计 otherIDs 中每个用户与当前用户共同参加的日程数：双方均为创建者或未拒绝邀请的参与人；
+// 统计失败时全部按 0 处理。
+func countCoEvents(userID uint, otherIDs []uint) map[uint]int64 {
+	counts := make(map[uint]int64, len(otherIDs))
+	if userID == 0 || len(otherIDs) == 0 {
+		return counts
+	}
+	attended := model.DB.Model(&model.Event{}).Select("id").
+		Where("user_id = ? OR id IN (?)", userID, model.DB.Model(&model.EventParticipant{}).
+			Select("event_id").
+			Where("user_id = ? AND status <> ?", userID, "declined"))
+	var rows []struct {
+		UserID   uint
+		CoEvents int64
+	}
+	if err := model.DB.Raw(`SELECT attendance.user_id, COUNT(DISTINCT attendance.event_id) AS co_events FROM (
+		SELECT id AS event_id, user_id FROM events
+		UNION ALL
+		SELECT event_id, user_id FROM event_participants WHERE status <> ?
+	) AS attendance
+	WHERE attendance.user_id IN ? AND attendance.event_id IN (?)
+	GROUP BY attendance.user_id`, "declined", otherIDs, attended).Scan(&rows).Error; err != nil {
+		return counts
+	}
+	for _, row := range rows {
+		counts[row.UserID] = row.CoEvents
+	}
+	return counts
+}
+
+// resolveResultParticipants 为解析结果匹配参与人；查询不需要确认，有歧义的关键词按全部候选用户筛选。
+func resolveResultParticipants(userID uint, results []ParseResult) {
+	for i := range results {
+		proposal := &results[i].Proposal
+		if len(proposal.ParticipantKeywords) == 0 {
+			continue
+		}
+		ids, choices := resolveParticipants(userID, proposal.ParticipantKeywords)
+		if results[i].Intent == "query" {
+			for _, choice := range choices {
+				for _, candidate := range choice.Candidates {
+					ids = append(ids, candidate.ID)
+				}
+			}
+			ids, choices = uniqueUintList(ids), nil
+		}
+		proposal.ParticipantIDs, proposal.ParticipantChoices = ids, choices
+	}
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"fmt"
+	"testing"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// setupParticipantDB 初始化内存数据库并创建 alice 与昵称为 王1…王n 的用户，返回 alice 与这些用户。
+func setupParticipantDB(t *testing.T, n int) (model.User, []model.User) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.DBPath = "file:" + t.Name() + "?mode=memory&cache=shared"
+	model.InitDB(cfg)
+	if err := model.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	alice := model.User{Nickname: "alice", Email: "alice@example.com", Password: "x", Status: "active"}
+	if err := model.DB.Create(&alice).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	users := make([]model.User, n)
+	for i := range users {
+		users[i] = model.User{Nickname: fmt.Sprintf("王%d", i+1), Email: fmt.Sprintf("wang%d@example.com", i+1), Password: "x", Status: "active"}
+	}
+	if err := model.DB.Create(&users).Error; err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+	return alice, users
+}
+
+// createCoEvent 创建 creator 的日程并邀请 participant。
+func createCoEvent(t *testing.T, creator, participant model.User, status string) {
+	t.Helper()
+	event := model.Event{UserID: creator.ID, Title: "会议", Type: "work"}
+	if err := model.DB.Create(&event).Error; err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	if err := model.DB.Create(&model.EventParticipant{EventID: event.ID, UserID: participant.ID, Status: status}).Error; err != nil {
+		t.Fatalf("create participant: %v", err)
+	}
+}
+
+func TestResolveParticipantsRanksInBoundedQueries(t *testing.T) {
+	alice, users := setupParticipantDB(t, 80)
+	createCoEvent(t, alice, users[2], "accepted")
+	createCoEvent(t, users[2], alice, "pending")
+	createCoEvent(t, alice, users[6], "pending")
+	createCoEvent(t, alice, users[8], "declined")
+
+	queries := 0
+	// 子查询只在构建 SQL 时以 DryRun 经过回调，不计入实际查询。
+	count := func(db *gorm.DB) {
+		if !db.DryRun {
+			queries++
+		}
+	}
+	model.DB.Callback().Query().After("gorm:query").Register("test:count_query", count)
+	model.DB.Callback().Row().After("gorm:row").Register("test:count_row", count)
+
+	ids, choices := resolveParticipants(alice.ID, []string{"王"})
+	if len(ids) != 0 || len(choices) != 1 {
+		t.Fatalf("ids = %v, choices = %+v", ids, choices)
+	}
+	var got []string
+	for _, candidate := range choices[0].Candidates {
+		got = append(got, fmt.Sprintf("%s:%d", candidate.Nickname, candidate.CoEvents))
+	}
+	if want := "[王3:2 王7:1 王1:0 王2:0 王4:0]"; fmt.Sprint(got) != want {
+		t.Fatalf("candidates = %v, want %s", got, want)
+	}
+	if queries > 2 {
+		t.Fatalf("queries = %d, want at most 2 regardless of match count", queries)
+	}
+
+	// 匹配数超过上限时，昵称完全相同的用户仍优先匹配。
+	exact := model.User{Nickname: "王", Email: "wang@example.com", Password: "x", Status: "active"}
+	if err := model.DB.Create(&exact).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if ids, choices := resolveParticipants(alice.ID, []string{"王"}); len(ids) != 1 || ids[0] != exact.ID || len(choices) != 0 {
+		t.Fatalf("exact match = %v, %+v", ids, choices)
+	}
+}
@@ -0,0 +1,153 @@
+package ai
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"smartcalendar/model"
+)
+
+// confirmIDPrefix 标识待确认提案的 confirm_id。
+const confirmIDPrefix = "c_"
+
+// ProposalStore 保存等待用户确认的提案：提案与创建者绑定，过期后不可再确认。
+type ProposalStore interface {
+	// Save 保存会话中的提案并返回随机生成的 confirm_id。
+	Save(userID, sessionID uint, proposal Proposal, now time.Time) (string, error)
+	// ListSession 按创建顺序返回会话中未过期的提案。
+	ListSession(userID, sessionID uint, now time.Time) ([]StoredProposal, error)
+	// Get 查询用户未过期的提案，不删除。
+	Get(userID uint, confirmID string, now time.Time) (proposal Proposal, ok bool, err error)
+	// Consume 取出并删除用户未过期的提案，同一提案只能被取出一次。
+	Consume(userID uint, confirmID string, now time.Time) (proposal Proposal, ok bool, err error)
+	// Discard 删除用户的提案，不存在时忽略。
+	Discard(userID uint, confirmID string) error
+	// DiscardSession 删除会话中的全部提案。
+	DiscardSession(userID, sessionID uint) error
+	// Sweep 清理已过期的提案，返回清理数量。
+	Sweep(now time.Time) (int64, error)
+}
+
+// DBProposalStore 将提案保存在数据库中，可在多实例间共享并在重启后保留。
+type DBProposalStore struct {
+	ttl time.Duration
+}
+
+// NewDBProposalStore 创建数据库提案存储，ttl 为提案的有效期，非法时回退为 30 分钟。
+func NewDBProposalStore(ttl time.Duration) *DBProposalStore {
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+	return &DBProposalStore{ttl: ttl}
+}
+
+// Save 保存提案，confirm_id 由加密随机数生成，无法被猜测。
+func (s *DBProposalStore) Save(userID, sessionID uint, proposal Proposal, now time.Time) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(proposal)
+	if err != nil {
+		return "", err
+	}
+	record := model.AIProposal{
+		ID:        confirmIDPrefix + hex.EncodeToString(raw),
+		UserID:    userID,
+		SessionID: sessionID,
+		Payload:   model.JSONText(body),
+		ExpiresAt: now.Add(s.ttl).UTC(),
+	}
+	if err := model.DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+// ListSession 按创建顺序返回会话中未过期的提案。
+func (s *DBProposalStore) ListSession(userID, sessionID uint, now time.Time) ([]StoredProposal, error) {
+	if sessionID == 0 {
+		return nil, nil
+	}
+	var records []model.AIProposal
+	if err := model.DB.Where("user_id = ? AND session_id = ? AND expires_at > ?", userID, sessionID, now.UTC()).
+		Order("created_at asc, id asc").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	list := make([]StoredProposal, 0, len(records))
+	for _, record := range records {
+		var proposal Proposal
+		if err := json.Unmarshal([]byte(record.Payload), &proposal); err != nil {
+			return nil, err
+		}
+		list = append(list, StoredProposal{ConfirmID: record.ID, Proposal: proposal, ExpiresAt: record.ExpiresAt})
+	}
+	return list, nil
+}
+
+// Get 查询用户未过期的提案。
+func (s *DBProposalStore) Get(userID uint, confirmID string, now time.Time) (Proposal, bool, error) {
+	record, ok, err := findProposal(userID, confirmID, now)
+	if err != nil || !ok {
+		return Proposal{}, false, err
+	}
+	var proposal Proposal
+	if err := json.Unmarshal([]byte(record.Payload), &proposal); err != nil {
+		return Proposal{}, false, err
+	}
+	return proposal, true, nil
+}
+
+// Consume 取出并删除提案；以按条件删除作为认领，并发确认同一提案时只有一次成功。
+func (s *DBProposalStore) Consume(userID uint, confirmID string, now time.Time) (Proposal, bool, error) {
+	record, ok, err := findProposal(userID, confirmID, now)
+	if err != nil || !ok {
+		return Proposal{}, false, err
+	}
+	var proposal Proposal
+	if err := json.Unmarshal([]byte(record.Payload), &proposal); err != nil {
+		return Proposal{}, false, err
+	}
+	result := model.DB.Where("id = ? AND user_id = ?", record.ID, userID).Delete(&model.AIProposal{})
+	if result.Error != nil {
+		return Proposal{}, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Proposal{}, false, nil
+	}
+	return proposal, true, nil
+}
+
+// Discard 删除用户的提案。
+func (s *DBProposalStore) Discard(userID uint, confirmID string) error {
+	return model.DB.Where("id = ? AND user_id = ?", confirmID, userID).Delete(&model.AIProposal{}).Error
+}
+
+// DiscardSession 删除会话中的全部提案。
+func (s *DBProposalStore) DiscardSession(userID, sessionID uint) error {
+	return model.DB.Where("user_id = ? AND session_id = ?", userID, sessionID).Delete(&model.AIProposal{}).Error
+}
+
+// Sweep 删除已过期的提案。
+func (s *DBProposalStore) Sweep(now time.Time) (int64, error) {
+	result := model.DB.Where("expires_at <= ?", now.UTC()).Delete(&model.AIProposal{})
+	return result.RowsAffected, result.Error
+}
+
+// findProposal 查询用户未过期的提案记录。
+func findProposal(userID uint, confirmID string, now time.Time) (model.AIProposal, bool, error) {
+	if confirmID == "" {
+		return model.AIProposal{}, false, nil
+	}
+	var records []model.AIProposal
+	if err := model.DB.Where("id = ? AND user_id = ? AND expires_at > ?", confirmID, userID, now.UTC()).
+		Limit(1).Find(&records).Error; err != nil {
+		return model.AIProposal{}, false, err
+	}
+	if len(records) == 0 {
+		return model.AIProposal{}, false, nil
+	}
+	return records[0], true, nil
+}
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"smartcalendar/config"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ChatProvider 为大模型对话后端，接收完整的消息列表与可调用的工具，返回模型回复；
+// 模型决定调用工具时，回复的 ToolCalls 中包含一个或多个工具调用。
+type ChatProvider interface {
+	Generate(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.Message, error)
+}
+
+// StreamProvider 为支持流式输出的模型后端，按到达顺序返回回复片段，
+// 拼接全部片段（schema.ConcatMessages）即得到与 Generate 相同的完整回复。
+type StreamProvider interface {
+	Stream(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.StreamReader[*schema.Message], error)
+}
+
+// streamReply 以流式方式调用模型后端，不支持流式输出的后端整体作为一个片段返回。
+func streamReply(ctx context.Context, provider ChatProvider, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.StreamReader[*schema.Message], error) {
+	if streamer, ok := provider.(StreamProvider); ok {
+		return streamer.Stream(ctx, messages, tools)
+	}
+	resp, err := provider.Generate(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{resp}), nil
+}
+
+// 支持的大模型后端，由 AI_PROVIDER 选择。
+const (
+	ProviderArk    = "ark"    // 豆包 Ark
+	ProviderOpenAI = "openai" // OpenAI 兼容接口，如 vLLM、Ollama
+)
+
+// newProvider 按配置创建大模型后端。
+func newProvider(ctx context.Context, cfg config.AppConfig) (ChatProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.AIProvider)) {
+	case "", ProviderArk:
+		return newArkProvider(ctx, cfg)
+	case ProviderOpenAI:
+		return newOpenAIProvider(cfg)
+	default:
+		return nil, errors.New("AI_PROVIDER 无效：" + cfg.AIProvider)
+	}
+}
+
+// arkProvider 通过 Eino 调用豆包 Ark 模型。
+type arkProvider struct {
+	model *ark.ChatModel
+}
+
+// newArkProvider 校验 Ark 配置并构建 ChatModel。
+func newArkProvider(ctx context.Context, cfg config.AppConfig) (ChatProvider, error) {
+	if cfg.ArkModelID == "" {
+		return nil, errors.New("ARK_MODEL_ID 未配置")
+	}
+	if cfg.ArkAPIKey == "" && (cfg.ArkAccessKey == "" || cfg.ArkSecretKey == "") {
+		return nil, errors.New("ARK_API_KEY 或 ARK_ACCESS_KEY/ARK_SECRET_KEY 未配置")
+	}
+	chatModel, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
+		APIKey:    cfg.ArkAPIKey,
+		AccessKey: cfg.ArkAccessKey,
+		SecretKey: cfg.ArkSecretKey,
+		Model:     cfg.ArkModelID,
+		BaseURL:   cfg.ArkBaseURL,
+		Region:    cfg.ArkRegion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return arkProvider{model: chatModel}, nil
+}
+
+// Generate 调用 Ark 模型生成回复。
+func (p arkProvider) Generate(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.Message, error) {
+	if len(tools) == 0 {
+		return p.model.Generate(ctx, messages)
+	}
+	return p.model.Generate(ctx, messages, einomodel.WithTools(tools))
+}
+
+// Stream 以流式方式调用 Ark 模型，工具调用的参数分片到达，按 Index 拼接。
+func (p arkProvider) Stream(ctx context.Context, messages []*schema.Message, tools []*schema.ToolInfo) (*schema.StreamReader[*schema.Message], error) {
+	if len(tools) == 0 {
+		return p.model.Stream(ctx, messages)
+	}
+	return p.model.Stream(ctx, messages, einomodel.WithTools(tools))
+}
@@ -0,0 +1,1133 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 解析结果的来源。
+const (
+	ParserModel = "model" // 大模型工具调用
+	ParserRules = "rules" // 规则解析，模型未配置或调用失败时使用
+)
+
+// 规则解析使用的正则，中文数字与阿拉伯数字均可。
+const (
+	cnNumber   = `\d{1,2}|[零一二两三四五六七八九十]{1,3}`
+	cnPeriod   = `凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|今早|明早|今晚|明晚|早|晚`
+	cnMinute   = `半|一刻|三刻|\d{1,2}分?|[零一二三四五六七八九十]{1,3}分`
+	cnClock    = `(` + cnPeriod + `)?\s*(` + cnNumber + `)(?:[:：](\d{2})|[点时](` + cnMinute + `)?)`
+	cnClockOpt = `(` + cnPeriod + `)?\s*(` + cnNumber + `)(?:[:：](\d{2})|[点时](` + cnMinute + `)?)?`
+	enClock    = `(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)?`
+)
+
+var (
+	reCNRange     = regexp.MustCompile(cnClockOpt + `\s*(?:到|至|-|~|－)\s*` + cnClock)
+	reCNClock     = regexp.MustCompile(cnClock)
+	reENRange     = regexp.MustCompile(`(?i)\b(?:from\s+)?` + enClock + `\s*(?:-|to|until|till)\s*` + enClock + `\b`)
+	reENClock     = regexp.MustCompile(`(?i)\b(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)|\bat\s+(\d{1,2})(?::(\d{2}))?\b|\b(noon|midnight)\b`)
+	reISODate     = regexp.MustCompile(`(\d{4})[-/年](\d{1,2})[-/月](\d{1,2})[日号]?`)
+	reCNMonthDay  = regexp.MustCompile(`(` + cnNumber + `)月(` + cnNumber + `)[日号]`)
+	reCNMonthOnly = regexp.MustCompile(`(下个?月)?(` + cnNumber + `)[号]`)
+	reCNRelDay    = regexp.MustCompile(`大后天|后天|明天|明日|明早|明晚|今天|今日|今早|今晚`)
+	reCNWeekday   = regexp.MustCompile(`(这|本|下下|下)?个?(?:周|星期|礼拜)([一二三四五六日天])`)
+	reCNWeek      = regexp.MustCompile(`(这|本|下下|下)个?(?:周|星期|礼拜)`)
+	reCNMonth     = regexp.MustCompile(`(这个|本|下个)月`)
+	reCNNthDay    = regexp.MustCompile(`(这个|本|下个)?(?:月[底末]?)?的?(最后一个|倒数第一个|第(` + cnNumber + `)个)(?:周|星期|礼拜)([一二三四五六日天])`)
+	reENNthDay    = regexp.MustCompile(`(?i)\b(?:the\s+)?(first|second|third|fourth|fifth|last)\s+(mon|tues|wednes|thurs|fri|satur|sun)day\s+(?:of|in)\s+(?:the\s+|this\s+|(next)\s+)?month\b`)
+	reCNVague     = regexp.MustCompile(`月底|月初|月中|月末|年底|年初|年末|周末|上旬|中旬|下旬|下下个?月|上个?月|上周|上个?(?:星期|礼拜)|昨天|前天|明年|后年|去年|改天|过几天|最后一个|倒数第|第(?:` + cnNumber + `)个(?:周|星期|礼拜)`)
+	reENVague     = regexp.MustCompile(`(?i)\b(?:end of (?:the )?(?:month|week|year)|beginning of|start of (?:the )?(?:month|week|year)|weekend|yesterday|last\s+(?:week|month|year|(?:mon|tues|wednes|thurs|fri|satur|sun)day)|next\s+(?:month|year)|some ?time|someday|in a few days)\b`)
+	reCNDayPart   = regexp.MustCompile(`上午|中午|下午|傍晚|晚上|今晚|明晚`)
+	reCNDuration  = regexp.MustCompile(`(一个半|半个?|(?:\d+(?:\.\d+)?|[一两二三四五六七八九十]+)个?半?)\s*(小时|钟头|分钟)`)
+	reENRelDay    = regexp.MustCompile(`(?i)\b(day after tomorrow|tomorrow|today|tonight)\b`)
+	reENWeekday   = regexp.MustCompile(`(?i)\b(?:(this|next)\s+)?(mon|tues|wednes|thurs|fri|satur|sun)day(?:'s)?(?:\s+(this|next)\s+week)?\b`)
+	reENWeek      = regexp.MustCompile(`(?i)\b(this|next)\s+week(?:'s)?\b`)
+	reENDayPart   = regexp.MustCompile(`(?i)\b(?:this\s+|in\s+the\s+)?(morning|afternoon|evening)\b`)
+	reENDuration  = regexp.MustCompile(`(?i)\bfor\s+(an?|one|half\s+an?|\d+(?:\.\d+)?)\s*(hours?|hrs?|h|minutes?|mins?|m)\b`)
+	reCNLocation  = regexp.MustCompile(`在([^，,。\s]{1,20}?)(开会|开|参加|举行|进行|见面|吃饭|上课)`)
+	reENLocation  = regexp.MustCompile(`\b(?:in|at)\s+((?:[Rr]oom\s+\w+)|(?:[A-Z][\w-]*(?:\s+[A-Z0-9][\w-]*)*))`)
+	reCNInvite    = regexp.MustCompile(`邀请([^，,。\s]+?)(?:参加|一起|开|$|[，,。\s])`)
+	reCNSplitName = regexp.MustCompile(`和|与|跟|、|,|，|\band\b`)
+	reCNAfter     = regexp.MustCompile(`(\d+|[一两二三四五六七八九十半]+)个?(小时|钟头|分钟)(?:以?后|之后)`)
+	reCNWorkTime  = regexp.MustCompile(`下班后|下班以后|一上班|上班后`)
+	reENWith      = regexp.MustCompile(`\bwith\s+([A-Z\p{Han}][\w\p{Han}]*(?:\s*(?:,|and|、)\s*[A-Z\p{Han}][\w\p{Han}]*)*)`)
+	reCNWith      = regexp.MustCompile(`和([^，,。\s]+?)(?:开会|开|一起|聊|碰|见面|讨论|$|[，,。\s])`)
+	reCNSchedule  = regexp.MustCompile(`找(?:个|一下)?(?:时间|空|\d|[一两二三四五六七八九十半])|约个?时间|什么时候都?有空`)
+	reENSchedule  = regexp.MustCompile(`(?i)\bfind\s+(?:a\s+)?(?:time|slot|\d|an?\s+hour|half\s+an\s+hour)|\bwhen\s+(?:are|is)\b.*\bfree\b`)
+	reScheduleCue = regexp.MustCompile(`(?i)找(?:个|一下)?(?:时间|空)?|约个?时间|什么时候都?有空|大家|\b(?:find|a|time|slot|when|everyone|is|are|free)\b`)
+	reENSpan      = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?|an?|one|half\s+an?)\s*(hours?|hrs?|minutes?|mins?)\b`)
+	reCNUpdate    = regexp.MustCompile(`改到|改成|改为|推迟到|推到|延后到|延期到|提前到|挪到|移到|换到|调到|调整到`)
+	reENUpdate    = regexp.MustCompile(`(?i)\b(?:move|reschedule|push|shift|change|postpone)\b`)
+	reENTo        = regexp.MustCompile(`(?i)\s+to\s+`)
+	reCNDelete    = regexp.MustCompile(`取消|删除|删掉|去掉|不开了`)
+	reENDelete    = regexp.MustCompile(`(?i)\b(?:cancel|delete|remove|drop)\b`)
+	reCNQuery     = regexp.MustCompile(`有什么|有哪些|有没有|什么安排|哪些安排|查一下|查询|查看|看看|忙不忙|忙吗|空吗|几个会`)
+	reENQuery     = regexp.MustCompile(`(?i)\b(?:what(?:'s| is| do i have| are)|what's on|any (?:meetings|events)|show|list|agenda|am i (?:free|busy)|do i have)\b`)
+	reSpaces      = regexp.MustCompile(`\s+`)
+	reCNZone      = regexp.MustCompile(`(?:按|用)?(北京|上海|香港|台北|新加坡|东京|首尔|悉尼|莫斯科|迪拜|伦敦|巴黎|柏林|纽约|美东|美西|美国东部|美国西部|太平洋|洛杉矶|旧金山|格林尼治|当地|本地)时间`)
+	reENZone      = regexp.MustCompile(`(?i)\b(?:(UTC|GMT)\s*([+-]\d{1,2})(?::?(\d{2}))?|(UTC|GMT)|(beijing|shanghai|hong kong|singapore|tokyo|seoul|sydney|london|paris|berlin|new york|los angeles|san francisco|local)\s+time)\b`)
+	reENZoneAbbr  = regexp.MustCompile(`\b[A-Z]{1,3}[SD]?T\b`)
+)
+
+// cnFiller 为提取标题与关键词时去掉的中文虚词。
+var cnFiller = []string{"帮我", "请", "给我", "提醒我", "安排一下", "安排", "一下", "我要", "我们", "我", "要", "那个", "这个", "把", "的", "了", "吧", "一个", "日程", "去"}
+
+// enFiller 为提取标题与关键词时去掉的英文虚词。
+var enFiller = map[string]bool{
+	"schedule": true, "add": true, "create": true, "set": true, "up": true, "please": true, "a": true, "an": true,
+	"the": true, "on": true, "at": true, "for": true, "my": true, "me": true, "i": true, "have": true, "to": true,
+	"from": true, "book": true, "put": true, "in": true, "calendar": true, "event": true,
+}
+
+// genericTargets 为过于宽泛、不作为匹配关键词的词。
+var genericTargets = map[string]bool{"会": true, "会议": true, "日程": true, "安排": true, "活动": true, "event": true, "meeting": true}
+
+// weekdayIndex 为中英文星期到 time.Weekday 的映射。
+var weekdayIndex = map[string]time.Weekday{
+	"日": time.Sunday, "天": time.Sunday, "一": time.Monday, "二": time.Tuesday, "三": time.Wednesday,
+	"四": time.Thursday, "五": time.Friday, "六": time.Saturday,
+	"sun": time.Sunday, "mon": time.Monday, "tues": time.Tuesday, "wednes": time.Wednesday,
+	"thurs": time.Thursday, "fri": time.Friday, "satur": time.Saturday,
+}
+
+// ruleText 为规则解析中逐步消费的输入，已识别的片段替换为空格，剩余部分用于提取标题与关键词。
+type ruleText struct {
+	text string
+}
+
+// take 匹配并移除首个命中的片段，返回子匹配。
+func (r *ruleText) take(re *regexp.Regexp) []string {
+	loc := re.FindStringSubmatchIndex(r.text)
+	if loc == nil {
+		return nil
+	}
+	match := make([]string, len(loc)/2)
+	for i := range match {
+		if loc[2*i] >= 0 {
+			match[i] = r.text[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	r.text = r.text[:loc[0]] + " " + r.text[loc[1]:]
+	return match
+}
+
+// ruleWhen 为从文本中识别出的日期与时间。
+type ruleWhen struct {
+	date     *time.Time     // 当天零点
+	span     string         // date 所表示的范围：day / week / month
+	start    *time.Duration // 开始钟点，距当天零点的墙上时间
+	end      *time.Duration // 结束钟点
+	dayPart  string         // 上午 / 中午 / 下午 / 晚上，未给出具体钟点时使用
+	duration time.Duration
+	hasClock bool
+	unclear  string // 无法可靠换算的时间表达，非空时不生成提案
+}
+
+// ParseRules 使用规则解析常见的中英文日程表达，如“明天下午3点开会 1小时”“cancel Friday's review”，
+// 不依赖大模型，结果结构与模型解析一致；pending 非空且用户只说了新的时间时，修正最近的待确认提案。
+func ParseRules(user UserContext, pending []StoredProposal, message string) []ParseResult {
+	now := user.localNow()
+	text := strings.TrimSpace(message)
+	zone, cue, ok := takeExplicitZone(&text)
+	if !ok {
+		return []ParseResult{{Intent: "unknown", NeedConfirm: false, Parser: ParserRules,
+			Result: fmt.Sprintf("无法确定“%s”对应的时区，请改用你的本地时间或城市名（如“北京时间”）", cue)}}
+	}
+	if zone != nil {
+		// 显式时区下的日期与钟点按该时区理解，解析后再转换回用户时区。
+		now = now.In(zone)
+	}
+	lower := strings.ToLower(text)
+
+	var result ParseResult
+	switch {
+	case reCNDelete.MatchString(text) || reENDelete.MatchString(lower):
+		result = parseRuleDelete(user, now, text)
+	case reCNUpdate.MatchString(text) || (reENUpdate.MatchString(lower) && reENTo.MatchString(lower)):
+		result = parseRuleUpdate(user, now, pending, text)
+	case reCNSchedule.MatchString(text) || reENSchedule.MatchString(lower):
+		result = parseRuleSchedule(user, now, text)
+	case reCNQuery.MatchString(text) || reENQuery.MatchString(lower) || strings.HasSuffix(text, "？") || strings.HasSuffix(text, "?"):
+		result = parseRuleQuery(user, now, text)
+	default:
+		result = parseRuleCreate(user, now, text)
+	}
+	if zone != nil {
+		result = relocateResult(result, user.location())
+	}
+	result.Parser = ParserRules
+	results := []ParseResult{result}
+	resolveResultParticipants(user.UserID, results)
+	return results
+}
+
+// parseRuleCreate 识别创建日程：需要时间，未给出日期时为今天（时间已过则为明天），未给出结束时间或时长时默认一小时。
+func parseRuleCreate(user UserContext, now time.Time, text string) ParseResult {
+	input := &ruleText{text: text}
+	participants := takeParticipants(input)
+	location := takeLocation(input)
+	when := takeWhen(input, user, now)
+	if when.unclear != "" {
+		return unclearResult("create", when.unclear)
+	}
+	if !when.hasClock && when.dayPart == "" {
+		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "暂仅支持创建、修改、删除和查询日程，请说明日程时间"}
+	}
+	start, end := when.resolve(now, true)
+	title := cleanTitle(input.text)
+	if title == "" {
+		title = "日程"
+	}
+	proposal := Proposal{
+		Action:              "create",
+		Title:               title,
+		Type:                guessEventType(title),
+		StartTime:           start,
+		EndTime:             end,
+		Location:            location,
+		ParticipantKeywords: participants,
+	}
+	return formatResult(proposal)
+}
+
+// parseRuleDelete 识别删除日程：日期与时间作为原日程时间，其余词语作为关键词。
+func parseRuleDelete(user UserContext, now time.Time, text string) ParseResult {
+	input := &ruleText{text: text}
+	input.take(reCNDelete)
+	input.take(reENDelete)
+	when := takeWhen(input, user, now)
+	if when.unclear != "" {
+		return unclearResult("delete", when.unclear)
+	}
+	proposal := Proposal{
+		Action:         "delete",
+		TargetTime:     when.target(now),
+		TargetKeywords: targetKeywords(input.text),
+	}
+	return formatResult(proposal)
+}
+
+// parseRuleUpdate 识别改期：以“改到”“move … to”为界，前半部分定位原日程，后半部分为新的时间；
+// 前半部分没有任何定位信息且会话中有待确认提案时，修正最近的提案。
+func parseRuleUpdate(user UserContext, now time.Time, pending []StoredProposal, text string) ParseResult {
+	var before, after string
+	if loc := reCNUpdate.FindStringIndex(text); loc != nil {
+		before, after = text[:loc[0]], text[loc[1]:]
+	} else {
+		verb := reENUpdate.FindStringIndex(text)
+		rest := text[verb[1]:]
+		to := reENTo.FindAllStringIndex(rest, -1)
+		last := to[len(to)-1]
+		before, after = text[:verb[0]]+" "+rest[:last[0]], rest[last[1]:]
+	}
+
+	target := &ruleText{text: before}
+	targetWhen := takeWhen(target, user, now)
+	keywords := targetKeywords(target.text)
+	change := &ruleText{text: after}
+	newWhen := takeWhen(change, user, now)
+	if unclear := coalesce(targetWhen.unclear, newWhen.unclear); unclear != "" {
+		return unclearResult("update", unclear)
+	}
+	if !newWhen.hasClock && newWhen.date == nil && newWhen.dayPart == "" {
+		return ParseResult{Intent: "update", NeedConfirm: false, Result: "修改日程需要说明新的时间"}
+	}
+
+	if targetWhen.date == nil && !targetWhen.hasClock && len(keywords) == 0 && len(pending) > 0 {
+		latest := pending[len(pending)-1]
+		base := latest.Proposal.StartTime
+		if base == nil {
+			base = latest.Proposal.TargetTime
+		}
+		amended := Proposal{Action: latest.Proposal.Action}
+		amended.StartTime, amended.EndTime = newWhen.resolveFrom(base, now)
+		merged := amendProposal(latest.Proposal, amended)
+		result := formatResult(merged)
+		result.AmendConfirmID = latest.ConfirmID
+		return result
+	}
+
+	// 只给出新的钟点时沿用原日程的日期，只给出新的日期时沿用原日程的钟点。
+	targetTime := targetWhen.target(now)
+	start, end := newWhen.resolveFrom(targetTime, now)
+	proposal := Proposal{
+		Action:         "update",
+		StartTime:      start,
+		EndTime:        end,
+		TargetTime:     targetTime,
+		TargetKeywords: keywords,
+	}
+	return formatResult(proposal)
+}
+
+// parseRuleSchedule 识别为多人查找共同空闲时段，如“下周找30分钟和张三、李四开会”“find 30 minutes with Alice next week”：
+// “下周”“明天”等确定查找范围，未给出时查找此后一周。
+func parseRuleSchedule(user UserContext, now time.Time, text string) ParseResult {
+	input := &ruleText{text: text}
+	participants := takeParticipants(input)
+	if m := input.take(reCNWith); m != nil {
+		participants = append(participants, normalizeKeywords(reCNSplitName.Split(m[1], -1))...)
+	}
+	location := takeLocation(input)
+	var duration time.Duration
+	if m := input.take(reENSpan); m != nil {
+		duration = enDuration(m[1], m[2])
+	}
+	when := takeWhen(input, user, now)
+	if when.unclear != "" {
+		return unclearResult("schedule", when.unclear)
+	}
+	if duration == 0 {
+		duration = when.duration
+	}
+
+	proposal := Proposal{
+		Action:              "schedule",
+		Location:            location,
+		ParticipantKeywords: normalizeKeywords(participants),
+		DurationMinutes:     int(duration / time.Minute),
+	}
+	proposal.Title = cleanTitle(reScheduleCue.ReplaceAllString(input.text, " "))
+	if proposal.Title == "" {
+		proposal.Title = "会议"
+	}
+	proposal.Type = guessEventType(proposal.Title)
+	if proposal.DurationMinutes < minScheduleMinutes || proposal.DurationMinutes > maxScheduleMinutes {
+		return ParseResult{Intent: "schedule", NeedConfirm: false, Result: "安排日程需要会议时长，如“找30分钟”"}
+	}
+
+	var windowStart, windowEnd *time.Time
+	if when.date != nil {
+		start := *when.date
+		var end time.Time
+		switch when.span {
+		case "week":
+			end = start.AddDate(0, 0, 7)
+		case "month":
+			end = start.AddDate(0, 1, 0)
+		default:
+			end = start.AddDate(0, 0, 1)
+		}
+		windowStart, windowEnd = &start, &end
+	}
+	start, end, err := scheduleWindow(windowStart, windowEnd, now)
+	if err != nil {
+		return invalidToolResult("schedule", err)
+	}
+	proposal.StartTime, proposal.EndTime = start, end
+	return formatResult(proposal)
+}
+
+// parseRuleQuery 识别查询：日期、星期、“下周”“这个月”与上午下午等时段确定查询范围，未给出时查询今天。
+func parseRuleQuery(user UserContext, now time.Time, text string) ParseResult {
+	input := &ruleText{text: text}
+	when := takeWhen(input, user, now)
+	if when.unclear != "" {
+		return unclearResult("query", when.unclear)
+	}
+	proposal := Proposal{Action: "query"}
+	if when.date != nil {
+		start := *when.date
+		var end time.Time
+		switch when.span {
+		case "week":
+			end = start.AddDate(0, 0, 7)
+		case "month":
+			end = start.AddDate(0, 1, 0)
+		default:
+			end = start.AddDate(0, 0, 1)
+			if from, to, ok := dayPartRange(when.dayPart); ok && !when.hasClock {
+				start, end = atClock(start, from), atClock(start, to)
+			}
+		}
+		proposal.StartTime, proposal.EndTime = &start, &end
+	} else if from, to, ok := dayPartRange(when.dayPart); ok {
+		today := startOfDay(now)
+		start, end := atClock(today, from), atClock(today, to)
+		proposal.StartTime, proposal.EndTime = &start, &end
+	}
+	return formatResult(proposal)
+}
+
+// takeWhen 依次识别日期、时间范围、钟点、时段与时长，“下班后”“一上班”按用户的工作时间理解。
+func takeWhen(input *ruleText, user UserContext, now time.Time) ruleWhen {
+	var when ruleWhen
+	today := startOfDay(now)
+	weekStart := user.WeekStart
+	setDate := func(date time.Time, span string) {
+		if when.date == nil {
+			when.date, when.span = &date, span
+		}
+	}
+
+	// “这个月最后一个周五”“first Monday of next month”须先于星期与“这个月”识别。
+	if m := input.take(reCNNthDay); m != nil {
+		nth := -1
+		if m[3] != "" {
+			value, ok := parseCNNumber(m[3])
+			if !ok {
+				value = 0
+			}
+			nth = value
+		}
+		date, ok := nthWeekdayOfMonth(today, m[1] == "下个", m[1] != "", weekdayIndex[m[4]], nth)
+		if !ok {
+			when.unclear = m[0]
+			return when
+		}
+		setDate(date, "day")
+	} else if m := input.take(reENNthDay); m != nil {
+		nth := map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1}[strings.ToLower(m[1])]
+		date, ok := nthWeekdayOfMonth(today, m[3] != "", strings.Contains(strings.ToLower(m[0]), "this"), weekdayIndex[strings.ToLower(m[2])], nth)
+		if !ok {
+			when.unclear = m[0]
+			return when
+		}
+		setDate(date, "day")
+	}
+	// 规则无法可靠换算的表达（如“月底”“周末”“上周”）不猜测，避免生成可确认的错误时间。
+	if vague := coalesce(reCNVague.FindString(input.text), reENVague.FindString(input.text)); vague != "" {
+		when.unclear = vague
+		return when
+	}
+
+	if m := input.take(reISODate); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		setDate(time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location()), "day")
+	}
+	if m := input.take(reCNMonthDay); m != nil {
+		month, okMonth := parseCNNumber(m[1])
+		day, okDay := parseCNNumber(m[2])
+		if okMonth && okDay {
+			date := time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, now.Location())
+			if date.Before(today) {
+				date = date.AddDate(1, 0, 0)
+			}
+			setDate(date, "day")
+		}
+	}
+	if m := input.take(reCNRelDay); m != nil {
+		switch m[0] {
+		case "明天", "明日", "明早", "明晚":
+			setDate(today.AddDate(0, 0, 1), "day")
+		case "后天":
+			setDate(today.AddDate(0, 0, 2), "day")
+		case "大后天":
+			setDate(today.AddDate(0, 0, 3), "day")
+		default:
+			setDate(today, "day")
+		}
+		switch m[0] {
+		case "今晚", "明晚":
+			when.dayPart = "晚上"
+		case "今早", "明早":
+			when.dayPart = "上午"
+		}
+	}
+	if m := input.take(reENRelDay); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "tomorrow":
+			setDate(today.AddDate(0, 0, 1), "day")
+		case "day after tomorrow":
+			setDate(today.AddDate(0, 0, 2), "day")
+		case "tonight":
+			setDate(today, "day")
+			when.dayPart = "晚上"
+		default:
+			setDate(today, "day")
+		}
+	}
+	if m := input.take(reCNWeekday); m != nil {
+		setDate(weekdayDate(today, weekStart, weekdayIndex[m[2]], cnWeekOffset(m[1])), "day")
+	}
+	if m := input.take(reENWeekday); m != nil {
+		offset := -1
+		switch strings.ToLower(coalesce(m[3], m[1])) {
+		case "this":
+			offset = 0
+		case "next":
+			offset = 1
+		}
+		setDate(weekdayDate(today, weekStart, weekdayIndex[strings.ToLower(m[2])], offset), "day")
+	}
+	if m := input.take(reCNWeek); m != nil {
+		setDate(weekStartDate(today, weekStart).AddDate(0, 0, 7*cnWeekOffset(m[1])), "week")
+	}
+	if m := input.take(reENWeek); m != nil {
+		offset := 0
+		if strings.EqualFold(m[1], "next") {
+			offset = 1
+		}
+		setDate(weekStartDate(today, weekStart).AddDate(0, 0, 7*offset), "week")
+	}
+	if m := input.take(reCNMonthOnly); m != nil {
+		if day, ok := parseCNNumber(m[2]); ok && day >= 1 && day <= 31 {
+			date := time.Date(now.Year(), now.Month(), day, 0, 0, 0, 0, now.Location())
+			if m[1] != "" || date.Before(today) {
+				date = time.Date(now.Year(), now.Month()+1, day, 0, 0, 0, 0, now.Location())
+			}
+			setDate(date, "day")
+		}
+	}
+	if m := input.take(reCNMonth); m != nil {
+		first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if m[1] == "下个" {
+			first = first.AddDate(0, 1, 0)
+		}
+		setDate(first, "month")
+	}
+	if m := input.take(reENDayPart); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "morning":
+			when.dayPart = "上午"
+		case "afternoon":
+			when.dayPart = "下午"
+		default:
+			when.dayPart = "晚上"
+		}
+	}
+
+	if m := input.take(reCNAfter); m != nil {
+		if offset := cnDuration(m[1], m[2]); offset > 0 {
+			at := now.Add(offset)
+			day := startOfDay(at)
+			clock := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+			when.date, when.span, when.start, when.hasClock = &day, "day", &clock, true
+		}
+	} else if m := input.take(reCNWorkTime); m != nil {
+		workTime := user.WorkStart
+		if strings.HasPrefix(m[0], "下班") {
+			workTime = user.WorkEnd
+		}
+		if clock, err := time.Parse("15:04", workTime); err == nil {
+			value := time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+			when.start, when.hasClock = &value, true
+		}
+	} else if m := input.take(reCNRange); m != nil {
+		start, okStart := cnClockValue(m[1], m[2], m[3], m[4])
+		endPeriod := m[5]
+		if endPeriod == "" {
+			endPeriod = m[1]
+		}
+		end, okEnd := cnClockValue(endPeriod, m[6], m[7], m[8])
+		if okStart && okEnd {
+			if m[1] == "" && m[5] != "" && start+12*time.Hour <= end {
+				start += 12 * time.Hour
+			}
+			if end <= start && end < 12*time.Hour {
+				end += 12 * time.Hour
+			}
+			when.start, when.end, when.hasClock = &start, &end, true
+		}
+	} else if m := input.take(reENRange); m != nil {
+		end, okEnd := enClockValue(m[4], m[5], m[6])
+		startSuffix := m[3]
+		if startSuffix == "" {
+			startSuffix = m[6]
+		}
+		start, okStart := enClockValue(m[1], m[2], startSuffix)
+		if okStart && okEnd {
+			if start >= end && m[3] == "" {
+				start -= 12 * time.Hour
+			}
+			when.start, when.end, when.hasClock = &start, &end, true
+		}
+	} else if m := input.take(reCNClock); m != nil {
+		if clock, ok := cnClockValue(coalesce(m[1], when.dayPart), m[2], m[3], m[4]); ok {
+			when.start, when.hasClock = &clock, true
+		}
+	} else if m := input.take(reENClock); m != nil {
+		var clock time.Duration
+		ok := true
+		switch {
+		case m[6] != "":
+			clock = 12 * time.Hour
+			if strings.EqualFold(m[6], "midnight") {
+				clock = 0
+			}
+		case m[1] != "":
+			clock, ok = enClockValue(m[1], m[2], m[3])
+		default:
+			clock, ok = enClockValue(m[4], m[5], "")
+			if ok && when.dayPart != "" && clock < 12*time.Hour {
+				clock += 12 * time.Hour
+			}
+		}
+		if ok {
+			when.start, when.hasClock = &clock, true
+		}
+	}
+
+	if m := input.take(reCNDayPart); m != nil && when.dayPart == "" {
+		when.dayPart = normalizeDayPart(m[0])
+	}
+	if m := input.take(reCNDuration); m != nil {
+		when.duration = cnDuration(m[1], m[2])
+	} else if m := input.take(reENDuration); m != nil {
+		when.duration = enDuration(m[1], m[2])
+	}
+	return when
+}
+
+// resolve 计算开始与结束时间：未给出日期时为今天，future 为 true 且时间已过时顺延到明天；只有时段时取时段的默认开始时间。
+func (w ruleWhen) resolve(now time.Time, future bool) (*time.Time, *time.Time) {
+	clock := w.start
+	if clock == nil {
+		if value, ok := dayPartStart(w.dayPart); ok {
+			clock = &value
+		}
+	}
+	if clock == nil {
+		return nil, nil
+	}
+	day := startOfDay(now)
+	if w.date != nil {
+		day = *w.date
+	}
+	start := atClock(day, *clock)
+	if w.date == nil && future && !start.After(now) {
+		start = start.AddDate(0, 0, 1)
+		day = day.AddDate(0, 0, 1)
+	}
+	var end time.Time
+	switch {
+	case w.end != nil:
+		end = atClock(day, *w.end)
+	case w.duration > 0:
+		end = start.Add(w.duration)
+	default:
+		end = start.Add(time.Hour)
+	}
+	return &start, &end
+}
+
+// resolveFrom 计算改期后的时间：只给出钟点时沿用 base 的日期，只给出日期时沿用 base 的钟点；
+// 未给出结束时间与时长时结束时间留空，由原日程时长决定。
+func (w ruleWhen) resolveFrom(base *time.Time, now time.Time) (*time.Time, *time.Time) {
+	day := startOfDay(now)
+	if base != nil {
+		local := base.In(now.Location())
+		base = &local
+		day = startOfDay(local)
+	}
+	if w.date != nil {
+		day = *w.date
+	}
+	var clock time.Duration
+	switch {
+	case w.start != nil:
+		clock = *w.start
+	case w.dayPart != "":
+		clock, _ = dayPartStart(w.dayPart)
+	case base != nil:
+		clock = time.Duration(base.Hour())*time.Hour + time.Duration(base.Minute())*time.Minute
+	default:
+		return nil, nil
+	}
+	start := atClock(day, clock)
+	switch {
+	case w.end != nil:
+		end := atClock(day, *w.end)
+		return &start, &end
+	case w.duration > 0:
+		end := start.Add(w.duration)
+		return &start, &end
+	}
+	return &start, nil
+}
+
+// target 返回用于定位原日程的时间，只给出钟点时为今天。
+func (w ruleWhen) target(now time.Time) *time.Time {
+	if w.date == nil && !w.hasClock {
+		return nil
+	}
+	day := startOfDay(now)
+	if w.date != nil {
+		day = *w.date
+	}
+	if w.start != nil {
+		day = atClock(day, *w.start)
+	}
+	return &day
+}
+
+// takeParticipants 提取“邀请张三和李四”“with Alice and Bob”中的参与人。
+func takeParticipants(input *ruleText) []string {
+	var raw string
+	if m := input.take(reCNInvite); m != nil {
+		raw = m[1]
+	} else if m := input.take(reENWith); m != nil {
+		raw = m[1]
+	}
+	if raw == "" {
+		return nil
+	}
+	parts := reCNSplitName.Split(raw, -1)
+	return normalizeKeywords(parts)
+}
+
+// takeLocation 提取“在3楼会议室开会”“in Room 502”中的地点。
+func takeLocation(input *ruleText) string {
+	if loc := reCNLocation.FindStringSubmatchIndex(input.text); loc != nil {
+		location := input.text[loc[2]:loc[3]]
+		input.text = input.text[:loc[0]] + " " + input.text[loc[4]:]
+		return location
+	}
+	if m := input.take(reENLocation); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// cleanTitle 去掉虚词与标点后作为标题。
+func cleanTitle(text string) string {
+	for _, word := range cnFiller {
+		text = strings.ReplaceAll(text, word, " ")
+	}
+	text = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("，,。.！!？?；;：:、'\"“”()（）", r) {
+			return ' '
+		}
+		return r
+	}, text)
+	words := make([]string, 0)
+	for _, word := range strings.Fields(text) {
+		if enFiller[strings.ToLower(word)] {
+			continue
+		}
+		words = append(words, word)
+	}
+	title := strings.Join(words, " ")
+	if runes := []rune(title); len(runes) > 50 {
+		title = string(runes[:50])
+	}
+	return strings.TrimSpace(reSpaces.ReplaceAllString(title, " "))
+}
+
+// targetKeywords 将剩余文本作为匹配原日程的关键词，过于宽泛的词不作为关键词。
+func targetKeywords(text string) []string {
+	title := cleanTitle(text)
+	var keywords []string
+	for _, word := range strings.Fields(title) {
+		if genericTargets[strings.ToLower(word)] {
+			continue
+		}
+		keywords = append(keywords, word)
+	}
+	return normalizeKeywords(keywords)
+}
+
+// guessEventType 按标题关键词推断日程类型，默认为 work。
+func guessEventType(title string) string {
+	lower := strings.ToLower(title)
+	for _, word := range []string{"学习", "课", "读书", "培训", "考试", "study", "class", "course", "learn", "read"} {
+		if strings.Contains(lower, word) {
+			return "growth"
+		}
+	}
+	for _, word := range []string{"吃饭", "聚餐", "午饭", "晚饭", "健身", "跑步", "爬山", "看病", "体检", "电影", "购物", "聚会", "dinner", "lunch", "gym", "run", "movie", "doctor", "party"} {
+		if strings.Contains(lower, word) {
+			return "life"
+		}
+	}
+	return "work"
+}
+
+// cnClockValue 将中文时段、小时与分钟转换为距零点的时间；没有时段的 1-6 点按下午理解。
+func cnClockValue(period, hourText, colonMinute, minuteText string) (time.Duration, bool) {
+	hour, ok := parseCNNumber(hourText)
+	if !ok || hour > 24 {
+		return 0, false
+	}
+	minute := 0
+	switch {
+	case colonMinute != "":
+		minute, _ = strconv.Atoi(colonMinute)
+	case minuteText == "半":
+		minute = 30
+	case minuteText == "一刻":
+		minute = 15
+	case minuteText == "三刻":
+		minute = 45
+	case minuteText != "":
+		minute, ok = parseCNNumber(strings.TrimSuffix(minuteText, "分"))
+		if !ok {
+			return 0, false
+		}
+	}
+	if minute > 59 {
+		return 0, false
+	}
+	switch normalizeDayPart(period) {
+	case "中午":
+		if hour < 6 {
+			hour += 12
+		}
+	case "下午", "晚上":
+		if hour < 12 {
+			hour += 12
+		}
+	case "上午":
+	default:
+		if period == "" && hour >= 1 && hour <= 6 {
+			hour += 12
+		}
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, true
+}
+
+// enClockValue 将英文钟点转换为距零点的时间；没有 am/pm 的 1-6 点按下午理解。
+func enClockValue(hourText, minuteText, suffix string) (time.Duration, bool) {
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || hour > 24 {
+		return 0, false
+	}
+	minute := 0
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	if minute > 59 {
+		return 0, false
+	}
+	switch strings.ReplaceAll(strings.ToLower(suffix), ".", "") {
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	default:
+		if hour >= 1 && hour <= 6 {
+			hour += 12
+		}
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, true
+}
+
+// cnDuration 解析“1小时”“半小时”“一个半小时”“30分钟”等时长。
+func cnDuration(amount, unit string) time.Duration {
+	var hours float64
+	switch {
+	case amount == "一个半":
+		hours = 1.5
+	case strings.HasPrefix(amount, "半"):
+		hours = 0.5
+	default:
+		half := strings.HasSuffix(amount, "半")
+		number := strings.TrimSuffix(strings.TrimSuffix(amount, "半"), "个")
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			parsed, ok := parseCNNumber(number)
+			if !ok {
+				return 0
+			}
+			value = float64(parsed)
+		}
+		if half {
+			value += 0.5
+		}
+		hours = value
+	}
+	if unit == "分钟" {
+		return time.Duration(hours * float64(time.Minute))
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// enDuration 解析“for an hour”“for 30 minutes”等时长。
+func enDuration(amount, unit string) time.Duration {
+	amount = strings.ToLower(strings.Join(strings.Fields(amount), " "))
+	var value float64
+	switch amount {
+	case "a", "an", "one":
+		value = 1
+	case "half a", "half an":
+		value = 0.5
+	default:
+		parsed, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return 0
+		}
+		value = parsed
+	}
+	if strings.HasPrefix(strings.ToLower(unit), "m") {
+		return time.Duration(value * float64(time.Minute))
+	}
+	return time.Duration(value * float64(time.Hour))
+}
+
+// parseCNNumber 解析阿拉伯数字或 0-99 的中文数字。
+func parseCNNumber(text string) (int, bool) {
+	if text == "" {
+		return 0, false
+	}
+	if value, err := strconv.Atoi(text); err == nil {
+		return value, true
+	}
+	digits := map[rune]int{'零': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	if idx := strings.Index(text, "十"); idx >= 0 {
+		tens, ones := 1, 0
+		if left := text[:idx]; left != "" {
+			value, ok := digits[[]rune(left)[0]]
+			if !ok || utf8.RuneCountInString(left) != 1 {
+				return 0, false
+			}
+			tens = value
+		}
+		if right := text[idx+len("十"):]; right != "" {
+			value, ok := digits[[]rune(right)[0]]
+			if !ok || utf8.RuneCountInString(right) != 1 {
+				return 0, false
+			}
+			ones = value
+		}
+		return tens*10 + ones, true
+	}
+	if utf8.RuneCountInString(text) != 1 {
+		return 0, false
+	}
+	value, ok := digits[[]rune(text)[0]]
+	return value, ok
+}
+
+// normalizeDayPart 将时段词归一为上午 / 中午 / 下午 / 晚上。
+func normalizeDayPart(value string) string {
+	switch value {
+	case "凌晨", "早上", "早晨", "上午", "今早", "明早", "早":
+		return "上午"
+	case "中午":
+		return "中午"
+	case "下午":
+		return "下午"
+	case "傍晚", "晚上", "今晚", "明晚", "晚":
+		return "晚上"
+	}
+	return ""
+}
+
+// dayPartStart 返回只说了时段（如“明天下午”）时日程的默认开始时间。
+func dayPartStart(dayPart string) (time.Duration, bool) {
+	switch dayPart {
+	case "上午":
+		return 9 * time.Hour, true
+	case "中午":
+		return 12 * time.Hour, true
+	case "下午":
+		return 14 * time.Hour, true
+	case "晚上":
+		return 19 * time.Hour, true
+	}
+	return 0, false
+}
+
+// dayPartRange 返回时段的查询范围。
+func dayPartRange(dayPart string) (time.Duration, time.Duration, bool) {
+	switch dayPart {
+	case "上午":
+		return 0, 12 * time.Hour, true
+	case "中午":
+		return 11 * time.Hour, 14 * time.Hour, true
+	case "下午":
+		return 12 * time.Hour, 18 * time.Hour, true
+	case "晚上":
+		return 18 * time.Hour, 24 * time.Hour, true
+	}
+	return 0, 0, false
+}
+
+// cnZoneNames 为“北京时间”等中文时区说法对应的 IANA 时区；“当地时间”不在其中，需要用户说明。
+var cnZoneNames = map[string]string{
+	"北京": "Asia/Shanghai", "上海": "Asia/Shanghai", "香港": "Asia/Hong_Kong", "台北": "Asia/Taipei",
+	"新加坡": "Asia/Singapore", "东京": "Asia/Tokyo", "首尔": "Asia/Seoul", "悉尼": "Australia/Sydney",
+	"莫斯科": "Europe/Moscow", "迪拜": "Asia/Dubai", "伦敦": "Europe/London", "巴黎": "Europe/Paris",
+	"柏林": "Europe/Berlin", "纽约": "America/New_York", "美东": "America/New_York", "美国东部": "America/New_York",
+	"美西": "America/Los_Angeles", "美国西部": "America/Los_Angeles", "太平洋": "America/Los_Angeles",
+	"洛杉矶": "America/Los_Angeles", "旧金山": "America/Los_Angeles", "格林尼治": "UTC",
+}
+
+// enZoneNames 为英文城市名与无歧义的时区缩写对应的 IANA 时区；CST、IST 等有多种含义的缩写不在其中，需要用户说明。
+var enZoneNames = map[string]string{
+	"beijing": "Asia/Shanghai", "shanghai": "Asia/Shanghai", "hong kong": "Asia/Hong_Kong", "singapore": "Asia/Singapore",
+	"tokyo": "Asia/Tokyo", "seoul": "Asia/Seoul", "sydney": "Australia/Sydney", "london": "Europe/London",
+	"paris": "Europe/Paris", "berlin": "Europe/Berlin", "new york": "America/New_York",
+	"los angeles": "America/Los_Angeles", "san francisco": "America/Los_Angeles",
+	"PT": "America/Los_Angeles", "PST": "America/Los_Angeles", "PDT": "America/Los_Angeles",
+	"ET": "America/New_York", "EST": "America/New_York", "EDT": "America/New_York",
+	"CET": "Europe/Paris", "CEST": "Europe/Paris", "BST": "Europe/London", "JST": "Asia/Tokyo",
+	"KST": "Asia/Seoul", "SGT": "Asia/Singapore", "HKT": "Asia/Hong_Kong", "AEST": "Australia/Sydney", "AEDT": "Australia/Sydney",
+}
+
+// takeExplicitZone 识别并移除“北京时间”“3pm UTC+8”“10am PT”等显式时区，返回对应时区，没有显式时区时返回 nil；
+// 识别到时区说法却无法确定具体时区（如“当地时间”“CST”）时 ok 为 false，cue 为该说法。
+func takeExplicitZone(text *string) (zone *time.Location, cue string, ok bool) {
+	remove := func(loc []int) {
+		*text = (*text)[:loc[0]] + " " + (*text)[loc[1]:]
+	}
+	if m := reCNZone.FindStringSubmatchIndex(*text); m != nil {
+		cue = (*text)[m[0]:m[1]]
+		id, known := cnZoneNames[(*text)[m[2]:m[3]]]
+		if !known {
+			return nil, cue, false
+		}
+		return loadZone(id, cue, func() { remove(m) })
+	}
+	if m := reENZone.FindStringSubmatchIndex(*text); m != nil {
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return (*text)[m[2*i]:m[2*i+1]]
+		}
+		cue = group(0)
+		switch {
+		case group(2) != "":
+			hours, _ := strconv.Atoi(group(2))
+			minutes, _ := strconv.Atoi(group(3))
+			if hours < -12 || hours > 14 || minutes > 59 {
+				return nil, cue, false
+			}
+			offset := hours*3600 + minutes*60
+			if strings.HasPrefix(group(2), "-") {
+				offset = hours*3600 - minutes*60
+			}
+			remove(m)
+			return time.FixedZone(strings.ToUpper(group(1))+group(2), offset), cue, true
+		case group(4) != "":
+			remove(m)
+			return time.UTC, cue, true
+		}
+		id, known := enZoneNames[strings.ToLower(strings.Join(strings.Fields(group(5)), " "))]
+		if !known {
+			return nil, cue, false
+		}
+		return loadZone(id, cue, func() { remove(m) })
+	}
+	for _, m := range reENZoneAbbr.FindAllStringIndex(*text, -1) {
+		abbr := (*text)[m[0]:m[1]]
+		if id, known := enZoneNames[abbr]; known {
+			return loadZone(id, abbr, func() { remove(m) })
+		}
+		// 形似时区缩写（以 ST / DT 结尾）但无法确定的，如 CST、IST；IT、PPT 等普通缩写忽略。
+		if strings.HasSuffix(abbr, "ST") || strings.HasSuffix(abbr, "DT") {
+			return nil, abbr, false
+		}
+	}
+	return nil, "", true
+}
+
+// loadZone 加载 IANA 时区，成功时调用 consume 移除时区说法。
+func loadZone(id, cue string, consume func()) (*time.Location, string, bool) {
+	zone, err := time.LoadLocation(id)
+	if err != nil {
+		return nil, cue, false
+	}
+	consume()
+	return zone, cue, true
+}
+
+// relocateResult 将按显式时区解析出的时间转换到用户时区，并重新生成结果说明。
+func relocateResult(result ParseResult, loc *time.Location) ParseResult {
+	if result.Proposal.Action == "" {
+		return result
+	}
+	proposal := result.Proposal
+	for _, value := range []**time.Time{&proposal.StartTime, &proposal.EndTime, &proposal.TargetTime} {
+		if *value != nil {
+			converted := (*value).In(loc)
+			*value = &converted
+		}
+	}
+	relocated := formatResult(proposal)
+	relocated.AmendConfirmID = result.AmendConfirmID
+	return relocated
+}
+
+// nthWeekdayOfMonth 返回当月（next 为 true 时为下个月）第 nth 个星期 weekday，nth 为 -1 表示最后一个；
+// 未明确月份且该日期已过去时顺延到下个月，明确说了“这个月”却已过去或当月没有第 nth 个时返回 false。
+func nthWeekdayOfMonth(today time.Time, next, explicit bool, weekday time.Weekday, nth int) (time.Time, bool) {
+	if nth == 0 || nth > 5 {
+		return time.Time{}, false
+	}
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	if next {
+		first = first.AddDate(0, 1, 0)
+	}
+	pick := func(first time.Time) (time.Time, bool) {
+		if nth < 0 {
+			last := first.AddDate(0, 1, -1)
+			return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7)), true
+		}
+		date := first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(nth-1))
+		return date, date.Month() == first.Month()
+	}
+	date, ok := pick(first)
+	if ok && !next && date.Before(today) {
+		if explicit {
+			return time.Time{}, false
+		}
+		date, ok = pick(first.AddDate(0, 1, 0))
+	}
+	return date, ok
+}
+
+// unclearResult 返回要求用户说明具体时间的结果，不生成可确认的提案。
+func unclearResult(intent, expression string) ParseResult {
+	return ParseResult{Intent: intent, NeedConfirm: false,
+		Result: fmt.Sprintf("暂时无法确定“%s”对应的时间，请换成具体日期，如“10月30日下午4点”", expression)}
+}
+
+// cnWeekOffset 返回“这周”“下周”“下下周”相对本周的周数。
+func cnWeekOffset(prefix string) int {
+	switch prefix {
+	case "下":
+		return 1
+	case "下下":
+		return 2
+	case "这", "本":
+		return 0
+	}
+	return -1
+}
+
+// weekStartDate 返回 day 所在周的起始日。
+func weekStartDate(day time.Time, weekStart time.Weekday) time.Time {
+	offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// weekdayDate 返回相对本周 offset 周的星期 weekday；offset 为 -1 表示未指定，取今天或之后最近的一天。
+func weekdayDate(today time.Time, weekStart, weekday time.Weekday, offset int) time.Time {
+	if offset < 0 {
+		return today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+7)%7)
+	}
+	start := weekStartDate(today, weekStart)
+	return start.AddDate(0, 0, 7*offset+(int(weekday)-int(weekStart)+7)%7)
+}
+
+// atClock 返回 day 当天的墙上时间 clock，夏令时切换当天同样按钟点计算。
+func atClock(day time.Time, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(clock/time.Minute), 0, 0, day.Location())
+}
+
+// startOfDay 返回 value 当天零点。
+func startOfDay(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
+}
+
+// coalesce 返回第一个非空字符串。
+func coalesce(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
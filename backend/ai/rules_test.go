@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+// ruleTestUser 返回在 timezone 时区、当前时间为 now（RFC3339）的用户上下文。
+func ruleTestUser(t *testing.T, timezone, now string) UserContext {
+	t.Helper()
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	parsed, err := time.Parse(time.RFC3339, now)
+	if err != nil {
+		t.Fatalf("parse now: %v", err)
+	}
+	return UserContext{Now: parsed.In(loc), Location: loc, Locale: "zh-CN", WeekStart: time.Monday, WorkStart: "09:00", WorkEnd: "18:00"}
+}
+
+func TestParseRulesResolvesTimes(t *testing.T) {
+	cases := []struct {
+		name     string
+		timezone string
+		message  string
+		start    string
+	}{
+		{"这个月最后一个周五", "Asia/Shanghai", "这个月最后一个周五下午4点做月度复盘", "2026-10-30T16:00:00+08:00"},
+		{"月底最后一个周五", "Asia/Shanghai", "月底最后一个周五下午4点复盘", "2026-10-30T16:00:00+08:00"},
+		{"下个月第一个周一", "Asia/Shanghai", "下个月第一个周一上午10点季度规划", "2026-11-02T10:00:00+08:00"},
+		{"已过去的第一个周一顺延", "Asia/Shanghai", "第一个周一上午10点例会", "2026-11-02T10:00:00+08:00"},
+		{"last Friday of the month", "Asia/Shanghai", "review on the last Friday of the month at 4pm", "2026-10-30T16:00:00+08:00"},
+		{"北京时间", "Asia/Tokyo", "明天北京时间下午3点和上海团队开会", "2026-10-19T16:00:00+09:00"},
+		{"UTC 偏移", "Asia/Shanghai", "tomorrow at 9am UTC+1 sync", "2026-10-19T16:00:00+08:00"},
+		{"时区缩写", "Asia/Shanghai", "tomorrow at 9am PT standup", "2026-10-19T00:00:00+08:00"},
+		{"普通缩写不是时区", "Asia/Shanghai", "明天下午3点IT部门开会", "2026-10-19T15:00:00+08:00"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := ruleTestUser(t, tc.timezone, "2026-10-18T10:00:00+08:00")
+			results := ParseRules(user, nil, tc.message)
+			if len(results) != 1 || !results[0].NeedConfirm {
+				t.Fatalf("results = %+v", results)
+			}
+			got := results[0].Proposal.StartTime
+			if got == nil || got.Format(time.RFC3339) != tc.start {
+				t.Fatalf("start = %v, want %s (%s)", got, tc.start, results[0].Result)
+			}
+		})
+	}
+}
+
+func TestParseRulesAsksToClarifyInsteadOfGuessing(t *testing.T) {
+	messages := []string{
+		"月底开个复盘会 下午4点",
+		"这个月第五个周一上午10点例会",
+		"周末下午3点聚餐",
+		"上周五下午3点的会改到下午4点",
+		"明天当地时间下午3点开会",
+		"tomorrow at 3pm CST call",
+		"lunch at noon next month",
+	}
+	for _, message := range messages {
+		t.Run(message, func(t *testing.T) {
+			user := ruleTestUser(t, "Asia/Shanghai", "2026-10-18T10:00:00+08:00")
+			results := ParseRules(user, nil, message)
+			if len(results) != 1 {
+				t.Fatalf("results = %+v", results)
+			}
+			if results[0].NeedConfirm || results[0].Proposal.StartTime != nil {
+				t.Fatalf("confirmable proposal for %q: %+v", message, results[0])
+			}
+			if results[0].Parser != ParserRules {
+				t.Fatalf("parser = %s", results[0].Parser)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound 表示会话不存在或不属于当前用户。
+var ErrSessionNotFound = errors.New("ai session not found")
+
+const (
+	sessionTitleLength   = 30 // 会话标题截取首条消息的字符数
+	historyLoadLimit     = 50 // 每次最多读取的历史消息条数，再按 token 预算裁剪
+	messageTokenOverhead = 4  // 每条消息除正文外的估算开销
+)
+
+// Turn 表示会话历史中的一条消息。
+type Turn struct {
+	Role    string
+	Content string
+}
+
+// CreateSession 以首条用户消息为标题创建会话。
+func CreateSession(userID uint, message string) (model.AISession, error) {
+	title := strings.Join(strings.Fields(message), " ")
+	if runes := []rune(title); len(runes) > sessionTitleLength {
+		title = string(runes[:sessionTitleLength]) + "…"
+	}
+	session := model.AISession{UserID: userID, Title: title}
+	if err := model.DB.Create(&session).Error; err != nil {
+		return model.AISession{}, err
+	}
+	return session, nil
+}
+
+// FindSession 查询当前用户的会话。
+func FindSession(userID, sessionID uint) (model.AISession, error) {
+	var session model.AISession
+	if err := model.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.AISession{}, ErrSessionNotFound
+		}
+		return model.AISession{}, err
+	}
+	return session, nil
+}
+
+// DeleteSession 删除会话及其全部消息。
+func DeleteSession(session model.AISession) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", session.ID).Delete(&model.AIMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.AISession{}, session.ID).Error
+	})
+}
+
+// LoadHistory 按时间顺序返回会话最近的消息，发送前由 ParseMessage 按 token 预算裁剪。
+func LoadHistory(sessionID uint) ([]Turn, error) {
+	var messages []model.AIMessage
+	if err := model.DB.Where("session_id = ?", sessionID).
+		Order("id desc").Limit(historyLoadLimit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	turns := make([]Turn, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		turns = append(turns, Turn{Role: messages[i].Role, Content: messages[i].Content})
+	}
+	return turns, nil
+}
+
+// AppendMessages 追加会话消息并刷新会话的更新时间。
+func AppendMessages(sessionID uint, messages ...model.AIMessage) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range messages {
+			messages[i].SessionID = sessionID
+			if err := tx.Create(&messages[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.AISession{}).Where("id = ?", sessionID).Update("updated_at", time.Now()).Error
+	})
+}
+
+// trimHistory 从最新的消息往前保留不超过 budget 的历史，并保证首条为用户消息。
+func trimHistory(turns []Turn, budget int) []Turn {
+	if budget <= 0 {
+		return nil
+	}
+	used := 0
+	start := len(turns)
+	for start > 0 {
+		cost := estimateTokens(turns[start-1].Content) + messageTokenOverhead
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	for start < len(turns) && turns[start].Role != model.AIRoleUser {
+		start++
+	}
+	return turns[start:]
+}
+
+// estimateTokens 粗略估算文本的 token 数：中文等非 ASCII 字符按 1 个计，ASCII 字符按 4 个计 1 个。
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return other + (ascii+3)/4
+}
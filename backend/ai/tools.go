@@ -0,0 +1,414 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 模型可调用的日程工具名称。
+const (
+	ToolCreateEvent   = "create_event"
+	ToolUpdateEvent   = "update_event"
+	ToolDeleteEvent   = "delete_event"
+	ToolQueryEvents   = "query_events"
+	ToolAmendProposal = "amend_proposal"
+	ToolFindTime      = "find_time"
+)
+
+// 查找共同空闲时段的限制。
+const (
+	defaultScheduleWindow = 7 * 24 * time.Hour  // 未给出查找范围时查找此后一周
+	maxScheduleWindow     = 31 * 24 * time.Hour // 查找范围上限
+	minScheduleMinutes    = 5
+	maxScheduleMinutes    = 1440
+)
+
+// eventTypes 为日程类型枚举，与事件接口保持一致。
+var eventTypes = []string{"work", "life", "growth"}
+
+// scheduleTools 声明模型可调用的日程工具及其参数 JSON Schema。
+func scheduleTools() []*schema.ToolInfo {
+	timeParam := func(desc string) *schema.ParameterInfo {
+		return &schema.ParameterInfo{Type: schema.String, Desc: desc + "，RFC3339 格式并带时区偏移，如 2026-10-18T15:00:00+08:00"}
+	}
+	keywordsParam := func(desc string) *schema.ParameterInfo {
+		return &schema.ParameterInfo{Type: schema.Array, Desc: desc, ElemInfo: &schema.ParameterInfo{Type: schema.String}}
+	}
+	typeParam := &schema.ParameterInfo{Type: schema.String, Desc: "日程类型：work 工作、life 生活、growth 成长", Enum: eventTypes}
+	eventIDParam := &schema.ParameterInfo{Type: schema.Integer, Desc: "用户明确给出的日程ID"}
+
+	return []*schema.ToolInfo{
+		{
+			Name: ToolCreateEvent,
+			Desc: "创建一个新日程",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"title":                {Type: schema.String, Desc: "日程标题", Required: true},
+				"type":                 typeParam,
+				"start_time":           {Type: schema.String, Desc: "开始时间，RFC3339 格式并带时区偏移", Required: true},
+				"end_time":             timeParam("结束时间，未提及时省略，默认开始后1小时"),
+				"location":             {Type: schema.String, Desc: "地点"},
+				"description":          {Type: schema.String, Desc: "描述"},
+				"participant_keywords": keywordsParam("参与人昵称关键词"),
+			}),
+		},
+		{
+			Name: ToolUpdateEvent,
+			Desc: "修改一个已有日程，用 event_id、target_time 或 target_keywords 定位原日程，只填写需要修改的字段",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"event_id":             eventIDParam,
+				"target_time":          timeParam("原日程的开始时间"),
+				"target_keywords":      keywordsParam("用于匹配原日程标题或描述的关键词"),
+				"title":                {Type: schema.String, Desc: "新标题"},
+				"type":                 typeParam,
+				"start_time":           timeParam("新的开始时间"),
+				"end_time":             timeParam("新的结束时间"),
+				"location":             {Type: schema.String, Desc: "新地点"},
+				"description":          {Type: schema.String, Desc: "新描述"},
+				"participant_keywords": keywordsParam("新的参与人昵称关键词，会替换原参与人"),
+			}),
+		},
+		{
+			Name: ToolDeleteEvent,
+			Desc: "删除（取消）一个已有日程，用 event_id、target_time 或 target_keywords 定位原日程",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"event_id":        eventIDParam,
+				"target_time":     timeParam("原日程的开始时间"),
+				"target_keywords": keywordsParam("用于匹配原日程标题或描述的关键词"),
+			}),
+		},
+		{
+			Name: ToolQueryEvents,
+			Desc: "查询一段时间内的日程安排，如“明天下午有什么安排”“下周有哪些会议”",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"start_time":           timeParam("查询范围开始，如“明天下午”为明天12:00，“明天”为明天0点；未提及时间时省略表示今天"),
+				"end_time":             timeParam("查询范围结束，如“明天下午”为明天18:00，“明天”为后天0点"),
+				"type":                 typeParam,
+				"keywords":             keywordsParam("标题或地点关键词"),
+				"participant_keywords": keywordsParam("用户提到的同事昵称关键词"),
+			}),
+		},
+		{
+			Name: ToolFindTime,
+			Desc: "为用户与参与人查找共同空闲时段并安排日程，如“下周找30分钟和张三、李四开会”，系统会按各人的日程与工作时间推荐时段",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"title":                {Type: schema.String, Desc: "日程标题，未提及时省略"},
+				"duration_minutes":     {Type: schema.Integer, Desc: "会议时长（分钟）", Required: true},
+				"participant_keywords": keywordsParam("参与人昵称关键词"),
+				"start_time":           timeParam("查找范围开始，如“下周”为下周第一天0点；未提及时省略表示从现在开始"),
+				"end_time":             timeParam("查找范围结束，如“下周”为下下周第一天0点；未提及时省略表示一周内"),
+				"type":                 typeParam,
+				"location":             {Type: schema.String, Desc: "地点"},
+				"description":          {Type: schema.String, Desc: "描述"},
+			}),
+		},
+		{
+			Name: ToolAmendProposal,
+			Desc: "修正一个待确认的操作，如“改到下午4点”“地点换成502”，只填写需要修正的字段",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"confirm_id":           {Type: schema.String, Desc: "待确认操作的 confirm_id", Required: true},
+				"title":                {Type: schema.String, Desc: "标题"},
+				"type":                 typeParam,
+				"start_time":           timeParam("开始时间"),
+				"end_time":             timeParam("结束时间"),
+				"location":             {Type: schema.String, Desc: "地点"},
+				"description":          {Type: schema.String, Desc: "描述"},
+				"participant_keywords": keywordsParam("参与人昵称关键词"),
+				"event_id":             eventIDParam,
+				"target_time":          timeParam("原日程的开始时间"),
+				"target_keywords":      keywordsParam("用于匹配原日程标题或描述的关键词"),
+				"duration_minutes":     {Type: schema.Integer, Desc: "会议时长（分钟），仅用于 find_time 的操作"},
+			}),
+		},
+	}
+}
+
+// toolArguments 为各工具参数的并集，未出现的字段保持零值。
+type toolArguments struct {
+	ConfirmID           string      `json:"confirm_id"`
+	Title               string      `json:"title"`
+	Type                string      `json:"type"`
+	StartTime           string      `json:"start_time"`
+	EndTime             string      `json:"end_time"`
+	Location            string      `json:"location"`
+	Description         string      `json:"description"`
+	ParticipantKeywords []string    `json:"participant_keywords"`
+	EventID             flexibleID  `json:"event_id"`
+	TargetTime          string      `json:"target_time"`
+	TargetKeywords      []string    `json:"target_keywords"`
+	Keywords            []string    `json:"keywords"`
+	DurationMinutes     json.Number `json:"duration_minutes"`
+}
+
+// flexibleID 兼容模型以数字或数字字符串输出的日程ID。
+type flexibleID struct {
+	Value *uint
+}
+
+// UnmarshalJSON 解析数字、数字字符串、空字符串或 null。
+func (f *flexibleID) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(bytes.TrimSpace(data)), `"`)
+	if raw == "" || raw == "null" {
+		f.Value = nil
+		return nil
+	}
+	parsed, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || parsed == 0 {
+		return errors.New("event_id 必须为正整数")
+	}
+	id := uint(parsed)
+	f.Value = &id
+	return nil
+}
+
+// toolActions 为工具名称对应的提案动作。
+var toolActions = map[string]string{
+	ToolCreateEvent: "create",
+	ToolUpdateEvent: "update",
+	ToolDeleteEvent: "delete",
+	ToolQueryEvents: "query",
+	ToolFindTime:    "schedule",
+}
+
+// actionLabels 为参数无效时提示中的操作名称。
+var actionLabels = map[string]string{
+	"create":   "创建日程",
+	"update":   "修改日程",
+	"delete":   "删除日程",
+	"query":    "查询日程",
+	"schedule": "安排日程",
+}
+
+// parseToolCall 校验一次工具调用的参数并转换为解析结果；参数无效时返回不可确认的提示。
+// pending 为会话中待确认的提案，amend_proposal 只能修正其中的提案。
+func parseToolCall(call schema.ToolCall, pending []StoredProposal, now time.Time) ParseResult {
+	name := call.Function.Name
+	action, ok := toolActions[name]
+	if name == ToolAmendProposal {
+		action, ok = "amend", true
+	}
+	if !ok {
+		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "不支持的操作：" + name}
+	}
+
+	var args toolArguments
+	arguments := strings.TrimSpace(call.Function.Arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return invalidToolResult(action, argumentError(err))
+	}
+
+	if action == "amend" {
+		return parseAmendCall(args, pending, now)
+	}
+	proposal, err := buildToolProposal(action, args, now)
+	if err != nil {
+		return invalidToolResult(action, err)
+	}
+	return formatResult(proposal)
+}
+
+// parseAmendCall 在待确认的提案基础上合并修正；confirm_id 不在当前会话中时提示重新输入。
+func parseAmendCall(args toolArguments, pending []StoredProposal, now time.Time) ParseResult {
+	var target *StoredProposal
+	for i := range pending {
+		if pending[i].ConfirmID == args.ConfirmID {
+			target = &pending[i]
+			break
+		}
+	}
+	if target == nil {
+		return ParseResult{Intent: "unknown", NeedConfirm: false, Result: "待确认的操作不存在或已过期，请重新输入"}
+	}
+	amended, err := buildToolProposal("amend", args, now)
+	if err != nil {
+		return invalidToolResult(target.Proposal.Action, err)
+	}
+	merged := amendProposal(target.Proposal, amended)
+	if merged.StartTime != nil && merged.EndTime != nil && !merged.EndTime.After(*merged.StartTime) {
+		return invalidToolResult(merged.Action, errors.New("结束时间必须晚于开始时间"))
+	}
+	result := formatResult(merged)
+	result.AmendConfirmID = target.ConfirmID
+	return result
+}
+
+// buildToolProposal 校验工具参数并构造提案：时间须为有效时间、类型须为枚举值、结束时间须晚于开始时间，
+// 创建时标题与开始时间必填，未给出结束时间默认一小时、未给出类型默认为 work。
+func buildToolProposal(action string, args toolArguments, now time.Time) (Proposal, error) {
+	startTime, err := parseToolTime("start_time", args.StartTime, now)
+	if err != nil {
+		return Proposal{}, err
+	}
+	endTime, err := parseToolTime("end_time", args.EndTime, now)
+	if err != nil {
+		return Proposal{}, err
+	}
+	targetTime, err := parseToolTime("target_time", args.TargetTime, now)
+	if err != nil {
+		return Proposal{}, err
+	}
+	eventType := strings.TrimSpace(args.Type)
+	if eventType != "" && !isEventType(eventType) {
+		return Proposal{}, fmt.Errorf("type 必须为 %s 之一", strings.Join(eventTypes, "/"))
+	}
+
+	title := strings.TrimSpace(args.Title)
+	if action == "create" {
+		if title == "" {
+			return Proposal{}, errors.New("缺少标题")
+		}
+		if startTime == nil {
+			return Proposal{}, errors.New("缺少开始时间")
+		}
+		if endTime == nil {
+			end := startTime.Add(time.Hour)
+			endTime = &end
+		}
+		if eventType == "" {
+			eventType = "work"
+		}
+	}
+	if action != "query" && startTime != nil && endTime != nil && !endTime.After(*startTime) {
+		return Proposal{}, errors.New("结束时间必须晚于开始时间")
+	}
+	duration, err := parseDurationMinutes(args.DurationMinutes)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if action == "schedule" {
+		if duration == 0 {
+			return Proposal{}, errors.New("缺少会议时长")
+		}
+		if title == "" {
+			title = "会议"
+		}
+		if eventType == "" {
+			eventType = "work"
+		}
+		startTime, endTime, err = scheduleWindow(startTime, endTime, now)
+		if err != nil {
+			return Proposal{}, err
+		}
+	}
+
+	targetKeywords := normalizeKeywords(args.TargetKeywords)
+	if action == "query" {
+		targetKeywords = normalizeKeywords(args.Keywords)
+	}
+	participantKeywords := normalizeKeywords(args.ParticipantKeywords)
+	proposal := Proposal{
+		Action:              action,
+		Title:               title,
+		Type:                eventType,
+		StartTime:           startTime,
+		EndTime:             endTime,
+		Location:            strings.TrimSpace(args.Location),
+		Description:         strings.TrimSpace(args.Description),
+		ParticipantKeywords: participantKeywords,
+		EventID:             args.EventID.Value,
+		TargetTime:          targetTime,
+		TargetKeywords:      targetKeywords,
+		DurationMinutes:     duration,
+	}
+	if action == "delete" {
+		proposal.Title, proposal.Type, proposal.StartTime, proposal.EndTime = "", "", nil, nil
+		proposal.Location, proposal.Description = "", ""
+		proposal.ParticipantKeywords, proposal.ParticipantIDs = nil, nil
+	}
+	return proposal, nil
+}
+
+// parseDurationMinutes 解析会议时长，须为 5-1440 的整数分钟，未给出时为 0。
+func parseDurationMinutes(value json.Number) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	minutes, err := strconv.Atoi(value.String())
+	if err != nil || minutes < minScheduleMinutes || minutes > maxScheduleMinutes {
+		return 0, fmt.Errorf("duration_minutes 必须为 %d-%d 的整数", minScheduleMinutes, maxScheduleMinutes)
+	}
+	return minutes, nil
+}
+
+// scheduleWindow 补全查找共同空闲时段的范围：未给出开始时间或开始时间已过时从 now 开始，未给出结束时间时查找一周，
+// 范围不能超过 31 天。
+func scheduleWindow(startTime, endTime *time.Time, now time.Time) (*time.Time, *time.Time, error) {
+	start := now
+	if startTime != nil && startTime.After(now) {
+		start = *startTime
+	}
+	end := start.Add(defaultScheduleWindow)
+	if endTime != nil {
+		end = *endTime
+	}
+	if !end.After(start) {
+		return nil, nil, errors.New("查找范围已过去")
+	}
+	if end.Sub(start) > maxScheduleWindow {
+		return nil, nil, errors.New("查找范围不能超过 31 天")
+	}
+	return &start, &end, nil
+}
+
+// parseToolTime 解析 RFC3339 时间并转换到 now 所在的用户时区；模型遗漏时区偏移时按用户时区解释。
+func parseToolTime(field, value string, now time.Time) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		parsed = parsed.In(now.Location())
+		return &parsed, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if parsed, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, fmt.Errorf("%s 不是有效时间：%s", field, value)
+}
+
+// argumentError 将 JSON 解析错误转换为可读的提示。
+func argumentError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Errorf("%s 类型错误", typeErr.Field)
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return errors.New("参数不是有效的 JSON")
+	}
+	return err
+}
+
+// invalidToolResult 返回参数无效时的提示结果。
+func invalidToolResult(action string, err error) ParseResult {
+	label, ok := actionLabels[action]
+	if !ok {
+		label = "操作"
+	}
+	intent := action
+	if !ok {
+		intent = "unknown"
+	}
+	return ParseResult{Intent: intent, NeedConfirm: false, Result: label + "参数无效：" + err.Error()}
+}
+
+// isEventType 校验日程类型枚举。
+func isEventType(value string) bool {
+	for _, item := range eventTypes {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
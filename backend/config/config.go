@@ -12,6 +12,25 @@ type AppConfig struct {
 	TokenExpireHours int    // TOKEN_EXPIRE_HOURS：Token 过期小时数，默认 168
 	DBPath           string // DB_PATH：SQLite 文件路径，默认 data/smartcalendar.db
 	CorsAllowOrigin  string // CORS_ALLOW_ORIGIN：允许的前端域名，可用逗号分隔多个
+	PublicBaseURL    string // PUBLIC_BASE_URL：后端对外访问地址，用于生成日历订阅链接（可选，默认取请求 Host）
+
+	// 提醒任务配置
+	InstanceID              string // INSTANCE_ID：实例标识，用于多实例部署时认领提醒任务（可选，默认 主机名-进程号）
+	ReminderIntervalSeconds int    // REMINDER_INTERVAL_SECONDS：提醒任务扫描间隔秒数，默认 60
+	ReminderCatchUpMinutes  int    // REMINDER_CATCHUP_MINUTES：错过的提醒与每日摘要在到期后多少分钟内仍补发，默认 30
+	ReminderLeaseSeconds    int    // REMINDER_LEASE_SECONDS：认领提醒任务的租约秒数，超时未完成可被其他实例重新认领，默认 120
+
+	// 邮件通知配置（SMTP_HOST 为空时不发送邮件）
+	SMTPHost     string // SMTP_HOST：SMTP 服务器地址
+	SMTPPort     int    // SMTP_PORT：SMTP 端口，默认 587
+	SMTPUsername string // SMTP_USERNAME：SMTP 用户名（可选，为空时不鉴权）
+	SMTPPassword string // SMTP_PASSWORD：SMTP 密码（可选）
+	SMTPFrom     string // SMTP_FROM：发件人，如 SmartCalendar <noreply@example.com>
+	SMTPSecurity string // SMTP_SECURITY：starttls（默认，服务器支持时启用）/ tls（隐式 TLS，通常为 465 端口）/ none
+	AppBaseURL   string // APP_BASE_URL：前端访问地址，用于邮件中的跳转链接（可选）
+
+	// Webhook 配置
+	WebhookAllowPrivateNetworks bool // WEBHOOK_ALLOW_PRIVATE_NETWORKS：是否允许向内网、回环地址投递 Webhook，默认 false
 
 	// Ark 大模型配置（二选一鉴权：ARK_API_KEY 或 ARK_ACCESS_KEY/ARK_SECRET_KEY）
 	ArkModelID   string // ARK_MODEL_ID：模型 Endpoint ID（必填）
@@ -21,6 +40,16 @@ type AppConfig struct {
 	ArkBaseURL   string // ARK_BASE_URL：自定义 BaseURL（可选）
 	ArkRegion    string // ARK_REGION：区域（可选）
 
+	// OpenAI 兼容接口配置（AI_PROVIDER=openai 时使用，如 vLLM、Ollama）
+	OpenAIBaseURL string // OPENAI_BASE_URL：接口地址，如 http://localhost:11434/v1（必填）
+	OpenAIAPIKey  string // OPENAI_API_KEY：鉴权密钥（可选，本地服务通常不需要）
+	OpenAIModel   string // OPENAI_MODEL：模型名称（必填）
+
+	// AI 对话配置
+	AIProvider           string // AI_PROVIDER：大模型后端，ark（默认）/ openai
+	AIHistoryTokenBudget int    // AI_HISTORY_TOKEN_BUDGET：多轮对话随请求发送的历史消息 token 上限（按字符粗略估算），默认 2000
+	AIProposalTTLMinutes int    // AI_PROPOSAL_TTL_MINUTES：待确认提案的有效分钟数，过期后需重新发起，默认 30
+
 	// 豆包语音识别配置
 	SpeechApiKey        string // SPEECH_APP_KEY：控制台 App ID（必填）
 	SpeechResourceID    string // SPEECH_RESOURCE_ID：资源 ID（必填，如 volc.seedasr.auc）
@@ -53,6 +82,22 @@ func Load() AppConfig {
 		TokenExpireHours: getEnvInt("TOKEN_EXPIRE_HOURS", 168),
 		DBPath:           getEnv("DB_PATH", "data/smartcalendar.db"),
 		CorsAllowOrigin:  getEnv("CORS_ALLOW_ORIGIN", "http://localhost:5173"),
+		PublicBaseURL:    getEnv("PUBLIC_BASE_URL", ""),
+
+		InstanceID:              getEnv("INSTANCE_ID", defaultInstanceID()),
+		ReminderIntervalSeconds: getEnvInt("REMINDER_INTERVAL_SECONDS", 60),
+		ReminderCatchUpMinutes:  getEnvInt("REMINDER_CATCHUP_MINUTES", 30),
+		ReminderLeaseSeconds:    getEnvInt("REMINDER_LEASE_SECONDS", 120),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "SmartCalendar <noreply@smartcalendar.local>"),
+		SMTPSecurity: getEnv("SMTP_SECURITY", "starttls"),
+		AppBaseURL:   getEnv("APP_BASE_URL", ""),
+
+		WebhookAllowPrivateNetworks: getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
 
 		ArkAPIKey:    getEnv("ARK_API_KEY", ""),
 		ArkModelID:   getEnv("ARK_MODEL_ID", ""),
@@ -61,6 +106,14 @@ func Load() AppConfig {
 		ArkAccessKey: getEnv("ARK_ACCESS_KEY", ""),
 		ArkSecretKey: getEnv("ARK_SECRET_KEY", ""),
 
+		OpenAIBaseURL: getEnv("OPENAI_BASE_URL", ""),
+		OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:   getEnv("OPENAI_MODEL", ""),
+
+		AIProvider:           getEnv("AI_PROVIDER", "ark"),
+		AIHistoryTokenBudget: getEnvInt("AI_HISTORY_TOKEN_BUDGET", 2000),
+		AIProposalTTLMinutes: getEnvInt("AI_PROPOSAL_TTL_MINUTES", 30),
+
 		SpeechApiKey:        getEnv("SPEECH_API_KEY", ""),
 		SpeechResourceID:    getEnv("SPEECH_RESOURCE_ID", ""),
 		SpeechBaseURL:       getEnv("SPEECH_BASE_URL", "https://openspeech.bytedance.com/api/v3/auc/bigmodel"),
@@ -85,6 +138,15 @@ func Load() AppConfig {
 	}
 }
 
+// defaultInstanceID 以主机名与进程号生成实例标识。
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "smartcalendar"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
 // getEnv 读取字符串环境变量。
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -102,3 +164,13 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvBool 读取布尔环境变量，支持 true/false/1/0 等写法。
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
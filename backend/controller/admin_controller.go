@@ -2,8 +2,10 @@ package controller
 
 import (
 	"strings"
+	"time"
 
 	"smartcalendar/model"
+	"smartcalendar/service"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -11,7 +13,9 @@ import (
 )
 
 // AdminController 负责管理员接口。
-type AdminController struct{}
+type AdminController struct {
+	Scheduler *service.ReminderScheduler
+}
 
 // UpdateUserStatusRequest 表示用户状态更新请求。
 type UpdateUserStatusRequest struct {
@@ -100,3 +104,22 @@ func (a AdminController) ResetPassword(c *gin.Context) {
 		"new_password": newPassword,
 	})
 }
+
+// GetReminderSchedulerStatus 返回提醒调度状态：当前实例的累计指标与任务表中各状态的任务数。
+func (a AdminController) GetReminderSchedulerStatus(c *gin.Context) {
+	counts, err := service.ReminderJobCounts()
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	overdue, err := a.Scheduler.CountOverdueReminderJobs(time.Now())
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{
+		"instance": a.Scheduler.Stats(),
+		"jobs":     counts,
+		"overdue":  overdue,
+	})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -17,6 +18,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// aiScheduleSlotLimit 为安排日程时推荐的候选时段数量。
+const aiScheduleSlotLimit = 3
+
 // AIController 负责 AI 对话入口与确认执行。
 type AIController struct {
 	Cfg     config.AppConfig
@@ -29,6 +33,10 @@ type AIChatRequest struct {
 	ConfirmID string `json:"confirm_id"`
 	Confirm   bool   `json:"confirm"`
 	EventID   *uint  `json:"event_id"`
+	SessionID *uint  `json:"session_id"`
+	SlotIndex *int   `json:"slot_index"`
+	// ParticipantIDs 为确认时从 participant_choices 中选择的用户 ID。
+	ParticipantIDs []uint `json:"participant_ids"`
 }
 
 // Chat 处理 AI 对话、候选返回与确认执行。
@@ -39,135 +47,807 @@ func (a AIController) Chat(c *gin.Context) {
 		return
 	}
 	user := c.MustGet("user").(model.User)
+	session, ok := loadChatSession(c, user, req)
+	if !ok {
+		return
+	}
+	data, failure := a.handleChat(user, &session, req, a.Service.ParseMessage)
+	if failure != nil {
+		Error(c, failure.code, failure.message)
+		return
+	}
+	Success(c, data)
+}
+
+// ChatStream 与 Chat 相同，但以 Server-Sent Events 返回：先推送处理阶段（status）与模型输出的文本片段（token），
+// 最后以 result 推送与 Chat 响应 data 相同结构的结果，处理失败时推送 error（code、message）。
+// 模型输出中途失败改用规则解析时，先推送 reset 通知客户端丢弃已收到的 token。
+// 请求参数或会话无效时在开始推送前直接返回 JSON 错误响应。
+func (a AIController) ChatStream(c *gin.Context) {
+	var req AIChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	user := c.MustGet("user").(model.User)
+	session, ok := loadChatSession(c, user, req)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
 
+	// 客户端断开后不再推送，但仍完成处理并写入会话，可通过会话详情查看结果。
 	if req.Confirm && req.ConfirmID != "" {
-		proposal, ok := a.Service.ConsumeProposal(req.ConfirmID)
+		_ = writeSSE(c, "", "status", gin.H{"stage": "executing"})
+	}
+	parse := func(user ai.UserContext, history []ai.Turn, pending []ai.StoredProposal, message string) ([]ai.ParseResult, error) {
+		_ = writeSSE(c, "", "status", gin.H{"stage": "parsing"})
+		results, err := a.Service.ParseMessageStream(user, history, pending, message, func(delta string) {
+			_ = writeSSE(c, "", "token", gin.H{"content": delta})
+		}, func() {
+			_ = writeSSE(c, "", "reset", gin.H{"parser": ai.ParserRules})
+		})
+		if err == nil {
+			_ = writeSSE(c, "", "status", gin.H{"stage": "matching"})
+		}
+		return results, err
+	}
+	data, failure := a.handleChat(user, &session, req, parse)
+	if failure != nil {
+		_ = writeSSE(c, "", "error", gin.H{"code": failure.code, "message": failure.message})
+		return
+	}
+	_ = writeSSE(c, "", "result", data)
+}
+
+// chatParser 解析一条用户消息，对应 AIService.ParseMessage 或其流式版本。
+type chatParser func(user ai.UserContext, history []ai.Turn, pending []ai.StoredProposal, message string) ([]ai.ParseResult, error)
+
+// chatFailure 为对话处理失败时的错误码与提示。
+type chatFailure struct {
+	code    int
+	message string
+}
+
+// handleChat 执行确认或解析用户消息，将本轮对话写入会话并返回响应数据；Chat 与 ChatStream 共用。
+func (a AIController) handleChat(user model.User, session *model.AISession, req AIChatRequest, parse chatParser) (gin.H, *chatFailure) {
+	reply := func(data gin.H) (gin.H, *chatFailure) {
+		if err := a.record(session, req.Message, data); err != nil {
+			return nil, &chatFailure{50000, "服务器内部错误"}
+		}
+		return data, nil
+	}
+
+	if req.Confirm && req.ConfirmID != "" {
+		// 先校验用户的选择再取出提案，选择无效时提案保留，可用同一 confirm_id 重新确认。
+		proposal, ok, err := a.Service.LookupProposal(user.ID, req.ConfirmID)
+		if err != nil {
+			return nil, &chatFailure{50000, "服务器内部错误"}
+		}
 		if !ok {
-			Error(c, 40001, "确认已过期，请重新输入")
-			return
+			return nil, &chatFailure{40001, "确认已过期，请重新输入"}
 		}
 		if req.EventID != nil && proposal.EventID == nil {
 			proposal.EventID = req.EventID
 		}
+		proposal, ok = applyParticipantChoices(proposal, req.ParticipantIDs)
+		if !ok {
+			return nil, &chatFailure{40001, "参与人选择无效，请重新输入"}
+		}
+		var slot ai.SlotOption
+		if proposal.Action == "schedule" {
+			slot, ok = selectScheduleSlot(proposal, req.SlotIndex)
+			if !ok {
+				return nil, &chatFailure{40001, "所选时段无效，请重新输入"}
+			}
+		}
+		_, ok, err = a.Service.ConsumeProposal(user.ID, req.ConfirmID)
+		if err != nil {
+			return nil, &chatFailure{50000, "服务器内部错误"}
+		}
+		if !ok {
+			return nil, &chatFailure{40001, "确认已过期，请重新输入"}
+		}
 		switch proposal.Action {
 		case "create":
 			event, err := createEventFromProposal(user, proposal)
 			if err != nil {
-				Error(c, 50000, "服务器内部错误")
-				return
+				return nil, &chatFailure{50000, "服务器内部错误"}
 			}
-			Success(c, gin.H{
+			return reply(gin.H{
 				"status": "success",
 				"intent": "create",
 				"result": "已为你创建日程：" + event.Title + " " + event.StartTime.Format("2006-01-02 15:04") + "-" + event.EndTime.Format("15:04"),
 				"event":  buildEventResponse(event, user.ID),
 			})
-			return
 		case "update":
 			event, err := updateEventFromProposal(user, proposal)
 			if err != nil {
 				if errors.Is(err, errNeedEventID) {
-					Success(c, gin.H{
-						"status": "need_confirm",
-						"intent": "update",
-						"result": "匹配到多个日程，请指定日程ID后确认",
-					})
-					return
+					return a.reofferProposal(user, session, req.Message, proposal)
 				}
-				Error(c, 50000, "服务器内部错误")
-				return
+				return nil, &chatFailure{50000, "服务器内部错误"}
 			}
-			Success(c, gin.H{
+			return reply(gin.H{
 				"status": "success",
 				"intent": "update",
 				"result": "已为你更新日程：" + event.Title,
 				"event":  buildEventResponse(event, user.ID),
 			})
-			return
 		case "delete":
 			event, err := deleteEventFromProposal(user, proposal)
 			if err != nil {
 				if errors.Is(err, errNeedEventID) {
-					Success(c, gin.H{
-						"status": "need_confirm",
-						"intent": "delete",
-						"result": "匹配到多个日程，请指定日程ID后确认",
-					})
-					return
+					return a.reofferProposal(user, session, req.Message, proposal)
 				}
-				Error(c, 50000, "服务器内部错误")
-				return
+				return nil, &chatFailure{50000, "服务器内部错误"}
 			}
-			Success(c, gin.H{
+			return reply(gin.H{
 				"status": "success",
 				"intent": "delete",
 				"result": "已为你删除日程：" + event.Title,
 			})
-			return
+		case "schedule":
+			proposal.StartTime, proposal.EndTime = &slot.StartTime, &slot.EndTime
+			event, err := createEventFromProposal(user, proposal)
+			if err != nil {
+				return nil, &chatFailure{50000, "服务器内部错误"}
+			}
+			return reply(gin.H{
+				"status": "success",
+				"intent": "schedule",
+				"result": "已为你安排日程：" + event.Title + " " + event.StartTime.Format("2006-01-02 15:04") + "-" + event.EndTime.Format("15:04"),
+				"event":  buildEventResponse(event, user.ID),
+			})
 		default:
-			Error(c, 40001, "意图不支持")
-			return
+			return nil, &chatFailure{40001, "意图不支持"}
 		}
 	}
 
-	result, err := a.Service.ParseMessage(req.Message)
+	history, err := ai.LoadHistory(session.ID)
 	if err != nil {
-		Error(c, 50000, "服务器内部错误："+err.Error())
-		return
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	pending, err := a.Service.PendingProposals(user.ID, session.ID)
+	if err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	userContext := buildUserContext(user, time.Now())
+	results, err := parse(userContext, history, pending, req.Message)
+	if err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误：" + err.Error()}
+	}
+
+	now := userContext.Now
+	actions := make([]chatAction, 0, len(results))
+	replacePending := false
+	for _, result := range results {
+		action, err := buildChatAction(user, result, now)
+		if err != nil {
+			return nil, &chatFailure{50000, "服务器内部错误"}
+		}
+		if action.proposal != nil && action.amendConfirmID == "" {
+			replacePending = true
+		}
+		actions = append(actions, action)
+	}
+
+	// 新的请求使会话中此前待确认的提案全部作废；修正只替换被修正的提案，查询与无法识别的消息不影响待确认提案。
+	if err := a.savePendingProposals(user, session, req.Message, actions, replacePending); err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	return reply(mergeChatActions(actions))
+}
+
+// reofferProposal 在确认时匹配到多个日程的情况下重新保存已取出的提案，
+// 返回新的 confirm_id 与候选日程，用户指定 event_id 后可再次确认。
+func (a AIController) reofferProposal(user model.User, session *model.AISession, message string, proposal ai.Proposal) (gin.H, *chatFailure) {
+	candidates, err := findCandidateEvents(user.ID, proposal)
+	if err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	if err := saveChatSession(session, message); err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	confirmID, err := a.Service.StoreProposal(user.ID, session.ID, proposal)
+	if err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	data := gin.H{
+		"status":     "need_confirm",
+		"intent":     proposal.Action,
+		"result":     "匹配到多个日程，请指定日程ID后确认",
+		"confirm_id": confirmID,
+		"proposal":   buildProposalResponse(proposal),
+		"candidates": buildCandidateResponse(candidates),
+	}
+	if err := a.record(session, message, data); err != nil {
+		return nil, &chatFailure{50000, "服务器内部错误"}
+	}
+	return data, nil
+}
+
+// buildUserContext 按用户的时区、语言区域与工作时间构造解析上下文，now 转换到用户时区。
+func buildUserContext(user model.User, now time.Time) ai.UserContext {
+	loc := service.UserLocation(user)
+	prefs := service.UserAssistantPreferences(user)
+	workDays := make([]time.Weekday, 0, len(prefs.WorkDays))
+	for _, day := range prefs.WorkDays {
+		workDays = append(workDays, time.Weekday(day))
+	}
+	return ai.UserContext{
+		UserID:    user.ID,
+		Now:       now.In(loc),
+		Location:  loc,
+		Locale:    prefs.Locale,
+		WeekStart: service.LocaleWeekStart(prefs.Locale),
+		WorkStart: prefs.WorkStart,
+		WorkEnd:   prefs.WorkEnd,
+		WorkDays:  workDays,
+	}
+}
+
+// chatAction 为一次工具调用的处理结果，proposal 非空时需要保存为待确认提案。
+type chatAction struct {
+	data           gin.H
+	proposal       *ai.Proposal
+	amendConfirmID string
+}
+
+// buildChatAction 处理单个解析结果：查询直接给出答案，修改与删除匹配候选日程，创建与改期检测冲突；
+// 响应中的 parser 标明结果来自模型还是规则解析。
+func buildChatAction(user model.User, result ai.ParseResult, now time.Time) (chatAction, error) {
+	action, err := buildChatActionData(user, result, now)
+	if err != nil {
+		return chatAction{}, err
+	}
+	action.data["parser"] = result.Parser
+	return action, nil
+}
+
+// buildChatActionData 按意图构造单个解析结果的响应。
+func buildChatActionData(user model.User, result ai.ParseResult, now time.Time) (chatAction, error) {
+	if result.Intent == "query" {
+		text, start, end, list, err := answerQuery(user, result.Proposal, now)
+		if err != nil {
+			return chatAction{}, err
+		}
+		return chatAction{data: gin.H{
+			"status": "success",
+			"intent": "query",
+			"result": text,
+			"range":  gin.H{"start": start, "end": end},
+			"events": list,
+		}}, nil
 	}
 	if !result.NeedConfirm {
-		Success(c, gin.H{
+		return chatAction{data: gin.H{
 			"status": "success",
 			"intent": result.Intent,
 			"result": result.Result,
-		})
-		return
+		}}, nil
+	}
+	if result.Intent == "schedule" {
+		action, err := buildScheduleAction(user, result.Proposal, now)
+		action.amendConfirmID = result.AmendConfirmID
+		return action, err
 	}
 
 	var candidates []model.Event
 	if result.Intent == "update" || result.Intent == "delete" {
+		var err error
 		candidates, err = findCandidateEvents(user.ID, result.Proposal)
-		if err != nil {
-			Error(c, 50000, "服务器内部错误")
-			return
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return chatAction{}, err
 		}
-		if len(candidates) == 0 && result.Proposal.EventID == nil {
-			Success(c, gin.H{
+		if len(candidates) == 0 {
+			return chatAction{data: gin.H{
 				"status": "success",
 				"intent": result.Intent,
 				"result": "未找到匹配的日程，请补充时间、关键词或日程ID",
-			})
-			return
+			}}, nil
 		}
 		if len(candidates) == 1 && result.Proposal.EventID == nil {
 			candidateEventID := candidates[0].ID
 			result.Proposal.EventID = &candidateEventID
 		}
+		// 只改开始时间时保持原日程时长。
+		if len(candidates) == 1 && result.Proposal.StartTime != nil && result.Proposal.EndTime == nil {
+			end := result.Proposal.StartTime.Add(candidates[0].EndTime.Sub(candidates[0].StartTime))
+			result.Proposal.EndTime = &end
+		}
 	}
 
-	confirmID := a.Service.StoreProposal(result.Proposal)
-	Success(c, gin.H{
-		"status":     "need_confirm",
-		"intent":     result.Intent,
-		"result":     result.Result,
-		"confirm_id": confirmID,
-		"proposal": gin.H{
-			"action":               result.Proposal.Action,
-			"title":                result.Proposal.Title,
-			"type":                 result.Proposal.Type,
-			"start_time":           result.Proposal.StartTime,
-			"end_time":             result.Proposal.EndTime,
-			"location":             result.Proposal.Location,
-			"participant_keywords": result.Proposal.ParticipantKeywords,
-			"description":          result.Proposal.Description,
-			"event_id":             result.Proposal.EventID,
-			"target_time":          result.Proposal.TargetTime,
-			"target_keywords":      result.Proposal.TargetKeywords,
+	conflicts, err := findProposalConflicts(user, result.Proposal)
+	if err != nil {
+		return chatAction{}, err
+	}
+	for _, text := range []string{formatConflictText(conflicts), formatParticipantChoiceText(result.Proposal.ParticipantChoices)} {
+		if text == "" {
+			continue
+		}
+		if idx := strings.LastIndex(result.Result, "是否确认"); idx >= 0 {
+			result.Result = result.Result[:idx] + text + "。" + result.Result[idx:]
+		} else {
+			result.Result += text
+		}
+	}
+	proposal := result.Proposal
+	return chatAction{
+		data: gin.H{
+			"status":              "need_confirm",
+			"intent":              result.Intent,
+			"result":              result.Result,
+			"proposal":            buildProposalResponse(proposal),
+			"candidates":          buildCandidateResponse(candidates),
+			"conflicts":           conflictList(conflicts),
+			"participant_choices": proposal.ParticipantChoices,
 		},
-		"candidates": buildCandidateResponse(candidates),
+		proposal:       &proposal,
+		amendConfirmID: result.AmendConfirmID,
+	}, nil
+}
+
+// buildScheduleAction 按本人与参与人的日程和工作时间计算共同空闲时段，推荐的时段写入提案供用户选择；
+// 没有可用时段时直接提示，不生成提案。
+func buildScheduleAction(user model.User, proposal ai.Proposal, now time.Time) (chatAction, error) {
+	var participants []model.User
+	if len(proposal.ParticipantIDs) > 0 {
+		if err := model.DB.Where("id IN ?", proposal.ParticipantIDs).Order("id asc").Find(&participants).Error; err != nil {
+			return chatAction{}, err
+		}
+	}
+	rangeStart := *proposal.StartTime
+	if rangeStart.Before(now) {
+		rangeStart = now
+	}
+	slots, err := service.FindMeetingSlots(service.MeetingSlotQuery{
+		Organizer:    user,
+		Participants: participants,
+		RangeStart:   rangeStart,
+		RangeEnd:     *proposal.EndTime,
+		Duration:     time.Duration(proposal.DurationMinutes) * time.Minute,
+		Limit:        aiScheduleSlotLimit,
+	})
+	if err != nil {
+		return chatAction{}, err
+	}
+
+	names := make([]string, 0, len(participants))
+	participantList := make([]gin.H, 0, len(participants))
+	for _, participant := range participants {
+		names = append(names, participant.Nickname)
+		participantList = append(participantList, gin.H{"id": participant.ID, "nickname": participant.Nickname})
+	}
+	who := "你"
+	if len(names) > 0 {
+		who = "你和" + strings.Join(names, "、")
+	}
+	if len(slots) == 0 {
+		return chatAction{data: gin.H{
+			"status": "success",
+			"intent": "schedule",
+			"result": fmt.Sprintf("%s 至 %s 之间没有%s都空闲的 %d 分钟时段，请放宽时间范围或缩短时长",
+				rangeStart.Format("2006-01-02 15:04"), proposal.EndTime.Format("2006-01-02 15:04"), who, proposal.DurationMinutes),
+		}}, nil
+	}
+
+	proposal.Slots = make([]ai.SlotOption, 0, len(slots))
+	lines := []string{fmt.Sprintf("为%s找到 %d 个 %d 分钟的共同空闲时段：", who, len(slots), proposal.DurationMinutes)}
+	slotList := make([]gin.H, 0, len(slots))
+	for i, slot := range slots {
+		proposal.Slots = append(proposal.Slots, ai.SlotOption{StartTime: slot.StartTime, EndTime: slot.EndTime})
+		lines = append(lines, fmt.Sprintf("%d. %s-%s", i+1, slot.StartTime.Format("2006-01-02 15:04"), slot.EndTime.Format("15:04")))
+		slotList = append(slotList, gin.H{
+			"index":      i,
+			"start_time": slot.StartTime,
+			"end_time":   slot.EndTime,
+			"score":      slot.Score,
+		})
+	}
+	if text := formatParticipantChoiceText(proposal.ParticipantChoices); text != "" {
+		lines = append(lines, text+"（未计入空闲时段计算）")
+	}
+	lines = append(lines, "请选择一个时段确认创建：“"+proposal.Title+"”")
+	return chatAction{
+		data: gin.H{
+			"status":              "need_confirm",
+			"intent":              "schedule",
+			"result":              strings.Join(lines, "\n"),
+			"proposal":            buildProposalResponse(proposal),
+			"slots":               slotList,
+			"participants":        participantList,
+			"participant_choices": proposal.ParticipantChoices,
+		},
+		proposal: &proposal,
+	}, nil
+}
+
+// formatParticipantChoiceText 提示匹配到多个用户的参与人关键词。
+func formatParticipantChoiceText(choices []ai.ParticipantChoice) string {
+	if len(choices) == 0 {
+		return ""
+	}
+	keywords := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		keywords = append(keywords, "“"+choice.Keyword+"”")
+	}
+	return strings.Join(keywords, "、") + "匹配到多个用户，请在确认时选择参与人"
+}
+
+// applyParticipantChoices 将确认时选择的用户加入参与人；所选用户须来自提案的候选列表，未选择的关键词不邀请任何人。
+func applyParticipantChoices(proposal ai.Proposal, selected []uint) (ai.Proposal, bool) {
+	allowed := map[uint]struct{}{}
+	for _, choice := range proposal.ParticipantChoices {
+		for _, candidate := range choice.Candidates {
+			allowed[candidate.ID] = struct{}{}
+		}
+	}
+	for _, userID := range selected {
+		if _, ok := allowed[userID]; !ok {
+			return proposal, false
+		}
+	}
+	proposal.ParticipantIDs = uniqueUintList(append(append([]uint{}, proposal.ParticipantIDs...), selected...))
+	proposal.ParticipantChoices = nil
+	return proposal, true
+}
+
+// selectScheduleSlot 返回 schedule 提案中用户选择的时段，未指定时使用推荐度最高的第一个时段。
+func selectScheduleSlot(proposal ai.Proposal, index *int) (ai.SlotOption, bool) {
+	selected := 0
+	if index != nil {
+		selected = *index
+	}
+	if selected < 0 || selected >= len(proposal.Slots) {
+		return ai.SlotOption{}, false
+	}
+	return proposal.Slots[selected], true
+}
+
+// savePendingProposals 保存本轮产生的待确认提案并写入各自的 confirm_id；replaceAll 为 true 时先作废会话中全部待确认提案，
+// 否则只作废被修正的提案。
+func (a AIController) savePendingProposals(user model.User, session *model.AISession, message string, actions []chatAction, replaceAll bool) error {
+	hasProposal := false
+	for _, action := range actions {
+		if action.proposal != nil {
+			hasProposal = true
+			break
+		}
+	}
+	if !hasProposal {
+		return nil
+	}
+	if err := saveChatSession(session, message); err != nil {
+		return err
+	}
+	if replaceAll {
+		if err := a.Service.DiscardSessionProposals(user.ID, session.ID); err != nil {
+			return err
+		}
+	}
+	for _, action := range actions {
+		if action.proposal == nil {
+			continue
+		}
+		if action.amendConfirmID != "" && !replaceAll {
+			if err := a.Service.DiscardProposal(user.ID, action.amendConfirmID); err != nil {
+				return err
+			}
+		}
+		confirmID, err := a.Service.StoreProposal(user.ID, session.ID, *action.proposal)
+		if err != nil {
+			return err
+		}
+		action.data["confirm_id"] = confirmID
+	}
+	return nil
+}
+
+// mergeChatActions 汇总本轮全部操作的响应：只有一个操作时沿用该操作的字段，多个操作时 intent 为 multiple，
+// 任一操作待确认则 status 为 need_confirm，result 按行拼接；actions 中为每个操作的完整结果。
+func mergeChatActions(actions []chatAction) gin.H {
+	items := make([]gin.H, 0, len(actions))
+	for _, action := range actions {
+		items = append(items, action.data)
+	}
+	if len(items) == 1 {
+		data := gin.H{"actions": items}
+		for key, value := range items[0] {
+			data[key] = value
+		}
+		return data
+	}
+	status := "success"
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		if item["status"] == "need_confirm" {
+			status = "need_confirm"
+		}
+		if text, _ := item["result"].(string); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return gin.H{
+		"status":  status,
+		"intent":  "multiple",
+		"result":  strings.Join(lines, "\n"),
+		"parser":  items[0]["parser"],
+		"actions": items,
+	}
+}
+
+// loadChatSession 加载请求指定的会话，失败时直接写入错误响应；未指定时返回尚未保存的新会话，
+// 在首次写入消息或提案时才创建，避免解析失败留下空会话。
+func loadChatSession(c *gin.Context, user model.User, req AIChatRequest) (model.AISession, bool) {
+	if req.SessionID == nil {
+		return model.AISession{UserID: user.ID}, true
+	}
+	session, err := ai.FindSession(user.ID, *req.SessionID)
+	if err != nil {
+		if errors.Is(err, ai.ErrSessionNotFound) {
+			Error(c, 40401, "会话不存在")
+			return model.AISession{}, false
+		}
+		Error(c, 50000, "服务器内部错误")
+		return model.AISession{}, false
+	}
+	return session, true
+}
+
+// saveChatSession 在会话尚未保存时以本条消息为标题创建会话。
+func saveChatSession(session *model.AISession, message string) error {
+	if session.ID != 0 {
+		return nil
+	}
+	created, err := ai.CreateSession(session.UserID, message)
+	if err != nil {
+		return err
+	}
+	*session = created
+	return nil
+}
+
+// record 将本轮用户消息与助手回复写入会话，响应数据附带 session_id。
+func (a AIController) record(session *model.AISession, message string, data gin.H) error {
+	content, _ := data["result"].(string)
+	intent, _ := data["intent"].(string)
+	status, _ := data["status"].(string)
+	if err := saveChatSession(session, message); err != nil {
+		return err
+	}
+	if err := ai.AppendMessages(session.ID,
+		model.AIMessage{Role: model.AIRoleUser, Content: message},
+		model.AIMessage{Role: model.AIRoleAssistant, Content: content, Intent: intent, Status: status},
+	); err != nil {
+		return err
+	}
+	data["session_id"] = session.ID
+	return nil
+}
+
+// buildProposalResponse 输出待确认提案的展示字段。
+func buildProposalResponse(proposal ai.Proposal) gin.H {
+	return gin.H{
+		"action":               proposal.Action,
+		"title":                proposal.Title,
+		"type":                 proposal.Type,
+		"start_time":           proposal.StartTime,
+		"end_time":             proposal.EndTime,
+		"location":             proposal.Location,
+		"participant_keywords": proposal.ParticipantKeywords,
+		"description":          proposal.Description,
+		"event_id":             proposal.EventID,
+		"target_time":          proposal.TargetTime,
+		"target_keywords":      proposal.TargetKeywords,
+		"duration_minutes":     proposal.DurationMinutes,
+	}
+}
+
+// ListSessions 分页返回当前用户的 AI 会话，按最近消息时间倒序。
+func (a AIController) ListSessions(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	page := parsePage(c.Query("page"), 1)
+	pageSize := parsePageSize(c.Query("page_size"), 20)
+	query := model.DB.Model(&model.AISession{}).Where("user_id = ?", user.ID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	var sessions []model.AISession
+	if err := query.Order("updated_at desc, id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{
+		"list":      sessions,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
 	})
 }
 
+// GetSession 返回会话的全部消息与当前待确认的提案列表。
+func (a AIController) GetSession(c *gin.Context) {
+	session, ok := loadOwnSession(c)
+	if !ok {
+		return
+	}
+	var messages []model.AIMessage
+	if err := model.DB.Where("session_id = ?", session.ID).Order("id asc").Find(&messages).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	stored, err := a.Service.PendingProposals(session.UserID, session.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	pending := make([]gin.H, 0, len(stored))
+	for _, item := range stored {
+		pending = append(pending, gin.H{
+			"confirm_id": item.ConfirmID,
+			"proposal":   buildProposalResponse(item.Proposal),
+			"expires_at": item.ExpiresAt,
+		})
+	}
+	Success(c, gin.H{
+		"session":  session,
+		"messages": messages,
+		"pending":  pending,
+	})
+}
+
+// DeleteSession 删除会话及其消息，会话中待确认的提案随之失效。
+func (a AIController) DeleteSession(c *gin.Context) {
+	session, ok := loadOwnSession(c)
+	if !ok {
+		return
+	}
+	if err := a.Service.DiscardSessionProposals(session.UserID, session.ID); err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	if err := ai.DeleteSession(session); err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, nil)
+}
+
+// loadOwnSession 按路径参数加载当前用户的会话，失败时直接写入错误响应。
+func loadOwnSession(c *gin.Context) (model.AISession, bool) {
+	user := c.MustGet("user").(model.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		Error(c, 40401, "会话不存在")
+		return model.AISession{}, false
+	}
+	session, err := ai.FindSession(user.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, ai.ErrSessionNotFound) {
+			Error(c, 40401, "会话不存在")
+			return model.AISession{}, false
+		}
+		Error(c, 50000, "服务器内部错误")
+		return model.AISession{}, false
+	}
+	return session, true
+}
+
+// queryResultLimit 为查询结果摘要中逐条列出的日程数量上限。
+const queryResultLimit = 10
+
+// answerQuery 查询提案时间范围内用户创建或参与的日程实例，返回摘要文本、查询范围与日程列表。
+func answerQuery(user model.User, proposal ai.Proposal, now time.Time) (string, time.Time, time.Time, []gin.H, error) {
+	start, end := ai.QueryRange(proposal, now)
+	if len(proposal.ParticipantKeywords) > 0 && len(proposal.ParticipantIDs) == 0 {
+		return formatQueryTime(start, end) + " 没有与" + strings.Join(proposal.ParticipantKeywords, "、") + "相关的日程", start, end, []gin.H{}, nil
+	}
+	// 日程时间按提交时的时区偏移存储、按字符串比较，查询时前后各放宽一天，再按实例时间精确筛选。
+	paddedStart, paddedEnd := start.AddDate(0, 0, -1), end.AddDate(0, 0, 1)
+	events, err := service.QueryVisibleEvents(user.ID, service.EventQuery{
+		Type:      proposal.Type,
+		Keywords:  proposal.TargetKeywords,
+		MemberIDs: proposal.ParticipantIDs,
+		Start:     &paddedStart,
+		End:       &paddedEnd,
+	})
+	if err != nil {
+		return "", start, end, nil, err
+	}
+	occurrences, err := service.ExpandEvents(events, start, end)
+	if err != nil {
+		return "", start, end, nil, err
+	}
+	list := make([]gin.H, 0, len(occurrences))
+	lines := make([]string, 0, queryResultLimit)
+	for _, occurrence := range occurrences {
+		if !occurrence.StartTime.Before(end) || !occurrence.EndTime.After(start) {
+			continue
+		}
+		list = append(list, buildOccurrenceResponse(occurrence, user.ID))
+		if len(lines) < queryResultLimit {
+			line := formatQueryTime(occurrence.StartTime.In(start.Location()), occurrence.EndTime.In(start.Location())) + " " + occurrence.Title
+			if occurrence.Location != "" {
+				line += "（" + occurrence.Location + "）"
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	rangeText := formatQueryTime(start, end)
+	if len(list) == 0 {
+		return rangeText + " 没有日程安排", start, end, list, nil
+	}
+	text := rangeText + " 共有 " + strconv.Itoa(len(list)) + " 个日程：" + strings.Join(lines, "；")
+	if len(list) > len(lines) {
+		text += " 等"
+	}
+	return text, start, end, list, nil
+}
+
+// formatQueryTime 输出查询范围或日程时间：整天显示日期，同一天显示日期与起止时分，跨天显示完整起止时间。
+func formatQueryTime(start, end time.Time) string {
+	midnight := func(value time.Time) bool {
+		return value.Hour() == 0 && value.Minute() == 0 && value.Second() == 0
+	}
+	if midnight(start) && midnight(end) {
+		lastDay := end.AddDate(0, 0, -1)
+		if lastDay.Format("2006-01-02") == start.Format("2006-01-02") {
+			return start.Format("2006-01-02")
+		}
+		return start.Format("2006-01-02") + " 至 " + lastDay.Format("2006-01-02")
+	}
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return start.Format("2006-01-02 15:04") + "-" + end.Format("15:04")
+	}
+	return start.Format("2006-01-02 15:04") + " 至 " + end.Format("2006-01-02 15:04")
+}
+
+// findProposalConflicts 检测创建或改期提案与创建者、参与人已有日程的冲突。
+func findProposalConflicts(user model.User, proposal ai.Proposal) ([]service.Conflict, error) {
+	attendeeIDs := append([]uint{user.ID}, proposal.ParticipantIDs...)
+	switch proposal.Action {
+	case "create":
+		if proposal.StartTime == nil || proposal.EndTime == nil {
+			return nil, nil
+		}
+		return service.FindConflicts(model.Event{StartTime: *proposal.StartTime, EndTime: *proposal.EndTime}, attendeeIDs, user.ID)
+	case "update":
+		if proposal.EventID == nil || (proposal.StartTime == nil && proposal.EndTime == nil) {
+			return nil, nil
+		}
+		var event model.Event
+		if err := model.DB.Preload("Participants").Where("user_id = ?", user.ID).First(&event, *proposal.EventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if proposal.StartTime != nil {
+			event.StartTime = *proposal.StartTime
+		}
+		if proposal.EndTime != nil {
+			event.EndTime = *proposal.EndTime
+		}
+		if !event.EndTime.After(event.StartTime) {
+			return nil, nil
+		}
+		if proposal.ParticipantKeywords == nil {
+			attendeeIDs = append([]uint{user.ID}, collectParticipantIDs(event.Participants)...)
+		}
+		return service.FindConflicts(event, attendeeIDs, user.ID)
+	}
+	return nil, nil
+}
+
 var errNeedEventID = errors.New("need event id")
 
 // findCandidateEvents 按 ID/时间/关键词匹配候选日程。
@@ -284,6 +964,7 @@ func createEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 	}
 	participantIDs := collectParticipantIDs(event.Participants)
 	_ = service.CreateInvitationNotifications(event, participantIDs)
+	publishEventCreated(user, event)
 	return event, nil
 }
 
@@ -329,6 +1010,9 @@ func updateEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 	if proposal.EndTime != nil {
 		endTime = *proposal.EndTime
 		updates["end_time"] = *proposal.EndTime
+	} else if proposal.StartTime != nil {
+		endTime = startTime.Add(event.EndTime.Sub(event.StartTime))
+		updates["end_time"] = endTime
 	}
 	if !endTime.After(startTime) {
 		return model.Event{}, errors.New("invalid time range")
@@ -343,26 +1027,14 @@ func updateEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 			if err := tx.Model(&model.Event{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
 				return err
 			}
+			if err := service.SyncSeriesEnd(tx, event.ID); err != nil {
+				return err
+			}
 		}
 		if proposal.ParticipantKeywords != nil {
-			if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventParticipant{}).Error; err != nil {
+			if err := syncParticipants(tx, event.ID, user.ID, proposal.ParticipantIDs); err != nil {
 				return err
 			}
-			participants := make([]model.EventParticipant, 0, len(proposal.ParticipantIDs))
-			for _, userID := range proposal.ParticipantIDs {
-				if userID == user.ID {
-					continue
-				}
-				participants = append(participants, model.EventParticipant{
-					EventID: event.ID,
-					UserID:  userID,
-				})
-			}
-			if len(participants) > 0 {
-				if err := tx.Create(&participants).Error; err != nil {
-					return err
-				}
-			}
 		}
 		if err := service.CreateOperationLog(tx, user.ID, "update", event.Title, map[string]interface{}{
 			"before": before,
@@ -382,8 +1054,8 @@ func updateEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 		"before": before,
 		"after":  after,
 	})
-	participantIDs := collectParticipantIDs(event.Participants)
-	_ = service.CreateChangeNotifications(event, participantIDs)
+	_ = service.CreateEventUpdateNotifications(event, before, after, recurrenceScopeAll, time.Time{})
+	publishEventUpdated(user, event, before, after, nil)
 	return event, nil
 }
 
@@ -406,6 +1078,9 @@ func deleteEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 		if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventParticipant{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventException{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Delete(&model.Event{}, event.ID).Error; err != nil {
 			return err
 		}
@@ -419,7 +1094,8 @@ func deleteEventFromProposal(user model.User, proposal ai.Proposal) (model.Event
 		return model.Event{}, err
 	}
 
-	_ = service.CreateChangeNotifications(event, participantIDs)
+	_ = service.CreateEventCancelledNotifications(event, participantIDs, eventSnapshot(event), recurrenceScopeAll, time.Time{})
+	publishEventDeleted(user, event, nil)
 	return event, nil
 }
 
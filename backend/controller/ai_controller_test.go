@@ -0,0 +1,476 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smartcalendar/ai"
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupTestDB 为每个测试初始化独立的内存 SQLite 数据库并建表。
+func setupTestDB(t *testing.T) config.AppConfig {
+	t.Helper()
+	cfg := config.Load()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	cfg.DBPath = "file:" + name + "?mode=memory&cache=shared"
+	model.InitDB(cfg)
+	if err := model.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return cfg
+}
+
+// createTestUser 创建时区为 Asia/Shanghai 的用户。
+func createTestUser(t *testing.T, nickname string) model.User {
+	t.Helper()
+	user := model.User{Nickname: nickname, Email: nickname + "@example.com", Password: "x", Status: "active", Timezone: "Asia/Shanghai"}
+	if err := model.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// chatHarness 以 httptest 驱动 AIController，模型回复由 ScriptedProvider 预设。
+type chatHarness struct {
+	t        *testing.T
+	provider *ai.ScriptedProvider
+	service  *ai.AIService
+	router   *gin.Engine
+}
+
+// newChatHarness 创建使用内存数据库与 ScriptedProvider 的对话测试环境，请求通过 X-Test-User 指定当前用户。
+func newChatHarness(t *testing.T) *chatHarness {
+	t.Helper()
+	cfg := setupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	provider := ai.NewScriptedProvider()
+	service := ai.NewAIServiceWithProvider(cfg, provider)
+	controller := AIController{Cfg: cfg, Service: service}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		var user model.User
+		if err := model.DB.First(&user, c.GetHeader("X-Test-User")).Error; err != nil {
+			c.AbortWithStatus(401)
+			return
+		}
+		c.Set("user", user)
+	})
+	router.POST("/api/ai/chat", controller.Chat)
+	router.POST("/api/ai/chat/stream", controller.ChatStream)
+	return &chatHarness{t: t, provider: provider, service: service, router: router}
+}
+
+// chat 以 user 身份调用 /api/ai/chat 并返回解析后的响应。
+func (h *chatHarness) chat(user model.User, body gin.H) (int, string, map[string]interface{}) {
+	h.t.Helper()
+	recorder := h.post("/api/ai/chat", user, body)
+	var resp struct {
+		Code    int                    `json:"code"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		h.t.Fatalf("decode response %q: %v", recorder.Body.String(), err)
+	}
+	return resp.Code, resp.Message, resp.Data
+}
+
+// post 以 user 身份发送 JSON 请求。
+func (h *chatHarness) post(path string, user model.User, body gin.H) *httptest.ResponseRecorder {
+	h.t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatalf("encode request: %v", err)
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", fmt.Sprint(user.ID))
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// needConfirm 要求响应为待确认并返回 confirm_id。
+func (h *chatHarness) needConfirm(code int, message string, data map[string]interface{}) string {
+	t := h.t
+	t.Helper()
+	if code != 0 {
+		t.Fatalf("code = %d (%s), want 0", code, message)
+	}
+	if data["status"] != "need_confirm" {
+		t.Fatalf("status = %v, want need_confirm: %v", data["status"], data)
+	}
+	confirmID, _ := data["confirm_id"].(string)
+	if confirmID == "" {
+		t.Fatalf("missing confirm_id: %v", data)
+	}
+	return confirmID
+}
+
+// futureSlot 返回 days 天后用户时区 hour 点开始的时间，格式为 RFC3339。
+func futureSlot(days, hour int) string {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day()+days, hour, 0, 0, 0, loc).Format(time.RFC3339)
+}
+
+func TestChatCreateThenConfirm(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"产品评审","start_time":%q,"end_time":%q,"location":"3楼"}`, futureSlot(2, 15), futureSlot(2, 16))))
+
+	code, message, data := h.chat(user, gin.H{"message": "后天下午3点在3楼开产品评审"})
+	confirmID := h.needConfirm(code, message, data)
+	if data["intent"] != "create" || data["parser"] != ai.ParserModel {
+		t.Fatalf("intent/parser = %v/%v", data["intent"], data["parser"])
+	}
+	sessionID := data["session_id"]
+
+	var count int64
+	model.DB.Model(&model.Event{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("event created before confirm")
+	}
+
+	code, message, data = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID, "session_id": sessionID})
+	if code != 0 || data["status"] != "success" || data["intent"] != "create" {
+		t.Fatalf("confirm = %d %s %v", code, message, data)
+	}
+	var event model.Event
+	if err := model.DB.First(&event).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	if event.Title != "产品评审" || event.Location != "3楼" || event.UserID != user.ID {
+		t.Fatalf("event = %+v", event)
+	}
+	if want, _ := time.Parse(time.RFC3339, futureSlot(2, 15)); !event.StartTime.Equal(want) {
+		t.Fatalf("start = %v, want %v", event.StartTime, want)
+	}
+
+	// 同一提案只能确认一次。
+	code, _, _ = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID})
+	if code != 40001 {
+		t.Fatalf("second confirm code = %d, want 40001", code)
+	}
+}
+
+func TestChatConfirmRejectsForeignAndExpiredProposal(t *testing.T) {
+	h := newChatHarness(t)
+	owner := createTestUser(t, "alice")
+	other := createTestUser(t, "bob")
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"周会","start_time":%q}`, futureSlot(1, 10))))
+	confirmID := h.needConfirm(h.chat(owner, gin.H{"message": "明天10点周会"}))
+
+	code, message, _ := h.chat(other, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID})
+	if code != 40001 || message != "确认已过期，请重新输入" {
+		t.Fatalf("foreign confirm = %d %s", code, message)
+	}
+	code, _, _ = h.chat(owner, gin.H{"message": "确认", "confirm": true, "confirm_id": "c_unknown"})
+	if code != 40001 {
+		t.Fatalf("unknown confirm code = %d", code)
+	}
+	// 他人的确认尝试不影响提案本身。
+	code, message, data := h.chat(owner, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID})
+	if code != 0 || data["status"] != "success" {
+		t.Fatalf("owner confirm = %d %s %v", code, message, data)
+	}
+
+	h.service.SetProposalStore(ai.NewDBProposalStore(time.Nanosecond))
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"复盘","start_time":%q}`, futureSlot(1, 14))))
+	expiredID := h.needConfirm(h.chat(owner, gin.H{"message": "明天14点复盘"}))
+	time.Sleep(time.Millisecond)
+	code, message, _ = h.chat(owner, gin.H{"message": "确认", "confirm": true, "confirm_id": expiredID})
+	if code != 40001 || message != "确认已过期，请重新输入" {
+		t.Fatalf("expired confirm = %d %s", code, message)
+	}
+}
+
+func TestChatAmendPendingProposal(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"产品评审","start_time":%q,"end_time":%q}`, futureSlot(2, 15), futureSlot(2, 17))))
+	code, message, data := h.chat(user, gin.H{"message": "后天下午3点到5点产品评审"})
+	firstID := h.needConfirm(code, message, data)
+	sessionID := data["session_id"]
+
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolAmendProposal,
+		fmt.Sprintf(`{"confirm_id":%q,"start_time":%q,"location":"502"}`, firstID, futureSlot(2, 16))))
+	code, message, data = h.chat(user, gin.H{"message": "改到下午4点，地点换成502", "session_id": sessionID})
+	amendedID := h.needConfirm(code, message, data)
+	if amendedID == firstID {
+		t.Fatalf("amend kept confirm_id %s", firstID)
+	}
+
+	// 模型收到的上下文包含待确认提案。
+	requests := h.provider.Requests()
+	last := requests[len(requests)-1]
+	if prompt := last[len(last)-1].Content; !strings.Contains(prompt, firstID) {
+		t.Fatalf("pending proposal missing from prompt: %s", prompt)
+	}
+
+	code, _, _ = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": firstID})
+	if code != 40001 {
+		t.Fatalf("amended proposal still confirmable: %d", code)
+	}
+	code, message, data = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": amendedID, "session_id": sessionID})
+	if code != 0 || data["status"] != "success" {
+		t.Fatalf("confirm amended = %d %s %v", code, message, data)
+	}
+	var event model.Event
+	if err := model.DB.First(&event).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	start, _ := time.Parse(time.RFC3339, futureSlot(2, 16))
+	if !event.StartTime.Equal(start) || !event.EndTime.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("event time = %v-%v, want %v with original 2h duration", event.StartTime, event.EndTime, start)
+	}
+	if event.Title != "产品评审" || event.Location != "502" {
+		t.Fatalf("event = %+v", event)
+	}
+}
+
+func TestChatConfirmKeepsProposalOnInvalidParticipantChoice(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	first := createTestUser(t, "王小明")
+	createTestUser(t, "王小红")
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"同步","start_time":%q,"participant_keywords":["王小"]}`, futureSlot(1, 11))))
+	code, message, data := h.chat(user, gin.H{"message": "明天11点和王小同步"})
+	confirmID := h.needConfirm(code, message, data)
+	if choices, _ := data["participant_choices"].([]interface{}); len(choices) != 1 {
+		t.Fatalf("participant_choices = %v", data["participant_choices"])
+	}
+
+	code, message, _ = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID, "participant_ids": []uint{user.ID}})
+	if code != 40001 || message != "参与人选择无效，请重新输入" {
+		t.Fatalf("invalid choice = %d %s", code, message)
+	}
+	code, message, data = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID, "participant_ids": []uint{first.ID}})
+	if code != 0 || data["status"] != "success" {
+		t.Fatalf("retry with same confirm_id = %d %s %v", code, message, data)
+	}
+	var participants []model.EventParticipant
+	model.DB.Find(&participants)
+	if len(participants) != 1 || participants[0].UserID != first.ID {
+		t.Fatalf("participants = %+v", participants)
+	}
+}
+
+func TestChatConfirmKeepsProposalOnInvalidSlot(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolFindTime,
+		fmt.Sprintf(`{"title":"对齐","duration_minutes":30,"start_time":%q,"end_time":%q}`, futureSlot(1, 0), futureSlot(8, 0))))
+	code, message, data := h.chat(user, gin.H{"message": "下周找30分钟对齐"})
+	confirmID := h.needConfirm(code, message, data)
+	slots, _ := data["slots"].([]interface{})
+	if len(slots) == 0 {
+		t.Fatalf("no slots: %v", data)
+	}
+
+	code, message, _ = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID, "slot_index": len(slots)})
+	if code != 40001 || message != "所选时段无效，请重新输入" {
+		t.Fatalf("invalid slot = %d %s", code, message)
+	}
+	code, message, data = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID, "slot_index": 0})
+	if code != 0 || data["status"] != "success" || data["intent"] != "schedule" {
+		t.Fatalf("retry with same confirm_id = %d %s %v", code, message, data)
+	}
+}
+
+func TestChatConfirmReoffersWhenSeveralEventsMatch(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	for _, hour := range []int{9, 14} {
+		start, _ := time.Parse(time.RFC3339, futureSlot(1, hour))
+		event := model.Event{UserID: user.ID, Title: "周会", Type: "work", StartTime: start, EndTime: start.Add(time.Hour)}
+		if err := model.DB.Create(&event).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolDeleteEvent, `{"target_keywords":["周会"]}`))
+	confirmID := h.needConfirm(h.chat(user, gin.H{"message": "取消周会"}))
+
+	code, message, data := h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": confirmID})
+	reofferedID := h.needConfirm(code, message, data)
+	candidates, _ := data["candidates"].([]interface{})
+	if reofferedID == confirmID || len(candidates) != 2 {
+		t.Fatalf("reoffer = %v", data)
+	}
+	eventID := candidates[0].(map[string]interface{})["id"]
+	code, message, data = h.chat(user, gin.H{"message": "确认", "confirm": true, "confirm_id": reofferedID, "event_id": eventID})
+	if code != 0 || data["status"] != "success" || data["intent"] != "delete" {
+		t.Fatalf("confirm with event_id = %d %s %v", code, message, data)
+	}
+	var count int64
+	model.DB.Model(&model.Event{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("remaining events = %d, want 1", count)
+	}
+}
+
+// sseEvent 为流式响应中的一条事件。
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// stream 以 user 身份调用 /api/ai/chat/stream 并按顺序解析全部事件。
+func (h *chatHarness) stream(user model.User, body gin.H) []sseEvent {
+	h.t.Helper()
+	recorder := h.post("/api/ai/chat/stream", user, body)
+	if ct := recorder.Header().Get("Content-Type"); ct != "text/event-stream" {
+		h.t.Fatalf("content type = %q: %s", ct, recorder.Body.String())
+	}
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data); err != nil {
+					h.t.Fatalf("decode event %q: %v", line, err)
+				}
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// eventSequence 将事件概括为 “status:parsing”“token”“result” 形式，便于比较顺序。
+func eventSequence(events []sseEvent) []string {
+	sequence := make([]string, 0, len(events))
+	for _, event := range events {
+		name := event.name
+		if event.name == "status" {
+			name += ":" + fmt.Sprint(event.data["stage"])
+		}
+		if len(sequence) > 0 && name == "token" && sequence[len(sequence)-1] == "token" {
+			continue
+		}
+		sequence = append(sequence, name)
+	}
+	return sequence
+}
+
+// streamedText 拼接全部 token 事件的文本。
+func streamedText(events []sseEvent) string {
+	var b strings.Builder
+	for _, event := range events {
+		if event.name == "token" {
+			b.WriteString(fmt.Sprint(event.data["content"]))
+		}
+	}
+	return b.String()
+}
+
+func TestChatStreamEventOrder(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	reply := "你好，我可以帮你创建、修改、删除和查询日程。"
+	h.provider.Push(reply)
+
+	events := h.stream(user, gin.H{"message": "你能做什么"})
+	want := []string{"status:parsing", "token", "status:matching", "result"}
+	if got := eventSequence(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if got := streamedText(events); got != reply {
+		t.Fatalf("tokens = %q, want %q", got, reply)
+	}
+	result := events[len(events)-1].data
+	if result["result"] != reply || result["parser"] != ai.ParserModel {
+		t.Fatalf("result = %v", result)
+	}
+
+	h.provider.PushToolCalls(ai.ToolCall(ai.ToolCreateEvent,
+		fmt.Sprintf(`{"title":"产品评审","start_time":%q,"end_time":%q}`, futureSlot(2, 15), futureSlot(2, 16))))
+	sessionID := result["session_id"]
+	events = h.stream(user, gin.H{"message": "后天下午3点开产品评审", "session_id": sessionID})
+	want = []string{"status:parsing", "status:matching", "result"}
+	if got := eventSequence(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	confirmID, _ := events[len(events)-1].data["confirm_id"].(string)
+	if confirmID == "" {
+		t.Fatalf("missing confirm_id: %v", events[len(events)-1].data)
+	}
+
+	events = h.stream(user, gin.H{"message": "确认", "session_id": sessionID, "confirm": true, "confirm_id": confirmID})
+	want = []string{"status:executing", "result"}
+	if got := eventSequence(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if status := events[len(events)-1].data["status"]; status != "success" {
+		t.Fatalf("confirm status = %v: %v", status, events[len(events)-1].data)
+	}
+	var count int64
+	model.DB.Model(&model.Event{}).Where("title = ?", "产品评审").Count(&count)
+	if count != 1 {
+		t.Fatalf("events created = %d, want 1", count)
+	}
+	if len(h.provider.Requests()) != 2 {
+		t.Fatalf("model calls = %d, want 2 (confirm must not call the model)", len(h.provider.Requests()))
+	}
+}
+
+func TestChatStreamInterruptedFallsBackToRules(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	h.provider.PushInterrupted("好的，我来帮你安排明天")
+
+	events := h.stream(user, gin.H{"message": "明天下午3点开会"})
+	want := []string{"status:parsing", "token", "reset", "status:matching", "result"}
+	if got := eventSequence(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for _, event := range events {
+		if event.name == "reset" && event.data["parser"] != ai.ParserRules {
+			t.Fatalf("reset = %v", event.data)
+		}
+	}
+	result := events[len(events)-1].data
+	if result["parser"] != ai.ParserRules || result["status"] != "need_confirm" || result["intent"] != "create" {
+		t.Fatalf("result = %v", result)
+	}
+	if confirmID, _ := result["confirm_id"].(string); confirmID == "" {
+		t.Fatalf("missing confirm_id: %v", result)
+	}
+}
+
+func TestChatStreamInterruptedBeforeTokensSkipsReset(t *testing.T) {
+	h := newChatHarness(t)
+	user := createTestUser(t, "alice")
+	h.provider.PushInterrupted("")
+
+	events := h.stream(user, gin.H{"message": "明天下午3点开会"})
+	want := []string{"status:parsing", "status:matching", "result"}
+	if got := eventSequence(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if parser := events[len(events)-1].data["parser"]; parser != ai.ParserRules {
+		t.Fatalf("parser = %v", parser)
+	}
+}
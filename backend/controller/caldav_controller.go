@@ -0,0 +1,893 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CalDAV 相关 XML 命名空间。
+const (
+	davNamespace       = "DAV:"
+	calDAVNamespace    = "urn:ietf:params:xml:ns:caldav"
+	calServerNamespace = "http://calendarserver.org/ns/"
+)
+
+// CalDAV 路径与限制。每个用户只有一个日历集合 default，日程资源以 <UID>.ics 命名。
+const (
+	calDAVRoot          = "/caldav/"
+	calDAVCalendarName  = "default"
+	calDAVObjectSuffix  = ".ics"
+	maxCalDAVBodySize   = 1 << 20
+	calDAVTimeLayout    = "20060102T150405Z"
+	calDAVObjectContent = "text/calendar; charset=utf-8; component=vevent"
+)
+
+// davPrefixes 为多状态响应中使用的命名空间前缀。
+var davPrefixes = map[string]string{
+	davNamespace:       "D",
+	calDAVNamespace:    "C",
+	calServerNamespace: "CS",
+}
+
+// 支持的 WebDAV / CalDAV 属性。
+var (
+	propResourceType          = xml.Name{Space: davNamespace, Local: "resourcetype"}
+	propDisplayName           = xml.Name{Space: davNamespace, Local: "displayname"}
+	propCurrentUserPrincipal  = xml.Name{Space: davNamespace, Local: "current-user-principal"}
+	propPrincipalURL          = xml.Name{Space: davNamespace, Local: "principal-URL"}
+	propOwner                 = xml.Name{Space: davNamespace, Local: "owner"}
+	propGetETag               = xml.Name{Space: davNamespace, Local: "getetag"}
+	propGetContentType        = xml.Name{Space: davNamespace, Local: "getcontenttype"}
+	propGetLastModified       = xml.Name{Space: davNamespace, Local: "getlastmodified"}
+	propCurrentUserPrivileges = xml.Name{Space: davNamespace, Local: "current-user-privilege-set"}
+	propSupportedReportSet    = xml.Name{Space: davNamespace, Local: "supported-report-set"}
+	propCalendarHomeSet       = xml.Name{Space: calDAVNamespace, Local: "calendar-home-set"}
+	propCalendarUserAddresses = xml.Name{Space: calDAVNamespace, Local: "calendar-user-address-set"}
+	propSupportedComponents   = xml.Name{Space: calDAVNamespace, Local: "supported-calendar-component-set"}
+	propCalendarData          = xml.Name{Space: calDAVNamespace, Local: "calendar-data"}
+	propGetCTag               = xml.Name{Space: calServerNamespace, Local: "getctag"}
+)
+
+// davNodeKind 表示 CalDAV 路径对应的资源层级。
+type davNodeKind int
+
+const (
+	davNodeRoot davNodeKind = iota
+	davNodePrincipal
+	davNodeHome
+	davNodeCalendar
+	davNodeObject
+)
+
+// davNode 表示解析后的 CalDAV 资源路径。
+type davNode struct {
+	kind   davNodeKind
+	userID uint
+	uid    string
+}
+
+// davPropNames 捕获请求中 <D:prop> 下列出的属性名。
+type davPropNames struct {
+	Names []davAnyElement `xml:",any"`
+}
+
+// davAnyElement 仅记录元素名称。
+type davAnyElement struct {
+	XMLName xml.Name
+}
+
+// davPropfind 表示 PROPFIND 请求体。
+type davPropfind struct {
+	AllProp  *struct{}     `xml:"DAV: allprop"`
+	PropName *struct{}     `xml:"DAV: propname"`
+	Prop     *davPropNames `xml:"DAV: prop"`
+}
+
+// calendarQuery 表示 REPORT calendar-query 请求体。
+type calendarQuery struct {
+	AllProp *struct{}          `xml:"DAV: allprop"`
+	Prop    *davPropNames      `xml:"DAV: prop"`
+	Filter  calendarCompFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+// calendarCompFilter 表示 comp-filter 条件，仅支持 VCALENDAR/VEVENT 与 time-range。
+type calendarCompFilter struct {
+	Name        string               `xml:"name,attr"`
+	TimeRange   *calendarTimeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters []calendarCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// calendarTimeRange 表示 time-range 条件，时间为 UTC 格式。
+type calendarTimeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// calendarMultiget 表示 REPORT calendar-multiget 请求体。
+type calendarMultiget struct {
+	AllProp *struct{}     `xml:"DAV: allprop"`
+	Prop    *davPropNames `xml:"DAV: prop"`
+	Hrefs   []string      `xml:"DAV: href"`
+}
+
+// davProperty 表示一个属性及其已转义的 XML 内容。
+type davProperty struct {
+	name  xml.Name
+	value string
+}
+
+// davResponse 表示多状态响应中的单个资源；status 非 0 时表示资源本身的状态（如 404）。
+type davResponse struct {
+	href    string
+	found   []davProperty
+	missing []xml.Name
+	status  int
+}
+
+// davObject 表示一个日程资源及其序列化内容。
+type davObject struct {
+	event model.Event
+	data  string
+	etag  string
+}
+
+// CalDAVController 实现 CalDAV（RFC 4791）子集，供桌面与移动端日历客户端双向同步。
+type CalDAVController struct{}
+
+// WellKnown 处理 /.well-known/caldav 服务发现，重定向到 CalDAV 根路径。
+func (d CalDAVController) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, calDAVRoot)
+}
+
+// Options 声明支持的 DAV 能力与方法。
+func (d CalDAVController) Options(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// Propfind 返回主体、日历主目录、日历集合或日程资源的属性。
+func (d CalDAVController) Propfind(c *gin.Context) {
+	user, node, ok := resolveDAVNode(c)
+	if !ok {
+		return
+	}
+	body, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	var req davPropfind
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := xml.Unmarshal(body, &req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+	requested := requestedProps(req.AllProp, req.Prop)
+	propNameOnly := req.PropName != nil
+	depthOne := c.GetHeader("Depth") != "0"
+
+	var responses []davResponse
+	addResponse := func(href string, props []davProperty) {
+		responses = append(responses, selectProps(href, props, requested, propNameOnly))
+	}
+	switch node.kind {
+	case davNodeRoot:
+		addResponse(calDAVRoot, rootProperties(user))
+	case davNodePrincipal:
+		addResponse(principalHref(user.ID), principalProperties(user))
+	case davNodeHome, davNodeCalendar:
+		objects, err := loadDAVObjects(user.ID)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if node.kind == davNodeHome {
+			addResponse(homeHref(user.ID), homeProperties(user))
+			if depthOne {
+				addResponse(calendarHref(user.ID), calendarProperties(user, objects))
+			}
+			break
+		}
+		addResponse(calendarHref(user.ID), calendarProperties(user, objects))
+		if depthOne {
+			for _, object := range objects {
+				addResponse(objectHref(user.ID, service.EventUID(object.event)), objectProperties(user, object))
+			}
+		}
+	case davNodeObject:
+		object, err := loadDAVObject(user.ID, node.uid)
+		if err != nil {
+			writeDAVLookupError(c, err)
+			return
+		}
+		addResponse(objectHref(user.ID, service.EventUID(object.event)), objectProperties(user, object))
+	}
+	writeMultistatus(c, responses)
+}
+
+// Report 处理 calendar-query 与 calendar-multiget 报告。
+func (d CalDAVController) Report(c *gin.Context) {
+	user, node, ok := resolveDAVNode(c)
+	if !ok {
+		return
+	}
+	if node.kind != davNodeCalendar {
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: davNamespace, Local: "supported-report"})
+		return
+	}
+	body, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	root, err := davRootElement(body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	objects, err := loadDAVObjects(user.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var responses []davResponse
+	switch root {
+	case xml.Name{Space: calDAVNamespace, Local: "calendar-query"}:
+		var req calendarQuery
+		if err := xml.Unmarshal(body, &req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		matched, err := filterDAVObjects(objects, req.Filter)
+		if err != nil {
+			writeDAVError(c, http.StatusForbidden, xml.Name{Space: calDAVNamespace, Local: "valid-filter"})
+			return
+		}
+		requested := requestedProps(req.AllProp, req.Prop)
+		for _, object := range matched {
+			href := objectHref(user.ID, service.EventUID(object.event))
+			responses = append(responses, selectProps(href, objectProperties(user, object), requested, false))
+		}
+	case xml.Name{Space: calDAVNamespace, Local: "calendar-multiget"}:
+		var req calendarMultiget
+		if err := xml.Unmarshal(body, &req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		byUID := make(map[string]davObject, len(objects))
+		for _, object := range objects {
+			byUID[service.EventUID(object.event)] = object
+		}
+		requested := requestedProps(req.AllProp, req.Prop)
+		for _, href := range req.Hrefs {
+			href = strings.TrimSpace(href)
+			hrefNode, ok := parseDAVHref(href)
+			object, found := byUID[hrefNode.uid]
+			if !ok || hrefNode.kind != davNodeObject || hrefNode.userID != user.ID || !found {
+				responses = append(responses, davResponse{href: href, status: http.StatusNotFound})
+				continue
+			}
+			responses = append(responses, selectProps(href, objectProperties(user, object), requested, false))
+		}
+	default:
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: davNamespace, Local: "supported-report"})
+		return
+	}
+	writeMultistatus(c, responses)
+}
+
+// Get 返回单个日程资源；对日历集合返回全部日程。
+func (d CalDAVController) Get(c *gin.Context) {
+	user, node, ok := resolveDAVNode(c)
+	if !ok {
+		return
+	}
+	switch node.kind {
+	case davNodeCalendar:
+		events, err := service.LoadVisibleEvents(user.ID, nil)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		exceptions, err := service.LoadExceptions(collectEventIDs(events))
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(service.BuildICS(user.Nickname+" 的日程", events, exceptions)))
+	case davNodeObject:
+		object, err := loadDAVObject(user.ID, node.uid)
+		if err != nil {
+			writeDAVLookupError(c, err)
+			return
+		}
+		c.Header("ETag", object.etag)
+		c.Header("Last-Modified", object.event.UpdatedAt.UTC().Format(http.TimeFormat))
+		c.Data(http.StatusOK, calDAVObjectContent, []byte(object.data))
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+// Put 创建或整体替换日程资源，仅创建者可修改已有日程；支持 If-Match / If-None-Match 条件请求。
+func (d CalDAVController) Put(c *gin.Context) {
+	user, node, ok := resolveDAVNode(c)
+	if !ok {
+		return
+	}
+	if node.kind != davNodeObject {
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	body, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	items, itemErrs, err := service.ParseICS(bytes.NewReader(body))
+	if err == nil && len(itemErrs) > 0 {
+		// VEVENT 内容无法解析或含不支持的 RRULE 时整体拒绝，避免序列被降级保存后覆盖客户端的本地副本。
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: calDAVNamespace, Local: "valid-calendar-data"})
+		return
+	}
+	if err != nil || len(items) != 1 {
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: calDAVNamespace, Local: "valid-calendar-object-resource"})
+		return
+	}
+	item := items[0]
+	if !item.EndTime.After(item.StartTime) {
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: calDAVNamespace, Local: "valid-calendar-data"})
+		return
+	}
+	// 资源按 UID 命名：资源名对应的日程已存在但 UID 不同，视为 UID 冲突；
+	// 否则拒绝以其他资源名创建，避免日程出现在与请求路径不同的 href 下。
+	if node.uid != item.UID {
+		if _, err := service.FindVisibleEventByUID(user.ID, node.uid); err == nil {
+			writeDAVError(c, http.StatusForbidden, xml.Name{Space: calDAVNamespace, Local: "no-uid-conflict"})
+			return
+		}
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	existing, err := loadDAVObject(user.ID, item.UID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !checkDAVPreconditions(c, exists, existing.etag) {
+		return
+	}
+	if !exists {
+		if _, err := importICSEvent(user, item); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		// 存储内容与客户端提交的内容并非逐字节一致，按 RFC 4791 不返回 ETag，由客户端重新获取。
+		c.Status(http.StatusCreated)
+		return
+	}
+	if existing.event.UserID != user.ID {
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: davNamespace, Local: "need-privileges"})
+		return
+	}
+	if _, err := updateEventFromICS(user, existing.event, item); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Delete 删除日程资源，仅创建者可删除，删除后通知参与人。
+func (d CalDAVController) Delete(c *gin.Context) {
+	user, node, ok := resolveDAVNode(c)
+	if !ok {
+		return
+	}
+	if node.kind != davNodeObject {
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	object, err := loadDAVObject(user.ID, node.uid)
+	if err != nil {
+		writeDAVLookupError(c, err)
+		return
+	}
+	if !checkDAVPreconditions(c, true, object.etag) {
+		return
+	}
+	if object.event.UserID != user.ID {
+		writeDAVError(c, http.StatusForbidden, xml.Name{Space: davNamespace, Local: "need-privileges"})
+		return
+	}
+	participantIDs := collectParticipantIDs(object.event.Participants)
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteEventTx(tx, user, object.event)
+	}); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	_ = service.CreateEventCancelledNotifications(object.event, participantIDs, eventSnapshot(object.event), recurrenceScopeAll, time.Time{})
+	publishEventDeleted(user, object.event, nil)
+	c.Status(http.StatusNoContent)
+}
+
+// updateEventFromICS 用客户端提交的 VEVENT 整体替换日程字段、例外记录与参与人，并在同一事务中写入操作记录，提交后通知参与人。
+func updateEventFromICS(user model.User, event model.Event, item service.ICSEvent) (model.Event, error) {
+	beforeSnapshot := eventSnapshot(event)
+	participantIDs, err := resolveAttendeeEmails(item.Attendees)
+	if err != nil {
+		return model.Event{}, err
+	}
+	updated := event
+	updated.Title = truncateRunes(item.Title, 100)
+	if item.Type != "" {
+		updated.Type = item.Type
+	}
+	updated.StartTime = item.StartTime
+	updated.EndTime = item.EndTime
+	updated.Location = truncateRunes(item.Location, 200)
+	updated.Description = truncateRunes(item.Description, 500)
+	updated.RRule = item.RRule
+
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Event{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+			"title":       updated.Title,
+			"type":        updated.Type,
+			"start_time":  updated.StartTime,
+			"end_time":    updated.EndTime,
+			"location":    updated.Location,
+			"description": updated.Description,
+			"rrule":       updated.RRule,
+			"series_end":  service.SeriesEnd(updated),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventException{}).Error; err != nil {
+			return err
+		}
+		if updated.RRule != "" {
+			if exceptions := buildICSExceptions(updated, item); len(exceptions) > 0 {
+				if err := tx.Create(&exceptions).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := syncParticipants(tx, event.ID, user.ID, participantIDs); err != nil {
+			return err
+		}
+		if err := tx.Preload("Creator").Preload("Participants.User").First(&updated, event.ID).Error; err != nil {
+			return err
+		}
+		return service.CreateOperationLog(tx, user.ID, "update", updated.Title, map[string]interface{}{
+			"before": beforeSnapshot,
+			"after":  eventSnapshot(updated),
+		})
+	}); err != nil {
+		return model.Event{}, err
+	}
+
+	afterSnapshot := eventSnapshot(updated)
+	_ = service.CreateEventUpdateNotifications(updated, beforeSnapshot, afterSnapshot, recurrenceScopeAll, time.Time{})
+	publishEventUpdated(user, updated, beforeSnapshot, afterSnapshot, nil)
+	return updated, nil
+}
+
+// resolveDAVNode 解析请求路径，并限制用户只能访问自己的主体与日历。
+func resolveDAVNode(c *gin.Context) (model.User, davNode, bool) {
+	user := c.MustGet("user").(model.User)
+	node, ok := parseDAVPath(c.Param("path"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return model.User{}, davNode{}, false
+	}
+	if node.kind != davNodeRoot && node.userID != user.ID {
+		c.Status(http.StatusForbidden)
+		return model.User{}, davNode{}, false
+	}
+	return user, node, true
+}
+
+// parseDAVPath 解析 /caldav 之后的路径。
+func parseDAVPath(value string) (davNode, bool) {
+	trimmed := strings.Trim(value, "/")
+	if trimmed == "" {
+		return davNode{kind: davNodeRoot}, true
+	}
+	segments := strings.Split(trimmed, "/")
+	if len(segments) < 2 {
+		return davNode{}, false
+	}
+	userID, err := strconv.ParseUint(segments[1], 10, 32)
+	if err != nil {
+		return davNode{}, false
+	}
+	node := davNode{userID: uint(userID)}
+	switch {
+	case segments[0] == "principals" && len(segments) == 2:
+		node.kind = davNodePrincipal
+	case segments[0] == "calendars" && len(segments) == 2:
+		node.kind = davNodeHome
+	case segments[0] == "calendars" && len(segments) == 3 && segments[2] == calDAVCalendarName:
+		node.kind = davNodeCalendar
+	case segments[0] == "calendars" && len(segments) == 4 && segments[2] == calDAVCalendarName &&
+		strings.HasSuffix(segments[3], calDAVObjectSuffix) && len(segments[3]) > len(calDAVObjectSuffix):
+		node.kind = davNodeObject
+		node.uid = strings.TrimSuffix(segments[3], calDAVObjectSuffix)
+	default:
+		return davNode{}, false
+	}
+	return node, true
+}
+
+// parseDAVHref 解析 multiget 中的 href（可能为完整 URL 或已转义的路径）。
+func parseDAVHref(href string) (davNode, bool) {
+	parsed, err := url.Parse(href)
+	if err != nil || !strings.HasPrefix(parsed.Path, calDAVRoot) {
+		return davNode{}, false
+	}
+	return parseDAVPath(strings.TrimPrefix(parsed.Path, strings.TrimSuffix(calDAVRoot, "/")))
+}
+
+// principalHref 等函数生成各层级资源的 href。
+func principalHref(userID uint) string {
+	return fmt.Sprintf("%sprincipals/%d/", calDAVRoot, userID)
+}
+
+func homeHref(userID uint) string {
+	return fmt.Sprintf("%scalendars/%d/", calDAVRoot, userID)
+}
+
+func calendarHref(userID uint) string {
+	return homeHref(userID) + calDAVCalendarName + "/"
+}
+
+func objectHref(userID uint, uid string) string {
+	return calendarHref(userID) + url.PathEscape(uid) + calDAVObjectSuffix
+}
+
+// loadDAVObjects 加载用户创建或参与的全部日程并序列化为资源。
+func loadDAVObjects(userID uint) ([]davObject, error) {
+	events, err := service.LoadVisibleEvents(userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := service.LoadExceptions(collectEventIDs(events))
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]davObject, 0, len(events))
+	for _, event := range events {
+		objects = append(objects, newDAVObject(event, exceptions[event.ID]))
+	}
+	return objects, nil
+}
+
+// loadDAVObject 按 UID 加载单个日程资源，找不到时返回 gorm.ErrRecordNotFound。
+func loadDAVObject(userID uint, uid string) (davObject, error) {
+	event, err := service.FindVisibleEventByUID(userID, uid)
+	if err != nil {
+		return davObject{}, err
+	}
+	exceptions, err := service.LoadExceptions([]uint{event.ID})
+	if err != nil {
+		return davObject{}, err
+	}
+	return newDAVObject(event, exceptions[event.ID]), nil
+}
+
+// newDAVObject 序列化单个日程并计算 ETag。
+func newDAVObject(event model.Event, exceptions []model.EventException) davObject {
+	data := service.BuildICS("", []model.Event{event}, map[uint][]model.EventException{event.ID: exceptions})
+	return davObject{event: event, data: data, etag: service.EventETag(data)}
+}
+
+// filterDAVObjects 按 calendar-query 的 comp-filter 过滤资源。
+func filterDAVObjects(objects []davObject, filter calendarCompFilter) ([]davObject, error) {
+	if filter.Name != "VCALENDAR" {
+		return nil, errors.New("invalid filter")
+	}
+	if len(filter.CompFilters) == 0 {
+		return objects, nil
+	}
+	var eventFilter *calendarCompFilter
+	for i := range filter.CompFilters {
+		if filter.CompFilters[i].Name == "VEVENT" {
+			eventFilter = &filter.CompFilters[i]
+		}
+	}
+	if eventFilter == nil {
+		return nil, nil
+	}
+	if eventFilter.TimeRange == nil {
+		return objects, nil
+	}
+	rangeStart := time.Unix(0, 0).UTC()
+	rangeEnd := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	if eventFilter.TimeRange.Start != "" {
+		parsed, err := time.Parse(calDAVTimeLayout, eventFilter.TimeRange.Start)
+		if err != nil {
+			return nil, err
+		}
+		rangeStart = parsed
+	}
+	if eventFilter.TimeRange.End != "" {
+		parsed, err := time.Parse(calDAVTimeLayout, eventFilter.TimeRange.End)
+		if err != nil {
+			return nil, err
+		}
+		rangeEnd = parsed
+	}
+	events := make([]model.Event, 0, len(objects))
+	byID := make(map[uint]davObject, len(objects))
+	for _, object := range objects {
+		events = append(events, object.event)
+		byID[object.event.ID] = object
+	}
+	matched, err := service.FilterEventsInRange(events, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]davObject, 0, len(matched))
+	for _, event := range matched {
+		result = append(result, byID[event.ID])
+	}
+	return result, nil
+}
+
+// rootProperties 等函数返回各层级资源支持的全部属性。
+func rootProperties(user model.User) []davProperty {
+	return []davProperty{
+		{name: propResourceType, value: "<D:collection/>"},
+		{name: propDisplayName, value: "SmartCalendar"},
+		{name: propCurrentUserPrincipal, value: davHref(principalHref(user.ID))},
+	}
+}
+
+func principalProperties(user model.User) []davProperty {
+	return []davProperty{
+		{name: propResourceType, value: "<D:principal/>"},
+		{name: propDisplayName, value: davEscape(user.Nickname)},
+		{name: propCurrentUserPrincipal, value: davHref(principalHref(user.ID))},
+		{name: propPrincipalURL, value: davHref(principalHref(user.ID))},
+		{name: propCalendarHomeSet, value: davHref(homeHref(user.ID))},
+		{name: propCalendarUserAddresses, value: davHref("mailto:" + user.Email)},
+	}
+}
+
+func homeProperties(user model.User) []davProperty {
+	return []davProperty{
+		{name: propResourceType, value: "<D:collection/>"},
+		{name: propDisplayName, value: davEscape(user.Nickname)},
+		{name: propCurrentUserPrincipal, value: davHref(principalHref(user.ID))},
+		{name: propOwner, value: davHref(principalHref(user.ID))},
+	}
+}
+
+func calendarProperties(user model.User, objects []davObject) []davProperty {
+	etags := make([]string, 0, len(objects))
+	for _, object := range objects {
+		etags = append(etags, object.etag)
+	}
+	return []davProperty{
+		{name: propResourceType, value: "<D:collection/><C:calendar/>"},
+		{name: propDisplayName, value: davEscape(user.Nickname + " 的日程")},
+		{name: propCurrentUserPrincipal, value: davHref(principalHref(user.ID))},
+		{name: propOwner, value: davHref(principalHref(user.ID))},
+		{name: propSupportedComponents, value: `<C:comp name="VEVENT"/>`},
+		{name: propSupportedReportSet, value: "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"},
+		{name: propCurrentUserPrivileges, value: davPrivileges(true)},
+		{name: propGetCTag, value: davEscape(service.CalendarCTag(etags))},
+	}
+}
+
+func objectProperties(user model.User, object davObject) []davProperty {
+	return []davProperty{
+		{name: propResourceType, value: ""},
+		{name: propGetETag, value: davEscape(object.etag)},
+		{name: propGetContentType, value: calDAVObjectContent},
+		{name: propGetLastModified, value: object.event.UpdatedAt.UTC().Format(http.TimeFormat)},
+		{name: propCurrentUserPrivileges, value: davPrivileges(object.event.UserID == user.ID)},
+		{name: propCalendarData, value: davEscape(object.data)},
+	}
+}
+
+// davPrivileges 输出当前用户权限：创建者可写，参与人只读。
+func davPrivileges(writable bool) string {
+	value := "<D:privilege><D:read/></D:privilege>"
+	if writable {
+		value += "<D:privilege><D:write/></D:privilege><D:privilege><D:write-content/></D:privilege>" +
+			"<D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"
+	}
+	return value
+}
+
+// requestedProps 返回请求的属性名；nil 表示 allprop。
+func requestedProps(allProp *struct{}, prop *davPropNames) []xml.Name {
+	if allProp != nil || prop == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(prop.Names))
+	for _, item := range prop.Names {
+		names = append(names, item.XMLName)
+	}
+	return names
+}
+
+// selectProps 按请求筛选属性，不支持的属性放入 404 propstat。allprop 不返回 calendar-data。
+func selectProps(href string, props []davProperty, requested []xml.Name, propNameOnly bool) davResponse {
+	response := davResponse{href: href}
+	if requested == nil {
+		for _, prop := range props {
+			if prop.name == propCalendarData {
+				continue
+			}
+			if propNameOnly {
+				prop.value = ""
+			}
+			response.found = append(response.found, prop)
+		}
+		return response
+	}
+	available := make(map[xml.Name]davProperty, len(props))
+	for _, prop := range props {
+		available[prop.name] = prop
+	}
+	for _, name := range requested {
+		if prop, ok := available[name]; ok {
+			response.found = append(response.found, prop)
+			continue
+		}
+		response.missing = append(response.missing, name)
+	}
+	return response
+}
+
+// checkDAVPreconditions 校验 If-Match / If-None-Match，失败时写入 412。
+func checkDAVPreconditions(c *gin.Context, exists bool, etag string) bool {
+	if match := strings.TrimSpace(c.GetHeader("If-None-Match")); match != "" {
+		if exists && (match == "*" || match == etag) {
+			c.Status(http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if match := strings.TrimSpace(c.GetHeader("If-Match")); match != "" {
+		if !exists || (match != "*" && match != etag) {
+			c.Status(http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	return true
+}
+
+// readDAVBody 读取请求体并限制大小。
+func readDAVBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCalDAVBodySize+1))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+	if len(body) > maxCalDAVBodySize {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+// davRootElement 返回 XML 文档根元素名称。
+func davRootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// writeDAVLookupError 将资源查询错误映射为 HTTP 状态码。
+func writeDAVLookupError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusInternalServerError)
+}
+
+// writeDAVError 输出带前置条件元素的 DAV:error 响应体。
+func writeDAVError(c *gin.Context, status int, condition xml.Name) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	b.WriteString(davElement(condition, ""))
+	b.WriteString(`</D:error>`)
+	c.Data(status, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+// writeMultistatus 输出 207 多状态响应。
+func writeMultistatus(c *gin.Context, responses []davResponse) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`)
+	for _, response := range responses {
+		b.WriteString("<D:response>")
+		b.WriteString(davHref(response.href))
+		if response.status != 0 {
+			b.WriteString(davStatus(response.status))
+			b.WriteString("</D:response>")
+			continue
+		}
+		if len(response.found) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, prop := range response.found {
+				b.WriteString(davElement(prop.name, prop.value))
+			}
+			b.WriteString("</D:prop>")
+			b.WriteString(davStatus(http.StatusOK))
+			b.WriteString("</D:propstat>")
+		}
+		if len(response.missing) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range response.missing {
+				b.WriteString(davElement(name, ""))
+			}
+			b.WriteString("</D:prop>")
+			b.WriteString(davStatus(http.StatusNotFound))
+			b.WriteString("</D:propstat>")
+		}
+		b.WriteString("</D:response>")
+	}
+	b.WriteString("</D:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+// davElement 输出单个 XML 元素，未知命名空间就地声明前缀。
+func davElement(name xml.Name, inner string) string {
+	prefix, ok := davPrefixes[name.Space]
+	namespace := ""
+	if !ok {
+		prefix = "X"
+		namespace = fmt.Sprintf(` xmlns:X="%s"`, davEscape(name.Space))
+	}
+	tag := prefix + ":" + name.Local
+	if inner == "" {
+		return "<" + tag + namespace + "/>"
+	}
+	return "<" + tag + namespace + ">" + inner + "</" + tag + ">"
+}
+
+// davHref 输出 <D:href> 元素。
+func davHref(href string) string {
+	return "<D:href>" + davEscape(href) + "</D:href>"
+}
+
+// davStatus 输出 <D:status> 元素。
+func davStatus(status int) string {
+	return fmt.Sprintf("<D:status>HTTP/1.1 %d %s</D:status>", status, http.StatusText(status))
+}
+
+// davEscape 转义 XML 文本内容。
+func davEscape(value string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smartcalendar/middleware"
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// davClient 以 Bearer 令牌访问挂载了 CalDAV 路由的测试服务器，不跟随重定向。
+type davClient struct {
+	t      *testing.T
+	server *httptest.Server
+	user   model.User
+	token  string
+	http   *http.Client
+}
+
+// newDAVClient 按 router 的方式挂载 CalDAV 路由并启动 httptest 服务器，以新建用户的身份访问。
+func newDAVClient(t *testing.T) *davClient {
+	t.Helper()
+	cfg := setupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	calDAVController := CalDAVController{}
+	r := gin.New()
+	r.GET("/.well-known/caldav", calDAVController.WellKnown)
+	r.OPTIONS("/caldav/*path", calDAVController.Options)
+	dav := r.Group("/caldav")
+	dav.Use(middleware.DAVAuthRequired(cfg))
+	{
+		dav.Handle("PROPFIND", "/*path", calDAVController.Propfind)
+		dav.Handle("REPORT", "/*path", calDAVController.Report)
+		dav.GET("/*path", calDAVController.Get)
+		dav.PUT("/*path", calDAVController.Put)
+		dav.DELETE("/*path", calDAVController.Delete)
+	}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	user := createTestUser(t, "alice")
+	token, err := service.GenerateToken(cfg, user.ID, user.Role)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	return &davClient{t: t, server: server, user: user, token: token, http: client}
+}
+
+// do 发送请求并返回状态码、响应头与响应体。
+func (d *davClient) do(method, path string, headers map[string]string, body string) (int, http.Header, string) {
+	d.t.Helper()
+	req, err := http.NewRequest(method, d.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		d.t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		d.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		d.t.Fatalf("read body: %v", err)
+	}
+	return resp.StatusCode, resp.Header, string(data)
+}
+
+// multistatus 解析 207 响应，返回 href 到属性的映射。
+func (d *davClient) multistatus(status int, body string) map[string]davTestProps {
+	d.t.Helper()
+	if status != http.StatusMultiStatus {
+		d.t.Fatalf("status = %d, want 207: %s", status, body)
+	}
+	var doc struct {
+		Responses []struct {
+			Href     string `xml:"DAV: href"`
+			Propstat []struct {
+				Prop   davTestProps `xml:"DAV: prop"`
+				Status string       `xml:"DAV: status"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.Unmarshal([]byte(body), &doc); err != nil {
+		d.t.Fatalf("decode multistatus: %v\n%s", err, body)
+	}
+	result := make(map[string]davTestProps, len(doc.Responses))
+	for _, response := range doc.Responses {
+		var props davTestProps
+		for _, propstat := range response.Propstat {
+			if strings.Contains(propstat.Status, " 200 ") {
+				props = propstat.Prop
+			}
+		}
+		result[response.Href] = props
+	}
+	return result
+}
+
+// davTestProps 为测试关心的属性。
+type davTestProps struct {
+	ResourceType struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	CurrentUserPrincipal string `xml:"DAV: current-user-principal>href"`
+	CalendarHomeSet      struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	ETag         string `xml:"DAV: getetag"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+// davTestEvent 构造包含单个 VEVENT 的日历对象。
+func davTestEvent(uid, title string) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Test//CalDAV//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:20261001T000000Z",
+		"DTSTART:20261020T060000Z",
+		"DTEND:20261020T070000Z",
+		"SUMMARY:" + title,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+// davTestQuery 构造按 time-range 过滤 VEVENT 的 calendar-query 报告。
+func davTestQuery(start, end string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">
+    <C:time-range start="` + start + `" end="` + end + `"/>
+  </C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`
+}
+
+func TestCalDAVRoundTrip(t *testing.T) {
+	d := newDAVClient(t)
+	user := d.user
+	depth0 := map[string]string{"Depth": "0", "Content-Type": "application/xml"}
+	depth1 := map[string]string{"Depth": "1", "Content-Type": "application/xml"}
+
+	// 服务发现：well-known → 根 → 主体 → 日历主目录 → 日历集合。
+	status, header, _ := d.do("GET", "/.well-known/caldav", nil, "")
+	if status != http.StatusMovedPermanently || header.Get("Location") != calDAVRoot {
+		t.Fatalf("well-known = %d %q", status, header.Get("Location"))
+	}
+	status, _, body := d.do("PROPFIND", calDAVRoot, depth0,
+		`<D:propfind xmlns:D="DAV:"><D:prop><D:current-user-principal/></D:prop></D:propfind>`)
+	principal := d.multistatus(status, body)[calDAVRoot].CurrentUserPrincipal
+	if principal != principalHref(user.ID) {
+		t.Fatalf("current-user-principal = %q", principal)
+	}
+	status, _, body = d.do("PROPFIND", principal, depth0,
+		`<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><C:calendar-home-set/></D:prop></D:propfind>`)
+	home := d.multistatus(status, body)[principal].CalendarHomeSet.Href
+	if home != homeHref(user.ID) {
+		t.Fatalf("calendar-home-set = %q", home)
+	}
+	status, _, body = d.do("PROPFIND", home, depth1,
+		`<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`)
+	calendar := calendarHref(user.ID)
+	if props, ok := d.multistatus(status, body)[calendar]; !ok || props.ResourceType.Calendar == nil {
+		t.Fatalf("calendar collection missing from home listing: %s", body)
+	}
+
+	// 创建：If-None-Match: * 仅允许新建。
+	uid := "round-trip-1@example.com"
+	href := objectHref(user.ID, uid)
+	create := map[string]string{"Content-Type": "text/calendar", "If-None-Match": "*"}
+	if status, _, body := d.do("PUT", href, create, davTestEvent(uid, "周会")); status != http.StatusCreated {
+		t.Fatalf("create = %d: %s", status, body)
+	}
+	if status, _, _ := d.do("PUT", href, create, davTestEvent(uid, "周会")); status != http.StatusPreconditionFailed {
+		t.Fatalf("second create with If-None-Match = %d, want 412", status)
+	}
+
+	// 资源名与 UID 不一致时拒绝，不在其他 href 下创建日程。
+	if status, _, _ := d.do("PUT", objectHref(user.ID, "other-name"), create, davTestEvent("mismatch@example.com", "错位")); status != http.StatusConflict {
+		t.Fatalf("mismatched resource name = %d, want 409", status)
+	}
+	var mismatched int64
+	model.DB.Model(&model.Event{}).Where("uid = ?", "mismatch@example.com").Count(&mismatched)
+	if mismatched != 0 {
+		t.Fatalf("event created under a different href")
+	}
+
+	// 不支持的 RRULE 返回 valid-calendar-data，不降级为单次日程。
+	monthly := strings.Replace(davTestEvent("monthly@example.com", "月报"), "SUMMARY:", "RRULE:FREQ=MONTHLY;BYMONTHDAY=20\r\nSUMMARY:", 1)
+	status, _, body = d.do("PUT", objectHref(user.ID, "monthly@example.com"), create, monthly)
+	if status != http.StatusForbidden || !strings.Contains(body, "valid-calendar-data") {
+		t.Fatalf("unsupported rrule = %d: %s", status, body)
+	}
+	var downgraded int64
+	model.DB.Model(&model.Event{}).Where("uid = ?", "monthly@example.com").Count(&downgraded)
+	if downgraded != 0 {
+		t.Fatalf("event with unsupported rrule was saved")
+	}
+
+	// calendar-query：时间范围内返回资源与 ETag，范围外不返回。
+	status, _, body = d.do("REPORT", calendar, depth1, davTestQuery("20261019T000000Z", "20261021T000000Z"))
+	props, ok := d.multistatus(status, body)[href]
+	if !ok || props.ETag == "" || !strings.Contains(props.CalendarData, "SUMMARY:周会") {
+		t.Fatalf("calendar-query missing %s: %s", href, body)
+	}
+	etag := props.ETag
+	status, _, body = d.do("REPORT", calendar, depth1, davTestQuery("20261101T000000Z", "20261102T000000Z"))
+	if objects := d.multistatus(status, body); len(objects) != 0 {
+		t.Fatalf("calendar-query outside range = %v", objects)
+	}
+	status, header, body = d.do("GET", href, nil, "")
+	if status != http.StatusOK || header.Get("ETag") != etag {
+		t.Fatalf("get = %d etag %q, want %q: %s", status, header.Get("ETag"), etag, body)
+	}
+
+	// 替换：If-Match 不匹配时拒绝，匹配时更新并产生新的 ETag。
+	update := map[string]string{"Content-Type": "text/calendar", "If-Match": `"stale"`}
+	if status, _, _ := d.do("PUT", href, update, davTestEvent(uid, "周会（改）")); status != http.StatusPreconditionFailed {
+		t.Fatalf("update with stale If-Match = %d, want 412", status)
+	}
+	update["If-Match"] = etag
+	if status, _, body := d.do("PUT", href, update, davTestEvent(uid, "周会（改）")); status != http.StatusNoContent {
+		t.Fatalf("update = %d: %s", status, body)
+	}
+	status, header, body = d.do("GET", href, nil, "")
+	newETag := header.Get("ETag")
+	if status != http.StatusOK || newETag == "" || newETag == etag || !strings.Contains(body, "SUMMARY:周会（改）") {
+		t.Fatalf("after update = %d etag %q (old %q): %s", status, newETag, etag, body)
+	}
+
+	// 删除：旧 ETag 被拒绝，当前 ETag 删除成功，之后资源不存在。
+	if status, _, _ := d.do("DELETE", href, map[string]string{"If-Match": etag}, ""); status != http.StatusPreconditionFailed {
+		t.Fatalf("delete with old etag = %d, want 412", status)
+	}
+	if status, _, body := d.do("DELETE", href, map[string]string{"If-Match": newETag}, ""); status != http.StatusNoContent {
+		t.Fatalf("delete = %d: %s", status, body)
+	}
+	if status, _, _ := d.do("GET", href, nil, ""); status != http.StatusNotFound {
+		t.Fatalf("get after delete = %d, want 404", status)
+	}
+	status, _, body = d.do("PROPFIND", calendar, depth1, `<D:propfind xmlns:D="DAV:"><D:prop><D:getetag/></D:prop></D:propfind>`)
+	if _, ok := d.multistatus(status, body)[href]; ok {
+		t.Fatalf("deleted resource still listed: %s", body)
+	}
+}
+
+func TestCalDAVUpdateRollsBackWhenOperationLogFails(t *testing.T) {
+	d := newDAVClient(t)
+	uid := "rollback@example.com"
+	href := objectHref(d.user.ID, uid)
+	if status, _, body := d.do("PUT", href, map[string]string{"Content-Type": "text/calendar"}, davTestEvent(uid, "周会")); status != http.StatusCreated {
+		t.Fatalf("create = %d: %s", status, body)
+	}
+	if err := model.DB.Callback().Create().Before("gorm:create").Register("test:fail_operation_log", func(db *gorm.DB) {
+		if _, ok := db.Statement.Dest.(*model.OperationLog); ok {
+			db.AddError(errors.New("injected failure"))
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	if status, _, _ := d.do("PUT", href, map[string]string{"Content-Type": "text/calendar"}, davTestEvent(uid, "周会（改）")); status != http.StatusInternalServerError {
+		t.Fatalf("update with failing log = %d, want 500", status)
+	}
+	if status, _, body := d.do("GET", href, nil, ""); status != http.StatusOK || !strings.Contains(body, "SUMMARY:周会\r\n") {
+		t.Fatalf("after failed update = %d: %s", status, body)
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 时间冲突处理策略：reject 拒绝保存，warn 照常保存并在响应中返回冲突（默认）。
+const (
+	conflictPolicyReject = "reject"
+	conflictPolicyWarn   = "warn"
+)
+
+// isValidConflictPolicy 校验冲突处理策略，空值视为 warn。
+func isValidConflictPolicy(value string) bool {
+	return value == "" || value == conflictPolicyReject || value == conflictPolicyWarn
+}
+
+// rejectConflicts 返回时间冲突错误，并在 data 中携带冲突列表。
+func rejectConflicts(c *gin.Context, conflicts []service.Conflict) {
+	c.JSON(200, APIResponse{
+		Code:    40901,
+		Message: "日程时间冲突",
+		Data:    gin.H{"conflicts": conflicts},
+	})
+}
+
+// conflictList 保证冲突列表序列化为数组而非 null。
+func conflictList(conflicts []service.Conflict) []service.Conflict {
+	if conflicts == nil {
+		return []service.Conflict{}
+	}
+	return conflicts
+}
+
+// formatConflictText 将冲突列表转换为确认提示中的说明文字。
+func formatConflictText(conflicts []service.Conflict) string {
+	if len(conflicts) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(conflicts))
+	for i, conflict := range conflicts {
+		if i >= 3 {
+			items = append(items, fmt.Sprintf("等 %d 项", len(conflicts)))
+			break
+		}
+		items = append(items, fmt.Sprintf("%s《%s》%s-%s", conflict.Nickname, conflict.Title, conflict.StartTime.Format("01-02 15:04"), conflict.EndTime.Format("15:04")))
+	}
+	return "注意：与已有日程时间冲突（" + strings.Join(items, "；") + "）"
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"smartcalendar/model"
@@ -22,6 +24,9 @@ type EventCreateRequest struct {
 	ParticipantIDs []uint `json:"participant_ids"`
 	Location       string `json:"location" binding:"omitempty,max=200"`
 	Description    string `json:"description" binding:"omitempty,max=500"`
+	RRule          string `json:"rrule" binding:"omitempty,max=500"`
+	Reminders      *[]int `json:"reminders"`
+	ConflictPolicy string `json:"conflict_policy"`
 }
 
 // EventUpdateRequest 表示更新日程的请求体。
@@ -33,6 +38,18 @@ type EventUpdateRequest struct {
 	ParticipantIDs *[]uint `json:"participant_ids"`
 	Location       *string `json:"location" binding:"omitempty,max=200"`
 	Description    *string `json:"description" binding:"omitempty,max=500"`
+	RRule          *string `json:"rrule" binding:"omitempty,max=500"`
+	Reminders      *[]int  `json:"reminders"`
+	UseDefault     bool    `json:"use_default_reminders"`
+	Scope          string  `json:"scope"`
+	OccurrenceTime *string `json:"occurrence_start"`
+	ConflictPolicy string  `json:"conflict_policy"`
+}
+
+// EventResponseRequest 表示参与人回复邀请的请求体。
+type EventResponseRequest struct {
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment" binding:"omitempty,max=200"`
 }
 
 // CreateEvent 创建日程并写入参与人、操作日志与通知。
@@ -60,6 +77,23 @@ func (e EventController) CreateEvent(c *gin.Context) {
 		Error(c, 40001, "参数校验失败：end_time 必须晚于 start_time")
 		return
 	}
+	if err := service.ValidateRRule(req.RRule); err != nil {
+		Error(c, 40001, "参数校验失败：rrule 无效")
+		return
+	}
+	if !isValidConflictPolicy(req.ConflictPolicy) {
+		Error(c, 40001, "参数校验失败：conflict_policy 无效")
+		return
+	}
+	var reminders *string
+	if req.Reminders != nil {
+		if err := service.ValidateReminderOffsets(*req.Reminders); err != nil {
+			Error(c, 40001, "参数校验失败：reminders 无效")
+			return
+		}
+		value := service.FormatReminderOffsets(*req.Reminders)
+		reminders = &value
+	}
 
 	user := c.MustGet("user").(model.User)
 	event := model.Event{
@@ -70,36 +104,21 @@ func (e EventController) CreateEvent(c *gin.Context) {
 		EndTime:     endTime,
 		Location:    req.Location,
 		Description: req.Description,
+		RRule:       strings.TrimSpace(req.RRule),
+		Reminders:   reminders,
+	}
+	conflicts, err := service.FindConflicts(event, append([]uint{user.ID}, req.ParticipantIDs...), user.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	if req.ConflictPolicy == conflictPolicyReject && len(conflicts) > 0 {
+		rejectConflicts(c, conflicts)
+		return
 	}
 
 	if err := model.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&event).Error; err != nil {
-			return err
-		}
-		if len(req.ParticipantIDs) > 0 {
-			participants := make([]model.EventParticipant, 0, len(req.ParticipantIDs))
-			for _, userID := range uniqueUintList(req.ParticipantIDs) {
-				if userID == user.ID {
-					continue
-				}
-				participants = append(participants, model.EventParticipant{
-					EventID: event.ID,
-					UserID:  userID,
-				})
-			}
-			if len(participants) > 0 {
-				if err := tx.Create(&participants).Error; err != nil {
-					return err
-				}
-			}
-		}
-		if err := service.CreateOperationLog(tx, user.ID, "create", event.Title, map[string]interface{}{
-			"title": event.Title,
-			"type":  event.Type,
-		}); err != nil {
-			return err
-		}
-		return nil
+		return createEventTx(tx, user, &event, req.ParticipantIDs)
 	}); err != nil {
 		Error(c, 50000, "服务器内部错误")
 		return
@@ -111,8 +130,41 @@ func (e EventController) CreateEvent(c *gin.Context) {
 	}
 	participantIDs := collectParticipantIDs(event.Participants)
 	_ = service.CreateInvitationNotifications(event, participantIDs)
+	publishEventCreated(user, event)
 
-	Success(c, buildEventResponse(event, user.ID))
+	response := buildEventResponse(event, user.ID)
+	response["conflicts"] = conflictList(conflicts)
+	Success(c, response)
+}
+
+// createEventTx 在事务内写入日程、参与人与创建操作日志。
+func createEventTx(tx *gorm.DB, user model.User, event *model.Event, participantIDs []uint) error {
+	event.SeriesEnd = service.SeriesEnd(*event)
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+	if len(participantIDs) > 0 {
+		participants := make([]model.EventParticipant, 0, len(participantIDs))
+		for _, userID := range uniqueUintList(participantIDs) {
+			if userID == user.ID {
+				continue
+			}
+			participants = append(participants, model.EventParticipant{
+				EventID: event.ID,
+				UserID:  userID,
+			})
+		}
+		if len(participants) > 0 {
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return service.CreateOperationLog(tx, user.ID, "create", event.Title, map[string]interface{}{
+		"title": event.Title,
+		"type":  event.Type,
+		"rrule": event.RRule,
+	})
 }
 
 // ListEvents 列出用户创建或参与的日程。
@@ -122,47 +174,47 @@ func (e EventController) ListEvents(c *gin.Context) {
 	startQuery := c.Query("start")
 	endQuery := c.Query("end")
 
-	query := model.DB.Table("events").
-		Select("events.id").
-		Joins("LEFT JOIN event_participants ON event_participants.event_id = events.id").
-		Where("events.user_id = ? OR event_participants.user_id = ?", user.ID, user.ID).
-		Distinct()
-
-	if eventType != "" {
-		query = query.Where("events.type = ?", eventType)
-	}
+	filter := service.EventQuery{Type: eventType}
+	var rangeStart, rangeEnd *time.Time
 	if startQuery != "" {
 		if startTime, err := parseRFC3339(startQuery); err == nil {
-			query = query.Where("events.end_time >= ?", startTime)
+			rangeStart = &startTime
+			filter.Start = &startTime
 		}
 	}
 	if endQuery != "" {
 		if endTime, err := parseRFC3339(endQuery); err == nil {
-			query = query.Where("events.start_time <= ?", endTime)
+			rangeEnd = &endTime
+			filter.End = &endTime
 		}
 	}
 
-	var eventIDs []uint
-	if err := query.Scan(&eventIDs).Error; err != nil {
+	events, err := service.QueryVisibleEvents(user.ID, filter)
+	if err != nil {
 		Error(c, 50000, "服务器内部错误")
 		return
 	}
-	if len(eventIDs) == 0 {
+	if len(events) == 0 {
 		Success(c, gin.H{"list": []interface{}{}})
 		return
 	}
-	var events []model.Event
-	if err := model.DB.Where("id IN ?", eventIDs).
-		Preload("Creator").
-		Preload("Participants.User").
-		Order("start_time asc").
-		Find(&events).Error; err != nil {
+	if rangeStart == nil || rangeEnd == nil {
+		// 未指定完整区间时无法展开重复日程，直接返回序列本身。
+		list := make([]interface{}, 0, len(events))
+		for _, event := range events {
+			list = append(list, buildEventResponse(event, user.ID))
+		}
+		Success(c, gin.H{"list": list})
+		return
+	}
+	occurrences, err := service.ExpandEvents(events, *rangeStart, *rangeEnd)
+	if err != nil {
 		Error(c, 50000, "服务器内部错误")
 		return
 	}
-	list := make([]interface{}, 0, len(events))
-	for _, event := range events {
-		list = append(list, buildEventResponse(event, user.ID))
+	list := make([]interface{}, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		list = append(list, buildOccurrenceResponse(occurrence, user.ID))
 	}
 	Success(c, gin.H{"list": list})
 }
@@ -186,7 +238,7 @@ func (e EventController) GetEventDetail(c *gin.Context) {
 	Success(c, buildEventResponse(event, user.ID))
 }
 
-// UpdateEvent 更新日程并同步参与人、操作日志与通知。
+// UpdateEvent 更新日程并同步参与人、操作日志与通知；重复日程可按 scope 修改单次、此后或全部实例。
 func (e EventController) UpdateEvent(c *gin.Context) {
 	user := c.MustGet("user").(model.User)
 	var event model.Event
@@ -208,6 +260,19 @@ func (e EventController) UpdateEvent(c *gin.Context) {
 		Error(c, 40001, "参数校验失败："+err.Error())
 		return
 	}
+	if !isValidConflictPolicy(req.ConflictPolicy) {
+		Error(c, 40001, "参数校验失败：conflict_policy 无效")
+		return
+	}
+	occurrenceStart := ""
+	if req.OccurrenceTime != nil {
+		occurrenceStart = *req.OccurrenceTime
+	}
+	scope, recurrenceID, err := resolveRecurrenceScope(event, req.Scope, occurrenceStart)
+	if err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
 
 	beforeSnapshot := eventSnapshot(event)
 	updatedFields := map[string]interface{}{}
@@ -228,17 +293,45 @@ func (e EventController) UpdateEvent(c *gin.Context) {
 	if req.Description != nil {
 		updatedFields["description"] = *req.Description
 	}
+	if req.RRule != nil {
+		if scope == recurrenceScopeThis {
+			Error(c, 40001, "参数校验失败：单次实例不支持修改 rrule")
+			return
+		}
+		if err := service.ValidateRRule(*req.RRule); err != nil {
+			Error(c, 40001, "参数校验失败：rrule 无效")
+			return
+		}
+		updatedFields["rrule"] = strings.TrimSpace(*req.RRule)
+	}
+	if req.Reminders != nil || req.UseDefault {
+		if scope == recurrenceScopeThis {
+			Error(c, 40001, "参数校验失败：单次实例不支持修改 reminders")
+			return
+		}
+		if req.Reminders != nil && req.UseDefault {
+			Error(c, 40001, "参数校验失败：reminders 与 use_default_reminders 不能同时指定")
+			return
+		}
+		if req.UseDefault {
+			updatedFields["reminders"] = nil
+		} else {
+			if err := service.ValidateReminderOffsets(*req.Reminders); err != nil {
+				Error(c, 40001, "参数校验失败：reminders 无效")
+				return
+			}
+			updatedFields["reminders"] = service.FormatReminderOffsets(*req.Reminders)
+		}
+	}
 
-	startTime := event.StartTime
-	endTime := event.EndTime
+	var parsedStart, parsedEnd *time.Time
 	if req.StartTime != nil {
 		parsed, err := parseRFC3339(*req.StartTime)
 		if err != nil {
 			Error(c, 40001, "参数校验失败：start_time 无效")
 			return
 		}
-		startTime = parsed
-		updatedFields["start_time"] = parsed
+		parsedStart = &parsed
 	}
 	if req.EndTime != nil {
 		parsed, err := parseRFC3339(*req.EndTime)
@@ -246,62 +339,195 @@ func (e EventController) UpdateEvent(c *gin.Context) {
 			Error(c, 40001, "参数校验失败：end_time 无效")
 			return
 		}
-		endTime = parsed
-		updatedFields["end_time"] = parsed
+		parsedEnd = &parsed
+	}
+
+	_, rruleRequested := updatedFields["rrule"]
+	scheduleChanged := parsedStart != nil || parsedEnd != nil || rruleRequested || req.ParticipantIDs != nil
+	attendeeIDs := collectParticipantIDs(event.Participants)
+	if req.ParticipantIDs != nil {
+		attendeeIDs = uniqueUintList(*req.ParticipantIDs)
+	}
+
+	switch scope {
+	case recurrenceScopeThis:
+		if req.ParticipantIDs != nil {
+			Error(c, 40001, "参数校验失败：单次实例不支持修改参与人")
+			return
+		}
+		noticeBefore, err := occurrenceBeforeSnapshot(event, recurrenceID)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		exception, err := prepareOccurrence(event, recurrenceID, updatedFields, parsedStart, parsedEnd)
+		if err != nil {
+			if errors.Is(err, errInvalidTimeRange) {
+				Error(c, 40001, "参数校验失败：end_time 必须晚于 start_time")
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				Error(c, 40401, "资源不存在")
+				return
+			}
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		var conflicts []service.Conflict
+		if scheduleChanged {
+			candidate := model.Event{ID: event.ID, StartTime: exception.StartTime, EndTime: exception.EndTime}
+			if conflicts, err = service.FindConflicts(candidate, append([]uint{user.ID}, attendeeIDs...), user.ID); err != nil {
+				Error(c, 50000, "服务器内部错误")
+				return
+			}
+			if req.ConflictPolicy == conflictPolicyReject && len(conflicts) > 0 {
+				rejectConflicts(c, conflicts)
+				return
+			}
+		}
+		occurrence, err := saveOccurrence(user, event, exception, beforeSnapshot)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		afterSnapshot := occurrenceSnapshot(occurrence)
+		_ = service.CreateEventUpdateNotifications(occurrence.Event, noticeBefore, afterSnapshot, scope, recurrenceID)
+		publishEventUpdated(user, occurrence.Event, beforeSnapshot, afterSnapshot, map[string]interface{}{
+			"scope":            scope,
+			"occurrence_start": recurrenceID,
+		})
+		response := buildOccurrenceResponse(occurrence, user.ID)
+		response["conflicts"] = conflictList(conflicts)
+		Success(c, response)
+		return
+	case recurrenceScopeFollowing:
+		noticeBefore, err := followingBeforeSnapshot(event, recurrenceID)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		newEvent, err := prepareSplitEvent(user, event, recurrenceID, updatedFields, parsedStart, parsedEnd)
+		if err != nil {
+			if errors.Is(err, errInvalidTimeRange) {
+				Error(c, 40001, "参数校验失败：end_time 必须晚于 start_time")
+				return
+			}
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		var conflicts []service.Conflict
+		if scheduleChanged {
+			// 原序列此后的实例会被截止，检测时一并排除。
+			candidate := newEvent
+			candidate.ID = event.ID
+			if conflicts, err = service.FindConflicts(candidate, append([]uint{user.ID}, attendeeIDs...), user.ID); err != nil {
+				Error(c, 50000, "服务器内部错误")
+				return
+			}
+			if req.ConflictPolicy == conflictPolicyReject && len(conflicts) > 0 {
+				rejectConflicts(c, conflicts)
+				return
+			}
+		}
+		newEvent, err = saveSplitSeries(user, event, recurrenceID, newEvent, attendeeIDs, beforeSnapshot)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		afterSnapshot := eventSnapshot(newEvent)
+		_ = service.CreateEventUpdateNotifications(newEvent, noticeBefore, afterSnapshot, scope, recurrenceID)
+		// 此后的实例拆分为新日程，事件归属新日程，原日程 ID 一并给出。
+		publishEventUpdated(user, newEvent, beforeSnapshot, afterSnapshot, map[string]interface{}{
+			"scope":             scope,
+			"occurrence_start":  recurrenceID,
+			"original_event_id": event.ID,
+		})
+		response := buildEventResponse(newEvent, user.ID)
+		response["conflicts"] = conflictList(conflicts)
+		Success(c, response)
+		return
+	}
+
+	startTime := event.StartTime
+	endTime := event.EndTime
+	if parsedStart != nil {
+		startTime = *parsedStart
+		updatedFields["start_time"] = *parsedStart
+	}
+	if parsedEnd != nil {
+		endTime = *parsedEnd
+		updatedFields["end_time"] = *parsedEnd
 	}
 	if !endTime.After(startTime) {
 		Error(c, 40001, "参数校验失败：end_time 必须晚于 start_time")
 		return
 	}
+	var conflicts []service.Conflict
+	if scheduleChanged {
+		candidate := event
+		candidate.StartTime = startTime
+		candidate.EndTime = endTime
+		if value, ok := updatedFields["rrule"].(string); ok {
+			candidate.RRule = value
+		}
+		if conflicts, err = service.FindConflicts(candidate, append([]uint{user.ID}, attendeeIDs...), user.ID); err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		if req.ConflictPolicy == conflictPolicyReject && len(conflicts) > 0 {
+			rejectConflicts(c, conflicts)
+			return
+		}
+	}
+	// 序列起点或规则变化后，原有例外记录无法再对应到实例，一并清理。
+	_, startChanged := updatedFields["start_time"]
+	_, rruleChanged := updatedFields["rrule"]
+	clearExceptions := event.RRule != "" && (startChanged || rruleChanged)
 
 	if err := model.DB.Transaction(func(tx *gorm.DB) error {
 		if len(updatedFields) > 0 {
 			if err := tx.Model(&model.Event{}).Where("id = ?", event.ID).Updates(updatedFields).Error; err != nil {
 				return err
 			}
+			if scheduleChanged {
+				if err := service.SyncSeriesEnd(tx, event.ID); err != nil {
+					return err
+				}
+			}
 		}
-		if req.ParticipantIDs != nil {
-			if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventParticipant{}).Error; err != nil {
+		if clearExceptions {
+			if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventException{}).Error; err != nil {
 				return err
 			}
-			participants := make([]model.EventParticipant, 0, len(*req.ParticipantIDs))
-			for _, userID := range uniqueUintList(*req.ParticipantIDs) {
-				if userID == user.ID {
-					continue
-				}
-				participants = append(participants, model.EventParticipant{
-					EventID: event.ID,
-					UserID:  userID,
-				})
-			}
-			if len(participants) > 0 {
-				if err := tx.Create(&participants).Error; err != nil {
-					return err
-				}
+		}
+		if req.ParticipantIDs != nil {
+			if err := syncParticipants(tx, event.ID, user.ID, *req.ParticipantIDs); err != nil {
+				return err
 			}
 		}
-		return nil
+		if err := tx.Preload("Creator").Preload("Participants.User").First(&event, event.ID).Error; err != nil {
+			return err
+		}
+		return service.CreateOperationLog(tx, user.ID, "update", event.Title, map[string]interface{}{
+			"before": beforeSnapshot,
+			"after":  eventSnapshot(event),
+		})
 	}); err != nil {
 		Error(c, 50000, "服务器内部错误")
 		return
 	}
 
-	if err := model.DB.Preload("Creator").Preload("Participants.User").First(&event, event.ID).Error; err != nil {
-		Error(c, 50000, "服务器内部错误")
-		return
-	}
+	// 操作记录已随修改一并提交，通知在提交后发送。
 	afterSnapshot := eventSnapshot(event)
-	_ = service.CreateOperationLog(nil, user.ID, "update", event.Title, map[string]interface{}{
-		"before": beforeSnapshot,
-		"after":  afterSnapshot,
-	})
-	participantIDs := collectParticipantIDs(event.Participants)
-	_ = service.CreateChangeNotifications(event, participantIDs)
+	_ = service.CreateEventUpdateNotifications(event, beforeSnapshot, afterSnapshot, scope, time.Time{})
+	publishEventUpdated(user, event, beforeSnapshot, afterSnapshot, nil)
 
-	Success(c, buildEventResponse(event, user.ID))
+	response := buildEventResponse(event, user.ID)
+	response["conflicts"] = conflictList(conflicts)
+	Success(c, response)
 }
 
-// DeleteEvent 删除日程并生成操作日志与通知。
+// DeleteEvent 删除日程并生成操作日志与通知；重复日程可按 scope 删除单次、此后或全部实例。
 func (e EventController) DeleteEvent(c *gin.Context) {
 	user := c.MustGet("user").(model.User)
 	var event model.Event
@@ -317,30 +543,147 @@ func (e EventController) DeleteEvent(c *gin.Context) {
 		Error(c, 40301, "无权限")
 		return
 	}
+	scope, recurrenceID, err := resolveRecurrenceScope(event, c.Query("scope"), c.Query("occurrence_start"))
+	if err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
 
 	participantIDs := collectParticipantIDs(event.Participants)
-	if err := model.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventParticipant{}).Error; err != nil {
-			return err
+	snapshot := eventSnapshot(event)
+	logDelete := func(tx *gorm.DB) error {
+		return service.CreateOperationLog(tx, user.ID, "delete", event.Title, map[string]interface{}{
+			"title":            event.Title,
+			"scope":            scope,
+			"occurrence_start": recurrenceID,
+		})
+	}
+	switch scope {
+	case recurrenceScopeThis:
+		if snapshot, err = occurrenceBeforeSnapshot(event, recurrenceID); err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
 		}
-		if err := tx.Delete(&model.Event{}, event.ID).Error; err != nil {
-			return err
+		cancelled := false
+		if err := model.DB.Transaction(func(tx *gorm.DB) error {
+			if cancelled, err = cancelOccurrence(tx, event, recurrenceID); err != nil || !cancelled {
+				return err
+			}
+			return logDelete(tx)
+		}); err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
 		}
-		if err := service.CreateOperationLog(tx, user.ID, "delete", event.Title, map[string]interface{}{
-			"title": event.Title,
+		// 实例此前已取消，重复删除不再通知。
+		if !cancelled {
+			Success(c, gin.H{"deleted": true})
+			return
+		}
+	case recurrenceScopeFollowing:
+		truncated, err := service.TruncateRRule(event.RRule, recurrenceID)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		if err := model.DB.Transaction(func(tx *gorm.DB) error {
+			if err := truncateSeries(tx, event, recurrenceID, truncated); err != nil {
+				return err
+			}
+			return logDelete(tx)
 		}); err != nil {
-			return err
+			Error(c, 50000, "服务器内部错误")
+			return
 		}
-		return nil
+	}
+	if scope != recurrenceScopeAll {
+		_ = service.CreateEventCancelledNotifications(event, participantIDs, snapshot, scope, recurrenceID)
+		publishEventDeleted(user, event, map[string]interface{}{
+			"scope":            scope,
+			"occurrence_start": recurrenceID,
+		})
+		Success(c, gin.H{"deleted": true})
+		return
+	}
+
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteEventTx(tx, user, event)
 	}); err != nil {
 		Error(c, 50000, "服务器内部错误")
 		return
 	}
 
-	_ = service.CreateChangeNotifications(event, participantIDs)
+	_ = service.CreateEventCancelledNotifications(event, participantIDs, snapshot, scope, time.Time{})
+	publishEventDeleted(user, event, nil)
 	Success(c, gin.H{"deleted": true})
 }
 
+// deleteEventTx 删除整个日程及其参与人、例外记录，并写入操作记录。
+func deleteEventTx(tx *gorm.DB, user model.User, event model.Event) error {
+	if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventParticipant{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("event_id = ?", event.ID).Delete(&model.EventException{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&model.Event{}, event.ID).Error; err != nil {
+		return err
+	}
+	return service.CreateOperationLog(tx, user.ID, "delete", event.Title, map[string]interface{}{
+		"title": event.Title,
+	})
+}
+
+// RespondEvent 参与人接受、拒绝或暂定日程邀请，并通知创建者。
+func (e EventController) RespondEvent(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req EventResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	if !isValidResponseStatus(req.Status) {
+		Error(c, 40001, "参数校验失败：status 无效")
+		return
+	}
+	var event model.Event
+	if err := model.DB.Preload("Creator").First(&event, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, 40401, "资源不存在")
+			return
+		}
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	var participant model.EventParticipant
+	if err := model.DB.Where("event_id = ? AND user_id = ?", event.ID, user.ID).First(&participant).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, 40301, "无权限")
+			return
+		}
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+
+	now := time.Now()
+	comment := strings.TrimSpace(req.Comment)
+	if err := model.DB.Model(&model.EventParticipant{}).Where("id = ?", participant.ID).Updates(map[string]interface{}{
+		"status":       req.Status,
+		"comment":      comment,
+		"responded_at": now,
+	}).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	_ = service.CreateResponseNotification(event, user, req.Status, comment)
+
+	if err := model.DB.Preload("Creator").Preload("Participants.User").First(&event, event.ID).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	publishParticipantResponded(user, event, req.Status, comment)
+	Success(c, buildEventResponse(event, user.ID))
+}
+
 // parseRFC3339 解析 RFC3339 时间字符串。
 func parseRFC3339(value string) (time.Time, error) {
 	return time.Parse(time.RFC3339, value)
@@ -351,6 +694,11 @@ func isValidEventType(value string) bool {
 	return value == "work" || value == "life" || value == "growth"
 }
 
+// isValidResponseStatus 校验参与人可提交的回复状态。
+func isValidResponseStatus(value string) bool {
+	return value == model.ResponseAccepted || value == model.ResponseDeclined || value == model.ResponseTentative
+}
+
 // hasParticipant 判断用户是否为参与人。
 func hasParticipant(list []model.EventParticipant, userID uint) bool {
 	for _, participant := range list {
@@ -370,12 +718,55 @@ func collectParticipantIDs(list []model.EventParticipant) []uint {
 	return uniqueUintList(ids)
 }
 
+// syncParticipants 将参与人替换为新列表，保留仍在列表中的参与人及其回复状态。
+func syncParticipants(tx *gorm.DB, eventID uint, creatorID uint, userIDs []uint) error {
+	keep := map[uint]struct{}{}
+	for _, userID := range uniqueUintList(userIDs) {
+		if userID != creatorID {
+			keep[userID] = struct{}{}
+		}
+	}
+	var existing []model.EventParticipant
+	if err := tx.Where("event_id = ?", eventID).Find(&existing).Error; err != nil {
+		return err
+	}
+	removedIDs := make([]uint, 0)
+	for _, participant := range existing {
+		if _, ok := keep[participant.UserID]; ok {
+			delete(keep, participant.UserID)
+			continue
+		}
+		removedIDs = append(removedIDs, participant.ID)
+	}
+	if len(removedIDs) > 0 {
+		if err := tx.Delete(&model.EventParticipant{}, removedIDs).Error; err != nil {
+			return err
+		}
+	}
+	participants := make([]model.EventParticipant, 0, len(keep))
+	for _, userID := range uniqueUintList(userIDs) {
+		if _, ok := keep[userID]; !ok {
+			continue
+		}
+		participants = append(participants, model.EventParticipant{
+			EventID: eventID,
+			UserID:  userID,
+			Status:  model.ResponsePending,
+		})
+	}
+	if len(participants) > 0 {
+		return tx.Create(&participants).Error
+	}
+	return nil
+}
+
 // buildEventResponse 构建带权限标识的日程响应。
 func buildEventResponse(event model.Event, viewerID uint) gin.H {
 	isCreator := event.UserID == viewerID
 	isCollaboration := !isCreator && hasParticipant(event.Participants, viewerID)
 	return gin.H{
 		"id":               event.ID,
+		"uid":              service.EventUID(event),
 		"user_id":          event.UserID,
 		"title":            event.Title,
 		"type":             event.Type,
@@ -383,15 +774,55 @@ func buildEventResponse(event model.Event, viewerID uint) gin.H {
 		"end_time":         event.EndTime,
 		"location":         event.Location,
 		"description":      event.Description,
+		"rrule":            event.RRule,
+		"reminders":        service.EventReminderOffsets(event),
 		"created_at":       event.CreatedAt,
 		"updated_at":       event.UpdatedAt,
 		"is_creator":       isCreator,
 		"is_collaboration": isCollaboration,
 		"creator":          event.Creator,
 		"participants":     event.Participants,
+		"my_response":      buildMyResponse(event.Participants, viewerID),
+		"response_counts":  countResponses(event.Participants),
 	}
 }
 
+// buildMyResponse 返回当前用户作为参与人的回复，创建者返回 nil。
+func buildMyResponse(list []model.EventParticipant, viewerID uint) gin.H {
+	for _, participant := range list {
+		if participant.UserID == viewerID {
+			return gin.H{
+				"status":       participant.Status,
+				"comment":      participant.Comment,
+				"responded_at": participant.RespondedAt,
+			}
+		}
+	}
+	return nil
+}
+
+// countResponses 统计各回复状态的参与人数。
+func countResponses(list []model.EventParticipant) gin.H {
+	counts := map[string]int{
+		model.ResponsePending:   0,
+		model.ResponseAccepted:  0,
+		model.ResponseDeclined:  0,
+		model.ResponseTentative: 0,
+	}
+	for _, participant := range list {
+		status := participant.Status
+		if status == "" {
+			status = model.ResponsePending
+		}
+		counts[status]++
+	}
+	result := gin.H{}
+	for status, count := range counts {
+		result[status] = count
+	}
+	return result
+}
+
 // eventSnapshot 输出日程快照用于操作日志。
 func eventSnapshot(event model.Event) map[string]interface{} {
 	return map[string]interface{}{
@@ -401,6 +832,8 @@ func eventSnapshot(event model.Event) map[string]interface{} {
 		"end_time":        event.EndTime,
 		"location":        event.Location,
 		"description":     event.Description,
+		"rrule":           event.RRule,
+		"reminders":       service.EventReminderOffsets(event),
 		"participant_ids": collectParticipantIDs(event.Participants),
 	}
 }
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"smartcalendar/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// updateEvent 以 owner 身份调用 UpdateEvent，返回业务码。
+func updateEvent(t *testing.T, owner model.User, eventID uint, body map[string]interface{}) int {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", owner) })
+	router.PUT("/api/events/:id", EventController{}.UpdateEvent)
+	data, _ := json.Marshal(body)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("PUT", fmt.Sprintf("/api/events/%d", eventID), bytes.NewReader(data)))
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode update: %v", err)
+	}
+	return resp.Code
+}
+
+// deleteEvent 以 owner 身份调用 DeleteEvent，返回业务码。
+func deleteEvent(t *testing.T, owner model.User, eventID uint, query string) int {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", owner) })
+	router.DELETE("/api/events/:id", EventController{}.DeleteEvent)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("DELETE", fmt.Sprintf("/api/events/%d?%s", eventID, query), nil))
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode delete: %v", err)
+	}
+	return resp.Code
+}
+
+// countRows 统计满足条件的记录数。
+func countRows(t *testing.T, value interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var count int64
+	if err := model.DB.Model(value).Where(query, args...).Count(&count).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return count
+}
+
+func TestUpdateEventRollsBackWhenOperationLogFails(t *testing.T) {
+	setupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	start := time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)
+	event := model.Event{UserID: alice.ID, Title: "站会", Type: "work", StartTime: start, EndTime: start.Add(30 * time.Minute), RRule: "FREQ=DAILY;COUNT=5"}
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		return createEventTx(tx, alice, &event, []uint{bob.ID})
+	}); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+
+	// 操作记录写入失败时，修改与通知都不应生效。
+	errInjected := errors.New("injected failure")
+	failLogs := true
+	if err := model.DB.Callback().Create().Before("gorm:create").Register("test:fail_operation_log", func(db *gorm.DB) {
+		if _, ok := db.Statement.Dest.(*model.OperationLog); ok && failLogs {
+			db.AddError(errInjected)
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	occurrence := start.Add(48 * time.Hour).Format(time.RFC3339)
+	for _, scope := range []string{recurrenceScopeThis, recurrenceScopeFollowing, recurrenceScopeAll} {
+		body := map[string]interface{}{"title": "站会（改）", "scope": scope}
+		if scope != recurrenceScopeAll {
+			body["occurrence_start"] = occurrence
+		}
+		if code := updateEvent(t, alice, event.ID, body); code != 50000 {
+			t.Fatalf("%s update with failing log = %d, want 50000", scope, code)
+		}
+	}
+	var stored model.Event
+	model.DB.First(&stored, event.ID)
+	if stored.Title != "站会" || stored.RRule != event.RRule {
+		t.Fatalf("event changed after failed update: %+v", stored)
+	}
+	if n := countRows(t, &model.EventException{}, "event_id = ?", event.ID); n != 0 {
+		t.Fatalf("exceptions = %d, want 0", n)
+	}
+	if n := countRows(t, &model.Event{}, "user_id = ?", alice.ID); n != 1 {
+		t.Fatalf("events = %d, want 1 (no split)", n)
+	}
+	if n := countRows(t, &model.Notification{}, "user_id = ? AND type <> ?", bob.ID, "invitation"); n != 0 {
+		t.Fatalf("notifications after failed update = %d, want 0", n)
+	}
+
+	// 操作记录正常时，拆分、操作记录与通知一并生效。
+	failLogs = false
+	if code := updateEvent(t, alice, event.ID, map[string]interface{}{"title": "站会（改）", "scope": recurrenceScopeFollowing, "occurrence_start": occurrence}); code != 0 {
+		t.Fatalf("following update = %d", code)
+	}
+	if n := countRows(t, &model.Event{}, "user_id = ?", alice.ID); n != 2 {
+		t.Fatalf("events = %d, want 2 after split", n)
+	}
+	if n := countRows(t, &model.OperationLog{}, "action = ? AND detail LIKE ?", "update", `%"scope":"following"%`); n != 1 {
+		t.Fatalf("following operation logs = %d, want 1", n)
+	}
+	if n := countRows(t, &model.Notification{}, "user_id = ? AND type <> ?", bob.ID, "invitation"); n != 1 {
+		t.Fatalf("notifications after update = %d, want 1", n)
+	}
+}
+
+func TestDeleteOccurrenceRollsBackWhenOperationLogFails(t *testing.T) {
+	setupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	start := time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)
+	event := model.Event{UserID: alice.ID, Title: "站会", Type: "work", StartTime: start, EndTime: start.Add(30 * time.Minute), RRule: "FREQ=DAILY;COUNT=5"}
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		return createEventTx(tx, alice, &event, []uint{bob.ID})
+	}); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	failLogs := true
+	if err := model.DB.Callback().Create().Before("gorm:create").Register("test:fail_operation_log", func(db *gorm.DB) {
+		if _, ok := db.Statement.Dest.(*model.OperationLog); ok && failLogs {
+			db.AddError(errors.New("injected failure"))
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	occurrence := "occurrence_start=" + start.Add(48*time.Hour).Format("2006-01-02T15:04:05Z")
+	cancellations := func() int64 {
+		return countRows(t, &model.Notification{}, "user_id = ? AND type <> ?", bob.ID, "invitation")
+	}
+
+	// 操作记录写入失败时，取消与截止都回滚且不通知。
+	for _, scope := range []string{recurrenceScopeThis, recurrenceScopeFollowing} {
+		if code := deleteEvent(t, alice, event.ID, "scope="+scope+"&"+occurrence); code != 50000 {
+			t.Fatalf("%s delete with failing log = %d, want 50000", scope, code)
+		}
+	}
+	var stored model.Event
+	model.DB.First(&stored, event.ID)
+	if stored.RRule != event.RRule || countRows(t, &model.EventException{}, "event_id = ?", event.ID) != 0 || cancellations() != 0 {
+		t.Fatalf("delete not rolled back: rrule %q", stored.RRule)
+	}
+
+	// 取消成功后通知一次，重复取消同一实例不再记录或通知。
+	failLogs = false
+	for i := 0; i < 2; i++ {
+		if code := deleteEvent(t, alice, event.ID, "scope="+recurrenceScopeThis+"&"+occurrence); code != 0 {
+			t.Fatalf("delete occurrence = %d", code)
+		}
+	}
+	if n := countRows(t, &model.OperationLog{}, "action = ?", "delete"); n != 1 {
+		t.Fatalf("delete operation logs = %d, want 1", n)
+	}
+	if n := cancellations(); n != 1 {
+		t.Fatalf("cancellation notifications = %d, want 1", n)
+	}
+}
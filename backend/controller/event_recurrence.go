@@ -0,0 +1,351 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 重复日程修改/删除范围。
+const (
+	recurrenceScopeThis      = "this"
+	recurrenceScopeFollowing = "following"
+	recurrenceScopeAll       = "all"
+)
+
+var errInvalidTimeRange = errors.New("invalid time range")
+
+// resolveRecurrenceScope 校验 scope 与 occurrence_start，非重复日程一律按 all 处理。
+func resolveRecurrenceScope(event model.Event, scope string, occurrenceStart string) (string, time.Time, error) {
+	if scope == "" {
+		scope = recurrenceScopeAll
+	}
+	if scope != recurrenceScopeThis && scope != recurrenceScopeFollowing && scope != recurrenceScopeAll {
+		return "", time.Time{}, errors.New("scope 无效")
+	}
+	if event.RRule == "" || scope == recurrenceScopeAll {
+		return recurrenceScopeAll, time.Time{}, nil
+	}
+	recurrenceID, err := parseRFC3339(occurrenceStart)
+	if err != nil {
+		return "", time.Time{}, errors.New("occurrence_start 无效")
+	}
+	if !service.IsOccurrence(event, recurrenceID) {
+		return "", time.Time{}, errors.New("occurrence_start 不是该日程的实例")
+	}
+	if scope == recurrenceScopeFollowing && recurrenceID.Equal(event.StartTime) {
+		return recurrenceScopeAll, time.Time{}, nil
+	}
+	return scope, recurrenceID, nil
+}
+
+// findException 按原始开始时间查找例外记录。
+func findException(db *gorm.DB, eventID uint, recurrenceID time.Time) (model.EventException, bool, error) {
+	var exceptions []model.EventException
+	if err := db.Where("event_id = ?", eventID).Find(&exceptions).Error; err != nil {
+		return model.EventException{}, false, err
+	}
+	for _, exception := range exceptions {
+		if exception.OriginalStart.Equal(recurrenceID) {
+			return exception, true, nil
+		}
+	}
+	return model.EventException{}, false, nil
+}
+
+// prepareOccurrence 基于已有例外或序列本身，计算单次实例修改后的例外记录（不落库）。
+func prepareOccurrence(event model.Event, recurrenceID time.Time, fields map[string]interface{}, startTime, endTime *time.Time) (model.EventException, error) {
+	exception, exists, err := findException(model.DB, event.ID, recurrenceID)
+	if err != nil {
+		return model.EventException{}, err
+	}
+	if exists && exception.Cancelled {
+		return model.EventException{}, gorm.ErrRecordNotFound
+	}
+	if !exists {
+		exception = model.EventException{
+			EventID:       event.ID,
+			OriginalStart: recurrenceID,
+			Title:         event.Title,
+			Type:          event.Type,
+			StartTime:     recurrenceID,
+			EndTime:       recurrenceID.Add(event.EndTime.Sub(event.StartTime)),
+			Location:      event.Location,
+			Description:   event.Description,
+		}
+	}
+	if value, ok := fields["title"].(string); ok {
+		exception.Title = value
+	}
+	if value, ok := fields["type"].(string); ok {
+		exception.Type = value
+	}
+	if value, ok := fields["location"].(string); ok {
+		exception.Location = value
+	}
+	if value, ok := fields["description"].(string); ok {
+		exception.Description = value
+	}
+	if startTime != nil {
+		exception.StartTime = *startTime
+	}
+	if endTime != nil {
+		exception.EndTime = *endTime
+	}
+	if !exception.EndTime.After(exception.StartTime) {
+		return model.EventException{}, errInvalidTimeRange
+	}
+	return exception, nil
+}
+
+// saveOccurrence 在同一事务中保存单次实例的例外记录并写入操作记录，返回修改后的实例。
+func saveOccurrence(user model.User, event model.Event, exception model.EventException, before map[string]interface{}) (service.EventOccurrence, error) {
+	var occurrence service.EventOccurrence
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&exception).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Creator").Preload("Participants.User").First(&event, event.ID).Error; err != nil {
+			return err
+		}
+		found := false
+		for _, item := range service.ExpandEvent(event, []model.EventException{exception}, exception.StartTime, exception.EndTime) {
+			if item.RecurrenceID.Equal(exception.OriginalStart) {
+				occurrence = service.EventOccurrence{Event: event, Occurrence: item}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		return service.CreateOperationLog(tx, user.ID, "update", occurrence.Title, map[string]interface{}{
+			"scope":  recurrenceScopeThis,
+			"before": before,
+			"after":  occurrenceSnapshot(occurrence),
+		})
+	}); err != nil {
+		return service.EventOccurrence{}, err
+	}
+	return occurrence, nil
+}
+
+// prepareSplitEvent 计算从 recurrenceID 起拆分出的新序列（不落库）。
+func prepareSplitEvent(user model.User, event model.Event, recurrenceID time.Time, fields map[string]interface{}, startTime, endTime *time.Time) (model.Event, error) {
+	newRRule, ok := fields["rrule"].(string)
+	if !ok {
+		var err error
+		newRRule, err = service.RemainingRRule(event, recurrenceID)
+		if err != nil {
+			return model.Event{}, err
+		}
+	}
+	newEvent := model.Event{
+		UserID:      user.ID,
+		Title:       event.Title,
+		Type:        event.Type,
+		StartTime:   recurrenceID,
+		EndTime:     recurrenceID.Add(event.EndTime.Sub(event.StartTime)),
+		Location:    event.Location,
+		Description: event.Description,
+		RRule:       newRRule,
+		Reminders:   event.Reminders,
+	}
+	if value, ok := fields["title"].(string); ok {
+		newEvent.Title = value
+	}
+	if value, ok := fields["type"].(string); ok {
+		newEvent.Type = value
+	}
+	if value, ok := fields["location"].(string); ok {
+		newEvent.Location = value
+	}
+	if value, ok := fields["description"].(string); ok {
+		newEvent.Description = value
+	}
+	if value, ok := fields["reminders"]; ok {
+		if reminders, ok := value.(string); ok {
+			newEvent.Reminders = &reminders
+		} else {
+			newEvent.Reminders = nil
+		}
+	}
+	if startTime != nil {
+		newEvent.StartTime = *startTime
+	}
+	if endTime != nil {
+		newEvent.EndTime = *endTime
+	}
+	if !newEvent.EndTime.After(newEvent.StartTime) {
+		return model.Event{}, errInvalidTimeRange
+	}
+	return newEvent, nil
+}
+
+// saveSplitSeries 在同一事务中截止原序列、保存拆分出的新序列及其参与人并写入操作记录。
+func saveSplitSeries(user model.User, event model.Event, recurrenceID time.Time, newEvent model.Event, participantIDs []uint, before map[string]interface{}) (model.Event, error) {
+	truncated, err := service.TruncateRRule(event.RRule, recurrenceID)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := truncateSeries(tx, event, recurrenceID, truncated); err != nil {
+			return err
+		}
+		newEvent.SeriesEnd = service.SeriesEnd(newEvent)
+		if err := tx.Create(&newEvent).Error; err != nil {
+			return err
+		}
+		participants := make([]model.EventParticipant, 0, len(participantIDs))
+		for _, userID := range uniqueUintList(participantIDs) {
+			if userID == user.ID {
+				continue
+			}
+			participants = append(participants, model.EventParticipant{
+				EventID: newEvent.ID,
+				UserID:  userID,
+			})
+		}
+		if len(participants) > 0 {
+			if err := tx.Create(&participants).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Creator").Preload("Participants.User").First(&newEvent, newEvent.ID).Error; err != nil {
+			return err
+		}
+		return service.CreateOperationLog(tx, user.ID, "update", newEvent.Title, map[string]interface{}{
+			"scope":            recurrenceScopeFollowing,
+			"occurrence_start": recurrenceID,
+			"before":           before,
+			"after":            eventSnapshot(newEvent),
+		})
+	}); err != nil {
+		return model.Event{}, err
+	}
+	return newEvent, nil
+}
+
+// truncateSeries 将重复日程截止到 recurrenceID 之前，并清理此后实例的例外记录。
+func truncateSeries(tx *gorm.DB, event model.Event, recurrenceID time.Time, truncatedRRule string) error {
+	truncated := event
+	truncated.RRule = truncatedRRule
+	if err := tx.Model(&model.Event{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"rrule":      truncatedRRule,
+		"series_end": service.SeriesEnd(truncated),
+	}).Error; err != nil {
+		return err
+	}
+	var exceptions []model.EventException
+	if err := tx.Where("event_id = ?", event.ID).Find(&exceptions).Error; err != nil {
+		return err
+	}
+	staleIDs := make([]uint, 0)
+	for _, exception := range exceptions {
+		if !exception.OriginalStart.Before(recurrenceID) {
+			staleIDs = append(staleIDs, exception.ID)
+		}
+	}
+	if len(staleIDs) > 0 {
+		if err := tx.Delete(&model.EventException{}, staleIDs).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOccurrence 将单次实例标记为取消，实例此前已取消时不做修改并返回 false。
+func cancelOccurrence(tx *gorm.DB, event model.Event, recurrenceID time.Time) (bool, error) {
+	exception, exists, err := findException(tx, event.ID, recurrenceID)
+	if err != nil {
+		return false, err
+	}
+	if exists && exception.Cancelled {
+		return false, nil
+	}
+	if !exists {
+		exception = model.EventException{
+			EventID:       event.ID,
+			OriginalStart: recurrenceID,
+			Title:         event.Title,
+			Type:          event.Type,
+			StartTime:     recurrenceID,
+			EndTime:       recurrenceID.Add(event.EndTime.Sub(event.StartTime)),
+			Location:      event.Location,
+			Description:   event.Description,
+		}
+	}
+	exception.Cancelled = true
+	if err := tx.Save(&exception).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// buildOccurrenceResponse 构建重复日程单次实例的响应，recurrence_id 用于后续按实例修改/删除。
+func buildOccurrenceResponse(occurrence service.EventOccurrence, viewerID uint) gin.H {
+	response := buildEventResponse(occurrence.Event, viewerID)
+	response["title"] = occurrence.Title
+	response["type"] = occurrence.Type
+	response["start_time"] = occurrence.StartTime
+	response["end_time"] = occurrence.EndTime
+	response["location"] = occurrence.Location
+	response["description"] = occurrence.Description
+	if occurrence.Event.RRule != "" {
+		response["recurrence_id"] = occurrence.RecurrenceID
+		response["is_exception"] = occurrence.IsException
+	}
+	return response
+}
+
+// occurrenceSnapshot 输出单次实例快照用于操作日志。
+func occurrenceSnapshot(occurrence service.EventOccurrence) map[string]interface{} {
+	snapshot := eventSnapshot(occurrence.Event)
+	snapshot["title"] = occurrence.Title
+	snapshot["type"] = occurrence.Type
+	snapshot["start_time"] = occurrence.StartTime
+	snapshot["end_time"] = occurrence.EndTime
+	snapshot["location"] = occurrence.Location
+	snapshot["description"] = occurrence.Description
+	snapshot["recurrence_id"] = occurrence.RecurrenceID
+	return snapshot
+}
+
+// occurrenceBeforeSnapshot 输出单次实例修改或取消前的快照，字段与 occurrenceSnapshot 一致；已有例外记录时按例外记录。
+func occurrenceBeforeSnapshot(event model.Event, recurrenceID time.Time) (map[string]interface{}, error) {
+	exception, exists, err := findException(model.DB, event.ID, recurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := eventSnapshot(event)
+	snapshot["start_time"] = recurrenceID
+	snapshot["end_time"] = recurrenceID.Add(event.EndTime.Sub(event.StartTime))
+	snapshot["recurrence_id"] = recurrenceID
+	if exists {
+		snapshot["title"] = exception.Title
+		snapshot["type"] = exception.Type
+		snapshot["start_time"] = exception.StartTime
+		snapshot["end_time"] = exception.EndTime
+		snapshot["location"] = exception.Location
+		snapshot["description"] = exception.Description
+	}
+	return snapshot, nil
+}
+
+// followingBeforeSnapshot 输出序列自 recurrenceID 起未修改时的快照，作为拆分出的新日程的比较基准。
+func followingBeforeSnapshot(event model.Event, recurrenceID time.Time) (map[string]interface{}, error) {
+	remaining, err := service.RemainingRRule(event, recurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := eventSnapshot(event)
+	snapshot["start_time"] = recurrenceID
+	snapshot["end_time"] = recurrenceID.Add(event.EndTime.Sub(event.StartTime))
+	snapshot["rrule"] = remaining
+	return snapshot, nil
+}
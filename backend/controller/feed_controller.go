@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedController 负责日历订阅令牌管理与只读 ICS 订阅源。
+type FeedController struct {
+	Cfg config.AppConfig
+}
+
+// FeedTokenCreateRequest 表示创建订阅令牌的请求体。
+type FeedTokenCreateRequest struct {
+	Name  string   `json:"name" binding:"max=50"`
+	Types []string `json:"types"`
+}
+
+// CreateFeedToken 创建订阅令牌，明文令牌与订阅链接仅在创建时返回一次。
+func (f FeedController) CreateFeedToken(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req FeedTokenCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	types, ok := normalizeFeedTypes(req.Types)
+	if !ok {
+		Error(c, 40001, "参数校验失败：types 无效")
+		return
+	}
+	count, err := service.CountActiveFeedTokens(user.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	if count >= service.MaxFeedTokensPerUser {
+		Error(c, 40001, "订阅链接数量已达上限，请先撤销不再使用的链接")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "日历订阅"
+	}
+
+	record, token, err := service.CreateFeedToken(user.ID, name, types)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+
+	response := buildFeedTokenResponse(record)
+	feedURL := f.feedURL(c, token)
+	response["token"] = token
+	response["url"] = feedURL
+	response["webcal_url"] = "webcal://" + strings.TrimPrefix(strings.TrimPrefix(feedURL, "https://"), "http://")
+	Success(c, response)
+}
+
+// ListFeedTokens 返回当前用户的订阅令牌（含已撤销），不包含明文令牌。
+func (f FeedController) ListFeedTokens(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var records []model.FeedToken
+	if err := model.DB.Where("user_id = ?", user.ID).Order("created_at desc").Find(&records).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	list := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		list = append(list, buildFeedTokenResponse(record))
+	}
+	Success(c, gin.H{"list": list})
+}
+
+// RevokeFeedToken 撤销订阅令牌，撤销后对应订阅链接立即失效。
+func (f FeedController) RevokeFeedToken(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		Error(c, 40401, "资源不存在")
+		return
+	}
+	if err := service.RevokeFeedToken(user.ID, uint(id)); err != nil {
+		if errors.Is(err, service.ErrFeedTokenNotFound) {
+			Error(c, 40401, "资源不存在")
+			return
+		}
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, nil)
+}
+
+// GetFeed 以订阅令牌鉴权输出只读 ICS 日历，供手机等日历客户端定期拉取。
+func (f FeedController) GetFeed(c *gin.Context) {
+	record, user, err := service.ResolveFeedToken(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, service.ErrFeedTokenNotFound) {
+			Error(c, 40401, "订阅链接不存在或已撤销")
+			return
+		}
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	types := service.FeedTokenTypes(record)
+	if value := c.Query("type"); value != "" {
+		requested, ok := normalizeFeedTypes(strings.Split(value, ","))
+		if !ok || len(requested) == 0 {
+			Error(c, 40001, "参数校验失败：type 无效")
+			return
+		}
+		types = intersectFeedTypes(types, requested)
+		if len(types) == 0 {
+			// 请求的类型不在令牌允许范围内时返回空日历，而不是放宽为全部类型。
+			c.Header("Cache-Control", "no-store")
+			c.Data(200, "text/calendar; charset=utf-8", []byte(service.BuildICS(record.Name, nil, nil)))
+			return
+		}
+	}
+
+	events, err := service.LoadVisibleEvents(user.ID, types)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	exceptions, err := service.LoadExceptions(collectEventIDs(events))
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	_ = service.TouchFeedToken(record.ID)
+	c.Header("Cache-Control", "no-store")
+	c.Data(200, "text/calendar; charset=utf-8", []byte(service.BuildICS(record.Name, events, exceptions)))
+}
+
+// feedURL 拼接订阅链接，优先使用配置的对外地址。
+func (f FeedController) feedURL(c *gin.Context, token string) string {
+	base := strings.TrimRight(f.Cfg.PublicBaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/api/feed/" + token
+}
+
+// buildFeedTokenResponse 构建订阅令牌响应，types 以数组返回。
+func buildFeedTokenResponse(record model.FeedToken) gin.H {
+	types := service.FeedTokenTypes(record)
+	if types == nil {
+		types = []string{}
+	}
+	return gin.H{
+		"id":           record.ID,
+		"name":         record.Name,
+		"token_prefix": record.TokenPrefix,
+		"types":        types,
+		"last_used_at": record.LastUsedAt,
+		"revoked_at":   record.RevokedAt,
+		"created_at":   record.CreatedAt,
+	}
+}
+
+// normalizeFeedTypes 校验并去重日程类型列表。
+func normalizeFeedTypes(values []string) ([]string, bool) {
+	seen := map[string]struct{}{}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !isValidEventType(value) {
+			return nil, false
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result, true
+}
+
+// intersectFeedTypes 在令牌允许的类型内进一步筛选，allowed 为空表示不限制。
+func intersectFeedTypes(allowed, requested []string) []string {
+	if len(allowed) == 0 {
+		return requested
+	}
+	result := make([]string, 0, len(requested))
+	for _, value := range requested {
+		for _, item := range allowed {
+			if item == value {
+				result = append(result, value)
+				break
+			}
+		}
+	}
+	return result
+}
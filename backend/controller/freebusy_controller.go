@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 忙闲查询限制，避免一次展开过多日程。
+const (
+	maxFreeBusyUsers = 20
+	maxFreeBusyRange = 62 * 24 * time.Hour
+)
+
+// FreeBusyController 负责忙闲查询与会议时段推荐接口。
+type FreeBusyController struct{}
+
+// FindSlotsRequest 表示查找共同空闲时段的请求体。
+type FindSlotsRequest struct {
+	ParticipantIDs  []uint `json:"participant_ids"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required,min=5,max=1440"`
+	Start           string `json:"start" binding:"required"`
+	End             string `json:"end" binding:"required"`
+	WorkStart       string `json:"work_start"`
+	WorkEnd         string `json:"work_end"`
+	WorkDays        []int  `json:"work_days"`
+	Timezone        string `json:"timezone"`
+	Limit           int    `json:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// GetFreeBusy 返回指定用户在区间内合并后的忙碌时段，不包含日程详情。
+func (f FreeBusyController) GetFreeBusy(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	userIDs, err := parseUintList(c.Query("user_ids"))
+	if err != nil {
+		Error(c, 40001, "参数校验失败：user_ids 无效")
+		return
+	}
+	if len(userIDs) == 0 {
+		userIDs = []uint{user.ID}
+	}
+	if len(userIDs) > maxFreeBusyUsers {
+		Error(c, 40001, "参数校验失败：user_ids 最多 20 个")
+		return
+	}
+	rangeStart, rangeEnd, ok := parseFreeBusyRange(c, c.Query("start"), c.Query("end"))
+	if !ok {
+		return
+	}
+
+	busy, err := service.FreeBusy(userIDs, rangeStart, rangeEnd)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	list := make([]gin.H, 0, len(userIDs))
+	for _, userID := range uniqueUintList(userIDs) {
+		list = append(list, gin.H{
+			"user_id": userID,
+			"busy":    busy[userID],
+		})
+	}
+	Success(c, gin.H{"list": list})
+}
+
+// FindSlots 在工作时间内为当前用户与参与人推荐共同空闲时段。
+func (f FreeBusyController) FindSlots(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req FindSlotsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	userIDs := uniqueUintList(append([]uint{user.ID}, req.ParticipantIDs...))
+	if len(userIDs) > maxFreeBusyUsers {
+		Error(c, 40001, "参数校验失败：participant_ids 最多 19 个")
+		return
+	}
+	rangeStart, rangeEnd, ok := parseFreeBusyRange(c, req.Start, req.End)
+	if !ok {
+		return
+	}
+	loc := time.Local
+	if req.Timezone != "" {
+		parsed, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			Error(c, 40001, "参数校验失败：timezone 无效")
+			return
+		}
+		loc = parsed
+	}
+	workStart, ok := clockOrDefault(req.WorkStart, 9*time.Hour)
+	if !ok {
+		Error(c, 40001, "参数校验失败：work_start 无效")
+		return
+	}
+	workEnd, ok := clockOrDefault(req.WorkEnd, 18*time.Hour)
+	if !ok || workEnd <= workStart {
+		Error(c, 40001, "参数校验失败：work_end 无效")
+		return
+	}
+	workDays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if req.WorkDays != nil {
+		workDays = workDays[:0]
+		for _, day := range req.WorkDays {
+			if day < 0 || day > 6 {
+				Error(c, 40001, "参数校验失败：work_days 无效")
+				return
+			}
+			workDays = append(workDays, time.Weekday(day))
+		}
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 5
+	}
+
+	slots, err := service.FindSlots(service.SlotQuery{
+		UserIDs:    userIDs,
+		RangeStart: rangeStart,
+		RangeEnd:   rangeEnd,
+		Duration:   time.Duration(req.DurationMinutes) * time.Minute,
+		WorkStart:  workStart,
+		WorkEnd:    workEnd,
+		WorkDays:   workDays,
+		Location:   loc,
+		Limit:      limit,
+	})
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{"list": slots})
+}
+
+// parseFreeBusyRange 解析并校验查询区间，失败时直接写入错误响应。
+func parseFreeBusyRange(c *gin.Context, startValue, endValue string) (time.Time, time.Time, bool) {
+	rangeStart, err := parseRFC3339(startValue)
+	if err != nil {
+		Error(c, 40001, "参数校验失败：start 无效")
+		return time.Time{}, time.Time{}, false
+	}
+	rangeEnd, err := parseRFC3339(endValue)
+	if err != nil {
+		Error(c, 40001, "参数校验失败：end 无效")
+		return time.Time{}, time.Time{}, false
+	}
+	if !rangeEnd.After(rangeStart) {
+		Error(c, 40001, "参数校验失败：end 必须晚于 start")
+		return time.Time{}, time.Time{}, false
+	}
+	if rangeEnd.Sub(rangeStart) > maxFreeBusyRange {
+		Error(c, 40001, "参数校验失败：查询区间不能超过 62 天")
+		return time.Time{}, time.Time{}, false
+	}
+	return rangeStart, rangeEnd, true
+}
+
+// parseUintList 解析逗号分隔的 ID 列表。
+func parseUintList(value string) ([]uint, error) {
+	var result []uint
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(item, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, uint(parsed))
+	}
+	return uniqueUintList(result), nil
+}
+
+// clockOrDefault 按 service.ParseClock 解析 HH:MM 格式的时刻，返回距零点的时长；为空时返回默认值。
+func clockOrDefault(value string, defaultValue time.Duration) (time.Duration, bool) {
+	if value == "" {
+		return defaultValue, true
+	}
+	minutes, ok := service.ParseClock(service.NormalizeClock(value))
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(minutes) * time.Minute, true
+}
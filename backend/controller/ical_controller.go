@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"log"
+	"strings"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ICalController 负责 iCalendar（.ics）导入导出接口。
+type ICalController struct{}
+
+// ExportICS 导出当前用户创建或参与的日程为 .ics 文件。
+func (i ICalController) ExportICS(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var eventTypes []string
+	if eventType := c.Query("type"); eventType != "" {
+		eventTypes = []string{eventType}
+	}
+	events, err := service.LoadVisibleEvents(user.ID, eventTypes)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	exceptions, err := service.LoadExceptions(collectEventIDs(events))
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	content := service.BuildICS(user.Nickname+" 的日程", events, exceptions)
+	c.Header("Content-Disposition", `attachment; filename="smartcalendar.ics"`)
+	c.Data(200, "text/calendar; charset=utf-8", []byte(content))
+}
+
+// ImportICS 解析上传的 .ics 文件并逐个创建日程，按 UID 去重保证重复导入幂等；
+// 失败的条目与按默认值处理的条目分别在 errors、warnings 中按 UID 列出。
+func (i ICalController) ImportICS(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	file, err := c.FormFile("file")
+	if err != nil {
+		Error(c, 40001, "参数校验失败：请上传文件")
+		return
+	}
+	if file.Size > 5*1024*1024 {
+		Error(c, 40001, "参数校验失败：文件过大")
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		Error(c, 50000, "文件打开失败")
+		return
+	}
+	defer src.Close()
+	parsed, itemErrs, err := service.ParseICS(src)
+	if err != nil {
+		Error(c, 40001, "参数校验失败：日历文件无法解析")
+		return
+	}
+
+	created, skipped := 0, 0
+	warnings := []service.ICSItemError{}
+	if itemErrs == nil {
+		itemErrs = []service.ICSItemError{}
+	}
+	for _, item := range parsed {
+		exists, err := importedEventExists(user.ID, item.UID)
+		if err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		if _, err := importICSEvent(user, item); err != nil {
+			log.Printf("[ics] import user=%d uid=%s failed: %v", user.ID, item.UID, err)
+			itemErrs = append(itemErrs, service.ICSItemError{UID: item.UID, Message: "创建日程失败"})
+			continue
+		}
+		if item.Type == "" && len(item.Categories) > 0 {
+			warnings = append(warnings, service.ICSItemError{
+				UID:     item.UID,
+				Message: "未识别的分类 " + strings.Join(item.Categories, ",") + "，已按 work 导入",
+			})
+		}
+		created++
+	}
+	Success(c, gin.H{
+		"total":    created + skipped + len(itemErrs),
+		"created":  created,
+		"skipped":  skipped,
+		"failed":   len(itemErrs),
+		"errors":   itemErrs,
+		"warnings": warnings,
+	})
+}
+
+// importedEventExists 判断用户可见的日程中是否已有该 UID。
+func importedEventExists(userID uint, uid string) (bool, error) {
+	if _, err := service.FindVisibleEventByUID(userID, uid); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// importICSEvent 通过与 CreateEvent 相同的事务路径创建日程，并写入例外记录与邀请通知。
+func importICSEvent(user model.User, item service.ICSEvent) (model.Event, error) {
+	// 未指定或无法识别分类时按 work 导入，由 ImportICS 在 warnings 中提示。
+	eventType := item.Type
+	if eventType == "" {
+		eventType = "work"
+	}
+	event := model.Event{
+		UID:         item.UID,
+		UserID:      user.ID,
+		Title:       truncateRunes(item.Title, 100),
+		Type:        eventType,
+		StartTime:   item.StartTime,
+		EndTime:     item.EndTime,
+		Location:    truncateRunes(item.Location, 200),
+		Description: truncateRunes(item.Description, 500),
+		RRule:       item.RRule,
+	}
+	participantIDs, err := resolveAttendeeEmails(item.Attendees)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := createEventTx(tx, user, &event, participantIDs); err != nil {
+			return err
+		}
+		if event.RRule == "" {
+			return nil
+		}
+		exceptions := buildICSExceptions(event, item)
+		if len(exceptions) > 0 {
+			return tx.Create(&exceptions).Error
+		}
+		return nil
+	}); err != nil {
+		return model.Event{}, err
+	}
+
+	if err := model.DB.Preload("Creator").Preload("Participants.User").First(&event, event.ID).Error; err != nil {
+		return model.Event{}, err
+	}
+	_ = service.CreateInvitationNotifications(event, collectParticipantIDs(event.Participants))
+	publishEventCreated(user, event)
+	return event, nil
+}
+
+// buildICSExceptions 将 EXDATE 与 RECURRENCE-ID 改期实例转换为例外记录。
+func buildICSExceptions(event model.Event, item service.ICSEvent) []model.EventException {
+	exceptions := make([]model.EventException, 0, len(item.ExDates)+len(item.Overrides))
+	for _, exDate := range item.ExDates {
+		exceptions = append(exceptions, model.EventException{
+			EventID:       event.ID,
+			OriginalStart: exDate,
+			Cancelled:     true,
+			Title:         event.Title,
+			Type:          event.Type,
+			StartTime:     exDate,
+			EndTime:       exDate.Add(event.EndTime.Sub(event.StartTime)),
+		})
+	}
+	for _, override := range item.Overrides {
+		exceptions = append(exceptions, model.EventException{
+			EventID:       event.ID,
+			OriginalStart: override.RecurrenceID,
+			Title:         truncateRunes(override.Title, 100),
+			Type:          event.Type,
+			StartTime:     override.StartTime,
+			EndTime:       override.EndTime,
+			Location:      truncateRunes(override.Location, 200),
+			Description:   truncateRunes(override.Description, 500),
+		})
+	}
+	return exceptions
+}
+
+// resolveAttendeeEmails 将 ATTENDEE 邮箱映射为系统用户 ID，未注册的邮箱忽略。
+func resolveAttendeeEmails(attendees []service.ICSAttendee) ([]uint, error) {
+	if len(attendees) == 0 {
+		return nil, nil
+	}
+	emails := make([]string, 0, len(attendees))
+	for _, attendee := range attendees {
+		emails = append(emails, strings.ToLower(attendee.Email))
+	}
+	var users []model.User
+	if err := model.DB.Where("email IN ?", emails).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// collectEventIDs 提取日程 ID。
+func collectEventIDs(events []model.Event) []uint {
+	ids := make([]uint, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+// truncateRunes 按字符数截断字符串，避免超出字段长度。
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit])
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importResult 为 ImportICS 的响应数据。
+type importResult struct {
+	Total    int                    `json:"total"`
+	Created  int                    `json:"created"`
+	Skipped  int                    `json:"skipped"`
+	Failed   int                    `json:"failed"`
+	Errors   []service.ICSItemError `json:"errors"`
+	Warnings []service.ICSItemError `json:"warnings"`
+}
+
+// importICS 以 user 身份上传日历文件并返回导入结果。
+func importICS(t *testing.T, user model.User, content string) importResult {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", user) })
+	router.POST("/api/ics/import", ICalController{}.ImportICS)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "import.ics")
+	part.Write([]byte(content))
+	writer.Close()
+	req := httptest.NewRequest("POST", "/api/ics/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	var resp struct {
+		Code int          `json:"code"`
+		Data importResult `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil || resp.Code != 0 {
+		t.Fatalf("import = %s", recorder.Body.String())
+	}
+	return resp.Data
+}
+
+// icsVEvent 构造 VEVENT 内容行。
+func icsVEvent(lines ...string) string {
+	return "BEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\n"
+}
+
+func TestImportICSReportsItemErrors(t *testing.T) {
+	setupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	user := createTestUser(t, "alice")
+	content := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		icsVEvent("UID:life@example.com", "DTSTART:20261020T060000Z", "DTEND:20261020T070000Z", "SUMMARY:跑步", "CATEGORIES:Sport,Life") +
+		icsVEvent("UID:color@example.com", "DTSTART:20261021T060000Z", "DTEND:20261021T070000Z", "SUMMARY:评审", "CATEGORIES:Red Category") +
+		icsVEvent("UID:no-start@example.com", "SUMMARY:缺少开始时间") +
+		icsVEvent("UID:bad-end@example.com", "DTSTART:20261022T070000Z", "DTEND:20261022T060000Z", "SUMMARY:倒置") +
+		icsVEvent("UID:monthly@example.com", "DTSTART:20261015T060000Z", "DTEND:20261015T070000Z", "SUMMARY:月报", "RRULE:FREQ=MONTHLY;BYMONTHDAY=15", "EXDATE:20261115T060000Z") +
+		icsVEvent("UID:monthly@example.com", "RECURRENCE-ID:20261215T060000Z", "DTSTART:20261216T060000Z", "DTEND:20261216T070000Z", "SUMMARY:月报（改期）") +
+		"END:VCALENDAR\r\n"
+
+	result := importICS(t, user, content)
+	if result.Total != 5 || result.Created != 2 || result.Skipped != 0 || result.Failed != 3 {
+		t.Fatalf("result = %+v", result)
+	}
+	want := map[string]string{
+		"no-start@example.com": "缺少 DTSTART",
+		"bad-end@example.com":  "结束时间必须晚于开始时间",
+		"monthly@example.com":  "不支持的 RRULE：FREQ=MONTHLY;BYMONTHDAY=15",
+	}
+	for _, item := range result.Errors {
+		if want[item.UID] != item.Message {
+			t.Fatalf("errors = %+v", result.Errors)
+		}
+		delete(want, item.UID)
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing errors %v in %+v", want, result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].UID != "color@example.com" || !strings.Contains(result.Warnings[0].Message, "Red Category") {
+		t.Fatalf("warnings = %+v", result.Warnings)
+	}
+	types := map[string]string{}
+	var events []model.Event
+	model.DB.Where("user_id = ?", user.ID).Find(&events)
+	for _, event := range events {
+		types[event.UID] = event.Type
+	}
+	if _, ok := types["monthly@example.com"]; ok || types["life@example.com"] != "life" || types["color@example.com"] != "work" {
+		t.Fatalf("types = %v", types)
+	}
+
+	// 重复导入：已有条目计入 skipped，错误条目仍逐条列出。
+	again := importICS(t, user, content)
+	if again.Total != 5 || again.Created != 0 || again.Skipped != 2 || again.Failed != 3 || len(again.Errors) != 3 || len(again.Warnings) != 0 {
+		t.Fatalf("second import = %+v", again)
+	}
+}
@@ -1,9 +1,14 @@
 package controller
 
 import (
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"smartcalendar/model"
+	"smartcalendar/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -61,6 +66,17 @@ func (n NotificationController) UnreadCount(c *gin.Context) {
 	Success(c, gin.H{"count": count})
 }
 
+// CreateStreamTicket 签发通知推送流的一次性票据，浏览器 EventSource 以 ticket 参数建立连接。
+func (n NotificationController) CreateStreamTicket(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	ticket, expiresAt, err := service.CreateStreamTicket(user.ID, time.Now())
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{"ticket": ticket, "expires_at": expiresAt})
+}
+
 // MarkRead 标记单条通知为已读。
 func (n NotificationController) MarkRead(c *gin.Context) {
 	user := c.MustGet("user").(model.User)
@@ -98,3 +114,130 @@ func (n NotificationController) MarkAllRead(c *gin.Context) {
 	}
 	Success(c, gin.H{"updated": result.RowsAffected})
 }
+
+// 通知推送流参数。
+const (
+	notificationStreamHeartbeat = 25 * time.Second // 心跳间隔，同时从数据库补齐其他实例产生的通知
+	notificationStreamPageSize  = 100              // 每次从数据库补齐的通知数
+	notificationStreamRetryMs   = 5000             // 建议客户端断线重连的等待毫秒数
+)
+
+// StreamNotifications 以 Server-Sent Events 推送当前用户的新通知。
+// 客户端可通过 Last-Event-ID 请求头或 last_event_id 参数从上次收到的通知之后续传，未指定时只推送连接之后的新通知。
+func (n NotificationController) StreamNotifications(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	lastID, resume, ok := parseLastEventID(c)
+	if !ok {
+		Error(c, 40001, "参数校验失败：last_event_id 无效")
+		return
+	}
+
+	// 先订阅再读取起始位置，避免两者之间产生的通知被遗漏。
+	messages, cancel := service.SubscribeNotifications(user.ID)
+	defer cancel()
+	if !resume {
+		if err := model.DB.Model(&model.Notification{}).
+			Where("user_id = ?", user.ID).
+			Select("COALESCE(MAX(id), 0)").
+			Scan(&lastID).Error; err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+	if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", notificationStreamRetryMs); err != nil {
+		return
+	}
+	if err := writeSSE(c, "", "ready", gin.H{"last_event_id": lastID}); err != nil {
+		return
+	}
+	if resume {
+		if err := sendPendingNotifications(c, user.ID, &lastID); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(notificationStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case _, open := <-messages:
+			if !open {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err := sendPendingNotifications(c, user.ID, &lastID); err != nil {
+			return
+		}
+	}
+}
+
+// parseLastEventID 解析续传位置，返回通知 ID、是否指定以及是否合法。
+func parseLastEventID(c *gin.Context) (uint, bool, bool) {
+	value := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if value == "" {
+		value = strings.TrimSpace(c.Query("last_event_id"))
+	}
+	if value == "" {
+		return 0, false, true
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, false, false
+	}
+	return uint(parsed), true, true
+}
+
+// sendPendingNotifications 从数据库按 ID 顺序推送 lastID 之后的通知，并推进 lastID。
+// 推送消息只作为唤醒信号，以数据库为准可保证不重不漏，也能补齐其他实例产生的通知。
+func sendPendingNotifications(c *gin.Context, userID uint, lastID *uint) error {
+	for {
+		var list []model.Notification
+		if err := model.DB.Where("user_id = ? AND id > ?", userID, *lastID).
+			Order("id asc").
+			Limit(notificationStreamPageSize).
+			Find(&list).Error; err != nil {
+			return err
+		}
+		for _, notification := range list {
+			if err := writeSSE(c, strconv.FormatUint(uint64(notification.ID), 10), "notification", notification); err != nil {
+				return err
+			}
+			*lastID = notification.ID
+		}
+		if len(list) < notificationStreamPageSize {
+			return nil
+		}
+	}
+}
+
+// writeSSE 写出一条 SSE 消息并立即刷新。
+func writeSSE(c *gin.Context, id string, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	b.WriteString("event: " + event + "\n")
+	b.WriteString("data: " + string(payload) + "\n\n")
+	if _, err := c.Writer.WriteString(b.String()); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"errors"
 	"strings"
 
 	"smartcalendar/model"
+	"smartcalendar/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -18,6 +20,43 @@ type UpdateProfileRequest struct {
 	Avatar string `json:"avatar" binding:"omitempty,max=500"`
 }
 
+// ReminderSettingsRequest 表示默认提醒设置请求体。
+type ReminderSettingsRequest struct {
+	DefaultReminders *[]int `json:"default_reminders" binding:"required"`
+}
+
+// NotificationChannelsRequest 表示通知渠道设置请求体。
+type NotificationChannelsRequest struct {
+	Email *bool `json:"email" binding:"required"`
+}
+
+// NotificationPreferencesRequest 表示通知偏好设置请求体，未提供的字段保持不变。
+type NotificationPreferencesRequest struct {
+	Timezone   *string                                `json:"timezone" binding:"omitempty,max=50"`
+	QuietHours *service.QuietHours                    `json:"quiet_hours"`
+	Types      map[string]NotificationChannelsSetting `json:"types"`
+}
+
+// NotificationChannelsSetting 表示某类通知的渠道开关，未提供的渠道保持不变。
+type NotificationChannelsSetting struct {
+	InApp *bool `json:"in_app"`
+	Email *bool `json:"email"`
+}
+
+// DigestSettingsRequest 表示每日日程摘要设置请求体。
+type DigestSettingsRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Time    string `json:"time"`
+}
+
+// AIPreferencesRequest 表示 AI 助手偏好设置请求体，未提供的字段保持不变。
+type AIPreferencesRequest struct {
+	Locale    *string `json:"locale"`
+	WorkStart *string `json:"work_start"`
+	WorkEnd   *string `json:"work_end"`
+	WorkDays  *[]int  `json:"work_days"`
+}
+
 // GetProfile 返回当前登录用户资料。
 func (u UserController) GetProfile(c *gin.Context) {
 	userValue, exists := c.Get("user")
@@ -72,6 +111,259 @@ func (u UserController) UpdateProfile(c *gin.Context) {
 	Success(c, user)
 }
 
+// GetReminderSettings 返回当前用户的默认提醒（提前分钟数）。
+func (u UserController) GetReminderSettings(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	Success(c, gin.H{"default_reminders": service.ParseReminderOffsets(user.DefaultReminders)})
+}
+
+// UpdateReminderSettings 更新当前用户的默认提醒，空数组表示默认不提醒。
+func (u UserController) UpdateReminderSettings(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req ReminderSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	if err := service.ValidateReminderOffsets(*req.DefaultReminders); err != nil {
+		Error(c, 40001, "参数校验失败：default_reminders 无效")
+		return
+	}
+	value := service.FormatReminderOffsets(*req.DefaultReminders)
+	if err := model.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("default_reminders", value).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{"default_reminders": service.ParseReminderOffsets(value)})
+}
+
+// GetNotificationChannels 返回当前用户的通知渠道设置，email_available 表示服务端是否已配置邮件通道。
+func (u UserController) GetNotificationChannels(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	Success(c, gin.H{
+		"email":           user.EmailNotifications,
+		"email_available": service.EmailEnabled(),
+	})
+}
+
+// UpdateNotificationChannels 更新当前用户是否通过邮件接收通知。
+func (u UserController) UpdateNotificationChannels(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req NotificationChannelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	if err := model.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("email_notifications", *req.Email).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, gin.H{
+		"email":           *req.Email,
+		"email_available": service.EmailEnabled(),
+	})
+}
+
+// GetNotificationPreferences 返回当前用户各类通知的渠道设置、时区与免打扰时段。
+func (u UserController) GetNotificationPreferences(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	preferences, err := service.LoadNotificationPreferences(model.DB, user.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, buildNotificationPreferences(user, preferences))
+}
+
+// UpdateNotificationPreferences 更新当前用户的通知渠道、时区与免打扰时段。
+func (u UserController) UpdateNotificationPreferences(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	preferences, err := service.LoadNotificationPreferences(model.DB, user.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	changed := map[string]service.NotificationChannels{}
+	for notificationType, setting := range req.Types {
+		if !service.IsNotificationType(notificationType) {
+			Error(c, 40001, "参数校验失败：types 包含未知的通知类型 "+notificationType)
+			return
+		}
+		channels := preferences[notificationType]
+		if setting.InApp != nil {
+			channels.InApp = *setting.InApp
+		}
+		if setting.Email != nil {
+			channels.Email = *setting.Email
+		}
+		preferences[notificationType] = channels
+		changed[notificationType] = channels
+	}
+	updates := map[string]interface{}{}
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if err := service.ValidateTimezone(timezone); err != nil {
+			Error(c, 40001, "参数校验失败：timezone 无效")
+			return
+		}
+		updates["timezone"] = timezone
+		user.Timezone = timezone
+	}
+	if req.QuietHours != nil {
+		quiet := *req.QuietHours
+		quiet.Start = service.NormalizeClock(quiet.Start)
+		quiet.End = service.NormalizeClock(quiet.End)
+		if err := service.ValidateQuietHours(quiet); err != nil {
+			Error(c, 40001, "参数校验失败：quiet_hours 无效，start 与 end 须为不同的 HH:MM")
+			return
+		}
+		if !quiet.Enabled {
+			quiet.Start, quiet.End = "", ""
+		}
+		updates["quiet_hours_start"] = quiet.Start
+		updates["quiet_hours_end"] = quiet.End
+		user.QuietHoursStart, user.QuietHoursEnd = quiet.Start, quiet.End
+	}
+
+	if err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return service.SaveNotificationPreferences(tx, user.ID, changed)
+	}); err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, buildNotificationPreferences(user, preferences))
+}
+
+// buildNotificationPreferences 输出通知偏好，email_notifications 为邮件总开关（见通知渠道设置）。
+func buildNotificationPreferences(user model.User, preferences map[string]service.NotificationChannels) gin.H {
+	return gin.H{
+		"types":               preferences,
+		"timezone":            user.Timezone,
+		"effective_timezone":  service.UserLocation(user).String(),
+		"quiet_hours":         service.UserQuietHours(user),
+		"email_notifications": user.EmailNotifications,
+		"email_available":     service.EmailEnabled(),
+	}
+}
+
+// GetDigestSettings 返回当前用户的每日日程摘要设置。
+func (u UserController) GetDigestSettings(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	Success(c, buildDigestSettings(user))
+}
+
+// UpdateDigestSettings 开启或关闭每日日程摘要，开启时未指定时间则使用默认时间。
+func (u UserController) UpdateDigestSettings(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req DigestSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	digestTime := ""
+	if *req.Enabled {
+		digestTime = service.NormalizeClock(req.Time)
+		if digestTime == "" {
+			digestTime = service.DefaultDigestTime
+		}
+		if err := service.ValidateDigestTime(digestTime); err != nil {
+			Error(c, 40001, "参数校验失败：time 须为 HH:MM")
+			return
+		}
+	}
+	if err := model.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("digest_time", digestTime).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	user.DigestTime = digestTime
+	Success(c, buildDigestSettings(user))
+}
+
+// buildDigestSettings 输出每日摘要设置，发送时间按 effective_timezone 解释。
+func buildDigestSettings(user model.User) gin.H {
+	return gin.H{
+		"enabled":            user.DigestTime != "",
+		"time":               user.DigestTime,
+		"effective_timezone": service.UserLocation(user).String(),
+	}
+}
+
+// GetAIPreferences 返回当前用户的 AI 助手偏好。
+func (u UserController) GetAIPreferences(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	Success(c, buildAIPreferences(user))
+}
+
+// UpdateAIPreferences 更新 AI 助手的语言区域、工作时间与工作日。
+func (u UserController) UpdateAIPreferences(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req AIPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	prefs := service.UserAssistantPreferences(user)
+	if req.Locale != nil {
+		prefs.Locale = strings.TrimSpace(*req.Locale)
+	}
+	if req.WorkStart != nil {
+		prefs.WorkStart = service.NormalizeClock(*req.WorkStart)
+	}
+	if req.WorkEnd != nil {
+		prefs.WorkEnd = service.NormalizeClock(*req.WorkEnd)
+	}
+	if req.WorkDays != nil {
+		prefs.WorkDays = *req.WorkDays
+	}
+	if err := service.ValidateAssistantPreferences(prefs); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLocale):
+			Error(c, 40001, "参数校验失败：locale 不支持")
+		case errors.Is(err, service.ErrInvalidWorkHours):
+			Error(c, 40001, "参数校验失败：work_start 与 work_end 须为 HH:MM 且开始早于结束")
+		default:
+			Error(c, 40001, "参数校验失败：work_days 须为 0-6 且至少一天")
+		}
+		return
+	}
+	updates := map[string]interface{}{
+		"locale":     prefs.Locale,
+		"work_start": prefs.WorkStart,
+		"work_end":   prefs.WorkEnd,
+		"work_days":  service.FormatWorkDays(prefs.WorkDays),
+	}
+	if err := model.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	user.Locale, user.WorkStart, user.WorkEnd = prefs.Locale, prefs.WorkStart, prefs.WorkEnd
+	user.WorkDays = updates["work_days"].(string)
+	Success(c, buildAIPreferences(user))
+}
+
+// buildAIPreferences 输出 AI 助手偏好，工作时间按 effective_timezone 解释，week_start 由语言区域决定（0 为周日）。
+func buildAIPreferences(user model.User) gin.H {
+	prefs := service.UserAssistantPreferences(user)
+	return gin.H{
+		"locale":             prefs.Locale,
+		"work_start":         prefs.WorkStart,
+		"work_end":           prefs.WorkEnd,
+		"work_days":          prefs.WorkDays,
+		"week_start":         int(service.LocaleWeekStart(prefs.Locale)),
+		"effective_timezone": service.UserLocation(user).String(),
+	}
+}
+
 // SearchUsers 按关键词搜索用户。
 func (u UserController) SearchUsers(c *gin.Context) {
 	keyword := strings.TrimSpace(c.Query("keyword"))
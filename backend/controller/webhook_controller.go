@@ -0,0 +1,422 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookController 负责 Webhook 登记、测试投递与投递日志查询。
+type WebhookController struct{}
+
+// WebhookCreateRequest 表示登记 Webhook 的请求体。
+type WebhookCreateRequest struct {
+	Name   string   `json:"name" binding:"max=50"`
+	URL    string   `json:"url" binding:"required,max=500"`
+	Events []string `json:"events"`
+	Global bool     `json:"global"`
+}
+
+// WebhookUpdateRequest 表示修改 Webhook 的请求体，未提供的字段保持不变。
+type WebhookUpdateRequest struct {
+	Name   *string   `json:"name" binding:"omitempty,max=50"`
+	URL    *string   `json:"url" binding:"omitempty,max=500"`
+	Events *[]string `json:"events"`
+	Active *bool     `json:"active"`
+}
+
+// CreateWebhook 登记 Webhook，签名密钥仅在创建时返回一次。仅管理员可登记接收全部日程事件的全局 Webhook。
+func (w WebhookController) CreateWebhook(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var req WebhookCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	if req.Global && user.Role != "admin" {
+		Error(c, 40301, "无权限")
+		return
+	}
+	if err := service.ValidateWebhookURL(req.URL); err != nil {
+		Error(c, 40001, "参数校验失败：url 无效或不允许指向内网地址")
+		return
+	}
+	events, ok := normalizeWebhookEvents(req.Events)
+	if !ok {
+		Error(c, 40001, "参数校验失败：events 无效")
+		return
+	}
+	count, err := service.CountWebhooks(user.ID)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	if count >= service.MaxWebhooksPerUser {
+		Error(c, 40001, "Webhook 数量已达上限，请先删除不再使用的 Webhook")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Webhook"
+	}
+
+	hook, err := service.CreateWebhook(user.ID, name, req.URL, events, req.Global)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	response := buildWebhookResponse(hook)
+	response["secret"] = hook.Secret
+	Success(c, response)
+}
+
+// ListWebhooks 返回当前用户登记的 Webhook，不包含签名密钥。
+func (w WebhookController) ListWebhooks(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	var hooks []model.Webhook
+	if err := model.DB.Where("user_id = ?", user.ID).Order("created_at desc").Find(&hooks).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	list := make([]gin.H, 0, len(hooks))
+	for _, hook := range hooks {
+		list = append(list, buildWebhookResponse(hook))
+	}
+	Success(c, gin.H{"list": list, "event_types": service.WebhookEventTypes})
+}
+
+// UpdateWebhook 修改 Webhook 的名称、地址、订阅事件或启用状态。
+func (w WebhookController) UpdateWebhook(c *gin.Context) {
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	var req WebhookUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, 40001, "参数校验失败："+err.Error())
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			name = "Webhook"
+		}
+		updates["name"] = name
+	}
+	if req.URL != nil {
+		if err := service.ValidateWebhookURL(*req.URL); err != nil {
+			Error(c, 40001, "参数校验失败：url 无效或不允许指向内网地址")
+			return
+		}
+		updates["url"] = strings.TrimSpace(*req.URL)
+	}
+	if req.Events != nil {
+		events, ok := normalizeWebhookEvents(*req.Events)
+		if !ok {
+			Error(c, 40001, "参数校验失败：events 无效")
+			return
+		}
+		updates["events"] = strings.Join(events, ",")
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if len(updates) > 0 {
+		if err := model.DB.Model(&model.Webhook{}).Where("id = ?", hook.ID).Updates(updates).Error; err != nil {
+			Error(c, 50000, "服务器内部错误")
+			return
+		}
+	}
+	if err := model.DB.First(&hook, hook.ID).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, buildWebhookResponse(hook))
+}
+
+// DeleteWebhook 删除 Webhook 及其投递日志，尚未发送的投递随之取消。
+func (w WebhookController) DeleteWebhook(c *gin.Context) {
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteWebhook(hook); err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, nil)
+}
+
+// RotateWebhookSecret 重新生成签名密钥，新密钥仅在本次返回。
+func (w WebhookController) RotateWebhookSecret(c *gin.Context) {
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	hook, err := service.RotateWebhookSecret(hook)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	response := buildWebhookResponse(hook)
+	response["secret"] = hook.Secret
+	Success(c, response)
+}
+
+// PingWebhook 发送一次测试事件，投递结果可在投递日志中查看。
+func (w WebhookController) PingWebhook(c *gin.Context) {
+	user := c.MustGet("user").(model.User)
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := service.EnqueueWebhookPing(hook, user)
+	if err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, buildWebhookDeliveryResponse(delivery))
+}
+
+// ListWebhookDeliveries 分页查询 Webhook 的投递日志，可按状态筛选。
+func (w WebhookController) ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	page := parsePage(c.Query("page"), 1)
+	pageSize := parsePageSize(c.Query("page_size"), 20)
+	offset := (page - 1) * pageSize
+
+	query := model.DB.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	var deliveries []model.WebhookDelivery
+	if err := query.Order("id desc").Offset(offset).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	list := make([]gin.H, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		list = append(list, buildWebhookDeliveryResponse(delivery))
+	}
+	Success(c, gin.H{
+		"list":      list,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// RedeliverWebhook 将已失败的投递重新加入队列，重试次数清零。
+func (w WebhookController) RedeliverWebhook(c *gin.Context) {
+	hook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+	var delivery model.WebhookDelivery
+	if err := model.DB.Where("id = ? AND webhook_id = ?", c.Param("deliveryId"), hook.ID).First(&delivery).Error; err != nil {
+		Error(c, 40401, "资源不存在")
+		return
+	}
+	if delivery.Status != model.WebhookDeliveryFailed {
+		Error(c, 40901, "仅可重新投递已失败的记录")
+		return
+	}
+	now := time.Now().UTC()
+	if err := model.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ?", delivery.ID, model.WebhookDeliveryFailed).
+		Updates(map[string]interface{}{
+			"status":          model.WebhookDeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"locked_by":       "",
+			"locked_until":    nil,
+		}).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	if err := model.DB.First(&delivery, delivery.ID).Error; err != nil {
+		Error(c, 50000, "服务器内部错误")
+		return
+	}
+	Success(c, buildWebhookDeliveryResponse(delivery))
+}
+
+// loadOwnWebhook 按路径参数加载当前用户的 Webhook，失败时直接写入错误响应。
+func loadOwnWebhook(c *gin.Context) (model.Webhook, bool) {
+	user := c.MustGet("user").(model.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		Error(c, 40401, "资源不存在")
+		return model.Webhook{}, false
+	}
+	hook, err := service.FindWebhook(user.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			Error(c, 40401, "资源不存在")
+			return model.Webhook{}, false
+		}
+		Error(c, 50000, "服务器内部错误")
+		return model.Webhook{}, false
+	}
+	return hook, true
+}
+
+// normalizeWebhookEvents 校验并去重订阅事件类型，为空时订阅全部事件。
+func normalizeWebhookEvents(values []string) ([]string, bool) {
+	if len(values) == 0 {
+		return append([]string{}, service.WebhookEventTypes...), true
+	}
+	allowed := map[string]struct{}{}
+	for _, item := range service.WebhookEventTypes {
+		allowed[item] = struct{}{}
+	}
+	seen := map[string]struct{}{}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if _, ok := allowed[value]; !ok {
+			return nil, false
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result, true
+}
+
+// buildWebhookResponse 输出 Webhook 信息，不包含签名密钥。
+func buildWebhookResponse(hook model.Webhook) gin.H {
+	return gin.H{
+		"id":           hook.ID,
+		"name":         hook.Name,
+		"url":          hook.URL,
+		"events":       service.WebhookEvents(hook),
+		"global":       hook.Global,
+		"active":       hook.Active,
+		"last_status":  hook.LastStatus,
+		"last_sent_at": hook.LastSentAt,
+		"created_at":   hook.CreatedAt,
+	}
+}
+
+// buildWebhookDeliveryResponse 输出投递日志，请求体按 JSON 对象返回便于查看。
+func buildWebhookDeliveryResponse(delivery model.WebhookDelivery) gin.H {
+	return gin.H{
+		"id":              delivery.ID,
+		"webhook_id":      delivery.WebhookID,
+		"event_type":      delivery.EventType,
+		"event_id":        delivery.EventID,
+		"payload":         json.RawMessage(delivery.Payload),
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"response_status": delivery.ResponseStatus,
+		"response_body":   delivery.ResponseBody,
+		"duration_ms":     delivery.DurationMs,
+		"last_error":      delivery.LastError,
+		"delivered_at":    delivery.DeliveredAt,
+		"created_at":      delivery.CreatedAt,
+	}
+}
+
+// publishEventCreated 向相关 Webhook 投递 event.created。
+func publishEventCreated(actor model.User, event model.Event) {
+	publishWebhookEvent(service.WebhookEventCreated, actor, event, nil, map[string]interface{}{
+		"event": eventSnapshot(event),
+	})
+}
+
+// publishEventUpdated 投递 event.updated，参与人变化时另行投递 participant.added / participant.removed。
+// extra 中的 scope、occurrence_start 等字段原样附加到事件数据。
+func publishEventUpdated(actor model.User, event model.Event, before map[string]interface{}, after map[string]interface{}, extra map[string]interface{}) {
+	beforeIDs, _ := before["participant_ids"].([]uint)
+	afterIDs, _ := after["participant_ids"].([]uint)
+	data := map[string]interface{}{
+		"before":  before,
+		"after":   after,
+		"changed": changedSnapshotFields(before, after),
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	publishWebhookEvent(service.WebhookEventUpdated, actor, event, beforeIDs, data)
+
+	added, removed := service.DiffIDs(beforeIDs, afterIDs)
+	if len(added) > 0 {
+		publishWebhookEvent(service.WebhookParticipantAdded, actor, event, nil, map[string]interface{}{
+			"event":    after,
+			"user_ids": added,
+		})
+	}
+	if len(removed) > 0 {
+		publishWebhookEvent(service.WebhookParticipantRemoved, actor, event, removed, map[string]interface{}{
+			"event":    after,
+			"user_ids": removed,
+		})
+	}
+}
+
+// publishEventDeleted 投递 event.deleted，event 需预加载参与人。
+func publishEventDeleted(actor model.User, event model.Event, extra map[string]interface{}) {
+	data := map[string]interface{}{
+		"event": eventSnapshot(event),
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	publishWebhookEvent(service.WebhookEventDeleted, actor, event, nil, data)
+}
+
+// publishParticipantResponded 投递 participant.responded，event 需预加载参与人。
+func publishParticipantResponded(actor model.User, event model.Event, status string, comment string) {
+	publishWebhookEvent(service.WebhookParticipantResponded, actor, event, nil, map[string]interface{}{
+		"event":   eventSnapshot(event),
+		"user_id": actor.ID,
+		"status":  status,
+		"comment": comment,
+	})
+}
+
+// publishWebhookEvent 补充日程标识后写入投递队列，接收方为创建者、参与人与 extraUserIDs 登记的 Webhook。
+func publishWebhookEvent(eventType string, actor model.User, event model.Event, extraUserIDs []uint, data map[string]interface{}) {
+	data["event_id"] = event.ID
+	data["uid"] = event.UID
+	userIDs := append([]uint{event.UserID}, collectParticipantIDs(event.Participants)...)
+	userIDs = uniqueUintList(append(userIDs, extraUserIDs...))
+	_ = service.EnqueueWebhookEvent(service.WebhookEvent{
+		Type:    eventType,
+		EventID: event.ID,
+		Actor:   actor,
+		UserIDs: userIDs,
+		Data:    data,
+	})
+}
+
+// changedSnapshotFields 比较两份快照，返回取值不同的字段名。
+func changedSnapshotFields(before map[string]interface{}, after map[string]interface{}) []string {
+	changed := []string{}
+	for key := range service.SnapshotChanges(before, after) {
+		changed = append(changed, key)
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookReceiver 为 httptest 接收方，按预设状态码依次响应并记录每次请求。
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []webhookRequest
+}
+
+// webhookRequest 为接收方收到的一次投递。
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// ServeHTTP 记录请求，预设状态码用完后返回 200。
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, webhookRequest{header: req.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	r.mu.Unlock()
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "status %d", status)
+}
+
+// received 返回已收到的请求。
+func (r *webhookReceiver) received() []webhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookRequest(nil), r.requests...)
+}
+
+// respond 追加后续请求的状态码。
+func (r *webhookReceiver) respond(statuses ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, statuses...)
+}
+
+// loadDelivery 重新读取投递记录。
+func loadDelivery(t *testing.T, id uint) model.WebhookDelivery {
+	t.Helper()
+	var delivery model.WebhookDelivery
+	if err := model.DB.First(&delivery, id).Error; err != nil {
+		t.Fatalf("load delivery: %v", err)
+	}
+	return delivery
+}
+
+func TestWebhookDeliveryRetriesAndRedelivers(t *testing.T) {
+	cfg := setupTestDB(t)
+	user := createTestUser(t, "alice")
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	hook, err := service.CreateWebhook(user.ID, "test", server.URL, []string{service.WebhookEventCreated}, false)
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	delivery, err := service.EnqueueWebhookPing(hook, user)
+	if err != nil {
+		t.Fatalf("enqueue ping: %v", err)
+	}
+	dispatcher := service.NewWebhookDispatcher(cfg, service.NewWebhookHTTPClient(true))
+
+	// 5xx 时保留为 pending 并按指数退避安排下次投递，退避期间不重发。
+	receiver.respond(http.StatusServiceUnavailable, http.StatusBadGateway)
+	now := time.Now().UTC()
+	if _, _, err := dispatcher.RunOnce(now); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	first := loadDelivery(t, delivery.ID)
+	if first.Status != model.WebhookDeliveryPending || first.Attempts != 1 || first.ResponseStatus != http.StatusServiceUnavailable ||
+		first.ResponseBody != "status 503" || first.LastError == "" {
+		t.Fatalf("after 503 = %+v", first)
+	}
+	if delay := first.NextAttemptAt.Sub(now); delay != 30*time.Second {
+		t.Fatalf("first retry delay = %s, want 30s", delay)
+	}
+	if _, _, err := dispatcher.RunOnce(now.Add(29 * time.Second)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := len(receiver.received()); got != 1 {
+		t.Fatalf("requests during backoff = %d, want 1", got)
+	}
+
+	now = first.NextAttemptAt
+	if _, _, err := dispatcher.RunOnce(now); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	second := loadDelivery(t, delivery.ID)
+	if second.Status != model.WebhookDeliveryPending || second.Attempts != 2 || second.ResponseStatus != http.StatusBadGateway {
+		t.Fatalf("after 502 = %+v", second)
+	}
+	if delay := second.NextAttemptAt.Sub(now); delay != time.Minute {
+		t.Fatalf("second retry delay = %s, want 1m", delay)
+	}
+
+	if succeeded, _, err := dispatcher.RunOnce(second.NextAttemptAt); err != nil || succeeded != 1 {
+		t.Fatalf("run = %d, %v", succeeded, err)
+	}
+	done := loadDelivery(t, delivery.ID)
+	if done.Status != model.WebhookDeliverySucceeded || done.Attempts != 3 || done.ResponseStatus != http.StatusOK ||
+		done.DeliveredAt == nil || done.LastError != "" {
+		t.Fatalf("after 200 = %+v", done)
+	}
+	var stored model.Webhook
+	model.DB.First(&stored, hook.ID)
+	if stored.LastStatus != model.WebhookDeliverySucceeded || stored.LastSentAt == nil {
+		t.Fatalf("webhook last status = %q %v", stored.LastStatus, stored.LastSentAt)
+	}
+
+	// 每次投递都携带同一投递 ID 与可校验的签名。
+	requests := receiver.received()
+	if len(requests) != 3 {
+		t.Fatalf("requests = %d, want 3", len(requests))
+	}
+	for i, req := range requests {
+		if !service.VerifyWebhookSignature(hook.Secret, req.header.Get("X-SmartCalendar-Timestamp"), req.body,
+			req.header.Get("X-SmartCalendar-Signature"), 5*time.Minute, time.Now()) {
+			t.Fatalf("request %d signature %q does not verify", i, req.header.Get("X-SmartCalendar-Signature"))
+		}
+		if service.VerifyWebhookSignature("whsec_other", req.header.Get("X-SmartCalendar-Timestamp"), req.body,
+			req.header.Get("X-SmartCalendar-Signature"), 5*time.Minute, time.Now()) {
+			t.Fatalf("request %d verifies with a different secret", i)
+		}
+		if req.header.Get("X-SmartCalendar-Delivery") != fmt.Sprint(delivery.ID) || req.header.Get("X-SmartCalendar-Event") != service.WebhookPing {
+			t.Fatalf("request %d headers = %v", i, req.header)
+		}
+		if string(req.body) != delivery.Payload {
+			t.Fatalf("request %d body = %s", i, req.body)
+		}
+	}
+
+	// 持续失败达到最大次数后标记为 failed，重新投递后清零重试次数并再次发送。
+	failing, err := service.EnqueueWebhookPing(hook, user)
+	if err != nil {
+		t.Fatalf("enqueue ping: %v", err)
+	}
+	now = time.Now().UTC()
+	for i := 0; i < 8; i++ {
+		receiver.respond(http.StatusInternalServerError)
+		if _, _, err := dispatcher.RunOnce(now); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+	failed := loadDelivery(t, failing.ID)
+	if failed.Status != model.WebhookDeliveryFailed || failed.Attempts != 8 || failed.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("after max attempts = %+v", failed)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", user) })
+	router.POST("/api/webhooks/:id/deliveries/:deliveryId/redeliver", WebhookController{}.RedeliverWebhook)
+	redeliver := func() (int, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", fmt.Sprintf("/api/webhooks/%d/deliveries/%d/redeliver", hook.ID, failing.ID), nil))
+		var resp struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode redeliver: %v", err)
+		}
+		return resp.Code, resp.Data
+	}
+	code, data := redeliver()
+	if code != 0 || data["status"] != model.WebhookDeliveryPending || data["attempts"] != float64(0) {
+		t.Fatalf("redeliver = %d %v", code, data)
+	}
+	if code, _ := redeliver(); code != 40901 {
+		t.Fatalf("redeliver pending = %d, want 40901", code)
+	}
+	before := len(receiver.received())
+	if succeeded, _, err := dispatcher.RunOnce(time.Now()); err != nil || succeeded != 1 {
+		t.Fatalf("run after redeliver = %d, %v", succeeded, err)
+	}
+	redelivered := loadDelivery(t, failing.ID)
+	if redelivered.Status != model.WebhookDeliverySucceeded || redelivered.Attempts != 1 || len(receiver.received()) != before+1 {
+		t.Fatalf("after redeliver = %+v", redelivered)
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"smartcalendar/ai"
 	"smartcalendar/config"
 	"smartcalendar/model"
 	"smartcalendar/router"
@@ -13,7 +19,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// main 初始化配置、数据库与路由，并启动提醒任务与 HTTP 服务。
+// main 初始化配置、数据库与路由，启动提醒调度、延后通知释放、邮件与 Webhook 投递、过期提案清理及 HTTP 服务，并在收到退出信号时优雅停止。
 func main() {
 	cfg := config.Load()
 
@@ -22,26 +28,70 @@ func main() {
 	}
 
 	model.InitDB(cfg)
-	if err := model.DB.AutoMigrate(&model.User{}, &model.Event{}, &model.EventParticipant{}, &model.OperationLog{}, &model.Notification{}); err != nil {
+	if err := model.Migrate(); err != nil {
 		panic(err)
 	}
+	if err := model.RelaxEmailOutboxNotificationID(); err != nil {
+		panic(err)
+	}
+	if updated, err := service.BackfillSeriesEnds(); err != nil {
+		panic(err)
+	} else if updated > 0 {
+		log.Printf("[reminder] backfilled series_end for %d recurring events", updated)
+	}
 
-	engine := router.SetupRouter(cfg)
+	service.ConfigureEmail(cfg)
+	service.ConfigureWebhooks(cfg)
+	scheduler := service.NewReminderScheduler(cfg)
+	emailDispatcher := service.NewEmailDispatcher(cfg, service.NewSMTPMailSender(cfg))
+	webhookDispatcher := service.NewWebhookDispatcher(cfg, nil)
+	notificationReleaser := service.NewNotificationReleaser(cfg)
+	aiService := ai.NewAIService(cfg)
+	proposalSweeper := service.NewProposalSweeper(cfg, aiService.SweepExpiredProposals)
+	engine := router.SetupRouter(cfg, scheduler, aiService)
 	engine.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status": "ok",
 		})
 	})
 
-	go startReminderJob()
+	scheduler.Start()
+	emailDispatcher.Start()
+	webhookDispatcher.Start()
+	notificationReleaser.Start()
+	proposalSweeper.Start()
 
-	_ = engine.Run(":8080")
-}
+	server := &http.Server{Addr: ":8080", Handler: engine}
+	// 关闭时结束通知推送连接，否则长连接会阻塞 Shutdown。
+	server.RegisterOnShutdown(service.CloseNotificationBroker)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-// startReminderJob 每分钟生成 15 分钟内即将开始的日程提醒通知。
-func startReminderJob() {
-	ticker := time.NewTicker(time.Minute)
-	for range ticker.C {
-		_ = service.GenerateReminderNotifications(time.Now())
+	// 收到退出信号后停止接收新请求，并等待各后台任务完成当前一轮后退出。
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	if err := scheduler.Stop(ctx); err != nil {
+		log.Printf("reminder scheduler stop: %v", err)
+	}
+	if err := emailDispatcher.Stop(ctx); err != nil {
+		log.Printf("email dispatcher stop: %v", err)
+	}
+	if err := webhookDispatcher.Stop(ctx); err != nil {
+		log.Printf("webhook dispatcher stop: %v", err)
+	}
+	if err := notificationReleaser.Stop(ctx); err != nil {
+		log.Printf("notification releaser stop: %v", err)
+	}
+	if err := proposalSweeper.Stop(ctx); err != nil {
+		log.Printf("proposal sweeper stop: %v", err)
 	}
 }
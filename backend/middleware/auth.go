@@ -4,32 +4,40 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"smartcalendar/config"
 	"smartcalendar/model"
 	"smartcalendar/service"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 // AuthRequired 校验 JWT 并注入用户上下文。
 func AuthRequired(cfg config.AppConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := extractBearer(c.GetHeader("Authorization"))
-		if tokenString == "" {
-			c.JSON(http.StatusOK, gin.H{"code": 40101, "message": "未登录或 Token 缺失", "data": nil})
-			c.Abort()
+		authenticate(c, cfg, extractBearer(c.GetHeader("Authorization")))
+	}
+}
+
+// StreamAuthRequired 为推送流鉴权。浏览器 EventSource 无法设置请求头，
+// 因此在 Authorization 头缺失时通过 ticket 查询参数传递一次性票据（见 service.CreateStreamTicket），
+// 避免长期有效的 JWT 出现在 URL 与访问日志中。
+func StreamAuthRequired(cfg config.AppConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenString := extractBearer(c.GetHeader("Authorization")); tokenString != "" {
+			authenticate(c, cfg, tokenString)
 			return
 		}
-		claims, err := service.ParseToken(cfg, tokenString)
-		if err != nil {
-			c.JSON(http.StatusOK, gin.H{"code": 40102, "message": "Token 无效或已过期", "data": nil})
-			c.Abort()
+		ticket := strings.TrimSpace(c.Query("ticket"))
+		if ticket == "" {
+			authenticate(c, cfg, "")
 			return
 		}
-		var user model.User
-		if err := model.DB.First(&user, claims.UserID).Error; err != nil {
+		userID, err := service.RedeemStreamTicket(ticket, time.Now())
+		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				c.JSON(http.StatusOK, gin.H{"code": 40102, "message": "Token 无效或已过期", "data": nil})
 				c.Abort()
@@ -39,16 +47,48 @@ func AuthRequired(cfg config.AppConfig) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		if user.Status == "disabled" {
-			c.JSON(http.StatusOK, gin.H{"code": 40301, "message": "账号已被禁用", "data": nil})
+		authorizeUser(c, userID)
+	}
+}
+
+// authenticate 校验 JWT 对应的用户并注入上下文，失败时中止请求。
+func authenticate(c *gin.Context, cfg config.AppConfig, tokenString string) {
+	if tokenString == "" {
+		c.JSON(http.StatusOK, gin.H{"code": 40101, "message": "未登录或 Token 缺失", "data": nil})
+		c.Abort()
+		return
+	}
+	claims, err := service.ParseToken(cfg, tokenString)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": 40102, "message": "Token 无效或已过期", "data": nil})
+		c.Abort()
+		return
+	}
+	authorizeUser(c, claims.UserID)
+}
+
+// authorizeUser 加载已通过鉴权的用户并注入上下文，用户不存在或被禁用时中止请求。
+func authorizeUser(c *gin.Context, userID uint) {
+	var user model.User
+	if err := model.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{"code": 40102, "message": "Token 无效或已过期", "data": nil})
 			c.Abort()
 			return
 		}
-		c.Set("userID", user.ID)
-		c.Set("role", user.Role)
-		c.Set("user", user)
-		c.Next()
+		c.JSON(http.StatusOK, gin.H{"code": 50000, "message": "服务器内部错误", "data": nil})
+		c.Abort()
+		return
 	}
+	if user.Status == "disabled" {
+		c.JSON(http.StatusOK, gin.H{"code": 40301, "message": "账号已被禁用", "data": nil})
+		c.Abort()
+		return
+	}
+	c.Set("userID", user.ID)
+	c.Set("role", user.Role)
+	c.Set("user", user)
+	c.Next()
 }
 
 // AdminRequired 限制管理员访问。
@@ -64,6 +104,57 @@ func AdminRequired() gin.HandlerFunc {
 	}
 }
 
+// DAVAuthRequired 为 CalDAV 等协议客户端提供鉴权：支持 Basic（邮箱 + 密码）与 Bearer JWT。
+// 协议客户端依赖 HTTP 状态码，失败时返回 401 并携带 WWW-Authenticate 质询。
+func DAVAuthRequired(cfg config.AppConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := resolveDAVUser(cfg, c.Request)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.Header("WWW-Authenticate", `Basic realm="SmartCalendar", charset="UTF-8"`)
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if user.Status == "disabled" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set("userID", user.ID)
+		c.Set("role", user.Role)
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// resolveDAVUser 解析请求中的凭证，凭证缺失或错误时返回 gorm.ErrRecordNotFound。
+func resolveDAVUser(cfg config.AppConfig, r *http.Request) (model.User, error) {
+	var user model.User
+	if tokenString := extractBearer(r.Header.Get("Authorization")); tokenString != "" {
+		claims, err := service.ParseToken(cfg, tokenString)
+		if err != nil {
+			return model.User{}, gorm.ErrRecordNotFound
+		}
+		if err := model.DB.First(&user, claims.UserID).Error; err != nil {
+			return model.User{}, err
+		}
+		return user, nil
+	}
+	email, password, ok := r.BasicAuth()
+	if !ok || email == "" {
+		return model.User{}, gorm.ErrRecordNotFound
+	}
+	if err := model.DB.Where("email = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error; err != nil {
+		return model.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return model.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
 // extractBearer 提取 Authorization: Bearer <token>。
 func extractBearer(authHeader string) string {
 	if authHeader == "" {
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+	"smartcalendar/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupStreamRouter 初始化内存数据库，并挂载使用 StreamAuthRequired 的测试路由。
+func setupStreamRouter(t *testing.T) (config.AppConfig, *gin.Engine) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.DBPath = "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	model.InitDB(cfg)
+	if err := model.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", StreamAuthRequired(cfg), func(c *gin.Context) {
+		c.JSON(200, gin.H{"code": 0, "data": c.MustGet("userID")})
+	})
+	return cfg, r
+}
+
+// streamCode 请求测试路由并返回响应码。
+func streamCode(t *testing.T, r *gin.Engine, query string, bearer string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/stream"+query, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", recorder.Body.String(), err)
+	}
+	return resp.Code
+}
+
+func TestStreamAuthTicketIsSingleUse(t *testing.T) {
+	cfg, r := setupStreamRouter(t)
+	user := model.User{Nickname: "alice", Email: "alice@example.com", Password: "x", Status: "active"}
+	if err := model.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	ticket, expiresAt, err := service.CreateStreamTicket(user.ID, time.Now())
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	if !strings.HasPrefix(ticket, "sst_") || expiresAt.Sub(time.Now()) > service.StreamTicketTTL {
+		t.Fatalf("ticket = %q expires %v", ticket, expiresAt)
+	}
+	if code := streamCode(t, r, "?ticket="+ticket, ""); code != 0 {
+		t.Fatalf("first use = %d, want 0", code)
+	}
+	if code := streamCode(t, r, "?ticket="+ticket, ""); code != 40102 {
+		t.Fatalf("reuse = %d, want 40102", code)
+	}
+
+	expired, _, err := service.CreateStreamTicket(user.ID, time.Now().Add(-2*service.StreamTicketTTL))
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	if code := streamCode(t, r, "?ticket="+expired, ""); code != 40102 {
+		t.Fatalf("expired = %d, want 40102", code)
+	}
+
+	token, err := service.GenerateToken(cfg, user.ID, user.Role)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	if code := streamCode(t, r, "?access_token="+token, ""); code != 40101 {
+		t.Fatalf("access_token query = %d, want 40101", code)
+	}
+	if code := streamCode(t, r, "", token); code != 0 {
+		t.Fatalf("bearer = %d, want 0", code)
+	}
+}
+
+func TestRedactPathHidesCredentials(t *testing.T) {
+	cases := map[string]string{
+		"/api/notifications/stream?ticket=sst_abc&last_event_id=5": "/api/notifications/stream?ticket=REDACTED&last_event_id=5",
+		"/api/notifications/stream?access_token=eyJ.x.y":           "/api/notifications/stream?access_token=REDACTED",
+		"/api/events?page=1&page_size=20":                          "/api/events?page=1&page_size=20",
+		"/api/events":                                              "/api/events",
+		"/api/feed/scf_43914abbe552515b":                           "/api/feed/REDACTED",
+		"/api/feed/scf_43914abbe552515b?type=work":                 "/api/feed/REDACTED?type=work",
+		"/api/feed-tokens":                                         "/api/feed-tokens",
+	}
+	for path, want := range cases {
+		if got := redactPath(path); got != want {
+			t.Errorf("redactPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
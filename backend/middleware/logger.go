@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedQueryParams 为访问日志中需要隐藏取值的查询参数：推送流票据，以及旧版客户端仍可能携带的 JWT。
+var redactedQueryParams = []string{"ticket", "access_token"}
+
+// redactedPathPrefixes 为路径中紧随其后的一段即凭证的前缀：webcal 订阅链接的令牌。
+var redactedPathPrefixes = []string{"/api/feed/"}
+
+// RequestLogger 与 gin 默认的访问日志格式相同，但隐藏路径与查询参数中的凭证。
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactPath(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactPath 将路径中的凭证段与凭证类查询参数的取值替换为 REDACTED，其余部分保持原样。
+func redactPath(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	for _, prefix := range redactedPathPrefixes {
+		if rest, ok := strings.CutPrefix(base, prefix); ok && rest != "" {
+			base = prefix + "REDACTED"
+			break
+		}
+	}
+	if !found {
+		return base
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		for _, redacted := range redactedQueryParams {
+			if name == redacted {
+				pairs[i] = key + "=REDACTED"
+				break
+			}
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// AISession 表示用户与 AI 助手的一次多轮对话，会话中待确认的提案见 AIProposal。
+type AISession struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Title     string    `gorm:"size:100" json:"title"` // 取首条用户消息的前若干字
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"` // 最近一条消息的时间
+}
+
+// AIMessage 表示 AI 会话中的一条消息。
+type AIMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SessionID uint      `gorm:"index;not null" json:"session_id"`
+	Role      string    `gorm:"size:20;not null" json:"role"` // user：用户输入；assistant：助手回复
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	Intent    string    `gorm:"size:20" json:"intent"` // 助手回复对应的意图
+	Status    string    `gorm:"size:20" json:"status"` // 助手回复的状态：success / need_confirm
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AIProposal 表示等待用户确认的 AI 提案，只能由创建者在过期前确认一次。
+type AIProposal struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"confirm_id"` // 随机生成的 confirm_id
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	SessionID uint      `gorm:"index" json:"session_id"`           // 提案所属会话，同一会话可有多个待确认提案
+	Payload   JSONText  `gorm:"type:text;not null" json:"payload"` // 提案 JSON
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`  // UTC
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名，避免默认命名将 AIProposal 拆为 a_iproposals。
+func (AIProposal) TableName() string {
+	return "ai_proposals"
+}
+
+// AI 会话消息角色。
+const (
+	AIRoleUser      = "user"
+	AIRoleAssistant = "assistant"
+)
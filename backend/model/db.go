@@ -21,3 +21,30 @@ func InitDB(cfg config.AppConfig) {
 	}
 	DB = db
 }
+
+// Migrate 按模型创建或更新全部数据表。
+func Migrate() error {
+	return DB.AutoMigrate(&User{}, &Event{}, &EventParticipant{}, &EventException{}, &OperationLog{}, &Notification{}, &FeedToken{}, &StreamTicket{}, &ReminderJob{}, &EmailOutbox{}, &Webhook{}, &WebhookDelivery{}, &NotificationPreference{}, &DeferredNotification{}, &AISession{}, &AIMessage{}, &AIProposal{})
+}
+
+// RelaxEmailOutboxNotificationID 放宽早期版本 email_outboxes.notification_id 的非空约束，
+// AutoMigrate 不会修改已有列的约束；仅发邮件、不写站内通知时该列为空。
+func RelaxEmailOutboxNotificationID() error {
+	columns, err := DB.Migrator().ColumnTypes(&EmailOutbox{})
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if column.Name() != "notification_id" {
+			continue
+		}
+		if nullable, ok := column.Nullable(); ok && !nullable {
+			// SQLite 通过重建表修改列，重建后需重新创建索引。
+			if err := DB.Migrator().AlterColumn(&EmailOutbox{}, "NotificationID"); err != nil {
+				return err
+			}
+			return DB.AutoMigrate(&EmailOutbox{})
+		}
+	}
+	return nil
+}
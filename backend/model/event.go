@@ -1,11 +1,17 @@
 // Package model 定义数据库模型与初始化逻辑。
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 // Event 表示日程实体。
 type Event struct {
 	ID           uint               `gorm:"primaryKey" json:"id"`
+	UID          string             `gorm:"size:255;index" json:"uid"`
 	UserID       uint               `gorm:"index;not null" json:"user_id"`
 	Title        string             `gorm:"size:100;not null" json:"title"`
 	Type         string             `gorm:"size:20;not null" json:"type"`
@@ -13,18 +19,56 @@ type Event struct {
 	EndTime      time.Time          `gorm:"not null" json:"end_time"`
 	Location     string             `gorm:"size:200" json:"location"`
 	Description  string             `gorm:"size:500" json:"description"`
+	RRule        string             `gorm:"column:rrule;size:500" json:"rrule"`
+	SeriesEnd    *time.Time         `gorm:"index" json:"-"`    // 重复日程最后一个实例的结束时间，无 COUNT/UNTIL 的无限序列为 NULL
+	Reminders    *string            `gorm:"size:100" json:"-"` // 逗号分隔的提前提醒分钟数，NULL 表示使用各用户的默认提醒
 	CreatedAt    time.Time          `json:"created_at"`
 	UpdatedAt    time.Time          `json:"updated_at"`
 	Creator      User               `gorm:"foreignKey:UserID" json:"creator,omitempty"`
 	Participants []EventParticipant `gorm:"foreignKey:EventID" json:"participants,omitempty"`
 }
 
-// EventParticipant 表示日程参与人关系。
+// BeforeCreate 为未指定 UID 的日程生成 iCalendar UID。
+func (e *Event) BeforeCreate(tx *gorm.DB) error {
+	if e.UID == "" {
+		e.UID = uuid.NewString() + "@smartcalendar"
+	}
+	return nil
+}
+
+// 参与人回复状态枚举。
+const (
+	ResponsePending   = "pending"
+	ResponseAccepted  = "accepted"
+	ResponseDeclined  = "declined"
+	ResponseTentative = "tentative"
+)
+
+// EventParticipant 表示日程参与人关系及其回复状态。
 type EventParticipant struct {
-	ID      uint `gorm:"primaryKey" json:"id"`
-	EventID uint `gorm:"index;not null" json:"event_id"`
-	UserID  uint `gorm:"index;not null" json:"user_id"`
-	User    User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	EventID     uint       `gorm:"index;not null" json:"event_id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Status      string     `gorm:"size:20;default:pending" json:"status"`
+	Comment     string     `gorm:"size:200" json:"comment"`
+	RespondedAt *time.Time `json:"responded_at"`
+	User        User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// EventException 表示重复日程单次实例的例外（取消或改期），按原始开始时间定位。
+type EventException struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	EventID       uint      `gorm:"index;not null" json:"event_id"`
+	OriginalStart time.Time `gorm:"index;not null" json:"original_start"`
+	Cancelled     bool      `gorm:"default:false" json:"cancelled"`
+	Title         string    `gorm:"size:100" json:"title"`
+	Type          string    `gorm:"size:20" json:"type"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Location      string    `gorm:"size:200" json:"location"`
+	Description   string    `gorm:"size:500" json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // OperationLog 表示用户操作记录。
@@ -46,4 +90,140 @@ type Notification struct {
 	EventID   uint      `gorm:"index" json:"event_id"`
 	IsRead    bool      `gorm:"default:false" json:"is_read"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// 日程变更类通知附带的结构化数据（变更前后取值等），其他通知为空。
+	Payload JSONText `gorm:"type:text" json:"payload,omitempty"`
+
+	// 仅提醒通知使用：提醒对应的实例开始时间与提前分钟数，用于按实例、按提醒去重。
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	RemindBefore    *int       `json:"remind_before,omitempty"`
+}
+
+// JSONText 表示以文本列保存的 JSON，序列化时按原始 JSON 输出而非字符串。
+type JSONText string
+
+// MarshalJSON 原样输出保存的 JSON，空值输出 null。
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// DeferredNotification 表示免打扰时段内延后投递的通知，到期后按用户当时的偏好转为正式通知。
+// 延后的通知不占用通知 ID，转为正式通知时才分配，保证推送流按 ID 续传不会遗漏。
+type DeferredNotification struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Type      string    `gorm:"size:30;not null" json:"type"`
+	Content   string    `gorm:"size:500;not null" json:"content"`
+	EventID   uint      `gorm:"index" json:"event_id"`
+	Payload   JSONText  `gorm:"type:text" json:"payload,omitempty"`
+	DeliverAt time.Time `gorm:"index;not null" json:"deliver_at"`
+	CreatedAt time.Time `json:"created_at"`
 }
+
+// ReminderJob 表示一条计划发送的提醒，同一实例、同一用户、同一提前分钟数只对应一条记录。
+// 多实例部署时通过租约（LockedBy / LockedUntil）认领，租约过期后可被其他实例接手。
+type ReminderJob struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	EventID         uint       `gorm:"uniqueIndex:idx_reminder_job_key;not null" json:"event_id"`
+	UserID          uint       `gorm:"uniqueIndex:idx_reminder_job_key;not null" json:"user_id"`
+	OccurrenceStart time.Time  `gorm:"uniqueIndex:idx_reminder_job_key;not null" json:"occurrence_start"`
+	RemindBefore    int        `gorm:"uniqueIndex:idx_reminder_job_key;not null" json:"remind_before"`
+	DueAt           time.Time  `gorm:"index;not null" json:"due_at"`
+	Status          string     `gorm:"size:20;index;default:pending" json:"status"`
+	Attempts        int        `gorm:"default:0" json:"attempts"`
+	LockedBy        string     `gorm:"size:100" json:"locked_by"`
+	LockedUntil     *time.Time `json:"locked_until"`
+	LastError       string     `gorm:"size:500" json:"last_error"`
+	SentAt          *time.Time `json:"sent_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// 提醒任务状态枚举。
+const (
+	ReminderJobPending   = "pending"   // 等待到期
+	ReminderJobRunning   = "running"   // 已被某实例认领，发送中
+	ReminderJobSent      = "sent"      // 已发送
+	ReminderJobSkipped   = "skipped"   // 同一实例有更近的提醒同时到期，只发送最近的一个
+	ReminderJobCancelled = "cancelled" // 日程已删除、改期或不再需要该提醒
+	ReminderJobExpired   = "expired"   // 超过补发窗口或实例已开始，不再发送
+	ReminderJobFailed    = "failed"    // 多次发送失败
+)
+
+// EmailOutbox 表示待发送的通知邮件。邮件内容在写入通知时渲染并持久化，发送失败按退避时间重试。
+type EmailOutbox struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	NotificationID *uint      `gorm:"uniqueIndex" json:"notification_id"` // 关闭站内通知、仅发邮件时为空
+	UserID         uint       `gorm:"index;not null" json:"user_id"`
+	ToAddress      string     `gorm:"size:100;not null" json:"to_address"`
+	Subject        string     `gorm:"size:200;not null" json:"subject"`
+	TextBody       string     `gorm:"type:text" json:"-"`
+	HTMLBody       string     `gorm:"type:text" json:"-"`
+	Status         string     `gorm:"size:20;index;default:pending" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index;not null" json:"next_attempt_at"`
+	LockedBy       string     `gorm:"size:100" json:"locked_by"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	LastError      string     `gorm:"size:500" json:"last_error"`
+	SentAt         *time.Time `json:"sent_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// 邮件发件箱状态枚举。
+const (
+	EmailPending = "pending" // 等待发送或等待重试
+	EmailSending = "sending" // 已被某实例认领，发送中
+	EmailSent    = "sent"    // 已发送
+	EmailFailed  = "failed"  // 超过最大重试次数
+)
+
+// Webhook 表示用户登记的外部回调地址，日程发生变化时向其投递签名的 JSON 事件。
+// 普通用户的 Webhook 只接收本人创建或参与的日程事件；管理员登记的全局 Webhook 接收全部日程事件。
+type Webhook struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"size:50" json:"name"`
+	URL        string     `gorm:"size:500;not null" json:"url"`
+	Secret     string     `gorm:"size:100;not null" json:"-"` // HMAC-SHA256 签名密钥
+	Events     string     `gorm:"size:200;not null" json:"-"` // 逗号分隔的订阅事件类型
+	Global     bool       `gorm:"default:false" json:"global"`
+	Active     bool       `gorm:"default:true" json:"active"`
+	LastStatus string     `gorm:"size:20" json:"last_status"` // 最近一次投递结果：succeeded / failed
+	LastSentAt *time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// WebhookDelivery 表示一次 Webhook 投递及其重试状态，同时作为投递日志保留。
+// 请求体在入队时生成并持久化，重试时原样发送，签名在每次发送时按当时的时间戳计算。
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"index;not null" json:"webhook_id"`
+	EventType      string     `gorm:"size:50;not null" json:"event_type"`
+	EventID        uint       `gorm:"index" json:"event_id"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"size:20;index;default:pending" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index;not null" json:"next_attempt_at"`
+	LockedBy       string     `gorm:"size:100" json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"size:1000" json:"response_body"`
+	DurationMs     int64      `json:"duration_ms"`
+	LastError      string     `gorm:"size:500" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Webhook 投递状态枚举。
+const (
+	WebhookDeliveryPending   = "pending"   // 等待发送或等待重试
+	WebhookDeliverySending   = "sending"   // 已被某实例认领，发送中
+	WebhookDeliverySucceeded = "succeeded" // 接收方返回 2xx
+	WebhookDeliveryFailed    = "failed"    // 超过最大重试次数
+)
@@ -5,13 +5,56 @@ import "time"
 
 // User 表示系统用户实体。
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Nickname  string    `gorm:"size:50;not null" json:"nickname"`
-	Email     string    `gorm:"size:100;uniqueIndex;not null" json:"email"`
-	Password  string    `gorm:"size:255;not null" json:"-"`
-	Avatar    string    `gorm:"size:500" json:"avatar"`
-	Role      string    `gorm:"size:20;default:user" json:"role"`
-	Status    string    `gorm:"size:20;default:active" json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Nickname           string    `gorm:"size:50;not null" json:"nickname"`
+	Email              string    `gorm:"size:100;uniqueIndex;not null" json:"email"`
+	Password           string    `gorm:"size:255;not null" json:"-"`
+	Avatar             string    `gorm:"size:500" json:"avatar"`
+	Role               string    `gorm:"size:20;default:user" json:"role"`
+	Status             string    `gorm:"size:20;default:active" json:"status"`
+	DefaultReminders   string    `gorm:"size:100;default:15" json:"-"` // 逗号分隔的默认提前提醒分钟数
+	EmailNotifications bool      `gorm:"default:false" json:"-"`       // 是否通过邮件接收通知，默认关闭
+	Timezone           string    `gorm:"size:50" json:"-"`             // IANA 时区，如 Asia/Shanghai，空表示服务器时区
+	QuietHoursStart    string    `gorm:"size:5" json:"-"`              // 免打扰开始时间 HH:MM，与结束时间均为空表示未开启
+	QuietHoursEnd      string    `gorm:"size:5" json:"-"`              // 免打扰结束时间 HH:MM，早于开始时间表示跨午夜
+	DigestTime         string    `gorm:"size:5" json:"-"`              // 每日日程摘要的发送时间 HH:MM，空表示未开启
+	DigestSentOn       string    `gorm:"size:10" json:"-"`             // 最近一次处理每日摘要的用户本地日期 YYYY-MM-DD，用于每天只发送一次
+	Locale             string    `gorm:"size:10" json:"-"`             // 语言区域，如 zh-CN / en-US，空表示 zh-CN；决定 AI 回复语言与每周起始日
+	WorkStart          string    `gorm:"size:5" json:"-"`              // 工作开始时间 HH:MM，空表示 09:00
+	WorkEnd            string    `gorm:"size:5" json:"-"`              // 工作结束时间 HH:MM，空表示 18:00
+	WorkDays           string    `gorm:"size:20" json:"-"`             // 逗号分隔的工作日（0 为周日），空表示周一至周五
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// FeedToken 表示用户的日历订阅令牌，仅保存令牌哈希，撤销后立即失效。
+type FeedToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	Name        string     `gorm:"size:50" json:"name"`
+	TokenHash   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	TokenPrefix string     `gorm:"size:16" json:"token_prefix"`
+	Types       string     `gorm:"size:100" json:"-"` // 逗号分隔的日程类型，空表示全部
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// StreamTicket 表示通知推送流的一次性票据，供无法设置请求头的 EventSource 鉴权，仅保存票据哈希。
+type StreamTicket struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"index;not null"`
+	TicketHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	CreatedAt  time.Time
+}
+
+// NotificationPreference 表示用户对某类通知的渠道设置，未设置的类型默认全部渠道开启。
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"uniqueIndex:idx_notification_preference;not null" json:"-"`
+	Type      string    `gorm:"uniqueIndex:idx_notification_preference;size:30;not null" json:"type"`
+	InApp     bool      `gorm:"not null" json:"in_app"` // 站内通知（通知列表、未读数与实时推送）
+	Email     bool      `gorm:"not null" json:"email"`  // 邮件，需同时开启邮件通知总开关
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -1,10 +1,12 @@
 package router
 
 import (
+	"net/http"
 	"smartcalendar/ai"
 	"smartcalendar/config"
 	"smartcalendar/controller"
 	"smartcalendar/middleware"
+	"smartcalendar/service"
 	"strings"
 
 	"github.com/gin-contrib/cors"
@@ -12,8 +14,10 @@ import (
 )
 
 // SetupRouter 注册路由与中间件。
-func SetupRouter(cfg config.AppConfig) *gin.Engine {
-	r := gin.Default()
+func SetupRouter(cfg config.AppConfig, scheduler *service.ReminderScheduler, aiService *ai.AIService) *gin.Engine {
+	// 访问日志隐藏推送流票据等凭证类查询参数，其余与 gin.Default 相同。
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 	allowOrigins := buildAllowOrigins(cfg.CorsAllowOrigin)
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowOrigins,
@@ -24,18 +28,25 @@ func SetupRouter(cfg config.AppConfig) *gin.Engine {
 	}))
 
 	authController := controller.AuthController{Cfg: cfg}
-	aiController := controller.AIController{Cfg: cfg, Service: ai.NewAIService(cfg)}
-	adminController := controller.AdminController{}
+	aiController := controller.AIController{Cfg: cfg, Service: aiService}
+	adminController := controller.AdminController{Scheduler: scheduler}
 	eventController := controller.EventController{}
 	userController := controller.UserController{}
 	logController := controller.OperationLogController{}
 	notificationController := controller.NotificationController{}
 	uploadController := controller.UploadController{Cfg: cfg}
+	freeBusyController := controller.FreeBusyController{}
+	icalController := controller.ICalController{}
+	feedController := controller.FeedController{Cfg: cfg}
+	calDAVController := controller.CalDAVController{}
+	webhookController := controller.WebhookController{}
 
 	api := r.Group("/api")
 	{
 		api.POST("/auth/register", authController.Register)
 		api.POST("/auth/login", authController.Login)
+		api.GET("/feed/:token", feedController.GetFeed)
+		api.GET("/notifications/stream", middleware.StreamAuthRequired(cfg), notificationController.StreamNotifications)
 
 		authed := api.Group("")
 		authed.Use(middleware.AuthRequired(cfg))
@@ -45,20 +56,54 @@ func SetupRouter(cfg config.AppConfig) *gin.Engine {
 			authed.GET("/events/:id", eventController.GetEventDetail)
 			authed.PUT("/events/:id", eventController.UpdateEvent)
 			authed.DELETE("/events/:id", eventController.DeleteEvent)
+			authed.PUT("/events/:id/response", eventController.RespondEvent)
+
+			authed.GET("/ics/export", icalController.ExportICS)
+			authed.POST("/ics/import", icalController.ImportICS)
+			authed.GET("/feed-tokens", feedController.ListFeedTokens)
+			authed.POST("/feed-tokens", feedController.CreateFeedToken)
+			authed.DELETE("/feed-tokens/:id", feedController.RevokeFeedToken)
+
+			authed.GET("/webhooks", webhookController.ListWebhooks)
+			authed.POST("/webhooks", webhookController.CreateWebhook)
+			authed.PUT("/webhooks/:id", webhookController.UpdateWebhook)
+			authed.DELETE("/webhooks/:id", webhookController.DeleteWebhook)
+			authed.POST("/webhooks/:id/rotate-secret", webhookController.RotateWebhookSecret)
+			authed.POST("/webhooks/:id/ping", webhookController.PingWebhook)
+			authed.GET("/webhooks/:id/deliveries", webhookController.ListWebhookDeliveries)
+			authed.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookController.RedeliverWebhook)
+
+			authed.GET("/freebusy", freeBusyController.GetFreeBusy)
+			authed.POST("/freebusy/slots", freeBusyController.FindSlots)
 
 			authed.GET("/operation-logs", logController.ListLogs)
 
 			authed.GET("/notifications", notificationController.ListNotifications)
 			authed.GET("/notifications/unread-count", notificationController.UnreadCount)
+			authed.POST("/notifications/stream-ticket", notificationController.CreateStreamTicket)
 			authed.PUT("/notifications/:id/read", notificationController.MarkRead)
 			authed.PUT("/notifications/read-all", notificationController.MarkAllRead)
 
 			authed.POST("/ai/chat", aiController.Chat)
+			authed.POST("/ai/chat/stream", aiController.ChatStream)
+			authed.GET("/ai/sessions", aiController.ListSessions)
+			authed.GET("/ai/sessions/:id", aiController.GetSession)
+			authed.DELETE("/ai/sessions/:id", aiController.DeleteSession)
 			authed.POST("/ai/speech/submit", aiController.SpeechSubmit)
 			authed.POST("/ai/speech/query", aiController.SpeechQuery)
 
 			authed.GET("/user/profile", userController.GetProfile)
 			authed.PUT("/user/profile", userController.UpdateProfile)
+			authed.GET("/user/reminders", userController.GetReminderSettings)
+			authed.PUT("/user/reminders", userController.UpdateReminderSettings)
+			authed.GET("/user/notification-channels", userController.GetNotificationChannels)
+			authed.PUT("/user/notification-channels", userController.UpdateNotificationChannels)
+			authed.GET("/user/notification-preferences", userController.GetNotificationPreferences)
+			authed.PUT("/user/notification-preferences", userController.UpdateNotificationPreferences)
+			authed.GET("/user/digest", userController.GetDigestSettings)
+			authed.PUT("/user/digest", userController.UpdateDigestSettings)
+			authed.GET("/user/ai-preferences", userController.GetAIPreferences)
+			authed.PUT("/user/ai-preferences", userController.UpdateAIPreferences)
 			authed.POST("/upload/avatar", uploadController.UploadAvatar)
 			authed.GET("/users/search", userController.SearchUsers)
 
@@ -68,10 +113,27 @@ func SetupRouter(cfg config.AppConfig) *gin.Engine {
 				admin.GET("/users", adminController.ListUsers)
 				admin.PUT("/users/:id/status", adminController.UpdateUserStatus)
 				admin.PUT("/users/:id/reset-password", adminController.ResetPassword)
+				admin.GET("/reminder-scheduler", adminController.GetReminderSchedulerStatus)
 			}
 		}
 	}
 
+	// CalDAV 客户端依赖 HTTP 状态码与 WebDAV 方法，单独挂载且不使用统一 JSON 响应。
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND"} {
+		r.Handle(method, "/.well-known/caldav", calDAVController.WellKnown)
+	}
+	r.OPTIONS("/caldav/*path", calDAVController.Options)
+	dav := r.Group("/caldav")
+	dav.Use(middleware.DAVAuthRequired(cfg))
+	{
+		dav.Handle("PROPFIND", "/*path", calDAVController.Propfind)
+		dav.Handle("REPORT", "/*path", calDAVController.Report)
+		dav.GET("/*path", calDAVController.Get)
+		dav.HEAD("/*path", calDAVController.Get)
+		dav.PUT("/*path", calDAVController.Put)
+		dav.DELETE("/*path", calDAVController.Delete)
+	}
+
 	return r
 }
 
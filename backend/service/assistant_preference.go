@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+)
+
+// AI 助手偏好的默认值。
+const (
+	DefaultLocale    = "zh-CN"
+	DefaultWorkStart = "09:00"
+	DefaultWorkEnd   = "18:00"
+)
+
+// SupportedLocales 为可设置的语言区域，值为该区域每周的起始日。
+var SupportedLocales = map[string]time.Weekday{
+	"zh-CN": time.Monday,
+	"zh-TW": time.Sunday,
+	"en-US": time.Sunday,
+	"en-GB": time.Monday,
+}
+
+// defaultWorkDays 为未设置工作日时的周一至周五。
+var defaultWorkDays = []int{1, 2, 3, 4, 5}
+
+// AssistantPreferences 表示用户的 AI 助手偏好，时间按用户时区解释。
+type AssistantPreferences struct {
+	Locale    string `json:"locale"`
+	WorkStart string `json:"work_start"`
+	WorkEnd   string `json:"work_end"`
+	WorkDays  []int  `json:"work_days"` // 0 为周日
+}
+
+// 助手偏好校验错误。
+var (
+	ErrInvalidLocale    = errors.New("invalid locale")
+	ErrInvalidWorkHours = errors.New("invalid work hours")
+	ErrInvalidWorkDays  = errors.New("invalid work days")
+)
+
+// UserAssistantPreferences 返回用户的 AI 助手偏好，未设置的字段使用默认值。
+func UserAssistantPreferences(user model.User) AssistantPreferences {
+	prefs := AssistantPreferences{
+		Locale:    user.Locale,
+		WorkStart: user.WorkStart,
+		WorkEnd:   user.WorkEnd,
+		WorkDays:  ParseWorkDays(user.WorkDays),
+	}
+	if _, ok := SupportedLocales[prefs.Locale]; !ok {
+		prefs.Locale = DefaultLocale
+	}
+	if prefs.WorkStart == "" || prefs.WorkEnd == "" {
+		prefs.WorkStart, prefs.WorkEnd = DefaultWorkStart, DefaultWorkEnd
+	}
+	if len(prefs.WorkDays) == 0 {
+		prefs.WorkDays = append([]int(nil), defaultWorkDays...)
+	}
+	return prefs
+}
+
+// ValidateAssistantPreferences 校验语言区域、工作时间（开始早于结束的 HH:MM）与工作日（0-6，至少一天）。
+func ValidateAssistantPreferences(prefs AssistantPreferences) error {
+	if _, ok := SupportedLocales[prefs.Locale]; !ok {
+		return ErrInvalidLocale
+	}
+	start, okStart := ParseClock(prefs.WorkStart)
+	end, okEnd := ParseClock(prefs.WorkEnd)
+	if !okStart || !okEnd || start >= end {
+		return ErrInvalidWorkHours
+	}
+	if len(prefs.WorkDays) == 0 {
+		return ErrInvalidWorkDays
+	}
+	for _, day := range prefs.WorkDays {
+		if day < 0 || day > 6 {
+			return ErrInvalidWorkDays
+		}
+	}
+	return nil
+}
+
+// LocaleWeekStart 返回语言区域的每周起始日，未知区域按周一。
+func LocaleWeekStart(locale string) time.Weekday {
+	if weekday, ok := SupportedLocales[locale]; ok {
+		return weekday
+	}
+	return time.Monday
+}
+
+// FormatWorkDays 将工作日去重排序后保存为逗号分隔的字符串。
+func FormatWorkDays(days []int) string {
+	seen := map[int]struct{}{}
+	list := make([]int, 0, len(days))
+	for _, day := range days {
+		if _, ok := seen[day]; ok {
+			continue
+		}
+		seen[day] = struct{}{}
+		list = append(list, day)
+	}
+	sort.Ints(list)
+	parts := make([]string, 0, len(list))
+	for _, day := range list {
+		parts = append(parts, strconv.Itoa(day))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseWorkDays 解析逗号分隔的工作日，忽略无效值。
+func ParseWorkDays(value string) []int {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || day < 0 || day > 6 {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// FindVisibleEventByUID 按 UID 查找用户创建或参与的日程（预加载创建者与参与人）。
+// 兼容未生成 UID 的历史日程使用的 event-<id>@smartcalendar 形式，找不到时返回 gorm.ErrRecordNotFound。
+func FindVisibleEventByUID(userID uint, uid string) (model.Event, error) {
+	var eventIDs []uint
+	if err := visibleEventIDs(userID).
+		Where("events.uid = ?", uid).
+		Scan(&eventIDs).Error; err != nil {
+		return model.Event{}, err
+	}
+	if len(eventIDs) == 0 {
+		var legacyID uint
+		if _, err := fmt.Sscanf(uid, "event-%d@smartcalendar", &legacyID); err != nil || legacyID == 0 {
+			return model.Event{}, gorm.ErrRecordNotFound
+		}
+		eventIDs = []uint{legacyID}
+	}
+	var event model.Event
+	if err := model.DB.Preload("Creator").Preload("Participants.User").First(&event, eventIDs[0]).Error; err != nil {
+		return model.Event{}, err
+	}
+	if event.UID != "" && event.UID != uid {
+		return model.Event{}, gorm.ErrRecordNotFound
+	}
+	if !IsEventVisible(event, userID) {
+		return model.Event{}, gorm.ErrRecordNotFound
+	}
+	return event, nil
+}
+
+// EventETag 基于日程序列化后的 iCalendar 内容计算 ETag，例外记录或回复状态变化都会改变 ETag。
+func EventETag(calendarData string) string {
+	sum := sha1.Sum([]byte(calendarData))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// CalendarCTag 根据集合内全部日程的 ETag 计算集合标签，任一日程变化都会改变。
+func CalendarCTag(etags []string) string {
+	sorted := make([]string, len(etags))
+	copy(sorted, etags)
+	sort.Strings(sorted)
+	hash := sha1.New()
+	for _, etag := range sorted {
+		hash.Write([]byte(etag))
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// FilterEventsInRange 返回在区间内至少有一个实例的日程，重复日程按实例展开判断。
+func FilterEventsInRange(events []model.Event, rangeStart, rangeEnd time.Time) ([]model.Event, error) {
+	occurrences, err := ExpandEvents(events, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	matched := map[uint]struct{}{}
+	for _, occurrence := range occurrences {
+		if occurrence.EndTime.After(rangeStart) && occurrence.StartTime.Before(rangeEnd) {
+			matched[occurrence.Event.ID] = struct{}{}
+		}
+	}
+	result := make([]model.Event, 0, len(matched))
+	for _, event := range events {
+		if _, ok := matched[event.ID]; ok {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
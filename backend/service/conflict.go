@@ -0,0 +1,175 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"smartcalendar/model"
+)
+
+// conflictHorizon 为重复日程检测冲突时展开的时间范围。
+const conflictHorizon = 90 * 24 * time.Hour
+
+// maxConflictOccurrences 限制重复日程参与冲突检测的实例数量。
+const maxConflictOccurrences = 50
+
+// Conflict 表示某个用户在候选时间段内已有的日程。
+type Conflict struct {
+	UserID    uint      `json:"user_id"`
+	Nickname  string    `json:"nickname"`
+	EventID   uint      `json:"event_id,omitempty"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// FindConflicts 检查候选日程与 userIDs 中各用户已创建或参与的日程是否重叠。
+// 对 viewerID 不可见的日程只返回忙碌时段，不返回标题与 ID。
+func FindConflicts(candidate model.Event, userIDs []uint, viewerID uint) ([]Conflict, error) {
+	userIDs = uniqueUintList(userIDs)
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	slots := candidateSlots(candidate)
+	if len(slots) == 0 {
+		return nil, nil
+	}
+	rangeStart := slots[0].StartTime
+	rangeEnd := slots[len(slots)-1].EndTime
+
+	events, err := LoadUserEvents(userIDs, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	filtered := events[:0]
+	for _, event := range events {
+		if candidate.ID != 0 && event.ID == candidate.ID {
+			continue
+		}
+		filtered = append(filtered, event)
+	}
+	occurrences, err := ExpandEvents(filtered, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	nicknames, err := loadNicknames(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[[3]int64]struct{}{}
+	var result []Conflict
+	for _, occurrence := range occurrences {
+		overlapping := false
+		for _, slot := range slots {
+			if occurrence.StartTime.Before(slot.EndTime) && slot.StartTime.Before(occurrence.EndTime) {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			continue
+		}
+		visible := IsEventVisible(occurrence.Event, viewerID)
+		for _, userID := range BusyUserIDs(occurrence.Event) {
+			if _, ok := nicknames[userID]; !ok {
+				continue
+			}
+			key := [3]int64{int64(userID), int64(occurrence.Event.ID), occurrence.StartTime.Unix()}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			conflict := Conflict{
+				UserID:    userID,
+				Nickname:  nicknames[userID],
+				Title:     "忙碌",
+				StartTime: occurrence.StartTime,
+				EndTime:   occurrence.EndTime,
+			}
+			if visible {
+				conflict.EventID = occurrence.Event.ID
+				conflict.Title = occurrence.Title
+			}
+			result = append(result, conflict)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
+
+// LoadUserEvents 查询 userIDs 创建或参与、且可能与区间相交的日程（含参与人，重复日程未展开）。
+// 查询范围按 storedTimeSlack 放宽，调用方须按展开的实例时间精确筛选。
+func LoadUserEvents(userIDs []uint, rangeStart, rangeEnd time.Time) ([]model.Event, error) {
+	rangeStart, rangeEnd = rangeStart.Add(-storedTimeSlack), rangeEnd.Add(storedTimeSlack)
+	var eventIDs []uint
+	if err := visibleEventIDs(userIDs...).
+		Where("(events.end_time >= ? OR events.rrule <> '')", rangeStart).
+		Where("events.start_time <= ?", rangeEnd).
+		Scan(&eventIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(eventIDs) == 0 {
+		return nil, nil
+	}
+	var events []model.Event
+	if err := model.DB.Where("id IN ?", eventIDs).
+		Preload("Participants").
+		Order("start_time asc").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// BusyUserIDs 返回因该日程而处于忙碌状态的用户：创建者与未拒绝的参与人。
+func BusyUserIDs(event model.Event) []uint {
+	ids := []uint{event.UserID}
+	for _, participant := range event.Participants {
+		if participant.Status == model.ResponseDeclined {
+			continue
+		}
+		ids = append(ids, participant.UserID)
+	}
+	return uniqueUintList(ids)
+}
+
+// IsEventVisible 判断用户是否为日程的创建者或参与人。
+func IsEventVisible(event model.Event, userID uint) bool {
+	if event.UserID == userID {
+		return true
+	}
+	for _, participant := range event.Participants {
+		if participant.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateSlots 返回候选日程需要检测的时间段，重复日程在检测范围内展开。
+func candidateSlots(candidate model.Event) []Occurrence {
+	if candidate.RRule == "" {
+		return []Occurrence{{StartTime: candidate.StartTime, EndTime: candidate.EndTime}}
+	}
+	occurrences := ExpandEvent(candidate, nil, candidate.StartTime, candidate.StartTime.Add(conflictHorizon))
+	if len(occurrences) > maxConflictOccurrences {
+		occurrences = occurrences[:maxConflictOccurrences]
+	}
+	return occurrences
+}
+
+// loadNicknames 查询用户昵称。
+func loadNicknames(userIDs []uint) (map[uint]string, error) {
+	var users []model.User
+	if err := model.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]string, len(users))
+	for _, user := range users {
+		result[user.ID] = user.Nickname
+	}
+	return result, nil
+}
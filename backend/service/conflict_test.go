@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"smartcalendar/model"
+)
+
+func TestConflictsAndFreeBusyAcrossStoredZones(t *testing.T) {
+	setupServiceDB(t)
+	user := model.User{Nickname: "alice", Email: "alice@example.com", Password: "x", Status: "active"}
+	if err := model.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	shanghai := time.FixedZone("CST", 8*3600)
+	// 导入的日程按 UTC 存储：10:00-11:00（+08:00）与次日同一时刻。
+	start := time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{start, start.AddDate(0, 0, 1)} {
+		if err := model.DB.Create(&model.Event{UserID: user.ID, Title: "导入", Type: "work", StartTime: at, EndTime: at.Add(time.Hour)}).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+
+	candidateStart := time.Date(2026, 10, 20, 10, 30, 0, 0, shanghai)
+	conflicts, err := FindConflicts(model.Event{StartTime: candidateStart, EndTime: candidateStart.Add(time.Hour)}, []uint{user.ID}, user.ID)
+	if err != nil {
+		t.Fatalf("find conflicts: %v", err)
+	}
+	if len(conflicts) != 1 || !conflicts[0].StartTime.Equal(start) {
+		t.Fatalf("conflicts = %+v, want the UTC-stored event", conflicts)
+	}
+
+	dayStart := time.Date(2026, 10, 20, 0, 0, 0, 0, shanghai)
+	busy, err := FreeBusy([]uint{user.ID}, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("free/busy: %v", err)
+	}
+	if blocks := busy[user.ID]; len(blocks) != 1 || !blocks[0].StartTime.Equal(start) || !blocks[0].EndTime.Equal(start.Add(time.Hour)) {
+		t.Fatalf("busy = %+v", blocks)
+	}
+}
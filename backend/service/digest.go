@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// DefaultDigestTime 为开启每日摘要但未指定时间时的默认发送时间。
+const DefaultDigestTime = "08:00"
+
+// ErrInvalidDigestTime 表示每日摘要的发送时间格式无效。
+var ErrInvalidDigestTime = errors.New("invalid digest time")
+
+// digestTypeOrder 为摘要中日程类型分组的展示顺序，未列出的类型排在最后。
+var digestTypeOrder = []string{"work", "life", "growth"}
+
+// DigestPayload 为每日摘要通知附带的结构化数据。
+type DigestPayload struct {
+	Date     string        `json:"date"`     // 用户时区的日期 YYYY-MM-DD
+	Timezone string        `json:"timezone"` // 计算当天范围所用的时区
+	Total    int           `json:"total"`
+	Groups   []DigestGroup `json:"groups"`
+}
+
+// DigestGroup 表示摘要中同一类型的日程。
+type DigestGroup struct {
+	Type   string       `json:"type"`
+	Label  string       `json:"label"`
+	Events []DigestItem `json:"events"`
+}
+
+// DigestItem 表示摘要中的一个日程实例。
+type DigestItem struct {
+	EventID   uint      `json:"event_id"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Location  string    `json:"location"`
+	Role      string    `json:"role"` // creator：本人创建；participant：受邀参与
+}
+
+// ValidateDigestTime 校验每日摘要的发送时间，格式为 HH:MM。
+func ValidateDigestTime(value string) error {
+	if _, ok := ParseClock(value); !ok {
+		return ErrInvalidDigestTime
+	}
+	return nil
+}
+
+// BuildDigest 汇总用户在 day 所在当天（按用户时区）创建或参与的日程，已拒绝的邀请除外；
+// 按类型分组，组内按开始时间排序，跨天日程只要与当天相交即计入。
+func BuildDigest(user model.User, day time.Time) (DigestPayload, error) {
+	loc := UserLocation(user)
+	local := day.In(loc)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	payload := DigestPayload{
+		Date:     dayStart.Format("2006-01-02"),
+		Timezone: loc.String(),
+		Groups:   []DigestGroup{},
+	}
+
+	events, err := LoadUserEvents([]uint{user.ID}, dayStart, dayEnd)
+	if err != nil {
+		return DigestPayload{}, err
+	}
+	occurrences, err := ExpandEvents(events, dayStart, dayEnd)
+	if err != nil {
+		return DigestPayload{}, err
+	}
+	groups := map[string][]DigestItem{}
+	for _, occurrence := range occurrences {
+		if !occurrence.StartTime.Before(dayEnd) || !occurrence.EndTime.After(dayStart) {
+			continue
+		}
+		busy := false
+		for _, userID := range BusyUserIDs(occurrence.Event) {
+			if userID == user.ID {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			continue
+		}
+		role := "participant"
+		if occurrence.Event.UserID == user.ID {
+			role = "creator"
+		}
+		groups[occurrence.Type] = append(groups[occurrence.Type], DigestItem{
+			EventID:   occurrence.Event.ID,
+			Title:     occurrence.Title,
+			StartTime: occurrence.StartTime.In(loc),
+			EndTime:   occurrence.EndTime.In(loc),
+			Location:  occurrence.Location,
+			Role:      role,
+		})
+		payload.Total++
+	}
+
+	types := make([]string, 0, len(groups))
+	for eventType := range groups {
+		types = append(types, eventType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		left, right := digestTypeRank(types[i]), digestTypeRank(types[j])
+		if left != right {
+			return left < right
+		}
+		return types[i] < types[j]
+	})
+	for _, eventType := range types {
+		items := groups[eventType]
+		sort.SliceStable(items, func(i, j int) bool {
+			if !items[i].StartTime.Equal(items[j].StartTime) {
+				return items[i].StartTime.Before(items[j].StartTime)
+			}
+			return items[i].EndTime.Before(items[j].EndTime)
+		})
+		label := eventTypeLabels[eventType]
+		if label == "" {
+			label = eventType
+		}
+		payload.Groups = append(payload.Groups, DigestGroup{Type: eventType, Label: label, Events: items})
+	}
+	return payload, nil
+}
+
+// digestTypeRank 返回日程类型的展示顺序。
+func digestTypeRank(eventType string) int {
+	for index, item := range digestTypeOrder {
+		if item == eventType {
+			return index
+		}
+	}
+	return len(digestTypeOrder)
+}
+
+// digestContent 生成摘要通知文案：按类型分组逐行列出日程，超出通知长度时截断。
+func digestContent(payload DigestPayload) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "今日共有 %d 个日程", payload.Total)
+	for _, group := range payload.Groups {
+		fmt.Fprintf(&builder, "\n【%s】", group.Label)
+		for _, item := range group.Events {
+			builder.WriteString("\n" + digestClock(item.StartTime, payload.Date) + "-" + digestClock(item.EndTime, payload.Date) + " " + item.Title)
+			if item.Location != "" {
+				builder.WriteString(" @" + item.Location)
+			}
+		}
+	}
+	return truncateRunes(builder.String(), 500)
+}
+
+// digestClock 显示时分，不在当天的时间带上日期。
+func digestClock(value time.Time, date string) string {
+	if value.Format("2006-01-02") == date {
+		return value.Format("15:04")
+	}
+	return value.Format("01-02 15:04")
+}
+
+// dispatchDueDigests 为到达每日摘要时间的用户发送当天的日程摘要，返回发送数量。
+// 错过发送时间超过 window 的摘要当天不再补发；当天没有日程时不发送。
+// 多实例部署时以按旧值更新 digest_sent_on 作为认领，同一用户每天只处理一次。
+func dispatchDueDigests(now time.Time, window time.Duration, stopping func() bool) (int, error) {
+	var users []model.User
+	if err := model.DB.Select("id", "timezone", "digest_time", "digest_sent_on").
+		Where("digest_time <> '' AND status <> ?", "disabled").
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, user := range users {
+		if stopping() {
+			break
+		}
+		clock, ok := ParseClock(user.DigestTime)
+		if !ok {
+			continue
+		}
+		local := now.In(UserLocation(user))
+		today := local.Format("2006-01-02")
+		if user.DigestSentOn == today {
+			continue
+		}
+		dueAt := time.Date(local.Year(), local.Month(), local.Day(), clock/60, clock%60, 0, 0, local.Location())
+		if now.Before(dueAt) || now.Sub(dueAt) > window {
+			continue
+		}
+
+		payload, err := BuildDigest(user, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		created, err := deliverDigest(user, today, payload, now)
+		if errors.Is(err, errDigestTaken) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if payload.Total > 0 {
+			sent++
+		}
+		if created != nil {
+			PublishNotification(*created)
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// errDigestTaken 表示当天的摘要已被其他实例处理。
+var errDigestTaken = errors.New("digest already handled")
+
+// deliverDigest 认领用户当天的摘要并按通知偏好投递，没有日程时只记录已处理。
+// 写入了站内通知时返回该通知，调用方需在事务提交后推送。
+func deliverDigest(user model.User, today string, payload DigestPayload, now time.Time) (*model.Notification, error) {
+	var created *model.Notification
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND COALESCE(digest_sent_on, '') = ?", user.ID, user.DigestSentOn).
+			Update("digest_sent_on", today)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDigestTaken
+		}
+		if payload.Total == 0 {
+			return nil
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		notification := model.Notification{
+			UserID:  user.ID,
+			Type:    "digest",
+			Content: digestContent(payload),
+			Payload: model.JSONText(body),
+			IsRead:  false,
+		}
+		// 摘要在用户选定的时间发送，不受免打扰延后。
+		inApp, err := dispatchNotification(tx, &notification, false, now)
+		if err != nil {
+			return err
+		}
+		if inApp {
+			created = &notification
+		}
+		return nil
+	})
+	return created, err
+}
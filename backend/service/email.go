@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emailSettings 保存邮件通道的全局配置，由 ConfigureEmail 在启动时设置。
+var emailSettings struct {
+	enabled    bool
+	appBaseURL string
+}
+
+// ConfigureEmail 按配置启用邮件通道，未配置 SMTP_HOST 时不写入发件箱。
+func ConfigureEmail(cfg config.AppConfig) {
+	emailSettings.enabled = strings.TrimSpace(cfg.SMTPHost) != ""
+	emailSettings.appBaseURL = strings.TrimRight(cfg.AppBaseURL, "/")
+}
+
+// EmailEnabled 判断服务端是否已配置邮件通道。
+func EmailEnabled() bool {
+	return emailSettings.enabled
+}
+
+// emailTemplate 表示某类通知的邮件模板：主题、纯文本正文与 HTML 正文。
+type emailTemplate struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// emailEventData 表示邮件中展示的日程信息。
+type emailEventData struct {
+	Title       string
+	TypeLabel   string
+	Time        string
+	Location    string
+	Description string
+	Recurring   bool
+}
+
+// emailTemplateData 表示渲染邮件模板的数据。
+type emailTemplateData struct {
+	Nickname string
+	Content  string
+	Title    string
+	Event    *emailEventData
+	Link     string
+}
+
+// emailTextLayout 为纯文本邮件的公共布局，正文由各类型模板的 body 定义。
+const emailTextLayout = `{{.Nickname}}，你好：
+
+{{template "body" .}}
+{{- with .Event}}
+
+日程：{{.Title}}
+时间：{{.Time}}{{if .Recurring}}（重复日程）{{end}}
+类型：{{.TypeLabel}}
+{{- if .Location}}
+地点：{{.Location}}
+{{- end}}
+{{- if .Description}}
+备注：{{.Description}}
+{{- end}}
+{{- end}}
+{{- if .Link}}
+
+查看日程：{{.Link}}
+{{- end}}
+
+——
+你收到这封邮件是因为开启了 SmartCalendar 邮件通知，可在个人设置中关闭。
+`
+
+// emailHTMLLayout 为 HTML 邮件的公共布局，正文由各类型模板的 body 定义。
+const emailHTMLLayout = `<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:0;background:#f5f5f5;font-family:-apple-system,'PingFang SC','Microsoft YaHei',sans-serif;color:#333;">
+<div style="max-width:560px;margin:0 auto;padding:24px;background:#fff;">
+<p>{{.Nickname}}，你好：</p>
+{{template "body" .}}
+{{- with .Event}}
+<table style="width:100%;border-collapse:collapse;margin:16px 0;font-size:14px;">
+<tr><td style="padding:6px 0;color:#888;width:56px;">日程</td><td style="padding:6px 0;"><strong>{{.Title}}</strong></td></tr>
+<tr><td style="padding:6px 0;color:#888;">时间</td><td style="padding:6px 0;">{{.Time}}{{if .Recurring}}（重复日程）{{end}}</td></tr>
+<tr><td style="padding:6px 0;color:#888;">类型</td><td style="padding:6px 0;">{{.TypeLabel}}</td></tr>
+{{- if .Location}}
+<tr><td style="padding:6px 0;color:#888;">地点</td><td style="padding:6px 0;">{{.Location}}</td></tr>
+{{- end}}
+{{- if .Description}}
+<tr><td style="padding:6px 0;color:#888;">备注</td><td style="padding:6px 0;">{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Link}}
+<p><a href="{{.Link}}" style="display:inline-block;padding:8px 16px;background:#1677ff;color:#fff;text-decoration:none;border-radius:4px;">查看日程</a></p>
+{{- end}}
+<p style="margin-top:24px;color:#999;font-size:12px;">你收到这封邮件是因为开启了 SmartCalendar 邮件通知，可在个人设置中关闭。</p>
+</div>
+</body>
+</html>
+`
+
+// emailTemplates 按通知类型注册邮件模板，未注册的类型不发送邮件。
+var emailTemplates = map[string]emailTemplate{
+	"invitation": newEmailTemplate(
+		"[SmartCalendar] 日程邀请：{{.Title}}",
+		`{{.Content}}。请登录 SmartCalendar 查看详情并回复是否参加。`,
+		`<p>{{.Content}}。</p><p>请登录 SmartCalendar 查看详情并回复是否参加。</p>`,
+	),
+	"change": newEmailTemplate( // 旧版变更通知，仅用于释放升级前延后的通知
+		"[SmartCalendar] 日程变更：{{.Title}}",
+		`{{.Content}}，以下为最新信息。`,
+		`<p>{{.Content}}，以下为最新信息。</p>`,
+	),
+	"digest": newEmailTemplate(
+		"[SmartCalendar] 今日日程摘要",
+		`{{.Content}}`,
+		`<p style="white-space:pre-line;">{{.Content}}</p>`,
+	),
+	NotificationCancelled: newEmailTemplate(
+		"[SmartCalendar] 日程取消：{{.Title}}",
+		`{{.Content}}。`,
+		`<p>{{.Content}}。</p>`,
+	),
+	NotificationRescheduled: newEmailTemplate(
+		"[SmartCalendar] 日程改期：{{.Title}}",
+		`{{.Content}}，以下为最新信息。`,
+		`<p>{{.Content}}，以下为最新信息。</p>`,
+	),
+	NotificationDetailsChanged: newEmailTemplate(
+		"[SmartCalendar] 日程变更：{{.Title}}",
+		`{{.Content}}，以下为最新信息。`,
+		`<p>{{.Content}}，以下为最新信息。</p>`,
+	),
+	NotificationRemoved: newEmailTemplate(
+		"[SmartCalendar] 已移出日程：{{.Title}}",
+		`{{.Content}}，该日程将不再显示在日历中。`,
+		`<p>{{.Content}}，该日程将不再显示在日历中。</p>`,
+	),
+	"reminder": newEmailTemplate(
+		"[SmartCalendar] 日程提醒：{{.Title}}",
+		`{{.Content}}。`,
+		`<p style="font-size:16px;">{{.Content}}。</p>`,
+	),
+}
+
+// newEmailTemplate 基于公共布局构建某类通知的邮件模板。
+func newEmailTemplate(subject string, textBody string, htmlBody string) emailTemplate {
+	text := texttemplate.Must(texttemplate.New("layout").Parse(emailTextLayout))
+	texttemplate.Must(text.New("body").Parse(textBody))
+	html := htmltemplate.Must(htmltemplate.New("layout").Parse(emailHTMLLayout))
+	htmltemplate.Must(html.New("body").Parse(htmlBody))
+	return emailTemplate{
+		subject: texttemplate.Must(texttemplate.New("subject").Parse(subject)),
+		text:    text,
+		html:    html,
+	}
+}
+
+// render 渲染邮件主题与正文。
+func (t emailTemplate) render(data emailTemplateData) (string, string, string, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", "", err
+	}
+	if err := t.text.ExecuteTemplate(&text, "layout", data); err != nil {
+		return "", "", "", err
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return "", "", "", err
+	}
+	return strings.TrimSpace(subject.String()), text.String(), html.String(), nil
+}
+
+// eventTypeLabels 为日程类型提供中文名称。
+var eventTypeLabels = map[string]string{
+	"work":   "工作",
+	"life":   "生活",
+	"growth": "成长",
+}
+
+// enqueueNotificationEmail 为开启邮件通知的用户渲染通知邮件并写入发件箱，需与通知写入处于同一事务。
+// 用户关闭了该类站内通知时 notification 未写入数据库，发件箱记录不关联通知。
+func enqueueNotificationEmail(tx *gorm.DB, notification model.Notification) error {
+	if !emailSettings.enabled {
+		return nil
+	}
+	tmpl, ok := emailTemplates[notification.Type]
+	if !ok {
+		return nil
+	}
+	var user model.User
+	if err := tx.Select("id", "nickname", "email", "status", "email_notifications").First(&user, notification.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.EmailNotifications || user.Status == "disabled" || strings.TrimSpace(user.Email) == "" {
+		return nil
+	}
+
+	data := emailTemplateData{
+		Nickname: user.Nickname,
+		Content:  notification.Content,
+		Title:    notification.Content,
+	}
+	// 日程变更类通知以附带的日程概要为准：日程可能已被删除，按实例变更时标题与时间也可能不同于整个序列。
+	payload, hasPayload := parseEventNotificationPayload(notification.Payload)
+	if notification.EventID != 0 {
+		var event model.Event
+		err := tx.First(&event, notification.EventID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			data.Title = event.Title
+			data.Event = buildEmailEventData(event, notification.OccurrenceStart)
+			if hasPayload && payload.Scope == "this" {
+				data.Event.Title = payload.Event.Title
+				data.Event.Time = formatEmailTimeRange(payload.Event.StartTime, payload.Event.EndTime)
+				data.Event.Location = payload.Event.Location
+				data.Event.Recurring = false
+			}
+			if emailSettings.appBaseURL != "" {
+				data.Link = emailSettings.appBaseURL + "/calendar"
+			}
+		}
+	}
+	if hasPayload && payload.Event.Title != "" {
+		data.Title = payload.Event.Title
+	}
+	subject, text, html, err := tmpl.render(data)
+	if err != nil {
+		return err
+	}
+	outbox := model.EmailOutbox{
+		UserID:        user.ID,
+		ToAddress:     user.Email,
+		Subject:       subject,
+		TextBody:      text,
+		HTMLBody:      html,
+		Status:        model.EmailPending,
+		NextAttemptAt: time.Now().UTC(),
+	}
+	if notification.ID != 0 {
+		notificationID := notification.ID
+		outbox.NotificationID = &notificationID
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&outbox).Error
+}
+
+// buildEmailEventData 构建邮件中的日程信息，提醒邮件展示被提醒实例的时间。
+func buildEmailEventData(event model.Event, occurrenceStart *time.Time) *emailEventData {
+	start := event.StartTime
+	if occurrenceStart != nil {
+		start = occurrenceStart.In(event.StartTime.Location())
+	}
+	end := start.Add(event.EndTime.Sub(event.StartTime))
+	label := eventTypeLabels[event.Type]
+	if label == "" {
+		label = event.Type
+	}
+	return &emailEventData{
+		Title:       event.Title,
+		TypeLabel:   label,
+		Time:        formatEmailTimeRange(start, end),
+		Location:    event.Location,
+		Description: event.Description,
+		Recurring:   event.RRule != "" && occurrenceStart == nil,
+	}
+}
+
+// formatEmailTimeRange 格式化邮件中的时间段并注明时区偏移。
+func formatEmailTimeRange(start time.Time, end time.Time) string {
+	return formatTimeRange(start, end) + " (UTC" + start.Format("-07:00") + ")"
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// 日程变更类通知类型，通知偏好中统一按 change 设置。
+const (
+	NotificationCancelled      = "cancelled"       // 日程或其部分实例被取消
+	NotificationRescheduled    = "rescheduled"     // 时间或重复规则变化
+	NotificationDetailsChanged = "details_changed" // 标题、类型、地点或备注变化
+	NotificationRemoved        = "removed"         // 参与人被移出日程
+)
+
+// EventNotificationPayload 为日程变更类通知附带的结构化数据。
+type EventNotificationPayload struct {
+	Event           NotificationEventSummary `json:"event"`                      // 变更后的日程概要，取消与移出时为变更前
+	Scope           string                   `json:"scope"`                      // 变更范围：all / this / following
+	OccurrenceStart *time.Time               `json:"occurrence_start,omitempty"` // 按实例变更时的实例原始开始时间
+	Changes         map[string]FieldChange   `json:"changes,omitempty"`          // 改期与详情变更时各字段的前后取值
+}
+
+// NotificationEventSummary 表示通知中的日程概要。
+type NotificationEventSummary struct {
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Location  string    `json:"location"`
+}
+
+// FieldChange 表示某个字段修改前后的取值。
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// notifiedEventFields 为需要通知参与人的日程字段，按文案中的展示顺序排列；提醒设置与参与人名单不计入。
+var notifiedEventFields = []string{"title", "type", "start_time", "end_time", "rrule", "location", "description"}
+
+// eventFieldLabels 为变更字段提供通知文案。
+var eventFieldLabels = map[string]string{
+	"title":       "标题",
+	"type":        "类型",
+	"start_time":  "时间",
+	"end_time":    "时间",
+	"rrule":       "重复规则",
+	"location":    "地点",
+	"description": "备注",
+}
+
+// SnapshotChanges 比较两份日程快照，返回取值不同的字段及其前后取值，只比较两份快照都有的字段。
+func SnapshotChanges(before map[string]interface{}, after map[string]interface{}) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for key, value := range after {
+		previous, ok := before[key]
+		if !ok {
+			continue
+		}
+		if !sameJSON(previous, value) {
+			changes[key] = FieldChange{Before: previous, After: value}
+		}
+	}
+	return changes
+}
+
+// DiffIDs 返回 after 相对 before 新增与移除的元素。
+func DiffIDs(before []uint, after []uint) ([]uint, []uint) {
+	beforeSet := map[uint]struct{}{}
+	for _, id := range before {
+		beforeSet[id] = struct{}{}
+	}
+	afterSet := map[uint]struct{}{}
+	added := []uint{}
+	for _, id := range after {
+		afterSet[id] = struct{}{}
+		if _, ok := beforeSet[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	removed := []uint{}
+	for _, id := range before {
+		if _, ok := afterSet[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// CreateEventUpdateNotifications 按日程修改前后的快照（与操作记录中的格式一致）通知参与人：
+// 新增的参与人收到邀请，被移出的参与人收到 removed，其余参与人在时间或详情变化时收到 rescheduled 或 details_changed。
+// scope 为修改范围，occurrenceStart 为按实例修改时的实例原始开始时间，修改整个日程时传零值。
+func CreateEventUpdateNotifications(event model.Event, before map[string]interface{}, after map[string]interface{}, scope string, occurrenceStart time.Time) error {
+	beforeIDs, _ := before["participant_ids"].([]uint)
+	afterIDs, _ := after["participant_ids"].([]uint)
+	added, removed := DiffIDs(beforeIDs, afterIDs)
+
+	var errs []error
+	if len(added) > 0 {
+		errs = append(errs, CreateInvitationNotifications(event, added))
+	}
+	if len(removed) > 0 {
+		payload := newEventNotificationPayload(before, scope, occurrenceStart)
+		errs = append(errs, sendEventNotifications(event, removed, NotificationRemoved, payload))
+	}
+
+	changes := map[string]FieldChange{}
+	for key, change := range SnapshotChanges(before, after) {
+		if _, ok := eventFieldLabels[key]; ok {
+			changes[key] = change
+		}
+	}
+	if len(changes) > 0 {
+		isAdded := map[uint]bool{}
+		for _, id := range added {
+			isAdded[id] = true
+		}
+		recipients := make([]uint, 0, len(afterIDs))
+		for _, id := range afterIDs {
+			if !isAdded[id] {
+				recipients = append(recipients, id)
+			}
+		}
+		payload := newEventNotificationPayload(after, scope, occurrenceStart)
+		payload.Changes = changes
+		errs = append(errs, sendEventNotifications(event, recipients, eventChangeType(changes), payload))
+	}
+	return errors.Join(errs...)
+}
+
+// CreateEventCancelledNotifications 通知参与人日程或其部分实例已取消，snapshot 为取消前的日程快照。
+func CreateEventCancelledNotifications(event model.Event, participantIDs []uint, snapshot map[string]interface{}, scope string, occurrenceStart time.Time) error {
+	payload := newEventNotificationPayload(snapshot, scope, occurrenceStart)
+	return sendEventNotifications(event, participantIDs, NotificationCancelled, payload)
+}
+
+// newEventNotificationPayload 从日程快照生成通知数据的公共部分。
+func newEventNotificationPayload(snapshot map[string]interface{}, scope string, occurrenceStart time.Time) EventNotificationPayload {
+	payload := EventNotificationPayload{Scope: scope}
+	payload.Event.Title, _ = snapshot["title"].(string)
+	payload.Event.StartTime, _ = snapshot["start_time"].(time.Time)
+	payload.Event.EndTime, _ = snapshot["end_time"].(time.Time)
+	payload.Event.Location, _ = snapshot["location"].(string)
+	if !occurrenceStart.IsZero() {
+		payload.OccurrenceStart = &occurrenceStart
+	}
+	return payload
+}
+
+// sendEventNotifications 按各接收人的时区生成文案，并按其通知偏好发送同一份结构化数据，创建者本人不通知。
+func sendEventNotifications(event model.Event, userIDs []uint, notificationType string, payload EventNotificationPayload) error {
+	recipients := make([]uint, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if userID != event.UserID {
+			recipients = append(recipients, userID)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var users []model.User
+	if err := model.DB.Select("id", "timezone").Where("id IN ?", recipients).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		notification := model.Notification{
+			UserID:  user.ID,
+			Type:    notificationType,
+			Content: eventNotificationContent(notificationType, payload, UserLocation(user)),
+			EventID: event.ID,
+			Payload: model.JSONText(body),
+			IsRead:  false,
+		}
+		created, err := dispatchNotification(model.DB, &notification, true, time.Now())
+		if err != nil {
+			return err
+		}
+		if created {
+			PublishNotification(notification)
+		}
+	}
+	return nil
+}
+
+// eventChangeType 时间或重复规则变化时为 rescheduled，否则为 details_changed。
+func eventChangeType(changes map[string]FieldChange) string {
+	for _, key := range []string{"start_time", "end_time", "rrule"} {
+		if _, ok := changes[key]; ok {
+			return NotificationRescheduled
+		}
+	}
+	return NotificationDetailsChanged
+}
+
+// eventNotificationContent 按通知类型与结构化数据生成文案，时间按接收人时区显示。
+func eventNotificationContent(notificationType string, payload EventNotificationPayload, loc *time.Location) string {
+	if notificationType == NotificationRemoved {
+		return "您已被移出日程《" + payload.Event.Title + "》"
+	}
+	subject := "您参与的日程《" + payload.Event.Title + "》"
+	if payload.OccurrenceStart != nil {
+		subject += payload.OccurrenceStart.In(loc).Format("01月02日 15:04")
+		if payload.Scope == "following" {
+			subject += " 起的实例"
+		} else {
+			subject += " 的实例"
+		}
+	}
+
+	switch notificationType {
+	case NotificationCancelled:
+		return subject + "已取消"
+	case NotificationRescheduled:
+		_, startChanged := payload.Changes["start_time"]
+		_, endChanged := payload.Changes["end_time"]
+		content := subject + "重复规则已调整"
+		if startChanged || endChanged {
+			content = subject + "时间调整为 " + formatTimeRange(payload.Event.StartTime.In(loc), payload.Event.EndTime.In(loc))
+		}
+		if others := changedFieldLabels(payload.Changes, true); others != "" {
+			content += "，" + others + "也已更新"
+		}
+		return content
+	default:
+		return subject + "的" + changedFieldLabels(payload.Changes, false) + "已更新"
+	}
+}
+
+// changedFieldLabels 按展示顺序拼接变更字段的名称，skipSchedule 为 true 时跳过时间与重复规则。
+func changedFieldLabels(changes map[string]FieldChange, skipSchedule bool) string {
+	var labels []string
+	seen := map[string]bool{}
+	for _, key := range notifiedEventFields {
+		if _, ok := changes[key]; !ok {
+			continue
+		}
+		if skipSchedule && (key == "start_time" || key == "end_time" || key == "rrule") {
+			continue
+		}
+		label := eventFieldLabels[key]
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, "、")
+}
+
+// formatTimeRange 格式化时间段，同一天结束时只显示结束的时分。
+func formatTimeRange(start time.Time, end time.Time) string {
+	text := start.Format("2006-01-02 15:04") + " - "
+	if end.Format("2006-01-02") == start.Format("2006-01-02") {
+		return text + end.Format("15:04")
+	}
+	return text + end.Format("2006-01-02 15:04")
+}
+
+// parseEventNotificationPayload 解析通知附带的结构化数据，非日程变更类通知返回 false。
+func parseEventNotificationPayload(value model.JSONText) (EventNotificationPayload, bool) {
+	var payload EventNotificationPayload
+	if value == "" || json.Unmarshal([]byte(value), &payload) != nil {
+		return EventNotificationPayload{}, false
+	}
+	return payload, true
+}
+
+// mergeDeferredEventNotification 在写入延后队列前与同一日程尚未投递的变更通知合并，已处理时返回 true：
+// 整个日程取消时丢弃尚未投递的改期与详情变更；同一范围的改期与详情变更合并为一条，
+// 各字段保留最早的修改前取值与最新的修改后取值，全部改回原值时撤销该通知。
+func mergeDeferredEventNotification(tx *gorm.DB, user model.User, notification model.Notification, deliverAt time.Time) (bool, error) {
+	if notification.EventID == 0 {
+		return false, nil
+	}
+	incoming, ok := parseEventNotificationPayload(notification.Payload)
+	if !ok {
+		return false, nil
+	}
+	pendingQuery := tx.Where("user_id = ? AND event_id = ? AND type IN ?", user.ID, notification.EventID,
+		[]string{NotificationRescheduled, NotificationDetailsChanged})
+	if notification.Type == NotificationCancelled {
+		if incoming.Scope != "all" {
+			return false, nil
+		}
+		return false, pendingQuery.Delete(&model.DeferredNotification{}).Error
+	}
+	if notification.Type != NotificationRescheduled && notification.Type != NotificationDetailsChanged {
+		return false, nil
+	}
+
+	var pending []model.DeferredNotification
+	if err := pendingQuery.Order("id asc").Find(&pending).Error; err != nil {
+		return false, err
+	}
+	for _, item := range pending {
+		existing, ok := parseEventNotificationPayload(item.Payload)
+		if !ok || !sameOccurrenceScope(existing, incoming) {
+			continue
+		}
+		merged := incoming
+		merged.Changes = map[string]FieldChange{}
+		for key, change := range existing.Changes {
+			merged.Changes[key] = change
+		}
+		for key, change := range incoming.Changes {
+			if previous, ok := existing.Changes[key]; ok {
+				change.Before = previous.Before
+			}
+			if sameJSON(change.Before, change.After) {
+				delete(merged.Changes, key)
+				continue
+			}
+			merged.Changes[key] = change
+		}
+		if len(merged.Changes) == 0 {
+			return true, tx.Delete(&model.DeferredNotification{}, item.ID).Error
+		}
+		body, err := json.Marshal(merged)
+		if err != nil {
+			return true, err
+		}
+		notificationType := eventChangeType(merged.Changes)
+		return true, tx.Model(&model.DeferredNotification{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"type":       notificationType,
+			"content":    eventNotificationContent(notificationType, merged, UserLocation(user)),
+			"payload":    string(body),
+			"deliver_at": deliverAt.UTC(),
+		}).Error
+	}
+	return false, nil
+}
+
+// sameOccurrenceScope 判断两条变更是否针对同一范围（整个日程或同一实例）。
+func sameOccurrenceScope(a EventNotificationPayload, b EventNotificationPayload) bool {
+	if a.Scope != b.Scope {
+		return false
+	}
+	if a.OccurrenceStart == nil || b.OccurrenceStart == nil {
+		return a.OccurrenceStart == nil && b.OccurrenceStart == nil
+	}
+	return a.OccurrenceStart.Equal(*b.OccurrenceStart)
+}
+
+// sameJSON 按 JSON 序列化结果比较两个取值。
+func sameJSON(a interface{}, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
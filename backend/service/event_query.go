@@ -0,0 +1,89 @@
+package service
+
+import (
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// EventQuery 表示查询用户可见日程的筛选条件，零值字段不参与筛选。
+type EventQuery struct {
+	Type      string     // 日程类型
+	Keywords  []string   // 标题、地点或描述须包含全部关键词
+	MemberIDs []uint     // 创建者或参与人须包含其中任一用户
+	Start     *time.Time // 区间开始，重复日程不按此过滤，由调用方展开
+	End       *time.Time // 区间结束
+}
+
+// visibleEventIDs 构造 userIDs 中任一用户创建或参与的日程 ID 查询，日程列表、导出、CalDAV 与冲突检测
+// 均在此基础上追加条件，保证可见性口径一致。
+func visibleEventIDs(userIDs ...uint) *gorm.DB {
+	return model.DB.Table("events").
+		Select("events.id").
+		Joins("LEFT JOIN event_participants ON event_participants.event_id = events.id").
+		Where("events.user_id IN ? OR event_participants.user_id IN ?", userIDs, userIDs).
+		Distinct()
+}
+
+// QueryVisibleEvents 查询用户创建或参与的日程，按开始时间排序并预加载创建者与参与人。
+func QueryVisibleEvents(userID uint, filter EventQuery) ([]model.Event, error) {
+	query := visibleEventIDs(userID)
+	if filter.Type != "" {
+		query = query.Where("events.type = ?", filter.Type)
+	}
+	if filter.Start != nil {
+		query = query.Where("(events.end_time >= ? OR events.rrule <> '')", *filter.Start)
+	}
+	if filter.End != nil {
+		query = query.Where("events.start_time <= ?", *filter.End)
+	}
+	for _, keyword := range filter.Keywords {
+		value := "%" + keyword + "%"
+		query = query.Where("(events.title LIKE ? OR events.location LIKE ? OR events.description LIKE ?)", value, value, value)
+	}
+
+	var eventIDs []uint
+	if err := query.Scan(&eventIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(eventIDs) == 0 {
+		return nil, nil
+	}
+	var events []model.Event
+	if err := model.DB.Where("id IN ?", eventIDs).
+		Preload("Creator").
+		Preload("Participants.User").
+		Order("start_time asc").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if len(filter.MemberIDs) == 0 {
+		return events, nil
+	}
+	members := make(map[uint]struct{}, len(filter.MemberIDs))
+	for _, id := range filter.MemberIDs {
+		members[id] = struct{}{}
+	}
+	matched := events[:0]
+	for _, event := range events {
+		if hasEventMember(event, members) {
+			matched = append(matched, event)
+		}
+	}
+	return matched, nil
+}
+
+// hasEventMember 判断日程的创建者或参与人是否在 members 中。
+func hasEventMember(event model.Event, members map[uint]struct{}) bool {
+	if _, ok := members[event.UserID]; ok {
+		return true
+	}
+	for _, participant := range event.Participants {
+		if _, ok := members[participant.UserID]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+func TestVisibleEventQueriesAgree(t *testing.T) {
+	setupServiceDB(t)
+	users := make([]model.User, 3)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		users[i] = model.User{Nickname: name, Email: name + "@example.com", Password: "x", Status: "active"}
+		if err := model.DB.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	alice, bob, carol := users[0], users[1], users[2]
+	start := time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)
+	create := func(title string, creator model.User, participants ...model.User) model.Event {
+		event := model.Event{UserID: creator.ID, Title: title, Type: "work", StartTime: start, EndTime: start.Add(time.Hour)}
+		if err := model.DB.Create(&event).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		for _, participant := range participants {
+			if err := model.DB.Create(&model.EventParticipant{EventID: event.ID, UserID: participant.ID}).Error; err != nil {
+				t.Fatalf("create participant: %v", err)
+			}
+		}
+		return event
+	}
+	create("自己创建", alice, bob)
+	create("受邀参加", bob, alice, carol)
+	hidden := create("与我无关", bob, carol)
+
+	titles := func(events []model.Event, err error) string {
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		var result []string
+		for _, event := range events {
+			result = append(result, event.Title)
+		}
+		return fmt.Sprint(result)
+	}
+	want := "[自己创建 受邀参加]"
+	if got := titles(QueryVisibleEvents(alice.ID, EventQuery{})); got != want {
+		t.Errorf("QueryVisibleEvents = %s, want %s", got, want)
+	}
+	if got := titles(LoadVisibleEvents(alice.ID, nil)); got != want {
+		t.Errorf("LoadVisibleEvents = %s, want %s", got, want)
+	}
+	if got := titles(LoadUserEvents([]uint{alice.ID}, start, start.Add(time.Hour))); got != want {
+		t.Errorf("LoadUserEvents = %s, want %s", got, want)
+	}
+	if _, err := FindVisibleEventByUID(alice.ID, hidden.UID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindVisibleEventByUID(hidden) err = %v, want not found", err)
+	}
+	if event, err := FindVisibleEventByUID(carol.ID, hidden.UID); err != nil || event.ID != hidden.ID {
+		t.Errorf("FindVisibleEventByUID(participant) = %d, %v", event.ID, err)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// feedTokenPrefix 标识日历订阅令牌，便于用户在日志或链接中辨认。
+const feedTokenPrefix = "scf_"
+
+// MaxFeedTokensPerUser 为每个用户可同时持有的有效订阅令牌数量上限。
+const MaxFeedTokensPerUser = 10
+
+// ErrFeedTokenNotFound 表示订阅令牌不存在、已撤销或所属用户已被禁用。
+var ErrFeedTokenNotFound = errors.New("feed token not found")
+
+// CreateFeedToken 生成新的订阅令牌，返回记录与明文令牌（明文仅此一次可见）。
+func CreateFeedToken(userID uint, name string, eventTypes []string) (model.FeedToken, string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return model.FeedToken{}, "", err
+	}
+	token := feedTokenPrefix + hex.EncodeToString(raw)
+	record := model.FeedToken{
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   hashFeedToken(token),
+		TokenPrefix: token[:len(feedTokenPrefix)+8],
+		Types:       strings.Join(eventTypes, ","),
+	}
+	if err := model.DB.Create(&record).Error; err != nil {
+		return model.FeedToken{}, "", err
+	}
+	return record, token, nil
+}
+
+// CountActiveFeedTokens 统计用户未撤销的订阅令牌数量。
+func CountActiveFeedTokens(userID uint) (int64, error) {
+	var count int64
+	err := model.DB.Model(&model.FeedToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// ResolveFeedToken 校验明文令牌并返回令牌记录与所属用户。
+func ResolveFeedToken(token string) (model.FeedToken, model.User, error) {
+	if !strings.HasPrefix(token, feedTokenPrefix) {
+		return model.FeedToken{}, model.User{}, ErrFeedTokenNotFound
+	}
+	var record model.FeedToken
+	if err := model.DB.Where("token_hash = ? AND revoked_at IS NULL", hashFeedToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.FeedToken{}, model.User{}, ErrFeedTokenNotFound
+		}
+		return model.FeedToken{}, model.User{}, err
+	}
+	var user model.User
+	if err := model.DB.First(&user, record.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.FeedToken{}, model.User{}, ErrFeedTokenNotFound
+		}
+		return model.FeedToken{}, model.User{}, err
+	}
+	if user.Status == "disabled" {
+		return model.FeedToken{}, model.User{}, ErrFeedTokenNotFound
+	}
+	return record, user, nil
+}
+
+// TouchFeedToken 记录令牌最近一次被日历客户端拉取的时间。
+func TouchFeedToken(id uint) error {
+	return model.DB.Model(&model.FeedToken{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+// RevokeFeedToken 撤销用户自己的订阅令牌，重复撤销视为成功。
+func RevokeFeedToken(userID, id uint) error {
+	var record model.FeedToken
+	if err := model.DB.Where("id = ? AND user_id = ?", id, userID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFeedTokenNotFound
+		}
+		return err
+	}
+	if record.RevokedAt != nil {
+		return nil
+	}
+	return model.DB.Model(&record).Update("revoked_at", time.Now()).Error
+}
+
+// FeedTokenTypes 返回令牌限定的日程类型，空表示全部类型。
+func FeedTokenTypes(record model.FeedToken) []string {
+	var types []string
+	for _, item := range strings.Split(record.Types, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			types = append(types, item)
+		}
+	}
+	return types
+}
+
+// hashFeedToken 计算令牌（订阅令牌与推送流票据）的 SHA-256 摘要，数据库中只保存摘要。
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"sort"
+	"time"
+)
+
+// BusyInterval 表示一段忙碌时间，不包含任何日程详情。
+type BusyInterval struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// SlotQuery 描述查找共同空闲时段的条件。
+type SlotQuery struct {
+	UserIDs    []uint
+	RangeStart time.Time
+	RangeEnd   time.Time
+	Duration   time.Duration
+	WorkStart  time.Duration // 每日工作开始时间（距当日零点）
+	WorkEnd    time.Duration // 每日工作结束时间（距当日零点）
+	WorkDays   []time.Weekday
+	Location   *time.Location
+	Step       time.Duration
+	Limit      int
+	// Unavailable 为不可安排但不计入评分的时段，如参与人的非工作时间。
+	Unavailable []BusyInterval
+}
+
+// Slot 表示一个候选会议时段，Score 越高越推荐。
+type Slot struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Score     int       `json:"score"`
+}
+
+// slotBuffer 为判断“紧挨着其他会议”的间隔阈值。
+const slotBuffer = 15 * time.Minute
+
+// FreeBusy 返回各用户在区间内合并后的忙碌时段，口径与日程列表一致（创建或参与，已拒绝的邀请除外）。
+func FreeBusy(userIDs []uint, rangeStart, rangeEnd time.Time) (map[uint][]BusyInterval, error) {
+	userIDs = uniqueUintList(userIDs)
+	result := make(map[uint][]BusyInterval, len(userIDs))
+	for _, userID := range userIDs {
+		result[userID] = []BusyInterval{}
+	}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	events, err := LoadUserEvents(userIDs, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	occurrences, err := ExpandEvents(events, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, occurrence := range occurrences {
+		start := occurrence.StartTime
+		end := occurrence.EndTime
+		if start.Before(rangeStart) {
+			start = rangeStart
+		}
+		if end.After(rangeEnd) {
+			end = rangeEnd
+		}
+		if !end.After(start) {
+			continue
+		}
+		for _, userID := range BusyUserIDs(occurrence.Event) {
+			if _, ok := result[userID]; !ok {
+				continue
+			}
+			result[userID] = append(result[userID], BusyInterval{StartTime: start, EndTime: end})
+		}
+	}
+	for userID, intervals := range result {
+		result[userID] = MergeIntervals(intervals)
+	}
+	return result, nil
+}
+
+// MergeIntervals 合并重叠或相接的忙碌时段。
+func MergeIntervals(intervals []BusyInterval) []BusyInterval {
+	if len(intervals) == 0 {
+		return []BusyInterval{}
+	}
+	sorted := make([]BusyInterval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+	merged := []BusyInterval{sorted[0]}
+	for _, interval := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !interval.StartTime.After(last.EndTime) {
+			if interval.EndTime.After(last.EndTime) {
+				last.EndTime = interval.EndTime
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// FindSlots 在工作时间内查找所有用户都空闲的时段，按推荐程度排序。
+// 推荐规则：不与任何人的前后会议紧挨的时段优先，其次时间越早越优先。
+func FindSlots(query SlotQuery) ([]Slot, error) {
+	busy, err := FreeBusy(query.UserIDs, query.RangeStart, query.RangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	return RankSlots(query, busy), nil
+}
+
+// RankSlots 基于已知的忙碌时段计算候选时段。
+func RankSlots(query SlotQuery, busy map[uint][]BusyInterval) []Slot {
+	loc := query.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	step := query.Step
+	if step <= 0 {
+		step = 30 * time.Minute
+	}
+	workDays := map[time.Weekday]struct{}{}
+	for _, day := range query.WorkDays {
+		workDays[day] = struct{}{}
+	}
+
+	all := append([]BusyInterval(nil), query.Unavailable...)
+	for _, intervals := range busy {
+		all = append(all, intervals...)
+	}
+	union := MergeIntervals(all)
+
+	var slots []Slot
+	rangeStart := query.RangeStart.In(loc)
+	day := time.Date(rangeStart.Year(), rangeStart.Month(), rangeStart.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(query.RangeEnd); day = day.AddDate(0, 0, 1) {
+		if len(workDays) > 0 {
+			if _, ok := workDays[day.Weekday()]; !ok {
+				continue
+			}
+		}
+		windowStart := day.Add(query.WorkStart)
+		windowEnd := day.Add(query.WorkEnd)
+		if windowStart.Before(query.RangeStart) {
+			windowStart = alignUp(query.RangeStart.In(loc), day, step)
+		}
+		if windowEnd.After(query.RangeEnd) {
+			windowEnd = query.RangeEnd
+		}
+		for start := windowStart; !start.Add(query.Duration).After(windowEnd); start = start.Add(step) {
+			end := start.Add(query.Duration)
+			if overlapsAny(union, start, end) {
+				continue
+			}
+			score := 100
+			for _, intervals := range busy {
+				if overlapsAny(intervals, start.Add(-slotBuffer), end.Add(slotBuffer)) {
+					score -= 10
+				}
+			}
+			slots = append(slots, Slot{StartTime: start, EndTime: end, Score: score})
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].Score != slots[j].Score {
+			return slots[i].Score > slots[j].Score
+		}
+		return slots[i].StartTime.Before(slots[j].StartTime)
+	})
+	if query.Limit > 0 && len(slots) > query.Limit {
+		slots = slots[:query.Limit]
+	}
+	if slots == nil {
+		slots = []Slot{}
+	}
+	return slots
+}
+
+// overlapsAny 判断 [start, end) 是否与任一区间重叠。
+func overlapsAny(intervals []BusyInterval, start, end time.Time) bool {
+	for _, interval := range intervals {
+		if interval.StartTime.Before(end) && start.Before(interval.EndTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// alignUp 将 value 对齐到从 dayStart 起的下一个 step 刻度。
+func alignUp(value, dayStart time.Time, step time.Duration) time.Time {
+	offset := value.Sub(dayStart)
+	if remainder := offset % step; remainder != 0 {
+		offset += step - remainder
+	}
+	return dayStart.Add(offset)
+}
@@ -0,0 +1,570 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"smartcalendar/model"
+)
+
+// icsProductID 为导出日历的 PRODID。
+const icsProductID = "-//SmartCalendar//SmartCalendar//ZH"
+
+// icsTimeLayout 为 UTC 时间的 iCalendar 格式。
+const icsTimeLayout = "20060102T150405Z"
+
+// ErrInvalidICS 表示日历文件无法解析。
+var ErrInvalidICS = errors.New("invalid ics")
+
+// ICSAttendee 表示 VEVENT 中的参与人。
+type ICSAttendee struct {
+	Email    string
+	Name     string
+	PartStat string
+}
+
+// ICSOverride 表示 VEVENT 中带 RECURRENCE-ID 的单次实例改期。
+type ICSOverride struct {
+	RecurrenceID time.Time
+	Title        string
+	StartTime    time.Time
+	EndTime      time.Time
+	Location     string
+	Description  string
+}
+
+// ICSEvent 表示从日历文件解析出的单个日程（已合并同 UID 的改期实例）。
+type ICSEvent struct {
+	UID          string
+	Title        string
+	Type         string
+	StartTime    time.Time
+	EndTime      time.Time
+	Location     string
+	Description  string
+	RRule        string
+	ExDates      []time.Time
+	Overrides    []ICSOverride
+	Organizer    string
+	Attendees    []ICSAttendee
+	LastModified *time.Time
+	// Categories 为 CATEGORIES 原始取值，其中没有可识别的日程类型时 Type 为空。
+	Categories []string
+}
+
+// ICSItemError 表示日历文件中某个 VEVENT 的解析或导入问题。
+type ICSItemError struct {
+	UID     string `json:"uid"`
+	Message string `json:"message"`
+}
+
+// EventUID 返回日程的 iCalendar UID，历史数据未生成 UID 时使用 ID 推导。
+func EventUID(event model.Event) string {
+	if event.UID != "" {
+		return event.UID
+	}
+	return fmt.Sprintf("event-%d@smartcalendar", event.ID)
+}
+
+// BuildICS 将日程（需预加载 Creator 与 Participants.User）及其例外记录序列化为 VCALENDAR 文本。
+func BuildICS(calendarName string, events []model.Event, exceptions map[uint][]model.EventException) string {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:"+icsProductID)
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	if calendarName != "" {
+		writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(calendarName))
+	}
+	for _, event := range events {
+		writeVEvent(&b, event, exceptions[event.ID])
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// LoadExceptions 按日程 ID 分组加载例外记录。
+func LoadExceptions(eventIDs []uint) (map[uint][]model.EventException, error) {
+	result := map[uint][]model.EventException{}
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+	var exceptions []model.EventException
+	if err := model.DB.Where("event_id IN ?", eventIDs).Order("original_start asc").Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+	for _, exception := range exceptions {
+		result[exception.EventID] = append(result[exception.EventID], exception)
+	}
+	return result, nil
+}
+
+// writeVEvent 输出单个日程及其改期实例。
+func writeVEvent(b *strings.Builder, event model.Event, exceptions []model.EventException) {
+	uid := EventUID(event)
+	writeICSLine(b, "BEGIN:VEVENT")
+	writeICSLine(b, "UID:"+escapeICSText(uid))
+	writeICSLine(b, "DTSTAMP:"+event.UpdatedAt.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "DTSTART:"+event.StartTime.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "DTEND:"+event.EndTime.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "SUMMARY:"+escapeICSText(event.Title))
+	if event.Location != "" {
+		writeICSLine(b, "LOCATION:"+escapeICSText(event.Location))
+	}
+	if event.Description != "" {
+		writeICSLine(b, "DESCRIPTION:"+escapeICSText(event.Description))
+	}
+	if event.Type != "" {
+		writeICSLine(b, "CATEGORIES:"+escapeICSText(event.Type))
+	}
+	if event.RRule != "" {
+		writeICSLine(b, "RRULE:"+event.RRule)
+	}
+	writeICSLine(b, "SEQUENCE:0")
+	writeICSLine(b, "LAST-MODIFIED:"+event.UpdatedAt.UTC().Format(icsTimeLayout))
+	writeOrganizerAndAttendees(b, event)
+	for _, exception := range exceptions {
+		if exception.Cancelled {
+			writeICSLine(b, "EXDATE:"+exception.OriginalStart.UTC().Format(icsTimeLayout))
+		}
+	}
+	writeICSLine(b, "END:VEVENT")
+
+	for _, exception := range exceptions {
+		if exception.Cancelled {
+			continue
+		}
+		writeICSLine(b, "BEGIN:VEVENT")
+		writeICSLine(b, "UID:"+escapeICSText(uid))
+		writeICSLine(b, "RECURRENCE-ID:"+exception.OriginalStart.UTC().Format(icsTimeLayout))
+		writeICSLine(b, "DTSTAMP:"+exception.UpdatedAt.UTC().Format(icsTimeLayout))
+		writeICSLine(b, "DTSTART:"+exception.StartTime.UTC().Format(icsTimeLayout))
+		writeICSLine(b, "DTEND:"+exception.EndTime.UTC().Format(icsTimeLayout))
+		writeICSLine(b, "SUMMARY:"+escapeICSText(exception.Title))
+		if exception.Location != "" {
+			writeICSLine(b, "LOCATION:"+escapeICSText(exception.Location))
+		}
+		if exception.Description != "" {
+			writeICSLine(b, "DESCRIPTION:"+escapeICSText(exception.Description))
+		}
+		writeOrganizerAndAttendees(b, event)
+		writeICSLine(b, "END:VEVENT")
+	}
+}
+
+// writeOrganizerAndAttendees 输出创建者（ORGANIZER）与参与人（ATTENDEE）。
+func writeOrganizerAndAttendees(b *strings.Builder, event model.Event) {
+	if event.Creator.Email != "" {
+		writeICSLine(b, fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", escapeICSParam(event.Creator.Nickname), event.Creator.Email))
+	}
+	for _, participant := range event.Participants {
+		if participant.User.Email == "" {
+			continue
+		}
+		writeICSLine(b, fmt.Sprintf("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=%s:mailto:%s",
+			escapeICSParam(participant.User.Nickname), partStat(participant.Status), participant.User.Email))
+	}
+}
+
+// partStat 将参与人回复状态映射为 PARTSTAT。
+func partStat(status string) string {
+	switch status {
+	case model.ResponseAccepted:
+		return "ACCEPTED"
+	case model.ResponseDeclined:
+		return "DECLINED"
+	case model.ResponseTentative:
+		return "TENTATIVE"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// writeICSLine 按 RFC 5545 以 75 字节折行并使用 CRLF 结尾。
+func writeICSLine(b *strings.Builder, line string) {
+	const limit = 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// escapeICSText 转义 TEXT 值中的特殊字符。
+func escapeICSText(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+	return replacer.Replace(value)
+}
+
+// escapeICSParam 处理参数值，含特殊字符时加引号。
+func escapeICSParam(value string) string {
+	value = strings.ReplaceAll(value, "\"", "'")
+	if strings.ContainsAny(value, ":;,") {
+		return "\"" + value + "\""
+	}
+	return value
+}
+
+// unescapeICSText 还原 TEXT 值中的转义字符。
+func unescapeICSText(value string) string {
+	replacer := strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+	return replacer.Replace(value)
+}
+
+// icsProperty 表示一行已解析的属性。
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// ParseICS 解析日历文件中的 VEVENT，同 UID 带 RECURRENCE-ID 的实例合并为改期记录；
+// 无法解析的 VEVENT 不中断整个文件，按 UID 逐条返回错误。
+func ParseICS(reader io.Reader) ([]ICSEvent, []ICSItemError, error) {
+	lines, err := unfoldICSLines(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		masters   []ICSEvent
+		itemErrs  []ICSItemError
+		overrides = map[string][]ICSOverride{}
+		current   []icsProperty
+		inEvent   bool
+		depth     int
+		sawCal    bool
+	)
+	for _, line := range lines {
+		prop, ok := parseICSProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VCALENDAR"):
+			sawCal = true
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT"):
+			inEvent = true
+			depth = 0
+			current = nil
+		case prop.Name == "BEGIN" && inEvent:
+			// VALARM 等嵌套组件忽略。
+			depth++
+		case prop.Name == "END" && inEvent && depth > 0:
+			depth--
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VEVENT") && inEvent:
+			inEvent = false
+			event, recurrenceID, err := buildICSEvent(current)
+			if err != nil {
+				itemErrs = append(itemErrs, ICSItemError{UID: icsPropertyValue(current, "UID"), Message: err.Error()})
+				continue
+			}
+			if recurrenceID != nil {
+				overrides[event.UID] = append(overrides[event.UID], ICSOverride{
+					RecurrenceID: *recurrenceID,
+					Title:        event.Title,
+					StartTime:    event.StartTime,
+					EndTime:      event.EndTime,
+					Location:     event.Location,
+					Description:  event.Description,
+				})
+				continue
+			}
+			masters = append(masters, event)
+		case inEvent && depth == 0:
+			current = append(current, prop)
+		}
+	}
+	if !sawCal {
+		return nil, nil, ErrInvalidICS
+	}
+	for i := range masters {
+		masters[i].Overrides = overrides[masters[i].UID]
+	}
+	return masters, itemErrs, nil
+}
+
+// icsPropertyValue 返回首个同名属性的文本值。
+func icsPropertyValue(props []icsProperty, name string) string {
+	for _, prop := range props {
+		if prop.Name == name {
+			return strings.TrimSpace(unescapeICSText(prop.Value))
+		}
+	}
+	return ""
+}
+
+// unfoldICSLines 读取并展开折行。
+func unfoldICSLines(reader io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseICSProperty 解析 NAME;PARAM=VALUE:VALUE 形式的内容行。
+func parseICSProperty(line string) (icsProperty, bool) {
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icsProperty{}, false
+	}
+	head := line[:colon]
+	prop := icsProperty{Params: map[string]string{}, Value: line[colon+1:]}
+	parts := strings.Split(head, ";")
+	prop.Name = strings.ToUpper(strings.TrimSpace(parts[0]))
+	for _, param := range parts[1:] {
+		pair := strings.SplitN(param, "=", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		prop.Params[strings.ToUpper(pair[0])] = strings.Trim(pair[1], "\"")
+	}
+	return prop, true
+}
+
+// buildICSEvent 将 VEVENT 属性转换为 ICSEvent，返回值中的 recurrenceID 非空表示改期实例。
+func buildICSEvent(props []icsProperty) (ICSEvent, *time.Time, error) {
+	var (
+		event        ICSEvent
+		recurrenceID *time.Time
+		duration     time.Duration
+		hasEnd       bool
+		allDay       bool
+	)
+	for _, prop := range props {
+		switch prop.Name {
+		case "UID":
+			event.UID = strings.TrimSpace(unescapeICSText(prop.Value))
+		case "SUMMARY":
+			event.Title = strings.TrimSpace(unescapeICSText(prop.Value))
+		case "LOCATION":
+			event.Location = strings.TrimSpace(unescapeICSText(prop.Value))
+		case "DESCRIPTION":
+			event.Description = strings.TrimSpace(unescapeICSText(prop.Value))
+		case "CATEGORIES":
+			for _, category := range strings.Split(unescapeICSText(prop.Value), ",") {
+				category = strings.TrimSpace(category)
+				if category == "" {
+					continue
+				}
+				event.Categories = append(event.Categories, category)
+				if normalized := strings.ToLower(category); event.Type == "" &&
+					(normalized == "work" || normalized == "life" || normalized == "growth") {
+					event.Type = normalized
+				}
+			}
+		case "DTSTART":
+			parsed, dateOnly, err := parseICSTime(prop)
+			if err != nil {
+				return ICSEvent{}, nil, fmt.Errorf("DTSTART 无效：%s", prop.Value)
+			}
+			event.StartTime = parsed
+			allDay = dateOnly
+		case "DTEND":
+			parsed, _, err := parseICSTime(prop)
+			if err != nil {
+				return ICSEvent{}, nil, fmt.Errorf("DTEND 无效：%s", prop.Value)
+			}
+			event.EndTime = parsed
+			hasEnd = true
+		case "DURATION":
+			parsed, err := parseICSDuration(prop.Value)
+			if err != nil {
+				return ICSEvent{}, nil, fmt.Errorf("DURATION 无效：%s", prop.Value)
+			}
+			duration = parsed
+		case "RRULE":
+			// 不支持的规则（如 BYMONTHDAY、BYSETPOS）不能降级为单次日程，否则会丢失整个序列及其例外。
+			if _, err := ParseRRule(prop.Value); err != nil {
+				return ICSEvent{}, nil, fmt.Errorf("不支持的 RRULE：%s", prop.Value)
+			}
+			event.RRule = strings.TrimSpace(prop.Value)
+		case "EXDATE":
+			for _, value := range strings.Split(prop.Value, ",") {
+				parsed, _, err := parseICSTime(icsProperty{Params: prop.Params, Value: value})
+				if err == nil {
+					event.ExDates = append(event.ExDates, parsed)
+				}
+			}
+		case "RECURRENCE-ID":
+			parsed, _, err := parseICSTime(prop)
+			if err != nil {
+				return ICSEvent{}, nil, fmt.Errorf("RECURRENCE-ID 无效：%s", prop.Value)
+			}
+			recurrenceID = &parsed
+		case "ORGANIZER":
+			event.Organizer = parseMailto(prop.Value)
+		case "ATTENDEE":
+			if email := parseMailto(prop.Value); email != "" {
+				event.Attendees = append(event.Attendees, ICSAttendee{
+					Email:    email,
+					Name:     prop.Params["CN"],
+					PartStat: strings.ToUpper(prop.Params["PARTSTAT"]),
+				})
+			}
+		case "LAST-MODIFIED":
+			if parsed, _, err := parseICSTime(prop); err == nil {
+				event.LastModified = &parsed
+			}
+		}
+	}
+	if event.UID == "" {
+		return ICSEvent{}, nil, errors.New("缺少 UID")
+	}
+	if event.StartTime.IsZero() {
+		return ICSEvent{}, nil, errors.New("缺少 DTSTART")
+	}
+	if !hasEnd {
+		switch {
+		case duration > 0:
+			event.EndTime = event.StartTime.Add(duration)
+		case allDay:
+			event.EndTime = event.StartTime.AddDate(0, 0, 1)
+		default:
+			event.EndTime = event.StartTime.Add(time.Hour)
+		}
+	}
+	if !event.EndTime.After(event.StartTime) {
+		return ICSEvent{}, nil, errors.New("结束时间必须晚于开始时间")
+	}
+	if event.Title == "" {
+		event.Title = "未命名日程"
+	}
+	return event, recurrenceID, nil
+}
+
+// parseICSTime 解析 DATE-TIME / DATE 值，支持 UTC、TZID 与浮动时间。
+func parseICSTime(prop icsProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+	loc := time.Local
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if parsed, err := time.LoadLocation(tzid); err == nil {
+			loc = parsed
+		}
+	}
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == 8 {
+		parsed, err := time.ParseInLocation("20060102", value, loc)
+		return parsed, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		parsed, err := time.Parse(icsTimeLayout, value)
+		return parsed, false, err
+	}
+	parsed, err := time.ParseInLocation("20060102T150405", value, loc)
+	return parsed, false, err
+}
+
+// parseICSDuration 解析 DURATION 值，如 PT1H30M、P1D。
+func parseICSDuration(value string) (time.Duration, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, ErrInvalidICS
+	}
+	value = value[1:]
+	var total time.Duration
+	inTime := false
+	number := ""
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	timeUnits := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case ch == 'T':
+			inTime = true
+		case ch >= '0' && ch <= '9':
+			number += string(ch)
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, ErrInvalidICS
+			}
+			unit, ok := units[ch]
+			if inTime {
+				unit, ok = timeUnits[ch]
+			}
+			if !ok {
+				return 0, ErrInvalidICS
+			}
+			total += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// parseMailto 提取 mailto: 地址并转为小写。
+func parseMailto(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "mailto:") {
+		value = value[7:]
+	}
+	if !strings.Contains(value, "@") {
+		return ""
+	}
+	return strings.ToLower(value)
+}
+
+// LoadVisibleEvents 查询用户创建或参与的全部日程（预加载创建者与参与人），可按类型过滤。
+func LoadVisibleEvents(userID uint, eventTypes []string) ([]model.Event, error) {
+	query := visibleEventIDs(userID)
+	if len(eventTypes) > 0 {
+		query = query.Where("events.type IN ?", eventTypes)
+	}
+	var eventIDs []uint
+	if err := query.Scan(&eventIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(eventIDs) == 0 {
+		return nil, nil
+	}
+	var events []model.Event
+	if err := model.DB.Where("id IN ?", eventIDs).
+		Preload("Creator").
+		Preload("Participants.User").
+		Order("start_time asc").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// 邮件发件箱调度参数。
+const (
+	emailOutboxInterval = 15 * time.Second // 扫描发件箱的间隔
+	emailOutboxBatch    = 50               // 每轮最多发送的邮件数
+	emailOutboxLease    = 2 * time.Minute  // 认领邮件的租约时长
+	emailMaxAttempts    = 8                // 单封邮件最多尝试次数
+	emailRetryBase      = time.Minute      // 首次重试等待时长，之后按指数退避
+	emailRetryMax       = time.Hour        // 重试等待上限
+	smtpTimeout         = 30 * time.Second // 单次 SMTP 会话超时
+)
+
+// MailMessage 表示一封待发送的邮件。
+type MailMessage struct {
+	From      string
+	To        string
+	Subject   string
+	Text      string
+	HTML      string
+	MessageID string
+}
+
+// MailSender 负责投递邮件，默认实现为 SMTPMailSender。
+type MailSender interface {
+	Send(message MailMessage) error
+}
+
+// SMTPMailSender 通过 SMTP 投递邮件，兼容 MailHog 等本地测试服务器。
+type SMTPMailSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Security string // starttls / tls / none
+}
+
+// NewSMTPMailSender 按配置创建 SMTP 投递器。
+func NewSMTPMailSender(cfg config.AppConfig) SMTPMailSender {
+	return SMTPMailSender{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		Security: strings.ToLower(strings.TrimSpace(cfg.SMTPSecurity)),
+	}
+}
+
+// Send 建立 SMTP 会话并投递一封 multipart/alternative 邮件。
+func (s SMTPMailSender) Send(message MailMessage) error {
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid to address: %w", err)
+	}
+	body, err := buildMIMEMessage(message, from, to)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	if s.Security == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.Security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(body); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMIMEMessage 构建包含纯文本与 HTML 两个版本的邮件内容。
+func buildMIMEMessage(message MailMessage, from *mail.Address, to *mail.Address) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.BEncoding.Encode("UTF-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", message.MessageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + parts.Boundary() + `"`},
+	}
+	for _, header := range headers {
+		if header[1] == "" {
+			continue
+		}
+		b.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+// EmailDispatcher 从发件箱认领待发送邮件并投递，失败按指数退避重试，超过最大次数后标记失败。
+// 多实例部署时通过租约认领，投递成功但未及标记时可能在租约到期后重复发送一次。
+type EmailDispatcher struct {
+	instanceID string
+	from       string
+	sender     MailSender
+	worker     *periodicWorker
+}
+
+// NewEmailDispatcher 创建发件箱投递器。
+func NewEmailDispatcher(cfg config.AppConfig, sender MailSender) *EmailDispatcher {
+	d := &EmailDispatcher{
+		instanceID: cfg.InstanceID,
+		from:       cfg.SMTPFrom,
+		sender:     sender,
+	}
+	d.worker = newPeriodicWorker(emailOutboxInterval, d.runAndLog)
+	return d
+}
+
+// Start 在后台定期投递发件箱中的邮件，未配置邮件通道时不启动。
+func (d *EmailDispatcher) Start() {
+	if !EmailEnabled() {
+		return
+	}
+	d.worker.start()
+}
+
+// Stop 停止投递并等待当前一轮结束。
+func (d *EmailDispatcher) Stop(ctx context.Context) error {
+	return d.worker.stop(ctx)
+}
+
+// runAndLog 执行一轮投递并记录结果。
+func (d *EmailDispatcher) runAndLog() {
+	sent, failed, err := d.RunOnce(time.Now())
+	if err != nil {
+		log.Printf("[email] instance=%s run failed: %v", d.instanceID, err)
+	}
+	if sent > 0 || failed > 0 {
+		log.Printf("[email] instance=%s sent=%d failed=%d", d.instanceID, sent, failed)
+	}
+}
+
+// RunOnce 投递一轮到期邮件，返回成功数与最终失败数。
+func (d *EmailDispatcher) RunOnce(now time.Time) (int, int, error) {
+	now = now.UTC()
+	var items []model.EmailOutbox
+	if err := model.DB.Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+		model.EmailPending, now, model.EmailSending, now).
+		Order("id asc").
+		Limit(emailOutboxBatch).
+		Find(&items).Error; err != nil {
+		return 0, 0, err
+	}
+
+	sent, failed := 0, 0
+	var errs []error
+	for _, item := range items {
+		if d.worker.stopping() {
+			break
+		}
+		claimed, err := d.claim(item, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		item.Attempts++
+		sendErr := d.sender.Send(MailMessage{
+			From:      d.from,
+			To:        item.ToAddress,
+			Subject:   item.Subject,
+			Text:      item.TextBody,
+			HTML:      item.HTMLBody,
+			MessageID: emailMessageID(item, d.from),
+		})
+		if sendErr == nil {
+			sentAt := time.Now().UTC()
+			if err := d.finish(item, map[string]interface{}{
+				"status":       model.EmailSent,
+				"sent_at":      sentAt,
+				"locked_until": nil,
+				"last_error":   "",
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			sent++
+			continue
+		}
+
+		log.Printf("[email] outbox=%d attempt=%d send failed: %v", item.ID, item.Attempts, sendErr)
+		updates := map[string]interface{}{
+			"status":       model.EmailPending,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   truncateRunes(sendErr.Error(), 500),
+		}
+		if item.Attempts >= emailMaxAttempts {
+			updates["status"] = model.EmailFailed
+			failed++
+		} else {
+			updates["next_attempt_at"] = now.Add(retryBackoff(item.Attempts, emailRetryBase, emailRetryMax))
+		}
+		if err := d.finish(item, updates); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return sent, failed, errors.Join(errs...)
+}
+
+// claim 以条件更新认领一封邮件，返回是否认领成功。
+func (d *EmailDispatcher) claim(item model.EmailOutbox, now time.Time) (bool, error) {
+	result := model.DB.Model(&model.EmailOutbox{}).
+		Where("id = ?", item.ID).
+		Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+			model.EmailPending, now, model.EmailSending, now).
+		Updates(map[string]interface{}{
+			"status":       model.EmailSending,
+			"locked_by":    d.instanceID,
+			"locked_until": now.Add(emailOutboxLease),
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// finish 在仍持有租约时更新邮件投递结果。
+func (d *EmailDispatcher) finish(item model.EmailOutbox, updates map[string]interface{}) error {
+	return model.DB.Model(&model.EmailOutbox{}).
+		Where("id = ? AND status = ? AND locked_by = ?", item.ID, model.EmailSending, d.instanceID).
+		Updates(updates).Error
+}
+
+// emailMessageID 生成稳定的 Message-ID，重试时保持不变以便收件端去重。
+func emailMessageID(item model.EmailOutbox, from string) string {
+	domain := "smartcalendar.local"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(address.Address, "@"); at >= 0 && at < len(address.Address)-1 {
+			domain = address.Address[at+1:]
+		}
+	}
+	if item.NotificationID == nil {
+		return fmt.Sprintf("<email-%d@%s>", item.ID, domain)
+	}
+	return fmt.Sprintf("<notification-%d@%s>", *item.NotificationID, domain)
+}
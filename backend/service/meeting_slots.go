@@ -0,0 +1,106 @@
+package service
+
+import (
+	"time"
+
+	"smartcalendar/model"
+)
+
+// MeetingSlotQuery 描述为组织者与参与人安排会议的条件。
+type MeetingSlotQuery struct {
+	Organizer    model.User
+	Participants []model.User
+	RangeStart   time.Time
+	RangeEnd     time.Time
+	Duration     time.Duration
+	Limit        int
+}
+
+// FindMeetingSlots 在组织者的工作时间内查找所有人都空闲的时段，并排除各参与人的非工作时间，
+// 按推荐程度返回互不重叠的时段；工作时间取各自 AI 助手偏好，按各自时区计算。
+func FindMeetingSlots(query MeetingSlotQuery) ([]Slot, error) {
+	prefs := UserAssistantPreferences(query.Organizer)
+	workStart, _ := ParseClock(prefs.WorkStart)
+	workEnd, _ := ParseClock(prefs.WorkEnd)
+	workDays := make([]time.Weekday, 0, len(prefs.WorkDays))
+	for _, day := range prefs.WorkDays {
+		workDays = append(workDays, time.Weekday(day))
+	}
+
+	userIDs := []uint{query.Organizer.ID}
+	var unavailable []BusyInterval
+	for _, participant := range query.Participants {
+		if participant.ID == query.Organizer.ID {
+			continue
+		}
+		userIDs = append(userIDs, participant.ID)
+		unavailable = append(unavailable, OffWorkIntervals(participant, query.RangeStart, query.RangeEnd)...)
+	}
+	slots, err := FindSlots(SlotQuery{
+		UserIDs:     userIDs,
+		RangeStart:  query.RangeStart,
+		RangeEnd:    query.RangeEnd,
+		Duration:    query.Duration,
+		WorkStart:   time.Duration(workStart) * time.Minute,
+		WorkEnd:     time.Duration(workEnd) * time.Minute,
+		WorkDays:    workDays,
+		Location:    UserLocation(query.Organizer),
+		Unavailable: unavailable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]Slot, 0, query.Limit)
+	for _, slot := range slots {
+		if query.Limit > 0 && len(selected) >= query.Limit {
+			break
+		}
+		overlapped := false
+		for _, item := range selected {
+			if item.StartTime.Before(slot.EndTime) && slot.StartTime.Before(item.EndTime) {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped {
+			selected = append(selected, slot)
+		}
+	}
+	return selected, nil
+}
+
+// OffWorkIntervals 返回用户在区间内的非工作时间（工作日的上班前、下班后与非工作日全天），按用户时区计算。
+func OffWorkIntervals(user model.User, rangeStart, rangeEnd time.Time) []BusyInterval {
+	prefs := UserAssistantPreferences(user)
+	loc := UserLocation(user)
+	workStart, _ := ParseClock(prefs.WorkStart)
+	workEnd, _ := ParseClock(prefs.WorkEnd)
+	workDays := map[time.Weekday]struct{}{}
+	for _, day := range prefs.WorkDays {
+		workDays[time.Weekday(day)] = struct{}{}
+	}
+
+	var intervals []BusyInterval
+	add := func(start, end time.Time) {
+		if start.Before(rangeStart) {
+			start = rangeStart
+		}
+		if end.After(rangeEnd) {
+			end = rangeEnd
+		}
+		if end.After(start) {
+			intervals = append(intervals, BusyInterval{StartTime: start, EndTime: end})
+		}
+	}
+	local := rangeStart.In(loc)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc); day.Before(rangeEnd); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if _, ok := workDays[day.Weekday()]; !ok {
+			add(day, next)
+			continue
+		}
+		add(day, time.Date(day.Year(), day.Month(), day.Day(), 0, workStart, 0, 0, loc))
+		add(time.Date(day.Year(), day.Month(), day.Day(), 0, workEnd, 0, 0, loc), next)
+	}
+	return MergeIntervals(intervals)
+}
@@ -1,49 +1,158 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"smartcalendar/model"
+
+	"gorm.io/gorm"
 )
 
-// CreateInvitationNotifications 向参与人发送邀请通知。
-func CreateInvitationNotifications(event model.Event, participantIDs []uint) error {
-	for _, userID := range participantIDs {
-		if userID == event.UserID {
-			continue
-		}
-		content := fmt.Sprintf("%s 邀请你参加日程《%s》", event.Creator.Nickname, event.Title)
-		notification := model.Notification{
-			UserID:  userID,
-			Type:    "invitation",
-			Content: content,
-			EventID: event.ID,
-			IsRead:  false,
+// dispatchNotification 按用户的通知偏好与免打扰时段投递通知：
+// 站内与邮件渠道均关闭时不投递；处于免打扰时段且可延后的通知写入延后队列，免打扰结束后再投递；
+// 否则按渠道写入站内通知与邮件发件箱。写入了站内通知时返回 true，调用方需在事务提交后推送。
+// 提醒等时效性通知传入 deferrable=false；邀请与变更通知对应的日程在免打扰结束前就要开始时同样不延后。
+func dispatchNotification(db *gorm.DB, notification *model.Notification, deferrable bool, now time.Time) (bool, error) {
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Select("id", "timezone", "quiet_hours_start", "quiet_hours_end").First(&user, notification.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
 		}
-		if err := model.DB.Create(&notification).Error; err != nil {
+		channels, err := loadNotificationChannels(tx, user.ID, notificationPreferenceType(notification.Type))
+		if err != nil {
 			return err
 		}
+		if !channels.InApp && !channels.Email {
+			return nil
+		}
+		if deferrable {
+			if until, quiet := quietHoursEnd(user, now); quiet {
+				urgent, err := eventStartsBefore(tx, notification.EventID, now, until)
+				if err != nil {
+					return err
+				}
+				if !urgent {
+					return deferNotification(tx, user, *notification, until)
+				}
+			}
+		}
+
+		if channels.InApp {
+			if err := tx.Create(notification).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+		if channels.Email {
+			return enqueueNotificationEmail(tx, *notification)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// eventStartsBefore 判断日程是否有实例在 until 之前开始或正在进行，用于判断通知是否紧急。
+func eventStartsBefore(tx *gorm.DB, eventID uint, now time.Time, until time.Time) (bool, error) {
+	if eventID == 0 {
+		return false, nil
 	}
-	return nil
+	var event model.Event
+	if err := tx.First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	var exceptions []model.EventException
+	if event.RRule != "" {
+		if err := tx.Where("event_id = ?", event.ID).Find(&exceptions).Error; err != nil {
+			return false, err
+		}
+	}
+	return len(ExpandEvent(event, exceptions, now, until)) > 0, nil
 }
 
-// CreateChangeNotifications 向参与人发送变更通知。
-func CreateChangeNotifications(event model.Event, participantIDs []uint) error {
+// deferNotification 将通知写入延后队列，日程变更类通知先与同一日程尚未投递的变更合并。
+func deferNotification(tx *gorm.DB, user model.User, notification model.Notification, deliverAt time.Time) error {
+	merged, err := mergeDeferredEventNotification(tx, user, notification, deliverAt)
+	if err != nil || merged {
+		return err
+	}
+	return tx.Create(&model.DeferredNotification{
+		UserID:    notification.UserID,
+		Type:      notification.Type,
+		Content:   notification.Content,
+		EventID:   notification.EventID,
+		Payload:   notification.Payload,
+		DeliverAt: deliverAt.UTC(),
+	}).Error
+}
+
+// CreateInvitationNotifications 按各参与人的通知偏好发送邀请通知。
+// 单个参与人投递失败时记录日志并继续处理其余参与人，返回合并后的错误。
+func CreateInvitationNotifications(event model.Event, participantIDs []uint) error {
+	var errs []error
 	for _, userID := range participantIDs {
 		if userID == event.UserID {
 			continue
 		}
-		content := fmt.Sprintf("您参与的日程《%s》时间已更新", event.Title)
+		content := fmt.Sprintf("%s 邀请你参加日程《%s》", event.Creator.Nickname, event.Title)
 		notification := model.Notification{
 			UserID:  userID,
-			Type:    "change",
+			Type:    "invitation",
 			Content: content,
 			EventID: event.ID,
 			IsRead:  false,
 		}
-		if err := model.DB.Create(&notification).Error; err != nil {
-			return err
+		created, err := dispatchNotification(model.DB, &notification, true, time.Now())
+		if err != nil {
+			log.Printf("[notification] invitation event=%d user=%d failed: %v", event.ID, userID, err)
+			errs = append(errs, fmt.Errorf("invitation to user %d: %w", userID, err))
+			continue
+		}
+		if created {
+			PublishNotification(notification)
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// responseStatusText 为回复状态提供通知文案。
+var responseStatusText = map[string]string{
+	model.ResponseAccepted:  "接受了",
+	model.ResponseDeclined:  "拒绝了",
+	model.ResponseTentative: "暂定参加",
+}
+
+// CreateResponseNotification 按创建者的通知偏好发送参与人回复通知。
+func CreateResponseNotification(event model.Event, responder model.User, status string, comment string) error {
+	if responder.ID == event.UserID {
+		return nil
+	}
+	content := fmt.Sprintf("%s %s你的日程《%s》", responder.Nickname, responseStatusText[status], event.Title)
+	if comment != "" {
+		content += "：" + comment
+	}
+	notification := model.Notification{
+		UserID:  event.UserID,
+		Type:    "response",
+		Content: content,
+		EventID: event.ID,
+		IsRead:  false,
+	}
+	created, err := dispatchNotification(model.DB, &notification, true, time.Now())
+	if err != nil {
+		return err
+	}
+	if created {
+		PublishNotification(notification)
+	}
 	return nil
 }
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sync"
+
+	"smartcalendar/model"
+)
+
+// NotificationBroker 为通知推送提供发布订阅，默认使用进程内实现。
+// 多实例部署时可通过 SetNotificationBroker 替换为基于 Redis 等的实现；
+// 订阅方只把消息当作“有新通知”的信号并从数据库补齐，因此实现允许丢弃积压消息。
+type NotificationBroker interface {
+	// Publish 发布一条已写入数据库的通知。
+	Publish(notification model.Notification)
+	// Subscribe 订阅某个用户的通知，返回消息通道与取消订阅函数。Close 后通道被关闭。
+	Subscribe(userID uint) (<-chan model.Notification, func())
+	// Close 关闭全部订阅，用于服务退出时结束推送连接。
+	Close()
+}
+
+// notificationSubscriberBuffer 为每个订阅者缓冲的消息数，缓冲满时丢弃新消息。
+const notificationSubscriberBuffer = 16
+
+var (
+	brokerMu           sync.RWMutex
+	notificationBroker NotificationBroker = NewMemoryNotificationBroker()
+)
+
+// SetNotificationBroker 替换全局通知发布订阅实现，需在服务启动前调用。
+func SetNotificationBroker(broker NotificationBroker) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	notificationBroker = broker
+}
+
+// currentNotificationBroker 返回当前使用的通知发布订阅实现。
+func currentNotificationBroker() NotificationBroker {
+	brokerMu.RLock()
+	defer brokerMu.RUnlock()
+	return notificationBroker
+}
+
+// PublishNotification 向在线订阅者推送通知，调用方需保证通知已提交到数据库。
+func PublishNotification(notification model.Notification) {
+	currentNotificationBroker().Publish(notification)
+}
+
+// SubscribeNotifications 订阅用户的新通知。
+func SubscribeNotifications(userID uint) (<-chan model.Notification, func()) {
+	return currentNotificationBroker().Subscribe(userID)
+}
+
+// CloseNotificationBroker 关闭全部通知订阅。
+func CloseNotificationBroker() {
+	currentNotificationBroker().Close()
+}
+
+// memoryNotificationBroker 是进程内的通知发布订阅实现。
+type memoryNotificationBroker struct {
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[uint]map[chan model.Notification]struct{}
+}
+
+// NewMemoryNotificationBroker 创建进程内通知发布订阅实现。
+func NewMemoryNotificationBroker() NotificationBroker {
+	return &memoryNotificationBroker{subscribers: map[uint]map[chan model.Notification]struct{}{}}
+}
+
+// Publish 非阻塞地投递给该用户的全部订阅者，订阅者缓冲已满时丢弃。
+func (b *memoryNotificationBroker) Publish(notification model.Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
+
+// Subscribe 注册订阅者，broker 已关闭时返回已关闭的通道。
+func (b *memoryNotificationBroker) Subscribe(userID uint) (<-chan model.Notification, func()) {
+	ch := make(chan model.Notification, notificationSubscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[chan model.Notification]struct{}{}
+	}
+	b.subscribers[userID][ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[userID][ch]; !ok {
+				return
+			}
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// Close 关闭全部订阅通道，之后的订阅立即结束。
+func (b *memoryNotificationBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for userID, channels := range b.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(b.subscribers, userID)
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationTypes 为可单独设置渠道的通知类型。
+var NotificationTypes = []string{"invitation", "change", "response", "reminder", "digest"}
+
+// NotificationChannels 表示某类通知在各渠道的开关。
+type NotificationChannels struct {
+	InApp bool `json:"in_app"`
+	Email bool `json:"email"`
+}
+
+// QuietHours 表示用户的免打扰时段，Start 与 End 为用户时区的 HH:MM。
+type QuietHours struct {
+	Enabled bool   `json:"enabled"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// ErrInvalidQuietHours 表示免打扰时段格式无效或开始与结束时间相同。
+var ErrInvalidQuietHours = errors.New("invalid quiet hours")
+
+// IsNotificationType 判断是否为可设置渠道的通知类型。
+func IsNotificationType(value string) bool {
+	for _, item := range NotificationTypes {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationPreferenceType 返回通知类型对应的偏好类型，取消、改期、详情变更与移出统一按 change 设置。
+func notificationPreferenceType(notificationType string) string {
+	switch notificationType {
+	case NotificationCancelled, NotificationRescheduled, NotificationDetailsChanged, NotificationRemoved:
+		return "change"
+	}
+	return notificationType
+}
+
+// LoadNotificationPreferences 返回用户全部通知类型的渠道设置，未设置的类型默认全部开启。
+func LoadNotificationPreferences(db *gorm.DB, userID uint) (map[string]NotificationChannels, error) {
+	result := make(map[string]NotificationChannels, len(NotificationTypes))
+	for _, item := range NotificationTypes {
+		result[item] = NotificationChannels{InApp: true, Email: true}
+	}
+	var records []model.NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if _, ok := result[record.Type]; ok {
+			result[record.Type] = NotificationChannels{InApp: record.InApp, Email: record.Email}
+		}
+	}
+	return result, nil
+}
+
+// loadNotificationChannels 返回用户某类通知的渠道设置。
+func loadNotificationChannels(db *gorm.DB, userID uint, notificationType string) (NotificationChannels, error) {
+	var records []model.NotificationPreference
+	if err := db.Where("user_id = ? AND type = ?", userID, notificationType).Limit(1).Find(&records).Error; err != nil {
+		return NotificationChannels{}, err
+	}
+	if len(records) == 0 {
+		return NotificationChannels{InApp: true, Email: true}, nil
+	}
+	return NotificationChannels{InApp: records[0].InApp, Email: records[0].Email}, nil
+}
+
+// SaveNotificationPreferences 写入用户对若干通知类型的渠道设置，调用方需先校验类型。
+func SaveNotificationPreferences(db *gorm.DB, userID uint, preferences map[string]NotificationChannels) error {
+	for notificationType, channels := range preferences {
+		record := model.NotificationPreference{
+			UserID: userID,
+			Type:   notificationType,
+			InApp:  channels.InApp,
+			Email:  channels.Email,
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "updated_at"}),
+		}).Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserQuietHours 返回用户的免打扰时段设置。
+func UserQuietHours(user model.User) QuietHours {
+	return QuietHours{
+		Enabled: user.QuietHoursStart != "" && user.QuietHoursEnd != "",
+		Start:   user.QuietHoursStart,
+		End:     user.QuietHoursEnd,
+	}
+}
+
+// ValidateQuietHours 校验免打扰时段，未开启时不校验时间。
+func ValidateQuietHours(value QuietHours) error {
+	if !value.Enabled {
+		return nil
+	}
+	start, ok := ParseClock(value.Start)
+	if !ok {
+		return ErrInvalidQuietHours
+	}
+	end, ok := ParseClock(value.End)
+	if !ok || start == end {
+		return ErrInvalidQuietHours
+	}
+	return nil
+}
+
+// ValidateTimezone 校验 IANA 时区名称，空字符串表示使用服务器时区。
+func ValidateTimezone(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := time.LoadLocation(value)
+	return err
+}
+
+// UserLocation 返回用户时区，未设置或无法加载时使用服务器时区。
+func UserLocation(user model.User) *time.Location {
+	if user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// quietHoursEnd 判断 now 是否处于用户的免打扰时段，是则返回本次免打扰结束的时间。
+func quietHoursEnd(user model.User, now time.Time) (time.Time, bool) {
+	quiet := UserQuietHours(user)
+	if !quiet.Enabled {
+		return time.Time{}, false
+	}
+	start, okStart := ParseClock(quiet.Start)
+	end, okEnd := ParseClock(quiet.End)
+	if !okStart || !okEnd || start == end {
+		return time.Time{}, false
+	}
+	local := now.In(UserLocation(user))
+	minute := local.Hour()*60 + local.Minute()
+	endAt := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if start < end {
+		if minute >= start && minute < end {
+			return endAt, true
+		}
+		return time.Time{}, false
+	}
+	// 跨午夜的时段：开始时间之后结束于次日，结束时间之前结束于当天。
+	if minute >= start {
+		return endAt.AddDate(0, 0, 1), true
+	}
+	if minute < end {
+		return endAt, true
+	}
+	return time.Time{}, false
+}
+
+// ParseClock 解析 HH:MM，返回当天的分钟数；H:MM 写法需先经 NormalizeClock 规范。
+func ParseClock(value string) (int, bool) {
+	var hour, minute int
+	if len(value) != 5 {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(value, "%02d:%02d", &hour, &minute); err != nil {
+		return 0, false
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
+
+// NormalizeClock 将 H:MM 等写法规范为 HH:MM，无法解析时原样返回。
+func NormalizeClock(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) == 4 && value[1] == ':' {
+		value = "0" + value
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// 延后通知释放参数。
+const (
+	notificationReleaseInterval = time.Minute // 扫描到期延后通知的间隔
+	notificationReleaseBatch    = 200         // 每轮最多释放的通知数
+)
+
+// NotificationReleaser 定期把免打扰时段结束的延后通知转为正式通知并推送。
+// 多实例部署时以删除延后记录作为认领，同一条通知只会被一个实例释放。
+type NotificationReleaser struct {
+	instanceID string
+	worker     *periodicWorker
+}
+
+// NewNotificationReleaser 创建延后通知释放任务。
+func NewNotificationReleaser(cfg config.AppConfig) *NotificationReleaser {
+	r := &NotificationReleaser{instanceID: cfg.InstanceID}
+	r.worker = newPeriodicWorker(notificationReleaseInterval, r.runAndLog)
+	return r
+}
+
+// Start 在后台定期释放到期的延后通知。
+func (r *NotificationReleaser) Start() {
+	r.worker.start()
+}
+
+// Stop 停止释放并等待当前一轮结束。
+func (r *NotificationReleaser) Stop(ctx context.Context) error {
+	return r.worker.stop(ctx)
+}
+
+// runAndLog 执行一轮释放并记录结果。
+func (r *NotificationReleaser) runAndLog() {
+	released, err := r.RunOnce(time.Now())
+	if err != nil {
+		log.Printf("[notification] instance=%s release failed: %v", r.instanceID, err)
+	}
+	if released > 0 {
+		log.Printf("[notification] instance=%s released=%d", r.instanceID, released)
+	}
+}
+
+// RunOnce 释放一轮到期的延后通知，返回释放数量。释放时按用户当时的渠道偏好投递，不再受免打扰限制。
+func (r *NotificationReleaser) RunOnce(now time.Time) (int, error) {
+	var items []model.DeferredNotification
+	if err := model.DB.Where("deliver_at <= ?", now.UTC()).
+		Order("id asc").
+		Limit(notificationReleaseBatch).
+		Find(&items).Error; err != nil {
+		return 0, err
+	}
+
+	released := 0
+	var errs []error
+	for _, item := range items {
+		if r.worker.stopping() {
+			break
+		}
+		notification := model.Notification{
+			UserID:  item.UserID,
+			Type:    item.Type,
+			Content: item.Content,
+			EventID: item.EventID,
+			Payload: item.Payload,
+		}
+		inApp := false
+		err := model.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Delete(&model.DeferredNotification{}, item.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errDeferredNotificationTaken
+			}
+			var err error
+			inApp, err = dispatchNotification(tx, &notification, false, now)
+			return err
+		})
+		if errors.Is(err, errDeferredNotificationTaken) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		released++
+		if inApp {
+			PublishNotification(notification)
+		}
+	}
+	return released, errors.Join(errs...)
+}
+
+// errDeferredNotificationTaken 表示延后通知已被其他实例释放。
+var errDeferredNotificationTaken = errors.New("deferred notification already released")
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateInvitationNotificationsContinuesAfterFailure(t *testing.T) {
+	setupServiceDB(t)
+	users := make([]model.User, 4)
+	for i := range users {
+		users[i] = model.User{Nickname: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "x", Status: "active"}
+		if err := model.DB.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	creator, failing := users[0], users[2]
+	event := model.Event{ID: 1, UserID: creator.ID, Title: "周会", Creator: creator}
+
+	// 模拟写入某个参与人的站内通知失败。
+	errInjected := errors.New("injected failure")
+	if err := model.DB.Callback().Create().Before("gorm:create").Register("test:fail_notification", func(db *gorm.DB) {
+		if notification, ok := db.Statement.Dest.(*model.Notification); ok && notification.UserID == failing.ID {
+			db.AddError(errInjected)
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	err := CreateInvitationNotifications(event, []uint{creator.ID, users[1].ID, failing.ID, users[3].ID})
+	if !errors.Is(err, errInjected) || !strings.Contains(err.Error(), fmt.Sprintf("user %d", failing.ID)) {
+		t.Fatalf("err = %v", err)
+	}
+	var notified []uint
+	model.DB.Model(&model.Notification{}).Where("type = ?", "invitation").Order("user_id").Pluck("user_id", &notified)
+	if fmt.Sprint(notified) != fmt.Sprint([]uint{users[1].ID, users[3].ID}) {
+		t.Fatalf("notified = %v, want users after the failure to be notified too", notified)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	cases := map[string]int{"00:00": 0, "09:30": 570, "9:30": 570, "23:59": 1439, "24:00": -1, "12:60": -1, "0930": -1, "": -1}
+	for value, want := range cases {
+		got, ok := ParseClock(NormalizeClock(value))
+		if !ok {
+			got = -1
+		}
+		if got != want {
+			t.Errorf("ParseClock(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
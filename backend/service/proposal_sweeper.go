@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"smartcalendar/config"
+)
+
+// proposalSweepInterval 为清理过期 AI 提案的间隔。
+const proposalSweepInterval = 5 * time.Minute
+
+// ProposalSweeper 定期清理已过期的 AI 待确认提案。清理为幂等删除，多实例同时执行也不会冲突。
+type ProposalSweeper struct {
+	instanceID string
+	sweep      func(now time.Time) (int64, error)
+	worker     *periodicWorker
+}
+
+// NewProposalSweeper 创建过期提案清理任务，sweep 为提案存储的清理方法。
+func NewProposalSweeper(cfg config.AppConfig, sweep func(now time.Time) (int64, error)) *ProposalSweeper {
+	s := &ProposalSweeper{instanceID: cfg.InstanceID, sweep: sweep}
+	s.worker = newPeriodicWorker(proposalSweepInterval, s.runAndLog)
+	return s
+}
+
+// Start 在后台定期清理过期提案。
+func (s *ProposalSweeper) Start() {
+	s.worker.start()
+}
+
+// Stop 停止清理并等待当前一轮结束。
+func (s *ProposalSweeper) Stop(ctx context.Context) error {
+	return s.worker.stop(ctx)
+}
+
+// runAndLog 执行一轮清理并记录结果。
+func (s *ProposalSweeper) runAndLog() {
+	swept, err := s.sweep(time.Now())
+	if err != nil {
+		log.Printf("[ai] instance=%s proposal sweep failed: %v", s.instanceID, err)
+	}
+	if swept > 0 {
+		log.Printf("[ai] instance=%s expired proposals swept=%d", s.instanceID, swept)
+	}
+}
@@ -0,0 +1,562 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// 重复规则频率枚举（RFC 5545 FREQ）。
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRecurrencePeriods 限制单次展开遍历的周期数，避免异常规则导致死循环。
+const maxRecurrencePeriods = 50000
+
+// storedTimeSlack 为按存储时间筛选日程时前后放宽的范围。日程时间按写入时的时区偏移存储
+// （导入与 CalDAV 为 UTC，AI 创建为用户时区），SQLite 按字符串比较，不同偏移间的顺序误差不超过一天；
+// 放宽查询后由调用方按展开的实例时间精确筛选。
+const storedTimeSlack = 24 * time.Hour
+
+// ErrInvalidRRule 表示重复规则无法解析。
+var ErrInvalidRRule = errors.New("invalid rrule")
+
+// WeekdayNum 表示 BYDAY 中的单项，Ordinal 为 0 表示周期内所有该星期几。
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// RecurrenceRule 表示解析后的 RRULE。
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []WeekdayNum
+}
+
+// Occurrence 表示重复日程展开后的单次实例。
+type Occurrence struct {
+	RecurrenceID time.Time // 实例原始开始时间，用于定位例外
+	StartTime    time.Time
+	EndTime      time.Time
+	Title        string
+	Type         string
+	Location     string
+	Description  string
+	IsException  bool
+}
+
+// weekdayNames 按 time.Weekday 顺序排列的 BYDAY 代码。
+var weekdayNames = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule 解析 RFC 5545 RRULE 字符串，支持 FREQ/INTERVAL/COUNT/UNTIL/BYDAY。
+func ParseRRule(value string) (RecurrenceRule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "RRULE:"), "rrule:")
+	rule := RecurrenceRule{Interval: 1}
+	if value == "" {
+		return rule, ErrInvalidRRule
+	}
+	for _, part := range strings.Split(value, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return rule, ErrInvalidRRule
+		}
+		key := strings.ToUpper(strings.TrimSpace(pair[0]))
+		val := strings.ToUpper(strings.TrimSpace(pair[1]))
+		switch key {
+		case "FREQ":
+			if val != FreqDaily && val != FreqWeekly && val != FreqMonthly && val != FreqYearly {
+				return rule, ErrInvalidRRule
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval <= 0 {
+				return rule, ErrInvalidRRule
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count <= 0 {
+				return rule, ErrInvalidRRule
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseRRuleTime(val)
+			if err != nil {
+				return rule, ErrInvalidRRule
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := parseWeekdayNum(item)
+				if err != nil {
+					return rule, ErrInvalidRRule
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "WKST":
+			// 周起始固定为周一，忽略 WKST。
+		default:
+			return rule, ErrInvalidRRule
+		}
+	}
+	if rule.Freq == "" {
+		return rule, ErrInvalidRRule
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return rule, ErrInvalidRRule
+	}
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return rule, ErrInvalidRRule
+		}
+	}
+	return rule, nil
+}
+
+// String 将规则序列化为 RRULE 字符串（不含 RRULE: 前缀）。
+func (r RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			code := weekdayNames[day.Weekday]
+			if day.Ordinal != 0 {
+				code = strconv.Itoa(day.Ordinal) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// ValidateRRule 校验 RRULE 字符串，空字符串视为非重复日程。
+func ValidateRRule(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	_, err := ParseRRule(value)
+	return err
+}
+
+// TruncateRRule 将规则截止到 before 之前，返回新的 RRULE 字符串。
+func TruncateRRule(value string, before time.Time) (string, error) {
+	rule, err := ParseRRule(value)
+	if err != nil {
+		return "", err
+	}
+	until := before.Add(-time.Second)
+	rule.Count = 0
+	rule.Until = &until
+	return rule.String(), nil
+}
+
+// RemainingRRule 为从 from 开始拆分出的新序列计算规则，COUNT 扣除已发生的次数。
+func RemainingRRule(event model.Event, from time.Time) (string, error) {
+	rule, err := ParseRRule(event.RRule)
+	if err != nil {
+		return "", err
+	}
+	if rule.Count > 0 {
+		before := len(expandStarts(rule, event.StartTime, event.StartTime, from.Add(-time.Second)))
+		rule.Count -= before
+		if rule.Count <= 0 {
+			rule.Count = 1
+		}
+	}
+	return rule.String(), nil
+}
+
+// seriesEndHorizon 为计算 COUNT 序列结束时间时向后展开的上限。
+const seriesEndHorizon = 100
+
+// SeriesEnd 返回重复日程最后一个实例的结束时间；非重复日程、无 COUNT/UNTIL 的无限序列或规则无效时返回 nil。
+// 改期到更晚时间的例外实例不计入，需要时另行按例外记录查询。
+func SeriesEnd(event model.Event) *time.Time {
+	if event.RRule == "" {
+		return nil
+	}
+	rule, err := ParseRRule(event.RRule)
+	if err != nil || (rule.Count == 0 && rule.Until == nil) {
+		return nil
+	}
+	to := event.StartTime.AddDate(seriesEndHorizon, 0, 0)
+	if rule.Until != nil && rule.Until.Before(to) {
+		to = *rule.Until
+	}
+	end := event.EndTime
+	if starts := expandStarts(rule, event.StartTime, event.StartTime, to); len(starts) > 0 {
+		end = starts[len(starts)-1].Add(event.EndTime.Sub(event.StartTime))
+	}
+	return &end
+}
+
+// SyncSeriesEnd 按日程当前的开始、结束时间与重复规则重新计算 series_end，在修改日程的事务内调用。
+func SyncSeriesEnd(tx *gorm.DB, eventID uint) error {
+	var event model.Event
+	if err := tx.Select("id", "start_time", "end_time", "rrule").First(&event, eventID).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.Event{}).Where("id = ?", eventID).Update("series_end", SeriesEnd(event)).Error
+}
+
+// BackfillSeriesEnds 为早期版本创建、带 COUNT/UNTIL 但尚未计算 series_end 的重复日程补齐结束时间。
+func BackfillSeriesEnds() (int, error) {
+	var events []model.Event
+	if err := model.DB.Select("id", "start_time", "end_time", "rrule").
+		Where("rrule <> '' AND series_end IS NULL AND (rrule LIKE ? OR rrule LIKE ?)", "%COUNT=%", "%UNTIL=%").
+		Find(&events).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, event := range events {
+		end := SeriesEnd(event)
+		if end == nil {
+			continue
+		}
+		if err := model.DB.Model(&model.Event{}).Where("id = ?", event.ID).Update("series_end", end).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// IsOccurrence 判断 recurrenceID 是否为重复日程的合法实例开始时间。
+func IsOccurrence(event model.Event, recurrenceID time.Time) bool {
+	if event.RRule == "" {
+		return recurrenceID.Equal(event.StartTime)
+	}
+	rule, err := ParseRRule(event.RRule)
+	if err != nil {
+		return false
+	}
+	for _, start := range expandStarts(rule, event.StartTime, recurrenceID, recurrenceID) {
+		if start.Equal(recurrenceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandEvent 将重复日程在 [rangeStart, rangeEnd] 区间内展开为实例，并应用例外记录。
+func ExpandEvent(event model.Event, exceptions []model.EventException, rangeStart, rangeEnd time.Time) []Occurrence {
+	duration := event.EndTime.Sub(event.StartTime)
+	base := Occurrence{
+		Title:       event.Title,
+		Type:        event.Type,
+		Location:    event.Location,
+		Description: event.Description,
+	}
+	var starts []time.Time
+	if event.RRule == "" {
+		starts = []time.Time{event.StartTime}
+	} else {
+		rule, err := ParseRRule(event.RRule)
+		if err != nil {
+			return nil
+		}
+		starts = expandStarts(rule, event.StartTime, rangeStart.Add(-duration), rangeEnd)
+	}
+
+	exceptionMap := map[int64]model.EventException{}
+	for _, exception := range exceptions {
+		exceptionMap[exception.OriginalStart.Unix()] = exception
+	}
+	seen := map[int64]struct{}{}
+	result := make([]Occurrence, 0, len(starts))
+	for _, start := range starts {
+		seen[start.Unix()] = struct{}{}
+		occurrence := base
+		occurrence.RecurrenceID = start
+		occurrence.StartTime = start
+		occurrence.EndTime = start.Add(duration)
+		if exception, ok := exceptionMap[start.Unix()]; ok {
+			if exception.Cancelled {
+				continue
+			}
+			occurrence = applyException(occurrence, exception)
+		}
+		if overlaps(occurrence.StartTime, occurrence.EndTime, rangeStart, rangeEnd) {
+			result = append(result, occurrence)
+		}
+	}
+	// 改期后落入区间、但原始时间不在区间内的实例。
+	for _, exception := range exceptions {
+		if exception.Cancelled {
+			continue
+		}
+		if _, ok := seen[exception.OriginalStart.Unix()]; ok {
+			continue
+		}
+		if !overlaps(exception.StartTime, exception.EndTime, rangeStart, rangeEnd) || !IsOccurrence(event, exception.OriginalStart) {
+			continue
+		}
+		occurrence := base
+		occurrence.RecurrenceID = exception.OriginalStart
+		result = append(result, applyException(occurrence, exception))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
+
+// applyException 使用例外记录覆盖实例字段。
+func applyException(occurrence Occurrence, exception model.EventException) Occurrence {
+	occurrence.Title = exception.Title
+	occurrence.Type = exception.Type
+	occurrence.StartTime = exception.StartTime
+	occurrence.EndTime = exception.EndTime
+	occurrence.Location = exception.Location
+	occurrence.Description = exception.Description
+	occurrence.IsException = true
+	return occurrence
+}
+
+// overlaps 判断两个时间段是否相交（端点相接视为相交，与列表查询保持一致）。
+func overlaps(start, end, rangeStart, rangeEnd time.Time) bool {
+	return !end.Before(rangeStart) && !start.After(rangeEnd)
+}
+
+// expandStarts 按规则生成 dtstart 起、落在 [from, to] 内的实例开始时间，并遵守 COUNT/UNTIL。
+func expandStarts(rule RecurrenceRule, dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	emitted := 0
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		candidates := periodCandidates(rule, dtstart, period*rule.Interval)
+		if candidates == nil {
+			break
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(dtstart) {
+				continue
+			}
+			if rule.Until != nil && candidate.After(*rule.Until) {
+				return result
+			}
+			if candidate.After(to) {
+				return result
+			}
+			emitted++
+			if rule.Count > 0 && emitted > rule.Count {
+				return result
+			}
+			if !candidate.Before(from) {
+				result = append(result, candidate)
+			}
+		}
+	}
+	return result
+}
+
+// periodCandidates 生成第 offset 个周期内的候选开始时间（已排序）。
+func periodCandidates(rule RecurrenceRule, dtstart time.Time, offset int) []time.Time {
+	loc := dtstart.Location()
+	hour, minute, second := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, dtstart.Nanosecond(), loc)
+	}
+	candidates := []time.Time{}
+	switch rule.Freq {
+	case FreqDaily:
+		day := at(dtstart.Year(), dtstart.Month(), dtstart.Day()+offset)
+		if len(rule.ByDay) == 0 || matchesWeekday(rule.ByDay, day.Weekday()) {
+			candidates = append(candidates, day)
+		}
+	case FreqWeekly:
+		mondayShift := (int(dtstart.Weekday()) + 6) % 7
+		weekStart := at(dtstart.Year(), dtstart.Month(), dtstart.Day()-mondayShift+offset*7)
+		if len(rule.ByDay) == 0 {
+			candidates = append(candidates, weekStart.AddDate(0, 0, mondayShift))
+		} else {
+			for i := 0; i < 7; i++ {
+				day := weekStart.AddDate(0, 0, i)
+				if matchesWeekday(rule.ByDay, day.Weekday()) {
+					candidates = append(candidates, day)
+				}
+			}
+		}
+	case FreqMonthly:
+		first := at(dtstart.Year(), dtstart.Month()+time.Month(offset), 1)
+		if len(rule.ByDay) == 0 {
+			day := at(first.Year(), first.Month(), dtstart.Day())
+			if day.Month() == first.Month() {
+				candidates = append(candidates, day)
+			}
+		} else {
+			candidates = expandByDay(rule.ByDay, first, first.AddDate(0, 1, 0))
+		}
+	case FreqYearly:
+		year := dtstart.Year() + offset
+		if len(rule.ByDay) == 0 {
+			day := at(year, dtstart.Month(), dtstart.Day())
+			if day.Month() == dtstart.Month() {
+				candidates = append(candidates, day)
+			}
+		} else {
+			first := at(year, time.January, 1)
+			candidates = expandByDay(rule.ByDay, first, first.AddDate(1, 0, 0))
+		}
+	default:
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+	return candidates
+}
+
+// expandByDay 在 [periodStart, periodEnd) 内展开 BYDAY，支持正负序号。
+func expandByDay(byDay []WeekdayNum, periodStart, periodEnd time.Time) []time.Time {
+	seen := map[int64]struct{}{}
+	var result []time.Time
+	for _, item := range byDay {
+		var matches []time.Time
+		for day := periodStart; day.Before(periodEnd); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == item.Weekday {
+				matches = append(matches, day)
+			}
+		}
+		selected := matches
+		if item.Ordinal > 0 {
+			selected = nil
+			if item.Ordinal <= len(matches) {
+				selected = []time.Time{matches[item.Ordinal-1]}
+			}
+		} else if item.Ordinal < 0 {
+			selected = nil
+			if -item.Ordinal <= len(matches) {
+				selected = []time.Time{matches[len(matches)+item.Ordinal]}
+			}
+		}
+		for _, day := range selected {
+			if _, ok := seen[day.Unix()]; ok {
+				continue
+			}
+			seen[day.Unix()] = struct{}{}
+			result = append(result, day)
+		}
+	}
+	return result
+}
+
+// matchesWeekday 判断星期几是否在 BYDAY 列表中。
+func matchesWeekday(byDay []WeekdayNum, weekday time.Weekday) bool {
+	for _, item := range byDay {
+		if item.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWeekdayNum 解析 BYDAY 单项，如 MO、1FR、-1SU。
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return WeekdayNum{}, ErrInvalidRRule
+	}
+	code := value[len(value)-2:]
+	weekday, ok := weekdayCodes[code]
+	if !ok {
+		return WeekdayNum{}, ErrInvalidRRule
+	}
+	ordinal := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		parsed, err := strconv.Atoi(prefix)
+		if err != nil || parsed == 0 || parsed > 53 || parsed < -53 {
+			return WeekdayNum{}, ErrInvalidRRule
+		}
+		ordinal = parsed
+	}
+	return WeekdayNum{Ordinal: ordinal, Weekday: weekday}, nil
+}
+
+// parseRRuleTime 解析 UNTIL 支持的几种时间格式。
+func parseRRuleTime(value string) (time.Time, error) {
+	layouts := []string{"20060102T150405Z", "20060102T150405", "20060102", time.RFC3339}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				parsed = parsed.Add(24*time.Hour - time.Second)
+			}
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid until: %s", value)
+}
+
+// EventOccurrence 绑定日程与其展开后的单次实例。
+type EventOccurrence struct {
+	Event model.Event
+	Occurrence
+}
+
+// ExpandEvents 加载重复日程的例外记录，并将日程列表在区间内展开为按开始时间排序的实例。
+func ExpandEvents(events []model.Event, rangeStart, rangeEnd time.Time) ([]EventOccurrence, error) {
+	recurringIDs := make([]uint, 0)
+	for _, event := range events {
+		if event.RRule != "" {
+			recurringIDs = append(recurringIDs, event.ID)
+		}
+	}
+	exceptionsByEvent := map[uint][]model.EventException{}
+	if len(recurringIDs) > 0 {
+		var exceptions []model.EventException
+		if err := model.DB.Where("event_id IN ?", recurringIDs).Find(&exceptions).Error; err != nil {
+			return nil, err
+		}
+		for _, exception := range exceptions {
+			exceptionsByEvent[exception.EventID] = append(exceptionsByEvent[exception.EventID], exception)
+		}
+	}
+	result := make([]EventOccurrence, 0, len(events))
+	for _, event := range events {
+		for _, occurrence := range ExpandEvent(event, exceptionsByEvent[event.ID], rangeStart, rangeEnd) {
+			result = append(result, EventOccurrence{Event: event, Occurrence: occurrence})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
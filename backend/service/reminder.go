@@ -1,61 +1,143 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"smartcalendar/model"
+
+	"gorm.io/gorm"
 )
 
-// GenerateReminderNotifications 生成 15 分钟内将开始的日程提醒通知。
-func GenerateReminderNotifications(now time.Time) error {
-	end := now.Add(15 * time.Minute)
-	var events []model.Event
-	if err := model.DB.Where("start_time >= ? AND start_time <= ?", now, end).
-		Preload("Creator").
-		Preload("Participants.User").
-		Find(&events).Error; err != nil {
-		return err
-	}
+// 提醒配置限制，偏移量单位为分钟。
+const (
+	DefaultReminderOffset = 15
+	MaxReminderOffset     = 7 * 24 * 60
+	MaxReminderCount      = 5
+)
+
+// reminderGrace 允许“开始时提醒”（偏移量 0）在开始后一个扫描周期内送达，实例开始超过该时长后不再提醒。
+const reminderGrace = time.Minute
+
+// ErrInvalidReminders 表示提醒偏移量不合法。
+var ErrInvalidReminders = errors.New("invalid reminders")
 
-	for _, event := range events {
-		userIDs := []uint{event.UserID}
-		for _, participant := range event.Participants {
-			userIDs = append(userIDs, participant.UserID)
+// ValidateReminderOffsets 校验提醒偏移量：0 到 7 天之间，最多 5 个，不可重复。
+func ValidateReminderOffsets(offsets []int) error {
+	if len(offsets) > MaxReminderCount {
+		return ErrInvalidReminders
+	}
+	seen := map[int]struct{}{}
+	for _, offset := range offsets {
+		if offset < 0 || offset > MaxReminderOffset {
+			return ErrInvalidReminders
 		}
-		for _, userID := range uniqueUintList(userIDs) {
-			exists, err := reminderExists(event.ID, userID)
-			if err != nil {
-				return err
-			}
-			if exists {
-				continue
-			}
-			content := fmt.Sprintf("您的日程《%s》将在 15 分钟后开始", event.Title)
-			notification := model.Notification{
-				UserID:  userID,
-				Type:    "reminder",
-				Content: content,
-				EventID: event.ID,
-				IsRead:  false,
-			}
-			if err := model.DB.Create(&notification).Error; err != nil {
-				return err
-			}
+		if _, ok := seen[offset]; ok {
+			return ErrInvalidReminders
 		}
+		seen[offset] = struct{}{}
 	}
 	return nil
 }
 
-// reminderExists 判断提醒通知是否已存在。
-func reminderExists(eventID uint, userID uint) (bool, error) {
-	var count int64
-	if err := model.DB.Model(&model.Notification{}).
-		Where("type = ? AND event_id = ? AND user_id = ?", "reminder", eventID, userID).
-		Count(&count).Error; err != nil {
+// FormatReminderOffsets 将偏移量按从大到小序列化为逗号分隔字符串。
+func FormatReminderOffsets(offsets []int) string {
+	sorted := make([]int, len(offsets))
+	copy(sorted, offsets)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	parts := make([]string, 0, len(sorted))
+	for _, offset := range sorted {
+		parts = append(parts, strconv.Itoa(offset))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseReminderOffsets 解析逗号分隔的偏移量，忽略非法项。
+func ParseReminderOffsets(value string) []int {
+	offsets := make([]int, 0)
+	for _, item := range strings.Split(value, ",") {
+		parsed, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || parsed < 0 || parsed > MaxReminderOffset {
+			continue
+		}
+		offsets = append(offsets, parsed)
+	}
+	return offsets
+}
+
+// EventReminderOffsets 返回日程单独设置的提醒，nil 表示使用各用户的默认提醒。
+func EventReminderOffsets(event model.Event) []int {
+	if event.Reminders == nil {
+		return nil
+	}
+	return ParseReminderOffsets(*event.Reminders)
+}
+
+// reminderContent 按距离开始的实际时长生成提醒文案。
+func reminderContent(title string, lead time.Duration) string {
+	minutes := int((lead + time.Minute - 1) / time.Minute)
+	if minutes <= 0 {
+		return fmt.Sprintf("您的日程《%s》现在开始", title)
+	}
+	var parts []string
+	if days := minutes / (24 * 60); days > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天", days))
+	}
+	if hours := minutes % (24 * 60) / 60; hours > 0 {
+		parts = append(parts, fmt.Sprintf("%d 小时", hours))
+	}
+	if rest := minutes % 60; rest > 0 {
+		parts = append(parts, fmt.Sprintf("%d 分钟", rest))
+	}
+	return fmt.Sprintf("您的日程《%s》将在 %s后开始", title, strings.Join(parts, " "))
+}
+
+// loadDefaultReminders 查询相关用户的默认提醒。
+func loadDefaultReminders(occurrences []EventOccurrence) (map[uint][]int, error) {
+	var userIDs []uint
+	for _, occurrence := range occurrences {
+		userIDs = append(userIDs, BusyUserIDs(occurrence.Event)...)
+	}
+	result := map[uint][]int{}
+	userIDs = uniqueUintList(userIDs)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var users []model.User
+	if err := model.DB.Select("id", "default_reminders").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		result[user.ID] = ParseReminderOffsets(user.DefaultReminders)
+	}
+	return result, nil
+}
+
+// reminderExists 判断某实例、某偏移量的提醒通知是否已发送。
+func reminderExists(db *gorm.DB, eventID uint, userID uint, occurrenceStart time.Time, offset int) (bool, error) {
+	var notifications []model.Notification
+	if err := db.Where("type = ? AND event_id = ? AND user_id = ?", "reminder", eventID, userID).
+		Find(&notifications).Error; err != nil {
 		return false, err
 	}
-	return count > 0, nil
+	for _, notification := range notifications {
+		if notification.OccurrenceStart == nil {
+			// 旧版提醒不记录实例与偏移量，只在开始前 15 分钟内发送一次。
+			if !notification.CreatedAt.Before(occurrenceStart.Add(-DefaultReminderOffset*time.Minute)) &&
+				!notification.CreatedAt.After(occurrenceStart) {
+				return true, nil
+			}
+			continue
+		}
+		if notification.OccurrenceStart.Equal(occurrenceStart) && notification.RemindBefore != nil && *notification.RemindBefore == offset {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // uniqueUintList 对 uint 列表去重。
@@ -0,0 +1,596 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 提醒任务调度参数。
+const (
+	reminderPlanAhead    = time.Hour          // 提前写入任务表的时长
+	reminderBatchSize    = 500                // 每轮最多处理的到期任务数
+	reminderMaxAttempts  = 5                  // 单条提醒最多尝试发送次数
+	reminderJobRetention = 7 * 24 * time.Hour // 已结束任务在实例开始后保留的时长
+	reminderPurgeEvery   = time.Hour          // 清理已结束任务的间隔
+)
+
+// errReminderLeaseLost 表示发送完成前租约已被其他实例接手。
+var errReminderLeaseLost = errors.New("reminder job lease lost")
+
+// ReminderRunResult 表示一轮调度的处理结果。
+type ReminderRunResult struct {
+	Planned   int `json:"planned"`
+	Sent      int `json:"sent"`
+	Skipped   int `json:"skipped"`
+	Cancelled int `json:"cancelled"`
+	Expired   int `json:"expired"`
+	Failed    int `json:"failed"`
+	Digests   int `json:"digests"`
+}
+
+// ReminderSchedulerStats 表示当前实例提醒调度器的累计指标。
+type ReminderSchedulerStats struct {
+	InstanceID     string     `json:"instance_id"`
+	Running        bool       `json:"running"`
+	Runs           int64      `json:"runs"`
+	Planned        int64      `json:"planned"`
+	Sent           int64      `json:"sent"`
+	Skipped        int64      `json:"skipped"`
+	Cancelled      int64      `json:"cancelled"`
+	Expired        int64      `json:"expired"`
+	Failed         int64      `json:"failed"`
+	Digests        int64      `json:"digests"`
+	Errors         int64      `json:"errors"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error"`
+	LastErrorAt    *time.Time `json:"last_error_at"`
+}
+
+// ReminderScheduler 基于 reminder_jobs 表调度提醒：计划到期任务、按租约认领并发送，
+// 服务停机期间错过的提醒在补发窗口内补发，多实例部署时同一提醒只发送一次。
+type ReminderScheduler struct {
+	instanceID string
+	interval   time.Duration
+	catchUp    time.Duration
+	lease      time.Duration
+	worker     *periodicWorker
+
+	mu        sync.Mutex
+	lastPurge time.Time
+	stats     ReminderSchedulerStats
+}
+
+// NewReminderScheduler 按配置创建提醒调度器，非法配置回退为默认值。
+func NewReminderScheduler(cfg config.AppConfig) *ReminderScheduler {
+	interval := time.Duration(cfg.ReminderIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	catchUp := time.Duration(cfg.ReminderCatchUpMinutes) * time.Minute
+	if catchUp < 0 {
+		catchUp = 30 * time.Minute
+	}
+	lease := time.Duration(cfg.ReminderLeaseSeconds) * time.Second
+	if lease <= 0 {
+		lease = 2 * time.Minute
+	}
+	s := &ReminderScheduler{
+		instanceID: cfg.InstanceID,
+		interval:   interval,
+		catchUp:    catchUp,
+		lease:      lease,
+		stats:      ReminderSchedulerStats{InstanceID: cfg.InstanceID},
+	}
+	s.worker = newPeriodicWorker(interval, s.runAndRecord)
+	return s
+}
+
+// Start 在后台启动调度循环，启动时立即执行一轮以补发停机期间错过的提醒。
+func (s *ReminderScheduler) Start() {
+	s.worker.start()
+}
+
+// Stop 停止调度并等待当前一轮处理结束，ctx 到期时直接返回。
+// 已认领但未处理的任务会在租约到期后由其他实例或下次启动接手。
+func (s *ReminderScheduler) Stop(ctx context.Context) error {
+	return s.worker.stop(ctx)
+}
+
+// Stats 返回当前实例的调度指标快照。
+func (s *ReminderScheduler) Stats() ReminderSchedulerStats {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+	stats.Running = s.worker.isRunning()
+	return stats
+}
+
+// RunOnce 执行一轮调度：计划任务、使过期任务失效、发送到期提醒与每日摘要并定期清理。
+func (s *ReminderScheduler) RunOnce(now time.Time) (ReminderRunResult, error) {
+	now = now.UTC().Truncate(time.Second)
+	var result ReminderRunResult
+	var errs []error
+
+	planned, err := PlanReminderJobs(now, s.catchUp)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result.Planned = planned
+
+	expired, err := expireReminderJobs(now, s.catchUp)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result.Expired = expired
+
+	if err := s.dispatchDueReminders(now, &result); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 每日摘要与提醒共用补发窗口，并至少覆盖一个调度间隔，避免因调度时刻错过。
+	digests, err := dispatchDueDigests(now, s.catchUp+s.interval, s.worker.stopping)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result.Digests = digests
+
+	s.mu.Lock()
+	purge := now.Sub(s.lastPurge) >= reminderPurgeEvery
+	if purge {
+		s.lastPurge = now
+	}
+	s.mu.Unlock()
+	if purge {
+		if err := purgeReminderJobs(now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// runAndRecord 执行一轮调度并记录指标与错误日志。
+func (s *ReminderScheduler) runAndRecord() {
+	startedAt := time.Now()
+	result, err := s.RunOnce(startedAt)
+	finishedAt := time.Now()
+
+	s.mu.Lock()
+	s.stats.Runs++
+	s.stats.Planned += int64(result.Planned)
+	s.stats.Sent += int64(result.Sent)
+	s.stats.Skipped += int64(result.Skipped)
+	s.stats.Cancelled += int64(result.Cancelled)
+	s.stats.Expired += int64(result.Expired)
+	s.stats.Failed += int64(result.Failed)
+	s.stats.Digests += int64(result.Digests)
+	s.stats.LastRunAt = &finishedAt
+	s.stats.LastDurationMs = finishedAt.Sub(startedAt).Milliseconds()
+	if err != nil {
+		s.stats.Errors++
+		s.stats.LastError = err.Error()
+		s.stats.LastErrorAt = &finishedAt
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[reminder] instance=%s run failed: %v", s.instanceID, err)
+	}
+	if result.Sent > 0 || result.Expired > 0 || result.Failed > 0 || result.Digests > 0 {
+		log.Printf("[reminder] instance=%s planned=%d sent=%d skipped=%d cancelled=%d expired=%d failed=%d digests=%d",
+			s.instanceID, result.Planned, result.Sent, result.Skipped, result.Cancelled, result.Expired, result.Failed, result.Digests)
+	}
+}
+
+// PlanReminderJobs 展开即将开始的日程实例，为到期时间落在补发窗口至计划时长内的提醒写入任务表。
+// 已存在的任务（按实例、用户、提前分钟数唯一）保持不变，返回新写入的任务数。
+func PlanReminderJobs(now time.Time, catchUp time.Duration) (int, error) {
+	rangeStart := now.Add(-reminderGrace)
+	rangeEnd := now.Add(reminderPlanAhead + MaxReminderOffset*time.Minute)
+	events, err := loadReminderCandidates(rangeStart, rangeEnd)
+	if err != nil {
+		return 0, err
+	}
+	occurrences, err := ExpandEvents(events, rangeStart, rangeEnd)
+	if err != nil {
+		return 0, err
+	}
+	defaults, err := loadDefaultReminders(occurrences)
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make([]model.ReminderJob, 0)
+	for _, occurrence := range occurrences {
+		if !occurrence.StartTime.After(rangeStart) {
+			continue
+		}
+		start := occurrence.StartTime.UTC()
+		eventOffsets := EventReminderOffsets(occurrence.Event)
+		for _, userID := range BusyUserIDs(occurrence.Event) {
+			offsets := eventOffsets
+			if offsets == nil {
+				offsets = defaults[userID]
+			}
+			for _, offset := range offsets {
+				dueAt := start.Add(-time.Duration(offset) * time.Minute)
+				if dueAt.After(now.Add(reminderPlanAhead)) || dueAt.Before(now.Add(-catchUp)) {
+					continue
+				}
+				jobs = append(jobs, model.ReminderJob{
+					EventID:         occurrence.Event.ID,
+					UserID:          userID,
+					OccurrenceStart: start,
+					RemindBefore:    offset,
+					DueAt:           dueAt,
+					Status:          model.ReminderJobPending,
+				})
+			}
+		}
+	}
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	result := model.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&jobs, 100)
+	return int(result.RowsAffected), result.Error
+}
+
+// loadReminderCandidates 加载在 (rangeStart, rangeEnd] 内可能有实例开始的日程，查询范围按 storedTimeSlack 放宽，
+// 由 PlanReminderJobs 按实例时间精确筛选。重复日程只加载窗口内仍可能有实例的序列：
+// 已开始且尚未结束（series_end 为 NULL 表示无限序列），或有例外实例改期到窗口内。
+func loadReminderCandidates(rangeStart, rangeEnd time.Time) ([]model.Event, error) {
+	start, end := rangeStart.Add(-storedTimeSlack), rangeEnd.Add(storedTimeSlack)
+	var events []model.Event
+	err := model.DB.Where("(start_time > ? AND start_time <= ?)", start, end).
+		Or("rrule <> '' AND start_time <= ? AND (series_end IS NULL OR series_end > ?)", end, start).
+		Or("id IN (?)", model.DB.Model(&model.EventException{}).
+			Select("event_id").
+			Where("cancelled = ? AND start_time > ? AND start_time <= ?", false, start, end)).
+		Preload("Participants").
+		Find(&events).Error
+	return events, err
+}
+
+// expireReminderJobs 将超过补发窗口或实例已开始的未发送任务标记为过期。
+func expireReminderJobs(now time.Time, catchUp time.Duration) (int, error) {
+	result := model.DB.Model(&model.ReminderJob{}).
+		Where("status = ? OR (status = ? AND locked_until < ?)", model.ReminderJobPending, model.ReminderJobRunning, now).
+		Where("due_at < ? OR occurrence_start <= ?", now.Add(-catchUp), now.Add(-reminderGrace)).
+		Updates(map[string]interface{}{
+			"status":       model.ReminderJobExpired,
+			"locked_by":    "",
+			"locked_until": nil,
+		})
+	return int(result.RowsAffected), result.Error
+}
+
+// purgeReminderJobs 删除实例已结束较久的已完结任务。
+func purgeReminderJobs(now time.Time) error {
+	return model.DB.Where("status IN ? AND occurrence_start < ?", []string{
+		model.ReminderJobSent,
+		model.ReminderJobSkipped,
+		model.ReminderJobCancelled,
+		model.ReminderJobExpired,
+		model.ReminderJobFailed,
+	}, now.Add(-reminderJobRetention)).Delete(&model.ReminderJob{}).Error
+}
+
+// reminderJobKey 标识同一实例、同一用户的提醒任务组。
+type reminderJobKey struct {
+	eventID         uint
+	userID          uint
+	occurrenceStart int64
+}
+
+// dispatchDueReminders 认领并发送到期任务。同一实例、同一用户多个提醒同时到期时只发送最近的一个。
+func (s *ReminderScheduler) dispatchDueReminders(now time.Time, result *ReminderRunResult) error {
+	var jobs []model.ReminderJob
+	if err := model.DB.Where("due_at <= ?", now).
+		Where("status = ? OR (status = ? AND locked_until < ?)", model.ReminderJobPending, model.ReminderJobRunning, now).
+		Order("event_id, user_id, occurrence_start, remind_before").
+		Limit(reminderBatchSize).
+		Find(&jobs).Error; err != nil {
+		return err
+	}
+
+	groups := map[reminderJobKey][]model.ReminderJob{}
+	keys := make([]reminderJobKey, 0)
+	for _, job := range jobs {
+		key := reminderJobKey{eventID: job.EventID, userID: job.UserID, occurrenceStart: job.OccurrenceStart.Unix()}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], job)
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if s.worker.stopping() {
+			break
+		}
+		group := groups[key]
+		job := group[0]
+		claimed, err := claimReminderJob(&job, s.instanceID, now, s.lease)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if len(group) > 1 {
+			skipped, err := skipReminderJobs(group[1:], now)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			result.Skipped += skipped
+		}
+
+		status, err := deliverReminderJob(job, s.instanceID, now)
+		if err != nil {
+			if errors.Is(err, errReminderLeaseLost) {
+				continue
+			}
+			errs = append(errs, err)
+			failed, releaseErr := releaseReminderJob(job, s.instanceID, err)
+			if releaseErr != nil {
+				errs = append(errs, releaseErr)
+			}
+			if failed {
+				result.Failed++
+			}
+			continue
+		}
+		switch status {
+		case model.ReminderJobSent:
+			result.Sent++
+		case model.ReminderJobSkipped:
+			result.Skipped++
+		case model.ReminderJobCancelled:
+			result.Cancelled++
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// claimReminderJob 以条件更新认领任务，返回是否认领成功；未到期租约的任务不会被重复认领。
+func claimReminderJob(job *model.ReminderJob, instanceID string, now time.Time, lease time.Duration) (bool, error) {
+	lockedUntil := now.Add(lease)
+	result := model.DB.Model(&model.ReminderJob{}).
+		Where("id = ?", job.ID).
+		Where("status = ? OR (status = ? AND locked_until < ?)", model.ReminderJobPending, model.ReminderJobRunning, now).
+		Updates(map[string]interface{}{
+			"status":       model.ReminderJobRunning,
+			"locked_by":    instanceID,
+			"locked_until": lockedUntil,
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	job.Status = model.ReminderJobRunning
+	job.LockedBy = instanceID
+	job.LockedUntil = &lockedUntil
+	job.Attempts++
+	return true, nil
+}
+
+// skipReminderJobs 将被更近提醒取代的同组任务标记为跳过。
+func skipReminderJobs(jobs []model.ReminderJob, now time.Time) (int, error) {
+	ids := make([]uint, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	result := model.DB.Model(&model.ReminderJob{}).
+		Where("id IN ?", ids).
+		Where("status = ? OR (status = ? AND locked_until < ?)", model.ReminderJobPending, model.ReminderJobRunning, now).
+		Updates(map[string]interface{}{
+			"status":       model.ReminderJobSkipped,
+			"locked_by":    "",
+			"locked_until": nil,
+		})
+	return int(result.RowsAffected), result.Error
+}
+
+// deliverReminderJob 在事务内校验任务仍然有效并生成提醒通知，返回任务的最终状态。
+// 日程已删除、实例已改期或提醒设置已变更时任务被取消；租约已被接手时返回 errReminderLeaseLost 并回滚。
+func deliverReminderJob(job model.ReminderJob, instanceID string, now time.Time) (string, error) {
+	status := model.ReminderJobSent
+	var created *model.Notification
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		occurrence, ok, err := loadReminderOccurrence(tx, job)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !ok:
+			status = model.ReminderJobCancelled
+		default:
+			superseded, err := reminderSuperseded(tx, job, now)
+			if err != nil {
+				return err
+			}
+			if superseded {
+				status = model.ReminderJobSkipped
+				break
+			}
+			exists, err := reminderExists(tx, job.EventID, job.UserID, job.OccurrenceStart, job.RemindBefore)
+			if err != nil {
+				return err
+			}
+			if exists {
+				break
+			}
+			occurrenceStart := job.OccurrenceStart.Local()
+			remindBefore := job.RemindBefore
+			notification := model.Notification{
+				UserID:          job.UserID,
+				Type:            "reminder",
+				Content:         reminderContent(occurrence.Title, job.OccurrenceStart.Sub(now)),
+				EventID:         job.EventID,
+				IsRead:          false,
+				OccurrenceStart: &occurrenceStart,
+				RemindBefore:    &remindBefore,
+			}
+			// 提醒具有时效性，不受免打扰时段影响，仅按用户的渠道偏好投递。
+			inApp, err := dispatchNotification(tx, &notification, false, now)
+			if err != nil {
+				return err
+			}
+			if inApp {
+				created = &notification
+			}
+		}
+
+		updates := map[string]interface{}{
+			"status":       status,
+			"locked_until": nil,
+			"last_error":   "",
+		}
+		if status == model.ReminderJobSent {
+			updates["sent_at"] = now
+		}
+		result := tx.Model(&model.ReminderJob{}).
+			Where("id = ? AND status = ? AND locked_by = ?", job.ID, model.ReminderJobRunning, instanceID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReminderLeaseLost
+		}
+		return nil
+	})
+	if err == nil && created != nil {
+		PublishNotification(*created)
+	}
+	return status, err
+}
+
+// releaseReminderJob 释放发送失败的任务供下一轮重试，超过最大尝试次数时标记为失败。
+func releaseReminderJob(job model.ReminderJob, instanceID string, cause error) (bool, error) {
+	status := model.ReminderJobPending
+	if job.Attempts >= reminderMaxAttempts {
+		status = model.ReminderJobFailed
+	}
+	err := model.DB.Model(&model.ReminderJob{}).
+		Where("id = ? AND locked_by = ?", job.ID, instanceID).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   truncateRunes(cause.Error(), 500),
+		}).Error
+	return status == model.ReminderJobFailed, err
+}
+
+// loadReminderOccurrence 按当前日程数据校验任务对应的实例、成员与提醒设置仍然有效，并返回该实例。
+func loadReminderOccurrence(tx *gorm.DB, job model.ReminderJob) (Occurrence, bool, error) {
+	var event model.Event
+	if err := tx.Preload("Participants").First(&event, job.EventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Occurrence{}, false, nil
+		}
+		return Occurrence{}, false, err
+	}
+	var exceptions []model.EventException
+	if event.RRule != "" {
+		if err := tx.Where("event_id = ?", event.ID).Find(&exceptions).Error; err != nil {
+			return Occurrence{}, false, err
+		}
+	}
+	var matched *Occurrence
+	for _, occurrence := range ExpandEvent(event, exceptions, job.OccurrenceStart.Add(-time.Second), job.OccurrenceStart.Add(time.Second)) {
+		if occurrence.StartTime.Equal(job.OccurrenceStart) {
+			occurrence := occurrence
+			matched = &occurrence
+			break
+		}
+	}
+	if matched == nil {
+		return Occurrence{}, false, nil
+	}
+
+	member := false
+	for _, userID := range BusyUserIDs(event) {
+		if userID == job.UserID {
+			member = true
+			break
+		}
+	}
+	if !member {
+		return Occurrence{}, false, nil
+	}
+
+	offsets := EventReminderOffsets(event)
+	if offsets == nil {
+		var user model.User
+		if err := tx.Select("id", "default_reminders").First(&user, job.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return Occurrence{}, false, nil
+			}
+			return Occurrence{}, false, err
+		}
+		offsets = ParseReminderOffsets(user.DefaultReminders)
+	}
+	for _, offset := range offsets {
+		if offset == job.RemindBefore {
+			return *matched, true, nil
+		}
+	}
+	return Occurrence{}, false, nil
+}
+
+// reminderSuperseded 判断同一实例、同一用户是否已有更近的提醒到期并正在或已经发送。
+func reminderSuperseded(tx *gorm.DB, job model.ReminderJob, now time.Time) (bool, error) {
+	var count int64
+	if err := tx.Model(&model.ReminderJob{}).
+		Where("event_id = ? AND user_id = ? AND occurrence_start = ?", job.EventID, job.UserID, job.OccurrenceStart).
+		Where("remind_before < ? AND due_at <= ?", job.RemindBefore, now).
+		Where("status IN ?", []string{model.ReminderJobRunning, model.ReminderJobSent}).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ReminderJobCounts 按状态统计任务表中的提醒任务数（全部实例）。
+func ReminderJobCounts() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Total  int64
+	}
+	if err := model.DB.Model(&model.ReminderJob{}).
+		Select("status, COUNT(*) AS total").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.Status] = row.Total
+	}
+	return counts, nil
+}
+
+// CountOverdueReminderJobs 统计到期超过一个扫描周期仍未发送的任务数，持续大于 0 说明调度积压或停止。
+func (s *ReminderScheduler) CountOverdueReminderJobs(now time.Time) (int64, error) {
+	var count int64
+	err := model.DB.Model(&model.ReminderJob{}).
+		Where("status IN ?", []string{model.ReminderJobPending, model.ReminderJobRunning}).
+		Where("due_at < ?", now.UTC().Add(-2*s.interval)).
+		Count(&count).Error
+	return count, err
+}
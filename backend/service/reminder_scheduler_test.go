@@ -0,0 +1,163 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+)
+
+// setupServiceDB 为每个测试初始化独立的内存 SQLite 数据库并建表。
+func setupServiceDB(t *testing.T) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.DBPath = "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	model.InitDB(cfg)
+	if err := model.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := model.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestSeriesEnd(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	event := model.Event{StartTime: start, EndTime: start.Add(time.Hour)}
+	cases := []struct {
+		rrule string
+		want  string
+	}{
+		{"", ""},
+		{"FREQ=DAILY", ""},
+		{"FREQ=DAILY;COUNT=3", "2026-10-03 10:00"},
+		{"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4", "2026-10-14 10:00"},
+		{"FREQ=DAILY;UNTIL=20261010T235959Z", "2026-10-10 10:00"},
+	}
+	for _, tc := range cases {
+		event.RRule = tc.rrule
+		got := ""
+		if end := SeriesEnd(event); end != nil {
+			got = end.In(time.Local).Format("2006-01-02 15:04")
+		}
+		if got != tc.want {
+			t.Errorf("SeriesEnd(%q) = %q, want %q", tc.rrule, got, tc.want)
+		}
+	}
+}
+
+func TestLoadReminderCandidatesSkipsFinishedSeries(t *testing.T) {
+	setupServiceDB(t)
+	user := model.User{Nickname: "alice", Email: "alice@example.com", Password: "x", Status: "active"}
+	if err := model.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local)
+	create := func(title string, start time.Time, rrule string) model.Event {
+		event := model.Event{UserID: user.ID, Title: title, Type: "work", StartTime: start, EndTime: start.Add(time.Hour), RRule: rrule}
+		event.SeriesEnd = SeriesEnd(event)
+		if err := model.DB.Create(&event).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		return event
+	}
+	create("单次-窗口内", now.Add(2*time.Hour), "")
+	create("单次-已过去", now.AddDate(0, 0, -3), "")
+	create("无限序列", now.AddDate(-1, 0, 0), "FREQ=DAILY")
+	create("进行中的 COUNT 序列", now.AddDate(0, 0, -21), "FREQ=WEEKLY;COUNT=10")
+	create("已结束的 COUNT 序列", now.AddDate(0, -2, 0), "FREQ=DAILY;COUNT=5")
+	create("已结束的 UNTIL 序列", now.AddDate(0, -2, 0), "FREQ=DAILY;UNTIL=20260901T000000Z")
+	create("尚未开始的序列", now.AddDate(1, 0, 0), "FREQ=DAILY")
+	moved := create("改期到窗口内", now.AddDate(0, -2, 0), "FREQ=DAILY;COUNT=3")
+	exception := model.EventException{
+		EventID:       moved.ID,
+		OriginalStart: moved.StartTime,
+		Title:         moved.Title,
+		Type:          moved.Type,
+		StartTime:     now.Add(3 * time.Hour),
+		EndTime:       now.Add(4 * time.Hour),
+	}
+	if err := model.DB.Create(&exception).Error; err != nil {
+		t.Fatalf("create exception: %v", err)
+	}
+
+	events, err := loadReminderCandidates(now.Add(-reminderGrace), now.Add(reminderPlanAhead+MaxReminderOffset*time.Minute))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var titles []string
+	for _, event := range events {
+		titles = append(titles, event.Title)
+	}
+	sort.Strings(titles)
+	want := []string{"单次-窗口内", "无限序列", "改期到窗口内", "进行中的 COUNT 序列"}
+	sort.Strings(want)
+	if strings.Join(titles, ",") != strings.Join(want, ",") {
+		t.Fatalf("candidates = %v, want %v", titles, want)
+	}
+}
+
+func TestPlanReminderJobsMixedStoredZones(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+	local := time.Local
+	time.Local = shanghai
+	t.Cleanup(func() { time.Local = local })
+	setupServiceDB(t)
+	user := model.User{Nickname: "alice", Email: "alice@example.com", Password: "x", Status: "active", DefaultReminders: "15"}
+	if err := model.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 同一时刻分别按 UTC（导入、CalDAV）与本地时区存储，都应在 15 分钟默认提醒的窗口内。
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, shanghai)
+	start := now.Add(10 * time.Minute)
+	for _, at := range []time.Time{start.UTC(), start.In(shanghai), start.In(time.FixedZone("PDT", -7*3600))} {
+		event := model.Event{UserID: user.ID, Title: at.Location().String(), Type: "work", StartTime: at, EndTime: at.Add(time.Hour)}
+		if err := model.DB.Create(&event).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+	planned, err := PlanReminderJobs(now, 30*time.Minute)
+	if err != nil || planned != 3 {
+		t.Fatalf("planned = %d, %v, want 3", planned, err)
+	}
+
+	// 存储偏移不同但实际已开始的日程不计划提醒。
+	past := now.Add(-time.Hour).UTC()
+	if err := model.DB.Create(&model.Event{UserID: user.ID, Title: "已开始", Type: "work", StartTime: past, EndTime: past.Add(2 * time.Hour)}).Error; err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	if planned, err := PlanReminderJobs(now, 30*time.Minute); err != nil || planned != 0 {
+		t.Fatalf("planned after started event = %d, %v, want 0", planned, err)
+	}
+}
+
+func TestBackfillSeriesEnds(t *testing.T) {
+	setupServiceDB(t)
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	events := []model.Event{
+		{UserID: 1, Title: "count", Type: "work", StartTime: start, EndTime: start.Add(time.Hour), RRule: "FREQ=DAILY;COUNT=3"},
+		{UserID: 1, Title: "infinite", Type: "work", StartTime: start, EndTime: start.Add(time.Hour), RRule: "FREQ=DAILY"},
+	}
+	if err := model.DB.Create(&events).Error; err != nil {
+		t.Fatalf("create events: %v", err)
+	}
+	updated, err := BackfillSeriesEnds()
+	if err != nil || updated != 1 {
+		t.Fatalf("backfill = %d, %v", updated, err)
+	}
+	var count, infinite model.Event
+	model.DB.First(&count, events[0].ID)
+	model.DB.First(&infinite, events[1].ID)
+	if count.SeriesEnd == nil || !count.SeriesEnd.Equal(start.AddDate(0, 0, 2).Add(time.Hour)) || infinite.SeriesEnd != nil {
+		t.Fatalf("series_end = %v / %v", count.SeriesEnd, infinite.SeriesEnd)
+	}
+}
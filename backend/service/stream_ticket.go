@@ -0,0 +1,52 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// streamTicketPrefix 标识通知推送流票据。
+const streamTicketPrefix = "sst_"
+
+// StreamTicketTTL 为推送流票据的有效期，票据只用于随后立即建立的一次连接。
+const StreamTicketTTL = time.Minute
+
+// CreateStreamTicket 为用户生成一次性推送流票据，返回明文票据与过期时间，并顺带清理已过期的票据。
+func CreateStreamTicket(userID uint, now time.Time) (string, time.Time, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	ticket := streamTicketPrefix + hex.EncodeToString(raw)
+	expiresAt := now.Add(StreamTicketTTL).UTC()
+	if err := model.DB.Where("expires_at < ?", now.UTC()).Delete(&model.StreamTicket{}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	record := model.StreamTicket{UserID: userID, TicketHash: hashFeedToken(ticket), ExpiresAt: expiresAt}
+	if err := model.DB.Create(&record).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expiresAt, nil
+}
+
+// RedeemStreamTicket 核销票据并返回所属用户 ID；票据不存在、已使用或已过期时返回 gorm.ErrRecordNotFound。
+// 以删除作为核销，并发使用同一票据时只有一个请求成功。
+func RedeemStreamTicket(ticket string, now time.Time) (uint, error) {
+	var record model.StreamTicket
+	if err := model.DB.Where("ticket_hash = ?", hashFeedToken(ticket)).First(&record).Error; err != nil {
+		return 0, err
+	}
+	result := model.DB.Where("id = ?", record.ID).Delete(&model.StreamTicket{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected != 1 || !record.ExpiresAt.After(now) {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return record.UserID, nil
+}
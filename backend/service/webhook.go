@@ -0,0 +1,332 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// Webhook 事件类型。
+const (
+	WebhookEventCreated         = "event.created"
+	WebhookEventUpdated         = "event.updated"
+	WebhookEventDeleted         = "event.deleted"
+	WebhookParticipantAdded     = "participant.added"
+	WebhookParticipantRemoved   = "participant.removed"
+	WebhookParticipantResponded = "participant.responded"
+	WebhookPing                 = "ping" // 手动测试投递，不需要订阅
+)
+
+const (
+	webhookSecretPrefix    = "whsec_"  // 签名密钥前缀，便于辨认
+	webhookSignaturePrefix = "sha256=" // 签名请求头的值前缀
+)
+
+// WebhookEventTypes 为可订阅的事件类型。
+var WebhookEventTypes = []string{
+	WebhookEventCreated,
+	WebhookEventUpdated,
+	WebhookEventDeleted,
+	WebhookParticipantAdded,
+	WebhookParticipantRemoved,
+	WebhookParticipantResponded,
+}
+
+// MaxWebhooksPerUser 为每个用户可登记的 Webhook 数量上限。
+const MaxWebhooksPerUser = 10
+
+var (
+	// ErrWebhookNotFound 表示 Webhook 不存在或不属于当前用户。
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrWebhookURLInvalid 表示回调地址格式无效或指向不允许的网络。
+	ErrWebhookURLInvalid = errors.New("webhook url invalid")
+)
+
+// webhookSettings 保存 Webhook 的全局配置，由 ConfigureWebhooks 在启动时设置。
+var webhookSettings struct {
+	allowPrivateNetworks bool
+}
+
+// ConfigureWebhooks 按配置设置 Webhook 地址校验策略。
+func ConfigureWebhooks(cfg config.AppConfig) {
+	webhookSettings.allowPrivateNetworks = cfg.WebhookAllowPrivateNetworks
+}
+
+// WebhookEvent 表示一次需要投递给 Webhook 的日程变化。
+type WebhookEvent struct {
+	Type    string
+	EventID uint
+	Actor   model.User
+	UserIDs []uint // 与日程相关的用户（创建者、变更前后的参与人），其登记的 Webhook 会收到该事件
+	Data    map[string]interface{}
+}
+
+// WebhookPayload 为投递给接收方的 JSON 请求体。
+type WebhookPayload struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	CreatedAt time.Time              `json:"created_at"`
+	Actor     *WebhookActor          `json:"actor,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// WebhookActor 表示触发事件的用户。
+type WebhookActor struct {
+	ID       uint   `json:"id"`
+	Nickname string `json:"nickname"`
+}
+
+// ValidateWebhookURL 校验回调地址：仅允许 http/https，且默认不允许指向本机或内网地址。
+func ValidateWebhookURL(raw string) error {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrWebhookURLInvalid
+	}
+	if webhookSettings.allowPrivateNetworks {
+		return nil
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookURLInvalid
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateWebhookIP(ip) {
+		return ErrWebhookURLInvalid
+	}
+	return nil
+}
+
+// isPrivateWebhookIP 判断地址是否属于回环、内网、链路本地等不允许投递的网段。
+func isPrivateWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast()
+}
+
+// CreateWebhook 登记 Webhook 并生成签名密钥，密钥仅在创建与轮换时返回。
+func CreateWebhook(userID uint, name string, rawURL string, events []string, global bool) (model.Webhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	hook := model.Webhook{
+		UserID: userID,
+		Name:   name,
+		URL:    strings.TrimSpace(rawURL),
+		Secret: secret,
+		Events: strings.Join(events, ","),
+		Global: global,
+		Active: true,
+	}
+	if err := model.DB.Create(&hook).Error; err != nil {
+		return model.Webhook{}, err
+	}
+	return hook, nil
+}
+
+// CountWebhooks 统计用户登记的 Webhook 数量。
+func CountWebhooks(userID uint) (int64, error) {
+	var count int64
+	err := model.DB.Model(&model.Webhook{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// FindWebhook 查找属于用户的 Webhook。
+func FindWebhook(userID uint, id uint) (model.Webhook, error) {
+	var hook model.Webhook
+	if err := model.DB.Where("id = ? AND user_id = ?", id, userID).First(&hook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Webhook{}, ErrWebhookNotFound
+		}
+		return model.Webhook{}, err
+	}
+	return hook, nil
+}
+
+// RotateWebhookSecret 为 Webhook 生成新的签名密钥，旧密钥立即失效。
+func RotateWebhookSecret(hook model.Webhook) (model.Webhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	if err := model.DB.Model(&model.Webhook{}).Where("id = ?", hook.ID).Update("secret", secret).Error; err != nil {
+		return model.Webhook{}, err
+	}
+	hook.Secret = secret
+	return hook, nil
+}
+
+// DeleteWebhook 删除 Webhook 及其投递记录。
+func DeleteWebhook(hook model.Webhook) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Webhook{}, hook.ID).Error
+	})
+}
+
+// WebhookEvents 返回 Webhook 订阅的事件类型。
+func WebhookEvents(hook model.Webhook) []string {
+	result := []string{}
+	for _, item := range strings.Split(hook.Events, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// webhookSubscribes 判断 Webhook 是否订阅了某类事件。
+func webhookSubscribes(hook model.Webhook, eventType string) bool {
+	for _, item := range WebhookEvents(hook) {
+		if item == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EnqueueWebhookEvent 为订阅了该事件的 Webhook 生成投递记录，由后台投递器异步发送。
+// 接收方为相关用户登记的 Webhook 与管理员登记的全局 Webhook，所属用户被禁用时不投递。
+func EnqueueWebhookEvent(event WebhookEvent) error {
+	var hooks []model.Webhook
+	query := model.DB.Model(&model.Webhook{}).
+		Joins("JOIN users ON users.id = webhooks.user_id").
+		Where("webhooks.active = ? AND users.status <> ?", true, "disabled")
+	if len(event.UserIDs) > 0 {
+		query = query.Where("(webhooks.global = ? AND users.role = ?) OR webhooks.user_id IN ?", true, "admin", event.UserIDs)
+	} else {
+		query = query.Where("webhooks.global = ? AND users.role = ?", true, "admin")
+	}
+	if err := query.Select("webhooks.*").Find(&hooks).Error; err != nil {
+		return err
+	}
+	var targets []model.Webhook
+	for _, hook := range hooks {
+		if webhookSubscribes(hook, event.Type) {
+			targets = append(targets, hook)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	payload, err := buildWebhookPayload(event.Type, &event.Actor, event.Data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	deliveries := make([]model.WebhookDelivery, 0, len(targets))
+	for _, hook := range targets {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventType:     event.Type,
+			EventID:       event.EventID,
+			Payload:       payload,
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	return model.DB.Create(&deliveries).Error
+}
+
+// EnqueueWebhookPing 为 Webhook 生成一次测试投递，用于确认接收方地址与签名校验可用。
+func EnqueueWebhookPing(hook model.Webhook, actor model.User) (model.WebhookDelivery, error) {
+	payload, err := buildWebhookPayload(WebhookPing, &actor, map[string]interface{}{
+		"webhook_id": hook.ID,
+		"events":     WebhookEvents(hook),
+	})
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	delivery := model.WebhookDelivery{
+		WebhookID:     hook.ID,
+		EventType:     WebhookPing,
+		Payload:       payload,
+		Status:        model.WebhookDeliveryPending,
+		NextAttemptAt: time.Now().UTC(),
+	}
+	if err := model.DB.Create(&delivery).Error; err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// buildWebhookPayload 生成投递请求体，同一事件投递给多个 Webhook 时共用同一个事件 ID。
+func buildWebhookPayload(eventType string, actor *model.User, data map[string]interface{}) (string, error) {
+	id, err := randomHex(12)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	payload := WebhookPayload{
+		ID:        "evt_" + id,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	if actor != nil && actor.ID != 0 {
+		payload.Actor = &WebhookActor{ID: actor.ID, Nickname: actor.Nickname}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// SignWebhookPayload 计算签名：对“时间戳.请求体”做 HMAC-SHA256，结果形如 sha256=<hex>。
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature 供接收方校验签名，并拒绝时间戳与当前时间相差超过 tolerance 的请求以防重放。
+func VerifyWebhookSignature(secret string, timestamp string, body []byte, signature string, tolerance time.Duration, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if tolerance > 0 {
+		diff := now.Sub(time.Unix(ts, 0))
+		if diff < -tolerance || diff > tolerance {
+			return false
+		}
+	}
+	return hmac.Equal([]byte(SignWebhookPayload(secret, ts, body)), []byte(signature))
+}
+
+// newWebhookSecret 生成带前缀的随机签名密钥。
+func newWebhookSecret() (string, error) {
+	value, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + value, nil
+}
+
+// randomHex 生成 n 字节随机数的十六进制表示。
+func randomHex(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"smartcalendar/config"
+	"smartcalendar/model"
+
+	"gorm.io/gorm"
+)
+
+// Webhook 投递调度参数。
+const (
+	webhookInterval      = 10 * time.Second    // 扫描待投递记录的间隔
+	webhookBatch         = 50                  // 每轮最多投递的记录数
+	webhookConcurrency   = 8                   // 每轮并发投递数，避免单个慢接收方拖住其他投递
+	webhookLease         = 2 * time.Minute     // 认领投递记录的租约时长，需大于请求超时
+	webhookTimeout       = 10 * time.Second    // 单次请求超时
+	webhookMaxAttempts   = 8                   // 单条投递最多尝试次数
+	webhookRetryBase     = 30 * time.Second    // 首次重试等待时长，之后按指数退避
+	webhookRetryMax      = time.Hour           // 重试等待上限
+	webhookResponseLimit = 1000                // 投递日志保留的响应体字符数
+	webhookRetention     = 30 * 24 * time.Hour // 已完结投递记录的保留时长
+	webhookPurgeEvery    = time.Hour           // 清理投递记录的间隔
+)
+
+var (
+	// errWebhookPrivateAddress 表示回调地址解析到了不允许投递的内网地址。
+	errWebhookPrivateAddress = errors.New("webhook address resolves to a private network")
+	// errWebhookInactive 表示投递时 Webhook 已删除或停用。
+	errWebhookInactive = errors.New("webhook deleted or disabled")
+)
+
+// WebhookResponse 表示一次投递的接收方响应。
+type WebhookResponse struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+// NewWebhookHTTPClient 创建投递用的 HTTP 客户端：不跟随重定向，且默认在建立连接时拒绝内网地址，
+// 避免通过 DNS 解析绕过登记时的地址校验。
+func NewWebhookHTTPClient(allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateWebhookIP(ip) {
+				return errWebhookPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   webhookTimeout,
+			ResponseHeaderTimeout: webhookTimeout,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SendWebhook 向 Webhook 发送一次签名请求，接收方返回 2xx 视为成功。
+// 请求头包含事件类型、投递 ID、时间戳与签名，接收方可用 VerifyWebhookSignature 校验。
+func SendWebhook(ctx context.Context, client *http.Client, hook model.Webhook, delivery model.WebhookDelivery) (WebhookResponse, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return WebhookResponse{}, err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "SmartCalendar-Webhook/1.0")
+	request.Header.Set("X-SmartCalendar-Event", delivery.EventType)
+	request.Header.Set("X-SmartCalendar-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set("X-SmartCalendar-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-SmartCalendar-Signature", SignWebhookPayload(hook.Secret, timestamp, body))
+
+	started := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return WebhookResponse{Duration: time.Since(started)}, err
+	}
+	defer response.Body.Close()
+	content, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit*4))
+	result := WebhookResponse{
+		StatusCode: response.StatusCode,
+		Body:       truncateRunes(string(content), webhookResponseLimit),
+		Duration:   time.Since(started),
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return result, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return result, nil
+}
+
+// WebhookDispatcher 认领待投递记录并发送，失败按指数退避重试，超过最大次数后标记失败。
+// 多实例部署时通过租约认领，接收方已处理但未及标记时可能在租约到期后重复投递，
+// 接收方可按 X-SmartCalendar-Delivery 去重。
+type WebhookDispatcher struct {
+	instanceID string
+	client     *http.Client
+	worker     *periodicWorker
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+// NewWebhookDispatcher 创建 Webhook 投递器，client 为空时按配置创建默认客户端。
+func NewWebhookDispatcher(cfg config.AppConfig, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = NewWebhookHTTPClient(cfg.WebhookAllowPrivateNetworks)
+	}
+	d := &WebhookDispatcher{
+		instanceID: cfg.InstanceID,
+		client:     client,
+	}
+	d.worker = newPeriodicWorker(webhookInterval, d.runAndLog)
+	return d
+}
+
+// Start 在后台定期投递待发送的 Webhook。
+func (d *WebhookDispatcher) Start() {
+	d.worker.start()
+}
+
+// Stop 停止投递并等待当前一轮结束。
+func (d *WebhookDispatcher) Stop(ctx context.Context) error {
+	return d.worker.stop(ctx)
+}
+
+// runAndLog 执行一轮投递并记录结果。
+func (d *WebhookDispatcher) runAndLog() {
+	succeeded, failed, err := d.RunOnce(time.Now())
+	if err != nil {
+		log.Printf("[webhook] instance=%s run failed: %v", d.instanceID, err)
+	}
+	if succeeded > 0 || failed > 0 {
+		log.Printf("[webhook] instance=%s succeeded=%d failed=%d", d.instanceID, succeeded, failed)
+	}
+}
+
+// RunOnce 投递一轮到期记录，返回成功数与最终失败数。
+func (d *WebhookDispatcher) RunOnce(now time.Time) (int, int, error) {
+	now = now.UTC()
+	var errs []error
+	d.mu.Lock()
+	purge := now.Sub(d.lastPurge) >= webhookPurgeEvery
+	if purge {
+		d.lastPurge = now
+	}
+	d.mu.Unlock()
+	if purge {
+		if err := purgeWebhookDeliveries(now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var items []model.WebhookDelivery
+	if err := model.DB.Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+		model.WebhookDeliveryPending, now, model.WebhookDeliverySending, now).
+		Order("id asc").
+		Limit(webhookBatch).
+		Find(&items).Error; err != nil {
+		return 0, 0, errors.Join(append(errs, err)...)
+	}
+	hooks, err := loadDeliveryWebhooks(items)
+	if err != nil {
+		return 0, 0, errors.Join(append(errs, err)...)
+	}
+
+	// 认领与结果写入在当前 goroutine 串行执行，只有 HTTP 请求并发，避免 SQLite 写冲突。
+	type result struct {
+		item     model.WebhookDelivery
+		hook     *model.Webhook
+		response WebhookResponse
+		err      error
+	}
+	results := make(chan result, len(items))
+	sem := make(chan struct{}, webhookConcurrency)
+	var wg sync.WaitGroup
+	pending := []result{}
+	for _, item := range items {
+		if d.worker.stopping() {
+			break
+		}
+		// 先占用并发名额再认领，避免认领后排队等待过久导致租约到期。
+		sem <- struct{}{}
+		claimed, err := d.claim(item, now)
+		if err != nil || !claimed {
+			<-sem
+			if err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		item.Attempts++
+		hook := hooks[item.WebhookID]
+		// Webhook 已删除或停用时不再投递。
+		if hook == nil || !hook.Active {
+			<-sem
+			pending = append(pending, result{item: item, err: errWebhookInactive})
+			continue
+		}
+		wg.Add(1)
+		go func(item model.WebhookDelivery, hook *model.Webhook) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			response, err := SendWebhook(ctx, d.client, *hook, item)
+			results <- result{item: item, hook: hook, response: response, err: err}
+		}(item, hook)
+	}
+	wg.Wait()
+	close(results)
+	for r := range results {
+		pending = append(pending, r)
+	}
+
+	succeeded, failed := 0, 0
+	for _, r := range pending {
+		status, err := d.record(r.item, r.hook, r.response, r.err, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		switch status {
+		case model.WebhookDeliverySucceeded:
+			succeeded++
+		case model.WebhookDeliveryFailed:
+			failed++
+		}
+	}
+	return succeeded, failed, errors.Join(errs...)
+}
+
+// record 写入一次投递的结果：成功、等待重试或最终失败，并更新 Webhook 的最近投递状态。
+func (d *WebhookDispatcher) record(item model.WebhookDelivery, hook *model.Webhook, response WebhookResponse, sendErr error, now time.Time) (string, error) {
+	if errors.Is(sendErr, errWebhookInactive) {
+		return model.WebhookDeliveryFailed, d.finish(item, map[string]interface{}{
+			"status":       model.WebhookDeliveryFailed,
+			"locked_until": nil,
+			"last_error":   sendErr.Error(),
+		})
+	}
+	finishedAt := time.Now().UTC()
+	updates := map[string]interface{}{
+		"response_status": response.StatusCode,
+		"response_body":   response.Body,
+		"duration_ms":     response.Duration.Milliseconds(),
+		"locked_until":    nil,
+	}
+	status := model.WebhookDeliverySucceeded
+	if sendErr == nil {
+		updates["delivered_at"] = finishedAt
+		updates["last_error"] = ""
+	} else {
+		log.Printf("[webhook] delivery=%d webhook=%d attempt=%d failed: %v", item.ID, hook.ID, item.Attempts, sendErr)
+		updates["last_error"] = truncateRunes(sendErr.Error(), 500)
+		updates["locked_by"] = ""
+		if item.Attempts >= webhookMaxAttempts {
+			status = model.WebhookDeliveryFailed
+		} else {
+			status = model.WebhookDeliveryPending
+			updates["next_attempt_at"] = now.Add(retryBackoff(item.Attempts, webhookRetryBase, webhookRetryMax))
+		}
+	}
+	updates["status"] = status
+	if err := d.finish(item, updates); err != nil {
+		return "", err
+	}
+	lastStatus := model.WebhookDeliverySucceeded
+	if sendErr != nil {
+		lastStatus = model.WebhookDeliveryFailed
+	}
+	return status, model.DB.Model(&model.Webhook{}).Where("id = ?", hook.ID).Updates(map[string]interface{}{
+		"last_status":  lastStatus,
+		"last_sent_at": finishedAt,
+	}).Error
+}
+
+// claim 以条件更新认领一条投递记录，返回是否认领成功。
+func (d *WebhookDispatcher) claim(item model.WebhookDelivery, now time.Time) (bool, error) {
+	result := model.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ?", item.ID).
+		Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+			model.WebhookDeliveryPending, now, model.WebhookDeliverySending, now).
+		Updates(map[string]interface{}{
+			"status":       model.WebhookDeliverySending,
+			"locked_by":    d.instanceID,
+			"locked_until": now.Add(webhookLease),
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// finish 在仍持有租约时更新投递结果。
+func (d *WebhookDispatcher) finish(item model.WebhookDelivery, updates map[string]interface{}) error {
+	return model.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND locked_by = ?", item.ID, model.WebhookDeliverySending, d.instanceID).
+		Updates(updates).Error
+}
+
+// loadDeliveryWebhooks 批量加载投递记录对应的 Webhook。
+func loadDeliveryWebhooks(items []model.WebhookDelivery) (map[uint]*model.Webhook, error) {
+	result := map[uint]*model.Webhook{}
+	if len(items) == 0 {
+		return result, nil
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.WebhookID)
+	}
+	var hooks []model.Webhook
+	if err := model.DB.Where("id IN ?", ids).Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		result[hooks[i].ID] = &hooks[i]
+	}
+	return result, nil
+}
+
+// purgeWebhookDeliveries 删除超过保留时长的已完结投递记录，按最后一次计划投递时间（UTC）判断。
+func purgeWebhookDeliveries(now time.Time) error {
+	return model.DB.Where("status IN ? AND next_attempt_at < ?", []string{
+		model.WebhookDeliverySucceeded,
+		model.WebhookDeliveryFailed,
+	}, now.Add(-webhookRetention)).Delete(&model.WebhookDelivery{}).Error
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodicWorker 按固定间隔在后台执行任务：启动时立即执行一轮，停止时等待当前一轮结束。
+type periodicWorker struct {
+	interval time.Duration
+	run      func()
+
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	started bool
+	running bool
+}
+
+// newPeriodicWorker 创建后台任务，interval 非法时回退为一分钟。
+func newPeriodicWorker(interval time.Duration, run func()) *periodicWorker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &periodicWorker{
+		interval: interval,
+		run:      run,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// start 启动后台循环，重复调用无效。
+func (w *periodicWorker) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started {
+		return
+	}
+	w.started = true
+	w.running = true
+	go w.loop()
+}
+
+// stop 请求停止并等待当前一轮结束，ctx 到期时直接返回。
+func (w *periodicWorker) stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	w.mu.Lock()
+	started := w.started
+	w.mu.Unlock()
+	if !started {
+		return nil
+	}
+	select {
+	case <-w.doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRunning 判断后台循环是否仍在运行。
+func (w *periodicWorker) isRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.running
+}
+
+// stopping 判断是否已请求停止，用于在一轮处理中途停止认领新任务。
+func (w *periodicWorker) stopping() bool {
+	select {
+	case <-w.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// loop 按间隔循环执行任务，直到 stop 被调用。
+func (w *periodicWorker) loop() {
+	defer close(w.doneCh)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.run()
+		select {
+		case <-w.stopCh:
+			w.mu.Lock()
+			w.running = false
+			w.mu.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryBackoff 返回第 attempt 次失败后的重试等待时长：base 起按指数增长，不超过 max。
+func retryBackoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// truncateRunes 按字符截断错误信息等文本，避免超出字段长度。
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit])
+}
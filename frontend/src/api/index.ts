@@ -99,6 +99,18 @@ export const listNotifications = (params: { is_read?: boolean; page?: number; pa
 
 export const getUnreadCount = () => apiClient.get<ApiResponse<{ count: number }>>('/notifications/unread-count')
 
+// EventSource 无法设置请求头，先换取一次性票据再通过 ticket 参数建立连接
+export const createStreamTicket = () =>
+  apiClient.post<ApiResponse<{ ticket: string; expires_at: string }>>('/notifications/stream-ticket')
+
+export const notificationStreamUrl = (ticket: string, lastEventId?: string) => {
+  const params = new URLSearchParams({ ticket })
+  if (lastEventId) {
+    params.set('last_event_id', lastEventId)
+  }
+  return `${apiClient.defaults.baseURL}/notifications/stream?${params.toString()}`
+}
+
 export const markNotificationRead = (id: number) =>
   apiClient.put<ApiResponse<NotificationItem>>(`/notifications/${id}/read`)
 
@@ -1,8 +1,16 @@
 import { Badge, Button, List, Popover, Space, Typography } from 'antd'
 import { BellOutlined } from '@ant-design/icons'
 import { useEffect, useState } from 'react'
-import { getUnreadCount, listNotifications, markAllNotificationsRead, markNotificationRead } from '../api'
+import {
+  createStreamTicket,
+  getUnreadCount,
+  listNotifications,
+  markAllNotificationsRead,
+  markNotificationRead,
+  notificationStreamUrl,
+} from '../api'
 import type { NotificationItem } from '../types'
+import { getToken } from '../utils/auth'
 
 const { Text } = Typography
 
@@ -27,8 +35,53 @@ const NotificationBell: React.FC = () => {
 
   useEffect(() => {
     fetchUnreadCount()
-    const timer = setInterval(fetchUnreadCount, 30000)
-    return () => clearInterval(timer)
+    const token = getToken()
+    if (!token || typeof EventSource === 'undefined') {
+      const timer = setInterval(fetchUnreadCount, 30000)
+      return () => clearInterval(timer)
+    }
+    // 服务端推送新通知；票据只能使用一次，断线后重新换取票据并从最后收到的通知续传
+    let source: EventSource | null = null
+    let retryTimer: ReturnType<typeof setTimeout> | undefined
+    let lastEventId: string | undefined
+    let closed = false
+    const retry = () => {
+      if (!closed) retryTimer = setTimeout(connect, 5000)
+    }
+    const connect = async () => {
+      let ticket: string
+      try {
+        const { data } = await createStreamTicket()
+        if (closed) return
+        if (data.code !== 0) {
+          retry()
+          return
+        }
+        ticket = data.data.ticket
+      } catch {
+        retry()
+        return
+      }
+      const stream = new EventSource(notificationStreamUrl(ticket, lastEventId))
+      source = stream
+      stream.addEventListener('notification', (event) => {
+        const message = event as MessageEvent
+        lastEventId = message.lastEventId || lastEventId
+        const item = JSON.parse(message.data) as NotificationItem
+        setNotifications((prev) => [item, ...prev.filter((n) => n.id !== item.id)].slice(0, 10))
+        fetchUnreadCount()
+      })
+      stream.onerror = () => {
+        stream.close()
+        retry()
+      }
+    }
+    connect()
+    return () => {
+      closed = true
+      clearTimeout(retryTimer)
+      source?.close()
+    }
   }, [])
 
   const handleOpenChange = (open: boolean) => {